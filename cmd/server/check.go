@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"docker-management-system/internal/api/handlers"
+	"docker-management-system/internal/config"
+	"docker-management-system/internal/docker"
+)
+
+// Preflight check outcomes
+const (
+	checkPass = "pass"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// CheckResult is the outcome of one preflight check
+type CheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// CheckReport is what --check prints. OK is false if any check failed.
+type CheckReport struct {
+	OK     bool          `json:"ok"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// defaultCheckTimeout bounds the Docker checks when the configuration could not be loaded
+const defaultCheckTimeout = 5 * time.Second
+
+// runPreflightChecks validates a deployment without serving: the configuration, the Docker
+// daemon, the build temp directory and the base image. cfgErr is the error from loading the
+// configuration; checks that need a valid configuration are skipped when it is set.
+func runPreflightChecks(ctx context.Context, cfg *config.Config, cfgErr error, dockerClient *docker.Client) CheckReport {
+	report := CheckReport{OK: true}
+	run := func(name string, check func() error) {
+		start := time.Now()
+		err := check()
+		result := CheckResult{Name: name, Status: checkPass, Duration: time.Since(start).Round(time.Millisecond).String()}
+		if err != nil {
+			result.Status = checkFail
+			result.Detail = err.Error()
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	skip := func(name, reason string) {
+		report.Checks = append(report.Checks, CheckResult{Name: name, Status: checkSkip, Detail: reason, Duration: "0s"})
+	}
+
+	run("config", func() error { return cfgErr })
+
+	timeout := defaultCheckTimeout
+	if cfgErr == nil && cfg.Docker.PingTimeout > 0 {
+		timeout = cfg.Docker.PingTimeout
+	}
+	dockerReachable := false
+	run("docker", func() error {
+		if err := checkDockerAvailability(ctx, dockerClient, timeout); err != nil {
+			return err
+		}
+		dockerReachable = true
+		return nil
+	})
+
+	if cfgErr != nil {
+		skip("build_temp_dir", "configuration is invalid")
+		skip("base_image", "configuration is invalid")
+		return report
+	}
+
+	run("build_temp_dir", func() error {
+		dir := cfg.Docker.BuildTempDir
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		probe, err := os.MkdirTemp(dir, "block-builder-check-")
+		if err != nil {
+			return fmt.Errorf("%s is not writable: %w", dir, err)
+		}
+		return os.Remove(probe)
+	})
+
+	if !dockerReachable {
+		skip("base_image", "Docker daemon not reachable")
+		return report
+	}
+	run("base_image", func() error {
+		policy := docker.PullPolicy(cfg.Container.DefaultPullPolicy)
+		if policy == "" {
+			policy = docker.DefaultPullPolicy
+		}
+		if _, err := dockerClient.EnsureImage(ctx, handlers.BaseImage, policy, ""); err != nil {
+			return fmt.Errorf("%s is not available: %w", handlers.BaseImage, err)
+		}
+		return nil
+	})
+	return report
+}
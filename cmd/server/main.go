@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -10,8 +13,14 @@ import (
 	"syscall"
 	"time"
 
+	"docker-management-system/docs"
 	"docker-management-system/internal/api/handlers"
+	"docker-management-system/internal/builds"
+	"docker-management-system/internal/config"
 	"docker-management-system/internal/docker"
+	"docker-management-system/internal/logging"
+	"docker-management-system/internal/middleware"
+	"docker-management-system/internal/notify"
 	gorillaHandlers "github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
@@ -20,8 +29,12 @@ import (
 // HealthCheckResponse is the response structure for health check
 type HealthCheckResponse struct {
 	Status string `json:"status"`
+	Docker string `json:"docker"`
 }
 
+// healthPingTimeout bounds the Docker ping performed by each health check
+const healthPingTimeout = 2 * time.Second
+
 // loggingMiddleware logs HTTP request details
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -34,20 +47,24 @@ func loggingMiddleware(next http.Handler) http.Handler {
 
 // main function
 func main() {
-	// Initialize router with logging middleware
-	router := mux.NewRouter()
-	router.Use(loggingMiddleware)
-	
-	// Add CORS middleware
-	corsMiddleware := gorillaHandlers.CORS(
-		gorillaHandlers.AllowedOrigins([]string{"*"}),
-		gorillaHandlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
-		gorillaHandlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Requested-With"}),
-		gorillaHandlers.AllowCredentials(),
-	)
-	
-	// Apply CORS middleware to all routes
-	handler := corsMiddleware(router)
+	configPath := flag.String("config", "config/config.yaml", "Path to the YAML configuration file")
+	check := flag.Bool("check", false, "Run preflight checks, print a JSON report and exit instead of serving")
+	flag.Parse()
+
+	if *check {
+		os.Exit(preflight(*configPath))
+	}
+
+	// Load configuration
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	// Initialize structured logging (used for request and audit logs)
+	if err := logging.InitLogger(cfg.Logging); err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
 
 	// Initialize Docker client
 	dockerClient, err := docker.NewClient("unix:///var/run/docker.sock", "", false, "")
@@ -55,35 +72,42 @@ func main() {
 		log.Fatalf("Failed to create Docker client: %v", err)
 	}
 
-	// Initialize container handler
-	containerHandler := handlers.NewContainerHandler(dockerClient)
-
-	// Register routes
-	router.HandleFunc("/health", healthCheckHandler).Methods("GET", "OPTIONS")
+	dockerClient.EnableListCache(cfg.Docker.ListCacheTTL)
+	// The inspect cache follows container events until the server shuts down
+	eventsCtx, stopEvents := context.WithCancel(context.Background())
+	defer stopEvents()
+	dockerClient.EnableInspectCache(eventsCtx, cfg.Docker.InspectCacheTTL)
 
-	// Container routes with explicit OPTIONS handling
-	apiRouter := router.PathPrefix("/api/v1").Subrouter()
-	apiRouter.HandleFunc("/containers", containerHandler.ListContainers).Methods("GET", "OPTIONS")
-	apiRouter.HandleFunc("/containers/{id}", containerHandler.GetContainer).Methods("GET", "OPTIONS")
-	apiRouter.HandleFunc("/containers/{id}/logs", containerHandler.GetContainerLogs).Methods("GET", "OPTIONS")
-	apiRouter.HandleFunc("/containers/{id}", containerHandler.DeleteContainer).Methods("DELETE", "OPTIONS")
+	// Report managed containers that die or are removed to the configured webhook
+	if cfg.Notifications.WebhookURL != "" {
+		webhook := notify.NewWebhook(cfg.Notifications.WebhookURL, cfg.Notifications.WebhookTimeout, cfg.Notifications.WebhookMaxRetries)
+		defer webhook.Close()
+		go dockerClient.WatchContainerEvents(eventsCtx, notify.Actions, webhook.HandleEvent, nil)
+	}
+	dockerClient.SetBuildTempDir(cfg.Docker.BuildTempDir)
 
-	// Legacy routes without /api/v1 prefix for backward compatibility
-	router.HandleFunc("/containers", containerHandler.ListContainers).Methods("GET", "OPTIONS")
-	router.HandleFunc("/containers/{id}", containerHandler.GetContainer).Methods("GET", "OPTIONS")
-	router.HandleFunc("/containers/{id}/logs", containerHandler.GetContainerLogs).Methods("GET", "OPTIONS")
-	router.HandleFunc("/containers/{id}", containerHandler.DeleteContainer).Methods("DELETE", "OPTIONS")
+	// The client connects lazily, so verify the daemon is reachable before serving
+	if err := checkDockerAvailability(context.Background(), dockerClient, cfg.Docker.PingTimeout); err != nil {
+		if !cfg.Docker.AllowDegradedStart {
+			log.Fatalf("%v", err)
+		}
+		log.Printf("WARNING: %v; starting in degraded mode", err)
+	}
 
-	// Serve Swagger files
-	router.PathPrefix("/swagger/").Handler(http.StripPrefix("/swagger/", http.FileServer(http.Dir("docs"))))
+	buildQueue := builds.NewQueue(cfg.Container.BuildConcurrency, cfg.Container.BuildJobTTL)
+	router := newRouter(dockerClient, cfg, buildQueue)
 
-	// Swagger UI
-	router.PathPrefix("/swagger-ui/").Handler(httpSwagger.Handler(
-		httpSwagger.URL("/swagger/swagger.json"),
-		httpSwagger.DeepLinking(true),
-		httpSwagger.DocExpansion("none"),
-		httpSwagger.DomID("swagger-ui"),
-	))
+	// Add CORS middleware
+	corsMiddleware := gorillaHandlers.CORS(
+		gorillaHandlers.AllowedOrigins([]string{"*"}),
+		gorillaHandlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}),
+		gorillaHandlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-Requested-With", "If-None-Match"}),
+		gorillaHandlers.ExposedHeaders([]string{"ETag"}),
+		gorillaHandlers.AllowCredentials(),
+	)
+	
+	// Apply CORS middleware to all routes, compressing responses for clients that accept gzip
+	handler := middleware.Compress(middleware.DefaultCompressMinSize)(corsMiddleware(router))
 
 	// Create a new HTTP server with timeouts
 	srv := &http.Server{
@@ -120,17 +144,160 @@ func main() {
 		log.Fatal("Server forced to shutdown")
 	}
 
+	// Let builds that were already accepted finish
+	buildQueue.Close()
+
 	log.Println("Server gracefully stopped")
 }
 
-// healthCheckHandler handles the health check requests
-func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
-	response := HealthCheckResponse{Status: "UP"}
-	w.Header().Set("Content-Type", "application/json")
-	
-	if err := json.NewEncoder(w).Encode(response); err != nil {
-		log.Printf("Error encoding health check response: %v", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
+// newRouter registers the API routes. Every matched request gets a request ID before it is
+// logged or handled, so handlers can label and audit with it, and is attributed to the actor
+// whose API token it presents.
+func newRouter(dockerClient *docker.Client, cfg *config.Config, buildQueue *builds.Queue) *mux.Router {
+	router := mux.NewRouter()
+	router.Use(middleware.RequestID)
+	router.Use(loggingMiddleware)
+	router.Use(middleware.Authenticate(cfg.Server.APITokens))
+	router.NotFoundHandler = middleware.NotFoundHandler()
+	router.MethodNotAllowedHandler = middleware.MethodNotAllowedHandler()
+
+	// Initialize container handler
+	containerHandler := handlers.NewContainerHandler(dockerClient, cfg.Container)
+	imageHandler := handlers.NewImageHandler(dockerClient)
+	projectHandler := handlers.NewProjectHandler()
+	systemHandler := handlers.NewSystemHandler(dockerClient)
+	buildHandler := handlers.NewBuildHandler(containerHandler, buildQueue)
+
+	// Register routes
+	router.HandleFunc("/health", newHealthCheckHandler(dockerClient)).Methods("GET", "OPTIONS")
+
+	// Container routes with explicit OPTIONS handling
+	apiRouter := router.PathPrefix("/api/v1").Subrouter()
+	apiRouter.HandleFunc("/containers", containerHandler.ListContainers).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/create", containerHandler.CreateContainer).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/containers/batch", containerHandler.BatchCreateContainers).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/containers/stop-all", containerHandler.StopAllContainers).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/containers/restart-exited", containerHandler.RestartExitedContainers).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/containers/rebuild-all", containerHandler.RebuildAllContainers).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/containers/summary", containerHandler.SummarizeContainers).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/by-request/{requestId}", containerHandler.GetContainersByRequest).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}", containerHandler.GetContainer).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}/inspect", containerHandler.InspectContainer).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}/describe", containerHandler.DescribeContainer).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}/logs", containerHandler.GetContainerLogs).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}/logs/download", containerHandler.DownloadContainerLogs).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}/attach", containerHandler.AttachContainer).Methods("GET")
+	apiRouter.HandleFunc("/containers/{id}/compose", containerHandler.ExportCompose).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}/env", containerHandler.GetContainerEnv).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}/labels", containerHandler.UpdateContainerLabels).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}/files", containerHandler.CopyFiles).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/containers/{id}", containerHandler.DeleteContainer).Methods("DELETE", "OPTIONS")
+
+	// Image routes
+	apiRouter.HandleFunc("/images/prune", imageHandler.PruneImages).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/images/managed", imageHandler.ListManagedImages).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/images/pull/stream", imageHandler.PullImageStream).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/images/load", imageHandler.LoadImage).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/images/save", imageHandler.SaveImage).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/images/{id}", imageHandler.InspectImage).Methods("GET", "OPTIONS")
+
+	// System routes
+	apiRouter.HandleFunc("/system/usage", systemHandler.GetUsage).Methods("GET", "OPTIONS")
+
+	// Build routes
+	apiRouter.HandleFunc("/builds", buildHandler.CreateBuild).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/builds/{jobId}", buildHandler.GetBuild).Methods("GET", "OPTIONS")
+	apiRouter.HandleFunc("/builds/{jobId}", buildHandler.CancelBuild).Methods("DELETE", "OPTIONS")
+
+	// API description
+	apiRouter.HandleFunc("/openapi.json", newOpenAPIHandler(docs.Files)).Methods("GET", "OPTIONS")
+
+	// Project routes
+	apiRouter.HandleFunc("/projects/validate", projectHandler.ValidateProject).Methods("POST", "OPTIONS")
+	apiRouter.HandleFunc("/projects/scaffold", projectHandler.ScaffoldProject).Methods("POST", "OPTIONS")
+
+	// Legacy routes without /api/v1 prefix for backward compatibility
+	router.HandleFunc("/containers", containerHandler.ListContainers).Methods("GET", "OPTIONS")
+	router.HandleFunc("/containers/create", containerHandler.CreateContainer).Methods("POST", "OPTIONS")
+	router.HandleFunc("/containers/{id}", containerHandler.GetContainer).Methods("GET", "OPTIONS")
+	router.HandleFunc("/containers/{id}/logs", containerHandler.GetContainerLogs).Methods("GET", "OPTIONS")
+	router.HandleFunc("/containers/{id}", containerHandler.DeleteContainer).Methods("DELETE", "OPTIONS")
+
+	// Serve the embedded Swagger files
+	router.PathPrefix("/swagger/").Handler(http.StripPrefix("/swagger/", http.FileServer(http.FS(docs.Files))))
+
+	// Swagger UI
+	router.PathPrefix("/swagger-ui/").Handler(httpSwagger.Handler(
+		httpSwagger.URL("/api/v1/openapi.json"),
+		httpSwagger.DeepLinking(true),
+		httpSwagger.DocExpansion("none"),
+		httpSwagger.DomID("swagger-ui"),
+	))
+
+	return router
+}
+
+// preflight runs the --check self-checks, prints the report to stdout and returns the exit code
+func preflight(configPath string) int {
+	cfg, cfgErr := config.LoadConfig(configPath)
+	dockerClient, err := docker.NewClient("unix:///var/run/docker.sock", "", false, "")
+	if err != nil {
+		log.Printf("Failed to create Docker client: %v", err)
+		return 1
+	}
+
+	report := runPreflightChecks(context.Background(), cfg, cfgErr, dockerClient)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Printf("Failed to write check report: %v", err)
+		return 1
+	}
+	if !report.OK {
+		return 1
+	}
+	return 0
+}
+
+// checkDockerAvailability pings the Docker daemon within the given timeout
+func checkDockerAvailability(ctx context.Context, dockerClient *docker.Client, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if err := dockerClient.Ping(ctx); err != nil {
+		return fmt.Errorf("Docker daemon not reachable at %s: %w", dockerClient.Host(), err)
+	}
+	return nil
+}
+
+// newHealthCheckHandler returns a handler reporting server health and Docker daemon reachability.
+// The server reports DEGRADED rather than failing when only the daemon is down.
+func newHealthCheckHandler(dockerClient *docker.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := HealthCheckResponse{Status: "UP", Docker: "UP"}
+		if err := checkDockerAvailability(r.Context(), dockerClient, healthPingTimeout); err != nil {
+			response = HealthCheckResponse{Status: "DEGRADED", Docker: "DOWN"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			log.Printf("Error encoding health check response: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// newOpenAPIHandler returns a handler serving the API spec embedded in the binary
+func newOpenAPIHandler(files fs.FS) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		spec, err := fs.ReadFile(files, "swagger.json")
+		if err != nil {
+			log.Printf("Error reading embedded API spec: %v", err)
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
 	}
 }
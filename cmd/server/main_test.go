@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"docker-management-system/docs"
+	"docker-management-system/internal/builds"
+	"docker-management-system/internal/config"
+	"docker-management-system/internal/docker"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func TestDegradedStartWithUnreachableDocker(t *testing.T) {
+	// Nothing listens on port 1, so the ping fails immediately
+	dockerClient, err := docker.NewClient("tcp://127.0.0.1:1", "1.41", false, "")
+	if err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+
+	err = checkDockerAvailability(context.Background(), dockerClient, time.Second)
+	if err == nil {
+		t.Fatal("Expected an error for an unreachable Docker daemon")
+	}
+	if !strings.Contains(err.Error(), "Docker daemon not reachable at tcp://127.0.0.1:1") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	newHealthCheckHandler(dockerClient)(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var response HealthCheckResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode health response: %v", err)
+	}
+	if response.Status != "DEGRADED" || response.Docker != "DOWN" {
+		t.Errorf("Health = %+v, want DEGRADED with docker DOWN", response)
+	}
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newOpenAPIHandler(docs.Files)(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var spec struct {
+		Swagger string                 `json:"swagger"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Spec is not valid JSON: %v", err)
+	}
+	if spec.Swagger == "" || len(spec.Paths) == 0 {
+		t.Errorf("Spec has no version or paths: %.200s", rec.Body.String())
+	}
+}
+
+// fakeCheckAPI answers the daemon calls made by the preflight checks
+type fakeCheckAPI struct {
+	client.APIClient
+
+	pingErr      error
+	imagePresent bool
+	pullErr      error
+	pulled       []string
+}
+
+func (f *fakeCheckAPI) Ping(ctx context.Context) (types.Ping, error) {
+	return types.Ping{}, f.pingErr
+}
+
+func (f *fakeCheckAPI) DaemonHost() string {
+	return "unix:///var/run/docker.sock"
+}
+
+func (f *fakeCheckAPI) ImageInspectWithRaw(ctx context.Context, ref string) (types.ImageInspect, []byte, error) {
+	if !f.imagePresent {
+		return types.ImageInspect{}, nil, errdefs.NotFound(fmt.Errorf("No such image: %s", ref))
+	}
+	return types.ImageInspect{ID: "sha256:node"}, nil, nil
+}
+
+func (f *fakeCheckAPI) ImagePull(ctx context.Context, ref string, options image.PullOptions) (io.ReadCloser, error) {
+	if f.pullErr != nil {
+		return nil, f.pullErr
+	}
+	f.pulled = append(f.pulled, ref)
+	return io.NopCloser(strings.NewReader(`{"status":"Downloaded newer image"}`)), nil
+}
+
+func TestRunPreflightChecks(t *testing.T) {
+	validConfig := func(t *testing.T) *config.Config {
+		return &config.Config{
+			Docker:    config.DockerConfig{PingTimeout: time.Second, BuildTempDir: t.TempDir()},
+			Container: config.ContainerConfig{DefaultPullPolicy: "missing"},
+		}
+	}
+	statuses := func(report CheckReport) map[string]string {
+		got := make(map[string]string, len(report.Checks))
+		for _, check := range report.Checks {
+			got[check.Name] = check.Status
+		}
+		return got
+	}
+
+	tests := []struct {
+		name      string
+		cfg       func(t *testing.T) *config.Config
+		cfgErr    error
+		fake      *fakeCheckAPI
+		wantOK    bool
+		want      map[string]string
+		wantPulls int
+	}{
+		{
+			name:   "all pass with the base image present",
+			cfg:    validConfig,
+			fake:   &fakeCheckAPI{imagePresent: true},
+			wantOK: true,
+			want:   map[string]string{"config": checkPass, "docker": checkPass, "build_temp_dir": checkPass, "base_image": checkPass},
+		},
+		{
+			name:      "missing base image is pulled",
+			cfg:       validConfig,
+			fake:      &fakeCheckAPI{},
+			wantOK:    true,
+			want:      map[string]string{"base_image": checkPass},
+			wantPulls: 1,
+		},
+		{
+			name:   "base image cannot be pulled",
+			cfg:    validConfig,
+			fake:   &fakeCheckAPI{pullErr: errors.New("pull access denied")},
+			wantOK: false,
+			want:   map[string]string{"docker": checkPass, "base_image": checkFail},
+		},
+		{
+			name:   "unreachable daemon skips the image check",
+			cfg:    validConfig,
+			fake:   &fakeCheckAPI{pingErr: errors.New("ping failed")},
+			wantOK: false,
+			want:   map[string]string{"docker": checkFail, "build_temp_dir": checkPass, "base_image": checkSkip},
+		},
+		{
+			name: "unwritable build temp dir",
+			cfg: func(t *testing.T) *config.Config {
+				cfg := validConfig(t)
+				cfg.Docker.BuildTempDir = filepath.Join(t.TempDir(), "missing")
+				return cfg
+			},
+			fake:   &fakeCheckAPI{imagePresent: true},
+			wantOK: false,
+			want:   map[string]string{"build_temp_dir": checkFail, "base_image": checkPass},
+		},
+		{
+			name:   "invalid config",
+			cfgErr: &config.ConfigError{Field: "Server.Port", Message: "must be between 1 and 65535"},
+			fake:   &fakeCheckAPI{imagePresent: true},
+			wantOK: false,
+			want:   map[string]string{"config": checkFail, "docker": checkPass, "build_temp_dir": checkSkip, "base_image": checkSkip},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg *config.Config
+			if tt.cfg != nil {
+				cfg = tt.cfg(t)
+			}
+			report := runPreflightChecks(context.Background(), cfg, tt.cfgErr, docker.NewClientFromAPI(tt.fake))
+
+			if report.OK != tt.wantOK {
+				t.Errorf("OK = %v, want %v: %+v", report.OK, tt.wantOK, report.Checks)
+			}
+			got := statuses(report)
+			for name, status := range tt.want {
+				if got[name] != status {
+					t.Errorf("%s = %q, want %q: %+v", name, got[name], status, report.Checks)
+				}
+			}
+			for _, check := range report.Checks {
+				if check.Status == checkFail && check.Detail == "" {
+					t.Errorf("Failed check %s has no detail", check.Name)
+				}
+			}
+			if len(tt.fake.pulled) != tt.wantPulls {
+				t.Errorf("Pulled %v, want %d pulls", tt.fake.pulled, tt.wantPulls)
+			}
+		})
+	}
+}
+
+// fakeRouterAPI records the containers created through the router
+type fakeRouterAPI struct {
+	fakeCheckAPI
+
+	created *container.Config
+}
+
+func (f *fakeRouterAPI) ImageBuild(ctx context.Context, buildContext io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+	if _, err := io.Copy(io.Discard, buildContext); err != nil {
+		return types.ImageBuildResponse{}, err
+	}
+	return types.ImageBuildResponse{Body: io.NopCloser(strings.NewReader(`{"id":"moby.image.id","aux":{"ID":"sha256:built"}}`))}, nil
+}
+
+func (f *fakeRouterAPI) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	f.created = config
+	return container.CreateResponse{ID: "abc123"}, nil
+}
+
+func TestRouterAssignsRequestID(t *testing.T) {
+	fake := &fakeRouterAPI{fakeCheckAPI: fakeCheckAPI{imagePresent: true}}
+	queue := builds.NewQueue(1, time.Minute)
+	defer queue.Close()
+	router := newRouter(docker.NewClientFromAPI(fake), &config.Config{}, queue)
+
+	projectPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(projectPath, "package.json"), []byte(`{"name": "app", "version": "1.0.0"}`), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+	body := fmt.Sprintf(`{"projectPath": %q, "name": "traced-app"}`, projectPath)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/containers/create", strings.NewReader(body))
+	req.Header.Set("X-Request-ID", "req-42")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Request-ID"); got != "req-42" {
+		t.Errorf("X-Request-ID header = %q, want req-42", got)
+	}
+	if fake.created == nil || fake.created.Labels[docker.RequestIDLabel] != "req-42" {
+		t.Errorf("Request ID label not set on the created container: %+v", fake.created)
+	}
+}
@@ -0,0 +1,9 @@
+package docs
+
+import "embed"
+
+// Files holds the generated spec so it ships with the binary instead of being read from a
+// docs directory next to it. Regenerate it with swag init after changing handler annotations.
+//
+//go:embed swagger.json swagger.yaml
+var Files embed.FS
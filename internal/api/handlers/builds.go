@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"docker-management-system/internal/builds"
+	"docker-management-system/internal/logging"
+)
+
+// BuildHandler handles async build jobs, which run the container create flow in the background
+type BuildHandler struct {
+	containers *ContainerHandler
+	queue      *builds.Queue
+}
+
+// NewBuildHandler creates a new BuildHandler instance
+func NewBuildHandler(containers *ContainerHandler, queue *builds.Queue) *BuildHandler {
+	return &BuildHandler{
+		containers: containers,
+		queue:      queue,
+	}
+}
+
+// CreateBuildResponse is returned when a build job has been queued
+type CreateBuildResponse struct {
+	JobID string `json:"jobId"`
+}
+
+// @Summary Queue a build
+// @Description Queues the same work as a container create and returns immediately. Poll GET /builds/{jobId} for the outcome.
+// @Tags builds
+// @Accept json
+// @Produce json
+// @Param request body CreateContainerRequest true "Node.js container configuration"
+// @Param onConflict query string false "What to do if the name is taken: fail (also error), suffix or replace; defaults to container.onNameConflict"
+// @Success 202 {object} CreateBuildResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse "Too many builds are waiting"
+// @Router /builds [post]
+func (h *BuildHandler) CreateBuild(w http.ResponseWriter, r *http.Request) {
+	var req CreateContainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+
+	onConflict, err := parseOnConflict(r.URL.Query().Get("onConflict"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid onConflict", err.Error())
+		return
+	}
+	if len(req.DependsOn) > 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid dependsOn", "dependsOn is only supported in batch creates")
+		return
+	}
+
+	// The job outlives this request but keeps its request ID in the logs
+	jobID, err := h.queue.Submit(logging.DetachedContext(r.Context()), func(ctx context.Context) (builds.Result, error) {
+		resp, createErr := h.containers.createContainer(ctx, req, onConflict)
+		if createErr != nil {
+			return builds.Result{}, fmt.Errorf("%s: %s", createErr.message, createErr.details)
+		}
+		// A cancel that arrives after the daemon created the container still removes it
+		if ctx.Err() != nil {
+			if err := h.containers.removeContainer(context.WithoutCancel(ctx), resp.ContainerID, true); err != nil {
+				logging.LogError(ctx, "failed to remove container of cancelled build", err, zap.String("container_id", resp.ContainerID))
+			}
+			return builds.Result{}, ctx.Err()
+		}
+		// The ID pins the image the job built, even once its tag moves to a newer build
+		return builds.Result{
+			ContainerID: resp.ContainerID,
+			Name:        resp.Name,
+			Warnings:    resp.Warnings,
+			ImageID:     resp.imageID,
+		}, nil
+	})
+	if err != nil {
+		if errors.Is(err, builds.ErrQueueFull) || errors.Is(err, builds.ErrQueueClosed) {
+			respondWithError(w, http.StatusServiceUnavailable, "Build queue unavailable", err.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to queue build", err.Error())
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/builds/"+jobID)
+	respondWithJSON(w, http.StatusAccepted, CreateBuildResponse{JobID: jobID})
+}
+
+// @Summary Get build status
+// @Description Returns the job's status (queued, building, success or failed), its current stage and, once it succeeded, the container ID
+// @Tags builds
+// @Produce json
+// @Param jobId path string true "Build job ID"
+// @Success 200 {object} builds.Job
+// @Failure 404 {object} ErrorResponse
+// @Router /builds/{jobId} [get]
+func (h *BuildHandler) GetBuild(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobId"]
+
+	job, ok := h.queue.Get(jobID)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Build job not found", fmt.Sprintf("no build job %s", jobID))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, job)
+}
+
+// @Summary Cancel a build
+// @Description Cancels a queued or running build job. A running job stops at its next Docker call and removes the files and container it created.
+// @Tags builds
+// @Produce json
+// @Param jobId path string true "Build job ID"
+// @Success 200 {object} builds.Job
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse "The job already finished"
+// @Router /builds/{jobId} [delete]
+func (h *BuildHandler) CancelBuild(w http.ResponseWriter, r *http.Request) {
+	jobID := mux.Vars(r)["jobId"]
+
+	job, err := h.queue.Cancel(jobID)
+	switch {
+	case errors.Is(err, builds.ErrJobNotFound):
+		respondWithError(w, http.StatusNotFound, "Build job not found", fmt.Sprintf("no build job %s", jobID))
+		return
+	case errors.Is(err, builds.ErrJobFinished):
+		respondWithError(w, http.StatusConflict, "Build job already finished", fmt.Sprintf("build job %s is %s", jobID, job.Status))
+		return
+	case err != nil:
+		respondWithError(w, http.StatusInternalServerError, "Failed to cancel build", err.Error())
+		return
+	}
+	logging.LogAudit(r.Context(), "cancel_build", jobID, logging.ActorFromContext(r.Context()), true)
+	respondWithJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"docker-management-system/internal/builds"
+	"docker-management-system/internal/config"
+	"docker-management-system/internal/docker"
+	"docker-management-system/internal/logging"
+)
+
+func TestBuildJob(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	logging.SetAuditLogger(zap.New(core))
+	defer logging.SetAuditLogger(nil)
+
+	fake := &fakeDockerAPI{}
+	queue := builds.NewQueue(1, time.Hour)
+	defer queue.Close()
+	h := NewBuildHandler(NewContainerHandler(docker.NewClientFromAPI(fake), config.ContainerConfig{}), queue)
+
+	body, err := json.Marshal(map[string]interface{}{"projectPath": newTestProject(t), "name": "my-app"})
+	if err != nil {
+		t.Fatalf("Failed to marshal request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/builds", bytes.NewReader(body))
+	req = req.WithContext(logging.WithRequestID(req.Context(), "req-42"))
+	rec := httptest.NewRecorder()
+	h.CreateBuild(rec, req)
+
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+	}
+	var created CreateBuildResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil || created.JobID == "" {
+		t.Fatalf("Expected a job ID, got %v (%v)", created, err)
+	}
+
+	var job builds.Job
+	deadline := time.Now().Add(5 * time.Second)
+	for job.Status != builds.StatusSuccess && job.Status != builds.StatusFailed {
+		if time.Now().After(deadline) {
+			t.Fatalf("build did not finish, last state %+v", job)
+		}
+		time.Sleep(5 * time.Millisecond)
+
+		get := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/builds/"+created.JobID, nil), map[string]string{"jobId": created.JobID})
+		rec = httptest.NewRecorder()
+		h.GetBuild(rec, get)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		job = builds.Job{}
+		if err := json.NewDecoder(rec.Body).Decode(&job); err != nil {
+			t.Fatalf("Failed to decode job: %v", err)
+		}
+	}
+	if job.Status != builds.StatusSuccess || job.ContainerID != "abc123" {
+		t.Fatalf("job = %+v, want success with container abc123", job)
+	}
+	// The job reports the image it built, which the container runs
+	if job.ImageID != "sha256:built" || fake.createConfig.Image != "blockbuilder/my-app:latest" {
+		t.Errorf("imageId = %q, container image %q; want sha256:built, built as blockbuilder/my-app:latest", job.ImageID, fake.createConfig.Image)
+	}
+
+	// The background create is still attributed to the request that queued it
+	records := logs.FilterMessage("audit").All()
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 audit record, got %d", len(records))
+	}
+	if got := records[0].ContextMap()["request_id"]; got != "req-42" {
+		t.Errorf("audit request_id = %v, want req-42", got)
+	}
+
+	get := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/builds/unknown", nil), map[string]string{"jobId": "unknown"})
+	rec = httptest.NewRecorder()
+	h.GetBuild(rec, get)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown job, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestCancelBuild(t *testing.T) {
+	tests := []struct {
+		name    string
+		fake    *fakeDockerAPI
+		started func(*fakeDockerAPI) chan struct{}
+	}{
+		{
+			name:    "during the base image pull",
+			fake:    &fakeDockerAPI{imageMissing: true, pullStarted: make(chan struct{})},
+			started: func(f *fakeDockerAPI) chan struct{} { return f.pullStarted },
+		},
+		{
+			name:    "during the image build",
+			fake:    &fakeDockerAPI{buildStarted: make(chan struct{})},
+			started: func(f *fakeDockerAPI) chan struct{} { return f.buildStarted },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := tt.fake
+			queue := builds.NewQueue(1, time.Hour)
+			defer queue.Close()
+			h := NewBuildHandler(NewContainerHandler(docker.NewClientFromAPI(fake), config.ContainerConfig{}), queue)
+
+			projectPath := newTestProject(t)
+			body, err := json.Marshal(map[string]interface{}{"projectPath": projectPath, "name": "my-app"})
+			if err != nil {
+				t.Fatalf("Failed to marshal request: %v", err)
+			}
+			rec := httptest.NewRecorder()
+			h.CreateBuild(rec, httptest.NewRequest(http.MethodPost, "/builds", bytes.NewReader(body)))
+			if rec.Code != http.StatusAccepted {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusAccepted, rec.Code, rec.Body.String())
+			}
+			var created CreateBuildResponse
+			if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			cancel := func() *httptest.ResponseRecorder {
+				req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, "/builds/"+created.JobID, nil), map[string]string{"jobId": created.JobID})
+				rec := httptest.NewRecorder()
+				h.CancelBuild(rec, req)
+				return rec
+			}
+
+			<-tt.started(fake)
+			rec = cancel()
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+			}
+			var job builds.Job
+			if err := json.NewDecoder(rec.Body).Decode(&job); err != nil || job.Status != builds.StatusCancelled {
+				t.Fatalf("Expected a cancelled job, got %+v (%v)", job, err)
+			}
+
+			deadline := time.Now().Add(5 * time.Second)
+			for job.FinishedAt == nil {
+				if time.Now().After(deadline) {
+					t.Fatalf("cancelled build did not stop, last state %+v", job)
+				}
+				time.Sleep(5 * time.Millisecond)
+				job, _ = queue.Get(created.JobID)
+			}
+			if job.Status != builds.StatusCancelled {
+				t.Errorf("status = %s after the build stopped, want cancelled", job.Status)
+			}
+			if fake.buildStarted != nil && !fake.buildCancelled {
+				t.Error("image build was not aborted by the cancel")
+			}
+			if fake.createConfig != nil {
+				t.Error("container was created after the build was cancelled")
+			}
+			if _, err := os.Stat(filepath.Join(projectPath, "Dockerfile")); !os.IsNotExist(err) {
+				t.Errorf("generated Dockerfile left behind by the cancelled build: %v", err)
+			}
+
+			if rec = cancel(); rec.Code != http.StatusConflict {
+				t.Errorf("Expected status %d cancelling a finished job, got %d", http.StatusConflict, rec.Code)
+			}
+		})
+	}
+}
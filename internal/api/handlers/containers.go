@@ -1,58 +1,172 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"docker-management-system/internal/builds"
+	"docker-management-system/internal/config"
 	"docker-management-system/internal/docker"
+	"docker-management-system/internal/docker/nodeproject"
+	apperrors "docker-management-system/internal/errors"
+	"docker-management-system/internal/logging"
 	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
 )
 
 // ContainerHandler handles container-related HTTP requests
 type ContainerHandler struct {
 	dockerClient *docker.Client
+	defaults     config.ContainerConfig
+	// projectLocks serializes builds of the same project, which share its generated Dockerfile
+	projectLocks projectLocks
 }
 
 // NewContainerHandler creates a new ContainerHandler instance
-func NewContainerHandler(dockerClient *docker.Client) *ContainerHandler {
+func NewContainerHandler(dockerClient *docker.Client, defaults config.ContainerConfig) *ContainerHandler {
 	return &ContainerHandler{
 		dockerClient: dockerClient,
+		defaults:     defaults,
 	}
 }
 
 // CreateContainerRequest represents the request body for container creation
 // @Description Request body for creating a new container from a Node.js project
 type CreateContainerRequest struct {
-	ProjectPath    string            `json:"projectPath" example:"/path/to/nodejs/project" binding:"required" description:"Path to the Node.js project containing package.json"`
-	Name          string            `json:"name" example:"my-nodejs-app" binding:"required" description:"Name for the container"`
-	Env           []string          `json:"env,omitempty" example:"NODE_ENV=production,PORT=3000" description:"Environment variables for the Node.js application"`
-	CPUShares     int64             `json:"cpuShares,omitempty" example:"1024" description:"CPU shares (relative weight)"`
-	MemoryLimit   int64             `json:"memoryLimit,omitempty" example:"536870912" description:"Memory limit in bytes"`
-	NetworkMode   string            `json:"networkMode,omitempty" example:"bridge" description:"Docker network mode"`
-	Labels        map[string]string `json:"labels,omitempty" example:"environment:production" description:"Docker container labels"`
+	ProjectPath        string              `json:"projectPath" example:"/path/to/nodejs/project" binding:"required" description:"Path to the Node.js project containing package.json"`
+	Workdir            string              `json:"workdir,omitempty" example:"apps/api" description:"Subdirectory of projectPath containing the app's package.json, for monorepos"`
+	Name               string              `json:"name" example:"my-nodejs-app" binding:"required" description:"Name for the container"`
+	Env                []string            `json:"env,omitempty" example:"NODE_ENV=production,PORT=3000" description:"Environment variables for the Node.js application"`
+	CPUShares          int64               `json:"cpuShares,omitempty" example:"1024" description:"CPU shares (relative weight)"`
+	CPULimit           float64             `json:"cpuLimit,omitempty" example:"0.5" description:"Hard CPU cap in CPUs, converted to a CFS quota"`
+	CPUQuota           int64               `json:"cpuQuota,omitempty" example:"50000" description:"CFS quota in microseconds per period"`
+	CPUPeriod          int64               `json:"cpuPeriod,omitempty" example:"100000" description:"CFS period in microseconds"`
+	MemoryLimit        int64               `json:"memoryLimit,omitempty" example:"536870912" description:"Memory limit in bytes"`
+	MemoryReservation  int64               `json:"memoryReservation,omitempty" example:"268435456" description:"Soft memory limit in bytes"`
+	MemorySwap         int64               `json:"memorySwap,omitempty" example:"1073741824" description:"Total memory plus swap limit in bytes, -1 for unlimited swap"`
+	NetworkMode        string              `json:"networkMode,omitempty" example:"bridge" description:"Docker network mode"`
+	Labels             map[string]string   `json:"labels,omitempty" example:"environment:production" description:"Docker container labels"`
+	ReadOnlyRootFS     bool                `json:"readOnlyRootFs,omitempty" example:"true" description:"Mount the container's root filesystem as read-only"`
+	TmpfsMounts        []string            `json:"tmpfsMounts,omitempty" example:"/tmp" description:"Writable tmpfs mounts in path[:options] format"`
+	CapAdd             []string            `json:"capAdd,omitempty" example:"NET_BIND_SERVICE" description:"Linux capabilities to add"`
+	CapDrop            []string            `json:"capDrop,omitempty" example:"ALL" description:"Linux capabilities to drop (defaults to NET_RAW)"`
+	PidsLimit          int64               `json:"pidsLimit,omitempty" example:"256" description:"Maximum number of processes in the container"`
+	ShmSize            int64               `json:"shmSize,omitempty" example:"1073741824" description:"Size of /dev/shm in bytes, e.g. 1GB for headless Chrome (defaults to Docker's 64MB; capped by the configured maximum, 2GB by default)"`
+	Ulimits            []docker.UlimitSpec `json:"ulimits,omitempty" description:"Process resource limits, e.g. nofile soft/hard"`
+	RestartPolicy      string              `json:"restartPolicy,omitempty" example:"no" description:"Docker restart policy: no, always, unless-stopped or on-failure (defaults to no)"`
+	RestartMaxRetries  int                 `json:"restartMaxRetries,omitempty" example:"3" description:"With on-failure, how many restarts Docker attempts before giving up (defaults to 0, retrying forever)"`
+	AutoRemove         bool                `json:"autoRemove,omitempty" example:"true" description:"Remove the container when it exits, for one-shot jobs; requires the no restart policy"`
+	ExtraHosts         []string            `json:"extraHosts,omitempty" example:"host.docker.internal:host-gateway" description:"Extra /etc/hosts entries in hostname:ip format"`
+	DNS                []string            `json:"dns,omitempty" example:"10.0.0.2" description:"DNS server IPs used instead of the Docker defaults"`
+	DNSSearch          []string            `json:"dnsSearch,omitempty" example:"corp.internal" description:"DNS search domains"`
+	DNSOptions         []string            `json:"dnsOptions,omitempty" example:"ndots:2" description:"resolv.conf options"`
+	PullPolicy         string              `json:"pullPolicy,omitempty" example:"missing" description:"When to pull the image: always, missing or never (defaults to the configured policy)"`
+	NpmRegistry        string              `json:"npmRegistry,omitempty" example:"https://npm.corp.internal/" description:"Private npm registry used to install dependencies"`
+	NpmToken           string              `json:"npmToken,omitempty" description:"Auth token for npmRegistry; passed to the build as a secret and never persisted"`
+	Init               *bool               `json:"init,omitempty" example:"true" description:"Run Docker's init process (tini) as PID 1 (defaults to true)"`
+	Command            []string            `json:"command,omitempty" example:"npm,run,migrate" description:"Command run instead of the default npm start, e.g. for a one-off migration"`
+	Args               []string            `json:"args,omitempty" example:"--dry-run" description:"Arguments appended to the command"`
+	StopSignal         string              `json:"stopSignal,omitempty" example:"SIGINT" description:"Signal sent to stop the container, for apps that shut down gracefully on something other than SIGTERM"`
+	StopTimeout        int                 `json:"stopTimeout,omitempty" example:"30" description:"Seconds docker stop, stop-all and graceful deletes wait for the app to exit before killing it (defaults to the configured stop timeout for stop-all and graceful deletes, and Docker's 10s for docker stop)"`
+	Ports              []PortMapping       `json:"ports,omitempty" description:"Container ports to publish, e.g. HTTP and a metrics port (defaults to 3000 on host port 3000)"`
+	BindIP             string              `json:"bindIP,omitempty" example:"0.0.0.0" description:"Host address the ports are published on (defaults to the configured bind IP, 127.0.0.1)"`
+	AutoStart          bool                `json:"autoStart,omitempty" example:"true" description:"Start the container once it is created"`
+	VerifyRunning      bool                `json:"verifyRunning,omitempty" example:"true" description:"With autoStart, fail with 422 and the last log lines if the container exits right after starting"`
+	Secrets            []SecretMount       `json:"secrets,omitempty" description:"Secrets mounted read-only as files; values are kept on a host tmpfs and never stored in the container config or logged"`
+	Platform           string              `json:"platform,omitempty" example:"linux/arm64" description:"Platform to pull, build and run the image for, e.g. linux/amd64 or linux/arm64 (defaults to the daemon's)"`
+	DockerfileTemplate string              `json:"dockerfileTemplate,omitempty" description:"Go text/template the image is built from instead of the generated Dockerfile; may reference .BaseImage, .Port, .PackageManager and .BuildOutputDir"`
+	User               string              `json:"user,omitempty" example:"1000:1000" description:"Numeric uid or uid:gid the app runs as, overriding the image's USER, e.g. to match bind-mount ownership"`
+	ProductionBuild    bool                `json:"productionBuild,omitempty" example:"true" description:"Leave devDependencies out of the runtime image; also implied by NODE_ENV=production in env"`
+	LoadDotEnv         bool                `json:"loadDotEnv,omitempty" example:"true" description:"Pass the variables of the app's .env file to the container at runtime; env entries take precedence"`
+	LogLevel           string              `json:"logLevel,omitempty" example:"debug" description:"Sets the LOG_LEVEL env var and the log-level label: debug, info, warn or error"`
+	Alpine             bool                `json:"alpine,omitempty" example:"true" description:"Build on the Alpine variant of the Node.js image, e.g. node:20-alpine; smaller, but native modules compile against musl libc"`
+	UseBuildCache      bool                `json:"useBuildCache,omitempty" example:"true" description:"Keep the package manager's download cache in a BuildKit cache mount shared across builds"`
+	LogDriver          string              `json:"logDriver,omitempty" example:"journald" description:"Docker log driver, e.g. json-file, local, journald or gelf (defaults to the configured driver, json-file); remote drivers make the logs endpoints unavailable"`
+	LogOpts            map[string]string   `json:"logOpts,omitempty" description:"Log driver options, e.g. max-size and max-file; json-file and local logs default to the configured rotation limits"`
+	Sysctls            map[string]string   `json:"sysctls,omitempty" description:"Namespaced kernel parameters, e.g. {\"net.core.somaxconn\": \"1024\"}; only net.*, fs.mqueue.* and the kernel IPC sysctls are allowed"`
+	Devices            []DeviceMapping     `json:"devices,omitempty" description:"Host devices to expose in the container; paths must exist under /dev and privileged devices need allowPrivilegedDevices in the configuration"`
+	DependsOn          []string            `json:"dependsOn,omitempty" example:"db" description:"Batch creates only: names of items in the same batch that must be created, and be ready if started, before this one"`
+}
+
+// PortMapping publishes a container port on the host
+type PortMapping struct {
+	ContainerPort int    `json:"containerPort" example:"9464"`
+	HostPort      int    `json:"hostPort,omitempty" example:"9464" description:"Host port; 0 lets Docker pick a free one"`
+	Protocol      string `json:"protocol,omitempty" example:"tcp" description:"tcp (default) or udp"`
+}
+
+// DeviceMapping exposes a host device in the container
+type DeviceMapping struct {
+	HostPath      string `json:"hostPath" example:"/dev/ttyUSB0" description:"Device on the host, under /dev"`
+	ContainerPath string `json:"containerPath,omitempty" example:"/dev/ttyUSB0" description:"Path inside the container (defaults to hostPath)"`
+	Permissions   string `json:"permissions,omitempty" example:"rw" description:"Cgroup permissions: any of r (read), w (write) and m (mknod) (defaults to rwm)"`
+}
+
+// SecretMount is a secret exposed to the container as a read-only file
+type SecretMount struct {
+	Name   string `json:"name" example:"db-password" description:"Identifies the secret in errors"`
+	Value  string `json:"value,omitempty" description:"Secret content; mutually exclusive with source"`
+	Source string `json:"source,omitempty" example:"/etc/block-builder/secrets/db-password" description:"Host file to read the secret from; must be inside the configured container.secretSourcesDir"`
+	Target string `json:"target" example:"/run/secrets/db-password" description:"Absolute path of the file inside the container"`
+}
+
+// String omits the value so a request cannot leak a secret into a log line
+func (s SecretMount) String() string {
+	return fmt.Sprintf("secret %s at %s", s.Name, s.Target)
+}
+
+// CreateContainerResponse is returned when a container has been created
+type CreateContainerResponse struct {
+	ContainerID string   `json:"containerId"`
+	Name        string   `json:"name" description:"Name the container was created with, which differs from the request with onConflict=suffix"`
+	Warnings    []string `json:"warnings" description:"Warnings reported by the Docker daemon, e.g. about ignored resource limits"`
+	Started     bool     `json:"started,omitempty" description:"Set when autoStart started the container"`
+
+	image   string // Image reference the container was created from
+	imageID string // ID of the image built for the container
 }
 
 // ErrorResponse represents an error response
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Details string `json:"details,omitempty"`
+	Error     string `json:"error"`
+	Details   string `json:"details,omitempty"`
+	ErrorCode string `json:"error_code,omitempty" example:"CONTAINER_NOT_FOUND"`
 }
 
 // @Summary Create a new Node.js container
-// @Description Creates a new container from a Node.js project. Validates project structure, generates Dockerfile, and configures the container
+// @Description Creates a new container from a Node.js project. Validates project structure, generates a Dockerfile, builds the project into an image with BuildKit and creates the container from it
 // @Description The project must contain a valid package.json file with name and version fields
-// @Description Container will expose port 3000 by default and use 'npm start' as the entry command
+// @Description Container will expose port 3000 unless ports are given and use 'npm start' as the entry command
 // @Tags containers
 // @Accept json
 // @Produce json
 // @Param request body CreateContainerRequest true "Node.js container configuration"
-// @Success 201 {object} map[string]string "Returns container ID"
+// @Param onConflict query string false "What to do if the name is taken: fail (409; also error), suffix (use name-2, name-3, ...) or replace (remove the existing container if this service manages it); defaults to container.onNameConflict"
+// @Success 201 {object} CreateContainerResponse "Returns container ID and daemon warnings"
 // @Failure 400 {object} ErrorResponse "Invalid request or invalid Node.js project structure"
-// @Failure 500 {object} ErrorResponse "Server error or Docker operation failed"
+// @Failure 409 {object} ErrorResponse "Container name already in use"
+// @Failure 422 {object} ErrorResponse "With verifyRunning, the container exited right after starting"
+// @Failure 500 {object} ErrorResponse "Server error, failed image build or Docker operation failed"
 // @Router /containers/create [post]
 func (h *ContainerHandler) CreateContainer(w http.ResponseWriter, r *http.Request) {
 	var req CreateContainerRequest
@@ -61,221 +175,3158 @@ func (h *ContainerHandler) CreateContainer(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Validate Node.js project structure
-	if !isValidNodeProject(req.ProjectPath) {
-		respondWithError(w, http.StatusBadRequest, "Invalid Node.js project", "Missing package.json or invalid structure")
+	onConflict, err := parseOnConflict(r.URL.Query().Get("onConflict"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid onConflict", err.Error())
 		return
 	}
-
-	// Create Dockerfile in the project directory
-	if err := createDockerfile(req.ProjectPath); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to create Dockerfile", err.Error())
+	if len(req.DependsOn) > 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid dependsOn", "dependsOn is only supported in batch creates")
 		return
 	}
 
-	// Read package.json to get project configuration
-	packageJSON, err := os.ReadFile(filepath.Join(req.ProjectPath, "package.json"))
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to read package.json", err.Error())
-		return
-	}
+	// Building the image can take longer than the server's WriteTimeout
+	clearWriteDeadline(w)
 
-	var packageData map[string]interface{}
-	if err := json.Unmarshal(packageJSON, &packageData); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to parse package.json", err.Error())
+	resp, createErr := h.createContainer(r.Context(), req, onConflict)
+	if createErr != nil {
+		respondWithJSON(w, createErr.status, ErrorResponse{Error: createErr.message, Details: createErr.details, ErrorCode: string(createErr.code)})
 		return
 	}
+	respondWithJSON(w, http.StatusCreated, resp)
+}
 
-	// Create container configuration
-	config := docker.ContainerConfig{
-		Image:        "node:latest",
-		Command:      []string{"npm", "start"},
-		Env:          append(req.Env, fmt.Sprintf("NODE_PROJECT_NAME=%v", packageData["name"])),
-		WorkingDir:   "/app",
-		CPUShares:    req.CPUShares,
-		MemoryLimit:  req.MemoryLimit,
-		NetworkMode:  req.NetworkMode,
-		Labels:       req.Labels,
-		RestartPolicy: "no", // Docker restart policy: no, always, unless-stopped, on-failure
-		Ports: map[string]string{
-			"3000": "3000", // Map container port 3000 to host port 3000
-		},
+// BaseImage is the image generated Dockerfiles build on when the project does not pin a
+// Node.js version
+const BaseImage = "node:latest"
+
+// nodeBaseImage picks the Node.js image from the app's .nvmrc or engines.node, then from the
+// monorepo root's when the app is nested in one, falling back to BaseImage. Images are
+// Debian-based like BaseImage unless alpine asks for the Alpine variant. The warning says when
+// the version pin was only partly understood.
+func nodeBaseImage(appDir, contextDir string, alpine bool) (string, string, error) {
+	dirs := []string{appDir}
+	if contextDir != appDir {
+		dirs = append(dirs, contextDir)
 	}
+	warning := ""
+	for _, dir := range dirs {
+		version, dirWarning, err := nodeproject.DetectNodeVersion(dir)
+		if err != nil {
+			return "", "", err
+		}
+		if warning == "" {
+			warning = dirWarning
+		}
+		if version != "" {
+			return nodeproject.NodeImage(version, alpine), warning, nil
+		}
+	}
+	return nodeproject.NodeImage("", alpine), warning, nil
+}
 
-	containerID, err := h.dockerClient.CreateContainer(r.Context(), req.Name, config)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to create container", err.Error())
-		return
+// defaultCommand matches the CMD of generated Dockerfiles
+var defaultCommand = []string{"npm", "start"}
+
+// containerCommand builds the container command from an optional override and extra
+// arguments. The Dockerfile keeps npm start as its CMD; the override only applies to this
+// container.
+func containerCommand(command, args []string) ([]string, error) {
+	if command == nil {
+		command = defaultCommand
+	} else if len(command) == 0 || strings.TrimSpace(command[0]) == "" {
+		return nil, errors.New("command must name an executable when provided")
 	}
 
-	respondWithJSON(w, http.StatusCreated, map[string]string{"containerId": containerID})
+	cmd := make([]string, 0, len(command)+len(args))
+	cmd = append(cmd, command...)
+	return append(cmd, args...), nil
 }
 
-// @Summary List all containers
-// @Description Get a list of all containers
-// @Tags containers
-// @Produce json
-// @Success 200 {array} docker.Container
-// @Failure 500 {object} ErrorResponse
-// @Router /containers [get]
-func (h *ContainerHandler) ListContainers(w http.ResponseWriter, r *http.Request) {
-	containers, err := h.dockerClient.ListContainers(r.Context(), true, nil)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to list containers", err.Error())
-		return
+// defaultPorts publishes the port generated Dockerfiles have always exposed
+var defaultPorts = []PortMapping{{ContainerPort: 3000, HostPort: 3000, Protocol: "tcp"}}
+
+// resolvePorts validates the requested port mappings and fills in the default protocol. No
+// mappings publishes the default port.
+func resolvePorts(mappings []PortMapping) ([]PortMapping, error) {
+	if len(mappings) == 0 {
+		return defaultPorts, nil
 	}
 
-	respondWithJSON(w, http.StatusOK, containers)
+	resolved := make([]PortMapping, 0, len(mappings))
+	seen := make(map[string]bool, len(mappings))
+	for _, m := range mappings {
+		if m.ContainerPort < 1 || m.ContainerPort > 65535 {
+			return nil, fmt.Errorf("container port %d is out of range", m.ContainerPort)
+		}
+		if m.HostPort < 0 || m.HostPort > 65535 {
+			return nil, fmt.Errorf("host port %d is out of range", m.HostPort)
+		}
+		m.Protocol = strings.ToLower(m.Protocol)
+		if m.Protocol == "" {
+			m.Protocol = "tcp"
+		}
+		if m.Protocol != "tcp" && m.Protocol != "udp" {
+			return nil, fmt.Errorf("protocol %q for port %d must be tcp or udp", m.Protocol, m.ContainerPort)
+		}
+		if seen[m.spec()] {
+			return nil, fmt.Errorf("container port %s is mapped more than once", m.spec())
+		}
+		seen[m.spec()] = true
+		resolved = append(resolved, m)
+	}
+	return resolved, nil
 }
 
-// @Summary Get container by ID
-// @Description Get detailed information about a container
-// @Tags containers
-// @Produce json
-// @Param id path string true "Container ID"
-// @Success 200 {object} docker.Container
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /containers/{id} [get]
-func (h *ContainerHandler) GetContainer(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	containerID := vars["id"]
+// spec formats the container side of the mapping as Docker does, e.g. "3000/tcp"
+func (m PortMapping) spec() string {
+	return fmt.Sprintf("%d/%s", m.ContainerPort, m.Protocol)
+}
 
-	// Try to get all containers first
-	containers, err := h.dockerClient.ListContainers(r.Context(), true, nil)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to list containers", err.Error())
-		return
+// containerPorts converts port mappings to the container config's port map
+func containerPorts(mappings []PortMapping) map[string]string {
+	ports := make(map[string]string, len(mappings))
+	for _, m := range mappings {
+		hostPort := ""
+		if m.HostPort != 0 {
+			hostPort = strconv.Itoa(m.HostPort)
+		}
+		ports[m.spec()] = hostPort
 	}
+	return ports
+}
 
-	// Find container by either full ID or prefix
-	var targetContainer *docker.ContainerInfo
-	for _, container := range containers {
-		if container.ID == containerID || strings.HasPrefix(container.ID, containerID) {
-			targetContainer = &container
-			break
+// exposedPorts lists the ports for a Dockerfile EXPOSE instruction, leaving tcp implicit
+func exposedPorts(mappings []PortMapping) string {
+	ports := make([]string, 0, len(mappings))
+	for _, m := range mappings {
+		if m.Protocol == "tcp" {
+			ports = append(ports, strconv.Itoa(m.ContainerPort))
+		} else {
+			ports = append(ports, m.spec())
 		}
 	}
+	return strings.Join(ports, " ")
+}
 
-	if targetContainer == nil {
-		respondWithError(w, http.StatusNotFound, "Container not found", "")
-		return
+// verifyRunningGrace is how long a started container must stay up to count as running. It is
+// a variable so tests can shorten it.
+var verifyRunningGrace = 3 * time.Second
+
+// crashLogTail is how many log lines are returned for a container that exited after start
+const crashLogTail = "50"
+
+// defaultBindIP is used when no bind IP is configured, so ports stay local unless opted in
+const defaultBindIP = "127.0.0.1"
+
+// defaultMaxShmSize caps shmSize when no maximum is configured
+const defaultMaxShmSize = 2 << 30
+
+// defaultSecretsDir is used when no secrets directory is configured
+const defaultSecretsDir = "/dev/shm/block-builder-secrets"
+
+// resolveSecrets validates the requested secrets and loads their values. A source must be a
+// file inside sourcesDir, symlinks included, and is rejected when sourcesDir is empty. Errors
+// name the secret but never include its value.
+func resolveSecrets(mounts []SecretMount, sourcesDir string) ([]docker.Secret, error) {
+	secrets := make([]docker.Secret, 0, len(mounts))
+	targets := make(map[string]bool, len(mounts))
+	for _, m := range mounts {
+		if m.Name == "" {
+			return nil, errors.New("secret name is required")
+		}
+		if !path.IsAbs(m.Target) || path.Clean(m.Target) == "/" {
+			return nil, fmt.Errorf("secret %s: target must be an absolute file path", m.Name)
+		}
+		target := path.Clean(m.Target)
+		if targets[target] {
+			return nil, fmt.Errorf("secret %s: target %s is used by another secret", m.Name, target)
+		}
+		targets[target] = true
+
+		var value []byte
+		switch {
+		case m.Value != "" && m.Source != "":
+			return nil, fmt.Errorf("secret %s: set either value or source, not both", m.Name)
+		case m.Source != "":
+			source, err := resolveSecretSource(sourcesDir, m.Source)
+			if err != nil {
+				return nil, fmt.Errorf("secret %s: %w", m.Name, err)
+			}
+			data, err := os.ReadFile(source)
+			if err != nil {
+				return nil, fmt.Errorf("secret %s: cannot read source: %w", m.Name, err)
+			}
+			value = data
+		case m.Value != "":
+			value = []byte(m.Value)
+		default:
+			return nil, fmt.Errorf("secret %s: value or source is required", m.Name)
+		}
+		secrets = append(secrets, docker.Secret{Name: m.Name, Value: value, Target: target})
 	}
+	return secrets, nil
+}
 
-	// Get detailed container info using the full ID
-	container, err := h.dockerClient.GetContainer(r.Context(), targetContainer.ID)
+// resolveSecretSource checks that a secret's source is inside sourcesDir, after following
+// symlinks in both, and returns the file it resolves to
+func resolveSecretSource(sourcesDir, source string) (string, error) {
+	if sourcesDir == "" {
+		return "", errors.New("source is not allowed: no secret sources directory is configured; set value instead")
+	}
+	if !filepath.IsAbs(source) {
+		return "", errors.New("source must be an absolute path")
+	}
+	if !isWithin(sourcesDir, filepath.Clean(source)) {
+		return "", fmt.Errorf("source must be inside %s", sourcesDir)
+	}
+
+	realRoot, err := filepath.EvalSymlinks(sourcesDir)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to get container details", err.Error())
-		return
+		return "", fmt.Errorf("failed to resolve the secret sources directory: %w", err)
 	}
+	realSource, err := filepath.EvalSymlinks(source)
+	if err != nil {
+		return "", fmt.Errorf("cannot read source: %w", err)
+	}
+	if !isWithin(realRoot, realSource) {
+		return "", fmt.Errorf("source must be inside %s", sourcesDir)
+	}
+	return realSource, nil
+}
 
-	respondWithJSON(w, http.StatusOK, container)
+// privilegedDevices give access to host memory, I/O ports or the kernel log, whatever the
+// container's capabilities
+var privilegedDevices = map[string]bool{
+	"/dev/mem":  true,
+	"/dev/kmem": true,
+	"/dev/port": true,
+	"/dev/kmsg": true,
 }
 
-// @Summary Get container logs
-// @Description Get logs from a container
-// @Tags containers
-// @Produce plain
-// @Param id path string true "Container ID"
-// @Success 200 {string} string "Container logs"
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /containers/{id}/logs [get]
-func (h *ContainerHandler) GetContainerLogs(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	containerID := vars["id"]
+// isPrivilegedDevice reports whether a device gives access beyond the container: the devices
+// above, per-CPU MSR and microcode devices, and block devices, which expose host storage
+func isPrivilegedDevice(devicePath string, mode os.FileMode) bool {
+	return privilegedDevices[devicePath] ||
+		strings.HasPrefix(devicePath, "/dev/cpu/") ||
+		mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0
+}
 
-	// Get the tail query parameter, default to "all"
-	tail := r.URL.Query().Get("tail")
-	if tail == "" {
-		tail = "all"
+// resolveDevices validates the requested device mappings against the host and fills in their
+// defaults. Symlinks such as /dev/serial/by-id entries are followed, and the device they point
+// to must be under /dev as well.
+func resolveDevices(devices []DeviceMapping, allowPrivileged bool) ([]docker.DeviceMapping, error) {
+	resolved := make([]docker.DeviceMapping, 0, len(devices))
+	for _, d := range devices {
+		if !path.IsAbs(d.HostPath) || !strings.HasPrefix(path.Clean(d.HostPath), "/dev/") {
+			return nil, fmt.Errorf("device %q: host path must be under /dev", d.HostPath)
+		}
+		hostPath := path.Clean(d.HostPath)
+		if !allowPrivileged && isPrivilegedDevice(hostPath, 0) {
+			return nil, fmt.Errorf("device %s is privileged and not allowed by configuration", hostPath)
+		}
+
+		target, err := filepath.EvalSymlinks(hostPath)
+		if err != nil {
+			return nil, fmt.Errorf("device %s: %w", hostPath, err)
+		}
+		if !strings.HasPrefix(target, "/dev/") {
+			return nil, fmt.Errorf("device %s: resolves to %s, outside /dev", hostPath, target)
+		}
+		info, err := os.Stat(target)
+		if err != nil {
+			return nil, fmt.Errorf("device %s: %w", hostPath, err)
+		}
+		if !allowPrivileged && isPrivilegedDevice(target, info.Mode()) {
+			return nil, fmt.Errorf("device %s is privileged and not allowed by configuration", hostPath)
+		}
+
+		device := docker.DeviceMapping{
+			HostPath:      hostPath,
+			ContainerPath: d.ContainerPath,
+			Permissions:   d.Permissions,
+		}
+		if device.ContainerPath == "" {
+			device.ContainerPath = hostPath
+		}
+		if device.Permissions == "" {
+			device.Permissions = "rwm"
+		}
+		resolved = append(resolved, device)
 	}
+	return resolved, nil
+}
 
-	logs, err := h.dockerClient.GetContainerLogs(r.Context(), containerID, tail)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to get container logs", err.Error())
-		return
+// bindIP returns the host address to publish ports on: the request's, else the configured default
+func (h *ContainerHandler) bindIP(requested string) string {
+	if requested != "" {
+		return requested
+	}
+	if h.defaults.DefaultBindIP != "" {
+		return h.defaults.DefaultBindIP
 	}
+	return defaultBindIP
+}
 
-	respondWithJSON(w, http.StatusOK, map[string]string{"logs": logs})
+// secretsDir returns the configured host directory for secret files
+func (h *ContainerHandler) secretsDir() string {
+	if h.defaults.SecretsDir != "" {
+		return h.defaults.SecretsDir
+	}
+	return defaultSecretsDir
 }
 
-// @Summary Delete a container
-// @Description Delete a container by ID
-// @Tags containers
-// @Produce json
-// @Param id path string true "Container ID"
-// @Success 200 {object} map[string]string
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /containers/{id} [delete]
-func (h *ContainerHandler) DeleteContainer(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	containerID := vars["id"]
+// logConfig resolves a container's log driver and options. Without a driver in the request the
+// configured one applies, and json-file and local logs get the configured rotation limits
+// unless the request sets its own, so logs cannot fill the host's disk.
+func (h *ContainerHandler) logConfig(driver string, opts map[string]string) (string, map[string]string) {
+	if driver == "" {
+		driver = h.defaults.DefaultLogDriver
+	}
+	if driver == "" {
+		driver = docker.DefaultLogDriver
+	}
+	if !docker.SupportsLogRotation(driver) {
+		return driver, opts
+	}
 
-	force := r.URL.Query().Get("force") == "true"
-	
-	if err := h.dockerClient.RemoveContainer(r.Context(), containerID, force); err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Failed to remove container", err.Error())
+	maxSize, maxFile := h.defaults.LogMaxSize, h.defaults.LogMaxFile
+	if maxSize == "" {
+		maxSize = docker.DefaultLogMaxSize
+	}
+	if maxFile == 0 {
+		maxFile = docker.DefaultLogMaxFile
+	}
+	resolved := map[string]string{
+		"max-size": maxSize,
+		"max-file": strconv.Itoa(maxFile),
+	}
+	for key, value := range opts {
+		resolved[key] = value
+	}
+	return driver, resolved
+}
+
+// removeContainer removes a container together with the secret files mounted into it
+func (h *ContainerHandler) removeContainer(ctx context.Context, containerID string, force bool) error {
+	// The label is read first because it is gone once the container is removed
+	secretsDir := h.containerSecretsDir(ctx, containerID)
+
+	if err := h.dockerClient.RemoveContainer(ctx, containerID, force); err != nil {
+		return err
+	}
+
+	h.removeSecrets(ctx, containerID, secretsDir)
+	return nil
+}
+
+// containerSecretsDir returns the secrets directory recorded on a container, if any
+func (h *ContainerHandler) containerSecretsDir(ctx context.Context, containerID string) string {
+	if inspect, err := h.dockerClient.InspectContainerRaw(ctx, containerID); err == nil && inspect.Config != nil {
+		return inspect.Config.Labels[docker.SecretsLabel]
+	}
+	return ""
+}
+
+// removeSecrets deletes a removed container's secrets directory; a failure is only logged
+func (h *ContainerHandler) removeSecrets(ctx context.Context, containerID, secretsDir string) {
+	if secretsDir == "" {
 		return
 	}
+	if err := docker.RemoveSecrets(h.secretsDir(), secretsDir); err != nil {
+		logging.LogError(ctx, "failed to remove container secrets", err, zap.String("container_id", containerID))
+	}
+}
 
-	w.WriteHeader(http.StatusNoContent)
+// createError is a failed create with the status and error response it maps to
+type createError struct {
+	status  int
+	message string
+	details string
+	code    apperrors.ErrorCode
 }
 
-// Helper functions
+// newCreateError is a createError for a failure that has no error code of its own, coded by
+// its status like respondWithError
+func newCreateError(status int, message, details string) *createError {
+	return &createError{status: status, message: message, details: details, code: statusErrorCodes[status]}
+}
 
-func isValidNodeProject(projectPath string) bool {
-	packageJSONPath := filepath.Join(projectPath, "package.json")
-	if _, err := os.Stat(packageJSONPath); err != nil {
-		return false
+// dockerCreateError is a createError for a failed Docker call, coded like respondWithDockerError.
+// A status other than 500 is the caller's deliberate choice and kept.
+func dockerCreateError(status int, message string, err error) *createError {
+	appErr := appErrorFromDocker(err)
+	if status == http.StatusInternalServerError && appErr.ErrorCode != apperrors.CodeInternal {
+		status = appErr.Code
 	}
+	return &createError{status: status, message: message, details: err.Error(), code: appErr.ErrorCode}
+}
 
-	// Read and parse package.json to verify it's valid
-	data, err := os.ReadFile(packageJSONPath)
+// createContainer validates the request, generates the build files and creates the container.
+// It is shared by single and batch creates, so failures are returned rather than written.
+func (h *ContainerHandler) createContainer(ctx context.Context, req CreateContainerRequest, onConflict string) (CreateContainerResponse, *createError) {
+	builds.ReportProgress(ctx, "validating project")
+
+	if onConflict == "" {
+		onConflict, _ = parseOnConflict(h.defaults.OnNameConflict)
+	}
+
+	// The Docker name carries the configured namespace; responses use the logical name. It is
+	// checked first so an unusable name fails before any file is written or image pulled.
+	name, err := h.dockerName(req.Name)
 	if err != nil {
-		return false
+		return CreateContainerResponse{}, newCreateError(http.StatusBadRequest, "Invalid container name", err.Error())
 	}
 
-	var packageJSON map[string]interface{}
-	if err := json.Unmarshal(data, &packageJSON); err != nil {
-		return false
+	// The project is resolved first so an unusable one fails before anything else is checked
+	build, env, createErr := h.resolveProjectBuild(req)
+	if createErr != nil {
+		return CreateContainerResponse{}, createErr
 	}
 
-	// Check for required fields
-	_, hasName := packageJSON["name"]
-	_, hasVersion := packageJSON["version"]
-	return hasName && hasVersion
-}
+	command, err := containerCommand(req.Command, req.Args)
+	if err != nil {
+		return CreateContainerResponse{}, newCreateError(http.StatusBadRequest, "Invalid command", err.Error())
+	}
 
-func createDockerfile(projectPath string) error {
-	dockerfileContent := `FROM node:latest
+	secrets, err := resolveSecrets(req.Secrets, h.defaults.SecretSourcesDir)
+	if err != nil {
+		return CreateContainerResponse{}, newCreateError(http.StatusBadRequest, "Invalid secrets", err.Error())
+	}
 
-WORKDIR /app
+	devices, err := resolveDevices(req.Devices, h.defaults.AllowPrivilegedDevices)
+	if err != nil {
+		return CreateContainerResponse{}, newCreateError(http.StatusBadRequest, "Invalid devices", err.Error())
+	}
 
-# Copy package files
-COPY package*.json ./
+	maxShmSize := h.defaults.MaxShmSize
+	if maxShmSize == 0 {
+		maxShmSize = defaultMaxShmSize
+	}
+	if req.ShmSize > maxShmSize {
+		return CreateContainerResponse{}, newCreateError(http.StatusBadRequest, "Invalid shm size", fmt.Sprintf("shmSize %d exceeds the maximum of %d bytes", req.ShmSize, maxShmSize))
+	}
 
-# Install dependencies
-RUN npm install
+	// The log level overrides any LOG_LEVEL from env or .env, so the label always matches
+	logLevel := strings.ToLower(req.LogLevel)
+	if logLevel != "" {
+		if !logLevels[logLevel] {
+			return CreateContainerResponse{}, newCreateError(http.StatusBadRequest, "Invalid log level", fmt.Sprintf("logLevel must be debug, info, warn or error, got %q", req.LogLevel))
+		}
+		env = mergeEnv(env, []string{logLevelEnv + "=" + logLevel})
+	}
 
-# Copy project files
-COPY . .
+	// Read package.json to get project configuration
+	packageJSON, err := os.ReadFile(filepath.Join(build.appDir, "package.json"))
+	if err != nil {
+		return CreateContainerResponse{}, newCreateError(http.StatusInternalServerError, "Failed to read package.json", err.Error())
+	}
 
-# Expose default port
-EXPOSE 3000
+	var packageData map[string]interface{}
+	if err := nodeproject.UnmarshalPackageJSON(packageJSON, &packageData); err != nil {
+		return CreateContainerResponse{}, newCreateError(http.StatusBadRequest, "Malformed package.json", err.Error())
+	}
 
-# Start the application
-CMD ["npm", "start"]
-`
-	return os.WriteFile(filepath.Join(projectPath, "Dockerfile"), []byte(dockerfileContent), 0644)
-}
+	// Create container configuration
+	// Docker restart policy: no, always, unless-stopped, on-failure
+	restartPolicy := req.RestartPolicy
+	if restartPolicy == "" {
+		restartPolicy = "no"
+	}
 
-func respondWithError(w http.ResponseWriter, code int, message string, details string) {
-	respondWithJSON(w, code, ErrorResponse{
-		Error:   message,
-		Details: details,
-	})
+	// Node.js does not reap zombies or handle signals properly as PID 1, so use tini unless opted out
+	useInit := true
+	if req.Init != nil {
+		useInit = *req.Init
+	}
+
+	// A custom template sets its own WORKDIR, which the container keeps
+	workingDir := path.Join("/app", build.appSubdir)
+	if build.template != nil {
+		workingDir = ""
+	}
+
+	// The image is tagged after the container's name, so with onConflict=suffix a taken name is
+	// swapped for a free one before the image is built
+	if onConflict == onConflictSuffix && name != "" {
+		name, err = h.freeName(ctx, name)
+		if err != nil {
+			if errors.Is(err, docker.ErrInvalidConfig) {
+				return CreateContainerResponse{}, dockerCreateError(http.StatusBadRequest, "Invalid container name", err)
+			}
+			if docker.IsNameConflictError(err) {
+				return CreateContainerResponse{}, dockerCreateError(http.StatusConflict, "Container name already in use", err)
+			}
+			return CreateContainerResponse{}, dockerCreateError(http.StatusInternalServerError, "Failed to create container", err)
+		}
+	}
+
+	config := docker.ContainerConfig{
+		Image:             docker.ManagedImageTag(name),
+		Command:           command,
+		Env:               append(env, fmt.Sprintf("NODE_PROJECT_NAME=%v", packageData["name"])),
+		WorkingDir:        workingDir,
+		CPUShares:         req.CPUShares,
+		CPULimit:          req.CPULimit,
+		CPUQuota:          req.CPUQuota,
+		CPUPeriod:         req.CPUPeriod,
+		MemoryLimit:       req.MemoryLimit,
+		MemoryReservation: req.MemoryReservation,
+		MemorySwap:        req.MemorySwap,
+		NetworkMode:       req.NetworkMode,
+		Labels:            mergeLabels(h.defaults.DefaultLabels, req.Labels),
+		RestartPolicy:     restartPolicy,
+		RestartMaxRetries: req.RestartMaxRetries,
+		Ports:             containerPorts(build.ports),
+		BindIP:            h.bindIP(req.BindIP),
+		ReadOnlyRootFS:    req.ReadOnlyRootFS,
+		TmpfsMounts:       req.TmpfsMounts,
+		CapAdd:            req.CapAdd,
+		CapDrop:           defaultCapDrop(req.CapAdd, req.CapDrop),
+		PidsLimit:         req.PidsLimit,
+		ShmSize:           req.ShmSize,
+		Ulimits:           req.Ulimits,
+		AutoRemove:        req.AutoRemove,
+		ExtraHosts:        req.ExtraHosts,
+		DNS:               req.DNS,
+		DNSSearch:         req.DNSSearch,
+		DNSOptions:        req.DNSOptions,
+		Init:              useInit,
+		StopSignal:        build.stopSignal,
+		StopTimeout:       req.StopTimeout,
+		Platform:          build.platform,
+		User:              req.User,
+		Sysctls:           req.Sysctls,
+		Devices:           devices,
+	}
+	config.LogDriver, config.LogOpts = h.logConfig(req.LogDriver, req.LogOpts)
+
+	// Record the originating request so the container can be traced back to it
+	delete(config.Labels, docker.RequestIDLabel)
+	if requestID := logging.RequestIDFromContext(ctx); requestID != "" {
+		config.Labels[docker.RequestIDLabel] = requestID
+	}
+	if logLevel != "" {
+		config.Labels[docker.LogLevelLabel] = logLevel
+	}
+	// The project and how it was built are recorded so rebuild-all can build it again
+	config.Labels[docker.ProjectPathLabel] = req.ProjectPath
+	spec, err := json.Marshal(newBuildSpec(req, build))
+	if err != nil {
+		return CreateContainerResponse{}, newCreateError(http.StatusInternalServerError, "Failed to record build settings", err.Error())
+	}
+	config.Labels[docker.BuildSpecLabel] = string(spec)
+
+	if err := docker.ValidateContainerConfig(config); err != nil {
+		return CreateContainerResponse{}, dockerCreateError(http.StatusBadRequest, "Invalid container configuration", err)
+	}
+
+	pullPolicy := req.PullPolicy
+	if pullPolicy == "" {
+		pullPolicy = h.defaults.DefaultPullPolicy
+	}
+	if pullPolicy == "" {
+		pullPolicy = string(docker.DefaultPullPolicy)
+	}
+	if !docker.IsValidPullPolicy(pullPolicy) {
+		return CreateContainerResponse{}, newCreateError(http.StatusBadRequest, "Invalid pull policy", "pullPolicy must be always, missing or never")
+	}
+
+	// Track files generated in the project so a failed create leaves no side effects
+	var generated []string
+	succeeded := false
+	defer func() {
+		if succeeded {
+			return
+		}
+		h.removeGeneratedArtifacts(ctx, build.contextDir, generated)
+	}()
+
+	imageID, nativeWarning, generated, createErr := h.buildProjectImage(ctx, build, config.Image, docker.PullPolicy(pullPolicy))
+	if createErr != nil {
+		return CreateContainerResponse{}, createErr
+	}
+
+	builds.ReportProgress(ctx, "creating container")
+
+	// Only the host path of the secrets is recorded, so the container can be cleaned up later
+	delete(config.Labels, docker.SecretsLabel)
+	if len(secrets) > 0 {
+		dir, files, err := docker.WriteSecrets(h.secretsDir(), secrets)
+		if err != nil {
+			return CreateContainerResponse{}, newCreateError(http.StatusInternalServerError, "Failed to write secrets", err.Error())
+		}
+		defer func() {
+			if succeeded {
+				return
+			}
+			if err := docker.RemoveSecrets(h.secretsDir(), dir); err != nil {
+				logging.LogError(ctx, "failed to remove container secrets", err)
+			}
+		}()
+		config.Labels[docker.SecretsLabel] = dir
+		config.SecretFiles = files
+	}
+
+	containerID, warnings, err := h.dockerClient.CreateContainer(ctx, name, config)
+	if onConflict == onConflictReplace && docker.IsNameConflictError(err) {
+		if replaceErr := h.replaceManagedContainer(ctx, name); replaceErr != nil {
+			logging.LogAudit(ctx, "create", name, logging.ActorFromContext(ctx), false)
+			return CreateContainerResponse{}, replaceErr
+		}
+		containerID, warnings, err = h.dockerClient.CreateContainer(ctx, name, config)
+	}
+	// Another request may take the suffixed name first, so pick again a few times
+	for attempt := 0; attempt < maxConflictRetries && onConflict == onConflictSuffix && docker.IsNameConflictError(err); attempt++ {
+		var nameErr error
+		name, nameErr = h.generateUniqueName(ctx, h.defaults.NamePrefix+req.Name)
+		if nameErr != nil {
+			err = nameErr
+			break
+		}
+		// The image's tag follows the container to its new name
+		tag := docker.ManagedImageTag(name)
+		if tagErr := h.dockerClient.TagImage(ctx, imageID, tag); tagErr != nil {
+			err = tagErr
+			break
+		}
+		config.Image = tag
+		containerID, warnings, err = h.dockerClient.CreateContainer(ctx, name, config)
+	}
+	if err != nil {
+		logging.LogAudit(ctx, "create", name, logging.ActorFromContext(ctx), false)
+		if errors.Is(err, docker.ErrInvalidConfig) {
+			return CreateContainerResponse{}, dockerCreateError(http.StatusBadRequest, "Invalid container name", err)
+		}
+		if docker.IsNameConflictError(err) {
+			return CreateContainerResponse{}, dockerCreateError(http.StatusConflict, "Container name already in use", err)
+		}
+		return CreateContainerResponse{}, dockerCreateError(http.StatusInternalServerError, "Failed to create container", err)
+	}
+	logging.LogAudit(ctx, "create", containerID, logging.ActorFromContext(ctx), true)
+	succeeded = true
+
+	if warnings == nil {
+		warnings = []string{}
+	}
+	if warning := docker.LogDriverWarning(config.LogDriver); warning != "" {
+		warnings = append(warnings, warning)
+	}
+	if build.versionWarning != "" {
+		warnings = append(warnings, build.versionWarning)
+	}
+	if nativeWarning != "" {
+		warnings = append(warnings, nativeWarning)
+	}
+	resp := CreateContainerResponse{
+		ContainerID: containerID,
+		Name:        h.logicalName(name),
+		Warnings:    warnings,
+		image:       config.Image,
+		imageID:     imageID,
+	}
+	if req.AutoStart {
+		// The container is kept when it fails to start so its state and logs can be inspected
+		if createErr := h.startContainer(ctx, containerID, req.VerifyRunning); createErr != nil {
+			return resp, createErr
+		}
+		resp.Started = true
+	}
+	return resp, nil
+}
+
+// projectBuild is everything a project's image is built from, resolved from a create request
+type projectBuild struct {
+	appDir         string // Directory holding the app's package.json
+	contextDir     string // Build context: the project, or the monorepo root the app shares dependencies with
+	appSubdir      string // The app's directory within contextDir, "" when they are the same
+	baseImage      string
+	versionWarning string
+	workspace      *nodeproject.Workspace
+	template       *nodeproject.DockerfileTemplate
+	stopSignal     string
+	ports          []PortMapping
+	production     bool
+	useBuildCache  bool
+	loadDotEnv     bool
+	npmRegistry    string
+	npmToken       string
+	platform       string
+}
+
+// resolveProjectBuild validates the project a request builds and resolves how its image is
+// built. It also returns the request's env merged with the app's .env, if that is loaded.
+// Nothing is written, so an unusable project is rejected before any side effect.
+func (h *ContainerHandler) resolveProjectBuild(req CreateContainerRequest) (projectBuild, []string, *createError) {
+	// Resolve the app directory for monorepo builds
+	appDir, err := resolveWorkdir(req.ProjectPath, req.Workdir)
+	if err != nil {
+		return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid workdir", err.Error())
+	}
+
+	// Validate Node.js project structure
+	if err := validateNodeProject(appDir); err != nil {
+		var pkgErr *nodeproject.PackageJSONError
+		if errors.As(err, &pkgErr) {
+			return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Malformed package.json", err.Error())
+		}
+		invalid := apperrors.NewInvalidProjectError(err)
+		return projectBuild{}, nil, &createError{status: invalid.Code, message: "Invalid Node.js project", details: err.Error(), code: invalid.ErrorCode}
+	}
+
+	// Build from the repository root when the app relies on shared root dependencies
+	contextDir, appSubdir := resolveBuildContext(req.ProjectPath, appDir)
+	build := projectBuild{
+		appDir:        appDir,
+		contextDir:    contextDir,
+		appSubdir:     appSubdir,
+		useBuildCache: req.UseBuildCache,
+		loadDotEnv:    req.LoadDotEnv,
+		npmRegistry:   req.NpmRegistry,
+		npmToken:      req.NpmToken,
+		platform:      strings.ToLower(req.Platform),
+	}
+
+	if err := validateNpmRegistry(req.NpmRegistry, req.NpmToken); err != nil {
+		return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid npm registry configuration", err.Error())
+	}
+
+	// The signal is baked into the Dockerfile as well, so it is checked before anything is written
+	if req.StopSignal != "" {
+		build.stopSignal, err = docker.NormalizeStopSignal(req.StopSignal)
+		if err != nil {
+			return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid stop signal", err.Error())
+		}
+	}
+
+	// Ports are exposed in the Dockerfile as well, so they are checked before anything is written
+	build.ports, err = resolvePorts(req.Ports)
+	if err != nil {
+		return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid ports", err.Error())
+	}
+
+	if req.DockerfileTemplate != "" {
+		build.template, err = nodeproject.ParseDockerfileTemplate(req.DockerfileTemplate)
+		if err != nil {
+			return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid Dockerfile template", err.Error())
+		}
+	}
+
+	// The app's .env is passed at runtime rather than copied into the image
+	env := req.Env
+	if req.LoadDotEnv {
+		dotEnv, err := nodeproject.LoadDotEnv(appDir)
+		if err != nil {
+			return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid .env file", err.Error())
+		}
+		env = mergeEnv(dotEnv, req.Env)
+	}
+
+	// Production installs are generated for apps that are their own build context. NODE_ENV
+	// alone leaves monorepo apps on their usual Dockerfile, but an explicit request is rejected.
+	build.production = req.ProductionBuild || nodeproject.IsProductionEnv(env)
+	if build.production && appSubdir != "" {
+		if req.ProductionBuild {
+			return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid production build", "productionBuild is not supported for monorepo apps that share the root's dependencies")
+		}
+		build.production = false
+	}
+
+	// Workspace roots install once for all packages, so the Dockerfile must target the package
+	if appSubdir != "" {
+		build.workspace, err = nodeproject.DetectWorkspace(contextDir)
+		if err != nil {
+			return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid workspace configuration", err.Error())
+		}
+		if build.workspace != nil && !build.workspace.Includes(appSubdir) {
+			build.workspace = nil
+		}
+	}
+
+	build.baseImage, build.versionWarning, err = nodeBaseImage(appDir, contextDir, req.Alpine)
+	if err != nil {
+		return projectBuild{}, nil, newCreateError(http.StatusBadRequest, "Invalid Node.js version", err.Error())
+	}
+	return build, env, nil
+}
+
+// buildProjectImage writes the project's Dockerfile and .dockerignore, pulls the base image and
+// builds the project into an image tagged tag. It returns the image ID, the warning about build
+// tools added for native modules, if any, and the files it generated in the project. Those are
+// returned even when the build fails, for the caller to remove if the image goes unused.
+func (h *ContainerHandler) buildProjectImage(ctx context.Context, build projectBuild, tag string, pullPolicy docker.PullPolicy) (string, string, []string, *createError) {
+	builds.ReportProgress(ctx, "generating build files")
+	var generated []string
+
+	npmSecret := build.npmRegistry != ""
+
+	// The build labels the image as managed and records its provenance. The Dockerfile carries
+	// the same labels, so images built from it elsewhere are recognised too.
+	builtAt := time.Now()
+	imageLabels := docker.ImageBuildLabels(build.contextDir, builtAt)
+	maps.Copy(imageLabels, nodeproject.NewProjectHandler(filepath.Join(build.contextDir, build.appSubdir), nil).BuildMetadataLabels(builtAt))
+
+	// Concurrent builds of the project would overwrite each other's Dockerfile, and a build could
+	// send the other's, so the project is held from writing it until the build has read it
+	unlock := h.projectLocks.lock(build.contextDir)
+	defer unlock()
+
+	// Create Dockerfile in the project directory
+	created, nativeWarning, err := createDockerfile(build.contextDir, build.appSubdir, build.baseImage, build.workspace, npmSecret, build.production, build.useBuildCache, build.stopSignal, build.ports, build.template, imageLabels)
+	if err != nil {
+		return "", "", generated, newCreateError(http.StatusInternalServerError, "Failed to create Dockerfile", err.Error())
+	}
+	if created {
+		generated = append(generated, "Dockerfile")
+	}
+
+	// Keep node_modules and VCS data out of the build context unless the user has their own rules
+	dockerignore := defaultDockerignore
+	if build.loadDotEnv {
+		dockerignore += nodeproject.DotEnvFile + "\n"
+	}
+	created, err = writeFileIfMissing(filepath.Join(build.contextDir, ".dockerignore"), []byte(dockerignore))
+	if err != nil {
+		return "", "", generated, newCreateError(http.StatusInternalServerError, "Failed to create .dockerignore", err.Error())
+	}
+	if created {
+		generated = append(generated, ".dockerignore")
+	}
+
+	builds.ReportProgress(ctx, "preparing image")
+	if _, err := h.dockerClient.EnsureImage(ctx, build.baseImage, pullPolicy, build.platform); err != nil {
+		if docker.IsImageNotFoundError(err) {
+			return "", "", generated, dockerCreateError(http.StatusBadRequest, "Image not available", err)
+		}
+		return "", "", generated, dockerCreateError(http.StatusInternalServerError, "Failed to pull image", err)
+	}
+
+	// The container runs the project built from the Dockerfile written above. Registry
+	// credentials are only needed while dependencies install, so the .npmrc is staged outside
+	// the project, mounted as a BuildKit secret and removed with the staging directory.
+	builds.ReportProgress(ctx, "building image")
+	var imageID string
+	err = h.dockerClient.StageBuildFiles(ctx, func(dir string) error {
+		opts := docker.BuildOptions{
+			Tag:      tag,
+			Labels:   imageLabels,
+			Platform: build.platform,
+		}
+		if npmSecret {
+			npmrc, err := writeNpmrcSecret(dir, build.npmRegistry, build.npmToken)
+			if err != nil {
+				return fmt.Errorf("failed to create .npmrc: %w", err)
+			}
+			opts.Secrets = map[string]string{npmrcSecretID: npmrc}
+		}
+		var err error
+		imageID, err = h.dockerClient.BuildImage(ctx, build.contextDir, opts)
+		return err
+	})
+	if err != nil {
+		return "", "", generated, dockerCreateError(http.StatusInternalServerError, "Failed to build image", err)
+	}
+	return imageID, nativeWarning, generated, nil
+}
+
+// projectLocks hands out a lock per project directory. Locks are dropped once nobody holds or
+// waits for them, so the map does not grow with every project ever built.
+type projectLocks struct {
+	mu    sync.Mutex
+	locks map[string]*projectLock
+}
+
+// projectLock is the lock of one project directory and the number of callers holding or
+// waiting for it
+type projectLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lock blocks until dir is free, holds it and returns the function that releases it
+func (l *projectLocks) lock(dir string) func() {
+	dir = filepath.Clean(dir)
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*projectLock)
+	}
+	pl, ok := l.locks[dir]
+	if !ok {
+		pl = &projectLock{}
+		l.locks[dir] = pl
+	}
+	pl.refs++
+	l.mu.Unlock()
+
+	pl.mu.Lock()
+	return func() {
+		pl.mu.Unlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if pl.refs--; pl.refs == 0 {
+			delete(l.locks, dir)
+		}
+	}
+}
+
+// removeGeneratedArtifacts removes the files a failed create generated in the project, once
+// no build of the project is reading them
+func (h *ContainerHandler) removeGeneratedArtifacts(ctx context.Context, projectPath string, generated []string) {
+	if len(generated) == 0 {
+		return
+	}
+	unlock := h.projectLocks.lock(projectPath)
+	defer unlock()
+	if err := cleanupGeneratedArtifacts(projectPath, generated); err != nil {
+		logging.LogError(ctx, "failed to clean up generated artifacts", err, zap.String("project_path", projectPath))
+	}
+}
+
+// buildSpec records the request settings a container's image was built with, so rebuild-all
+// can build it again the same way. The npm token is never recorded.
+type buildSpec struct {
+	Workdir            string        `json:"workdir,omitempty"`
+	Alpine             bool          `json:"alpine,omitempty"`
+	ProductionBuild    bool          `json:"productionBuild,omitempty"`
+	UseBuildCache      bool          `json:"useBuildCache,omitempty"`
+	LoadDotEnv         bool          `json:"loadDotEnv,omitempty"`
+	StopSignal         string        `json:"stopSignal,omitempty"`
+	Ports              []PortMapping `json:"ports,omitempty"`
+	NpmRegistry        string        `json:"npmRegistry,omitempty"`
+	DockerfileTemplate string        `json:"dockerfileTemplate,omitempty"`
+	Platform           string        `json:"platform,omitempty"`
+	// NpmToken records only that the registry needed a token, which rebuilds cannot supply
+	NpmToken bool `json:"npmToken,omitempty"`
+}
+
+// newBuildSpec records how req was built. Production is taken from the resolved build, as the
+// env that may have implied it is not recorded.
+func newBuildSpec(req CreateContainerRequest, build projectBuild) buildSpec {
+	return buildSpec{
+		Workdir:            req.Workdir,
+		Alpine:             req.Alpine,
+		ProductionBuild:    build.production,
+		UseBuildCache:      req.UseBuildCache,
+		LoadDotEnv:         req.LoadDotEnv,
+		StopSignal:         req.StopSignal,
+		Ports:              req.Ports,
+		NpmRegistry:        req.NpmRegistry,
+		DockerfileTemplate: req.DockerfileTemplate,
+		Platform:           req.Platform,
+		NpmToken:           req.NpmToken != "",
+	}
+}
+
+// request returns a create request that builds the project at projectPath as spec records
+func (spec buildSpec) request(projectPath string) CreateContainerRequest {
+	return CreateContainerRequest{
+		ProjectPath:        projectPath,
+		Workdir:            spec.Workdir,
+		Alpine:             spec.Alpine,
+		ProductionBuild:    spec.ProductionBuild,
+		UseBuildCache:      spec.UseBuildCache,
+		LoadDotEnv:         spec.LoadDotEnv,
+		StopSignal:         spec.StopSignal,
+		Ports:              spec.Ports,
+		NpmRegistry:        spec.NpmRegistry,
+		DockerfileTemplate: spec.DockerfileTemplate,
+		Platform:           spec.Platform,
+	}
+}
+
+// replaceManagedContainer removes the container holding name so a replace create can take it.
+// Containers this service does not manage are never removed; their name stays a conflict.
+func (h *ContainerHandler) replaceManagedContainer(ctx context.Context, name string) *createError {
+	existing, err := h.dockerClient.InspectContainerRaw(ctx, name)
+	if docker.IsContainerNotFoundError(err) {
+		// Removed since the create failed, so the name is free again
+		return nil
+	}
+	if err != nil {
+		return dockerCreateError(http.StatusInternalServerError, "Failed to inspect existing container", err)
+	}
+	if existing.Config == nil || existing.Config.Labels[docker.ManagedByLabel] != docker.ManagedByValue {
+		return newCreateError(http.StatusConflict, "Container name already in use", fmt.Sprintf("container %s is not managed by this service and is never replaced", h.logicalName(name)))
+	}
+
+	logging.GetLogger(ctx).Info("replacing existing container",
+		zap.String("container_id", existing.ID),
+		zap.String("container_name", name),
+	)
+	if err := h.removeContainer(ctx, existing.ID, true); err != nil {
+		logging.LogAudit(ctx, "delete", existing.ID, logging.ActorFromContext(ctx), false)
+		return dockerCreateError(http.StatusInternalServerError, "Failed to replace container", err)
+	}
+	logging.LogAudit(ctx, "delete", existing.ID, logging.ActorFromContext(ctx), true)
+	return nil
+}
+
+// startContainer starts a created container and, with verify, checks it is still running after
+// a grace period. A container that crashed on boot yields 422 with the tail of its logs.
+func (h *ContainerHandler) startContainer(ctx context.Context, containerID string, verify bool) *createError {
+	builds.ReportProgress(ctx, "starting container")
+	if err := h.dockerClient.StartContainer(ctx, containerID); err != nil {
+		logging.LogAudit(ctx, "start", containerID, logging.ActorFromContext(ctx), false)
+		createErr := dockerCreateError(http.StatusInternalServerError, "Failed to start container", err)
+		createErr.details = fmt.Sprintf("container %s: %v", containerID, err)
+		return createErr
+	}
+	logging.LogAudit(ctx, "start", containerID, logging.ActorFromContext(ctx), true)
+	if !verify {
+		return nil
+	}
+
+	err := h.dockerClient.VerifyRunning(ctx, containerID, verifyRunningGrace)
+	var exitErr *docker.ExitError
+	if errors.As(err, &exitErr) {
+		details := exitErr.Error()
+		if logs, logErr := h.dockerClient.GetContainerLogs(ctx, containerID, docker.LogOptions{Tail: crashLogTail}); logErr == nil || errors.Is(logErr, docker.ErrPartialLogs) {
+			details += "\n" + logs
+		} else {
+			logging.LogError(ctx, "failed to fetch logs of exited container", logErr, zap.String("container_id", containerID))
+		}
+		createErr := dockerCreateError(http.StatusUnprocessableEntity, "Container exited after start", err)
+		createErr.details = details
+		return createErr
+	}
+	if err != nil {
+		return dockerCreateError(http.StatusInternalServerError, "Failed to verify container is running", err)
+	}
+	return nil
+}
+
+// BatchCreateResult reports the outcome of one container in a batch create
+type BatchCreateResult struct {
+	Index       int      `json:"index" description:"Position of the request in the batch"`
+	Name        string   `json:"name,omitempty"`
+	ContainerID string   `json:"containerId,omitempty"`
+	Status      int      `json:"status" description:"HTTP status the item would have got as a single create"`
+	Started     bool     `json:"started,omitempty"`
+	RolledBack  bool     `json:"rolledBack,omitempty" description:"Set when the container was removed because another item failed"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Details     string   `json:"details,omitempty"`
+	ErrorCode   string   `json:"error_code,omitempty" description:"Stable error code, e.g. IMAGE_NOT_FOUND"`
+}
+
+// BatchCreateResponse summarizes a batch create
+type BatchCreateResponse struct {
+	Results    []BatchCreateResult `json:"results"`
+	Created    int                 `json:"created"`
+	Failed     int                 `json:"failed"`
+	RolledBack bool                `json:"rolledBack"`
+}
+
+// @Summary Create several containers
+// @Description Creates containers in the order given, continuing past failures, and optionally starts each one.
+// @Description Items listed in another item's dependsOn are created first, and when started must be ready (healthy, or running without a health check) before their dependents are created.
+// @Description With rollbackOnError=true, every container created by the batch is removed again if any item fails.
+// @Tags containers
+// @Accept json
+// @Produce json
+// @Param request body []CreateContainerRequest true "Containers to create"
+// @Param start query bool false "Start each container after it is created"
+// @Param rollbackOnError query bool false "Remove the batch's containers if any item fails"
+// @Param onConflict query string false "Applies to every item: fail (409; also error), suffix or replace; defaults to container.onNameConflict"
+// @Success 201 {object} BatchCreateResponse "Every container was created"
+// @Success 207 {object} BatchCreateResponse "At least one item failed; see each result's status"
+// @Failure 400 {object} ErrorResponse "Invalid request, or unknown or cyclic dependsOn"
+// @Router /containers/batch [post]
+func (h *ContainerHandler) BatchCreateContainers(w http.ResponseWriter, r *http.Request) {
+	var reqs []CreateContainerRequest
+	if err := json.NewDecoder(r.Body).Decode(&reqs); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if len(reqs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", "at least one container is required")
+		return
+	}
+
+	query := r.URL.Query()
+	onConflict, err := parseOnConflict(query.Get("onConflict"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid onConflict", err.Error())
+		return
+	}
+	var start, rollbackOnError bool
+	for _, flag := range []struct {
+		param string
+		value *bool
+	}{{"start", &start}, {"rollbackOnError", &rollbackOnError}} {
+		if raw := query.Get(flag.param); raw != "" {
+			parsed, err := strconv.ParseBool(raw)
+			if err != nil {
+				respondWithError(w, http.StatusBadRequest, "Invalid "+flag.param+" parameter", flag.param+" must be true or false")
+				return
+			}
+			*flag.value = parsed
+		}
+	}
+
+	order, err := batchOrder(reqs)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid dependsOn", err.Error())
+		return
+	}
+
+	// Each image is built, and dependencies can take up to dependencyReadyTimeout each to
+	// become healthy
+	clearWriteDeadline(w)
+
+	ctx := r.Context()
+	response := BatchCreateResponse{Results: make([]BatchCreateResult, len(reqs))}
+	ready := make(map[int]bool, len(reqs))
+	for _, i := range order {
+		req := reqs[i]
+		result := BatchCreateResult{Index: i, Name: req.Name}
+		if depErr := h.awaitDependencies(ctx, reqs, response.Results, ready, i); depErr != nil {
+			result.Status = depErr.status
+			result.Error = depErr.message
+			result.Details = depErr.details
+			response.Failed++
+			response.Results[i] = result
+			continue
+		}
+		created, createErr := h.createContainer(ctx, req, onConflict)
+		// A container that failed to start still exists, so it is reported and can be rolled back
+		if created.ContainerID != "" {
+			result.ContainerID = created.ContainerID
+			result.Name = created.Name
+			result.Warnings = created.Warnings
+			result.Started = created.Started
+		}
+		if createErr == nil {
+			result.Status = http.StatusCreated
+			if start && !created.Started {
+				createErr = h.startContainer(ctx, created.ContainerID, req.VerifyRunning)
+				result.Started = createErr == nil
+			}
+		}
+		if createErr != nil {
+			result.Status = createErr.status
+			result.Error = createErr.message
+			result.Details = createErr.details
+			result.ErrorCode = string(createErr.code)
+			response.Failed++
+		} else {
+			response.Created++
+		}
+		response.Results[i] = result
+	}
+
+	if rollbackOnError && response.Failed > 0 {
+		h.rollbackBatch(ctx, &response)
+	}
+
+	status := http.StatusCreated
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	respondWithJSON(w, status, response)
+}
+
+// dependencyReadyTimeout bounds how long a batch item waits for each started dependency to become
+// ready. It is a variable so tests can shorten it.
+var dependencyReadyTimeout = 2 * time.Minute
+
+// batchOrder returns the order to create a batch in: the given order, except that every item
+// comes after the items named in its dependsOn. Unknown or ambiguous names and cycles are errors.
+func batchOrder(reqs []CreateContainerRequest) ([]int, error) {
+	byName := make(map[string]int, len(reqs))
+	duplicates := make(map[string]bool)
+	for i, req := range reqs {
+		if _, ok := byName[req.Name]; ok {
+			duplicates[req.Name] = true
+		}
+		byName[req.Name] = i
+	}
+
+	deps := make([][]int, len(reqs))
+	for i, req := range reqs {
+		for _, name := range req.DependsOn {
+			j, ok := byName[name]
+			switch {
+			case name == "" || !ok:
+				return nil, fmt.Errorf("item %d depends on %q, which is not in the batch", i, name)
+			case duplicates[name]:
+				return nil, fmt.Errorf("item %d depends on %q, which names more than one item in the batch", i, name)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(reqs))
+	order := make([]int, 0, len(reqs))
+	var path []int
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case done:
+			return nil
+		case visiting:
+			// The path from the first occurrence of i back to i is the cycle
+			var names []string
+			for k := len(path) - 1; k >= 0; k-- {
+				names = append([]string{reqs[path[k]].Name}, names...)
+				if path[k] == i {
+					break
+				}
+			}
+			return fmt.Errorf("dependency cycle: %s -> %s", strings.Join(names, " -> "), reqs[i].Name)
+		}
+		state[i] = visiting
+		path = append(path, i)
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = done
+		order = append(order, i)
+		return nil
+	}
+	for i := range reqs {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// awaitDependencies checks that item i's dependencies were created and waits for the started
+// ones to become ready, remembering in ready which ones already are. A dependency that failed or
+// never became ready fails the item with 424 before anything is created for it.
+func (h *ContainerHandler) awaitDependencies(ctx context.Context, reqs []CreateContainerRequest, results []BatchCreateResult, ready map[int]bool, i int) *createError {
+	for _, name := range reqs[i].DependsOn {
+		j := slices.IndexFunc(reqs, func(req CreateContainerRequest) bool { return req.Name == name })
+		dep := results[j]
+		if dep.Error != "" {
+			return newCreateError(http.StatusFailedDependency, "Dependency failed", fmt.Sprintf("%s: %s", name, dep.Error))
+		}
+		if !dep.Started || ready[j] {
+			continue
+		}
+		builds.ReportProgress(ctx, "waiting for "+name)
+		if err := h.dockerClient.WaitForHealthy(ctx, dep.ContainerID, dependencyReadyTimeout); err != nil {
+			return newCreateError(http.StatusFailedDependency, "Dependency not ready", fmt.Sprintf("%s: %v", name, err))
+		}
+		ready[j] = true
+	}
+	return nil
+}
+
+// rollbackBatch force-removes every container the batch created, including ones created but
+// not started. Removal failures are kept in the item's details rather than failing the batch.
+func (h *ContainerHandler) rollbackBatch(ctx context.Context, response *BatchCreateResponse) {
+	response.RolledBack = true
+	for i := range response.Results {
+		result := &response.Results[i]
+		if result.ContainerID == "" {
+			continue
+		}
+		err := h.removeContainer(ctx, result.ContainerID, true)
+		logging.LogAudit(ctx, "delete", result.ContainerID, logging.ActorFromContext(ctx), err == nil)
+		if err != nil {
+			logging.LogError(ctx, "failed to roll back batch container", err, zap.String("container_id", result.ContainerID))
+			result.Details = fmt.Sprintf("rollback failed: %v", err)
+			continue
+		}
+		if result.Error == "" {
+			response.Created--
+		}
+		result.RolledBack = true
+		result.Started = false
+	}
+}
+
+const (
+	// onConflictFail rejects a create whose name is taken with 409, the default
+	onConflictFail = "fail"
+	// onConflictError is another name for onConflictFail
+	onConflictError = "error"
+	// onConflictSuffix retries a create whose name is taken as <name>-2, <name>-3 and so on
+	onConflictSuffix = "suffix"
+	// onConflictReplace removes the container holding the name, if this service manages it, and
+	// creates again
+	onConflictReplace = "replace"
+)
+
+// parseOnConflict validates an onConflict value and returns its canonical form. An empty value
+// is returned as is, so the configured default applies.
+func parseOnConflict(value string) (string, error) {
+	switch value {
+	case "", onConflictFail, onConflictSuffix, onConflictReplace:
+		return value, nil
+	case onConflictError:
+		return onConflictFail, nil
+	}
+	return "", fmt.Errorf("onConflict must be 'fail', 'error', 'suffix' or 'replace', got %q", value)
+}
+
+const (
+	// maxConflictRetries bounds how often a suffixed create is retried when it races another
+	maxConflictRetries = 3
+	// maxNameSuffix bounds the search for a free suffixed container name
+	maxNameSuffix = 1000
+)
+
+// logicalName strips the configured name prefix, keeping Docker's leading slash if present,
+// so responses show the name the user asked for
+func (h *ContainerHandler) logicalName(name string) string {
+	prefix := h.defaults.NamePrefix
+	if prefix == "" {
+		return name
+	}
+	slash := ""
+	if strings.HasPrefix(name, "/") {
+		slash = "/"
+	}
+	logical, ok := strings.CutPrefix(strings.TrimPrefix(name, "/"), prefix)
+	if !ok || logical == "" {
+		return name
+	}
+	return slash + logical
+}
+
+// dockerName applies the configured prefix to a requested name and checks the result against
+// Docker's naming rules. A name that only becomes too long with the prefix is reported with the
+// length the requested name may have. An empty name lets Docker pick one.
+func (h *ContainerHandler) dockerName(requested string) (string, error) {
+	if requested == "" {
+		return "", nil
+	}
+	prefix := h.defaults.NamePrefix
+	name := prefix + requested
+	if len(name) > docker.MaxContainerNameLength && prefix != "" {
+		return "", fmt.Errorf("%w: name %q is %d characters, but with the configured prefix %q names may be at most %d; choose a shorter name",
+			docker.ErrInvalidConfig, requested, len(requested), prefix, docker.MaxContainerNameLength-len(prefix))
+	}
+	if err := docker.ValidateContainerName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// freeName returns name if no existing container uses it, and otherwise the name
+// generateUniqueName picks in its place
+func (h *ContainerHandler) freeName(ctx context.Context, name string) (string, error) {
+	names, err := h.dockerClient.ContainerNames(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if !slices.Contains(names, name) {
+		return name, nil
+	}
+	return h.generateUniqueName(ctx, name)
+}
+
+// generateUniqueName returns the first of base-2, base-3, ... that no existing container uses
+func (h *ContainerHandler) generateUniqueName(ctx context.Context, base string) (string, error) {
+	names, err := h.dockerClient.ContainerNames(ctx, base)
+	if err != nil {
+		return "", err
+	}
+	taken := make(map[string]bool, len(names))
+	for _, name := range names {
+		taken[name] = true
+	}
+	for i := 2; i <= maxNameSuffix; i++ {
+		candidate := fmt.Sprintf("%s-%d", base, i)
+		if taken[candidate] {
+			continue
+		}
+		if len(candidate) > docker.MaxContainerNameLength {
+			return "", fmt.Errorf("%w: name %q is taken and adding the -%d suffix would exceed %d characters; choose a shorter name",
+				docker.ErrInvalidConfig, h.logicalName(base), i, docker.MaxContainerNameLength)
+		}
+		return candidate, nil
+	}
+	return "", &docker.ClientError{
+		Op:  "create_container",
+		Err: fmt.Errorf("%w: no free name for %q up to suffix %d", docker.ErrContainerAlreadyExists, base, maxNameSuffix),
+	}
+}
+
+// @Summary List all containers
+// @Description Get a list of all containers, optionally filtered by labels and by when they were created
+// @Tags containers
+// @Produce json
+// @Param label query []string false "Label filter as key=value, or key to match any value; may be repeated" collectionFormat(multi)
+// @Param createdAfter query string false "Only containers created after this RFC3339 time"
+// @Param createdBefore query string false "Only containers created before this RFC3339 time"
+// @Success 200 {array} docker.Container
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers [get]
+func (h *ContainerHandler) ListContainers(w http.ResponseWriter, r *http.Request) {
+	labelFilter, err := parseLabelFilters(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid label filter", err.Error())
+		return
+	}
+
+	window, err := parseCreatedWindow(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid creation time window", err.Error())
+		return
+	}
+
+	containers, err := h.dockerClient.ListContainers(r.Context(), true, labelFilter)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to list containers", err)
+		return
+	}
+
+	// Docker can only filter relative to other containers, so the window is applied here
+	filtered := make([]docker.ContainerInfo, 0, len(containers))
+	for _, container := range containers {
+		if window.contains(container.Created) {
+			container.Name = h.logicalName(container.Name)
+			filtered = append(filtered, container)
+		}
+	}
+
+	respondWithJSONETag(w, r, filtered)
+}
+
+// createdWindow bounds container creation times; a zero bound is open
+type createdWindow struct {
+	after  time.Time
+	before time.Time
+}
+
+func (w createdWindow) contains(created time.Time) bool {
+	if !w.after.IsZero() && !created.After(w.after) {
+		return false
+	}
+	return w.before.IsZero() || created.Before(w.before)
+}
+
+// parseCreatedWindow parses the createdAfter and createdBefore RFC3339 query parameters
+func parseCreatedWindow(r *http.Request) (createdWindow, error) {
+	var window createdWindow
+	for _, bound := range []struct {
+		param string
+		value *time.Time
+	}{
+		{"createdAfter", &window.after},
+		{"createdBefore", &window.before},
+	} {
+		value := r.URL.Query().Get(bound.param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return createdWindow{}, fmt.Errorf("%s must be an RFC3339 time such as 2024-05-01T10:00:00Z", bound.param)
+		}
+		*bound.value = parsed
+	}
+	if !window.after.IsZero() && !window.before.IsZero() && !window.after.Before(window.before) {
+		return createdWindow{}, errors.New("createdAfter must be earlier than createdBefore")
+	}
+	return window, nil
+}
+
+// @Summary Find containers by originating request
+// @Description Get the managed containers created by the API request with the given X-Request-ID. A batch create can yield several.
+// @Tags containers
+// @Produce json
+// @Param requestId path string true "X-Request-ID of the create request"
+// @Success 200 {array} docker.ContainerInfo
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/by-request/{requestId} [get]
+func (h *ContainerHandler) GetContainersByRequest(w http.ResponseWriter, r *http.Request) {
+	requestID := mux.Vars(r)["requestId"]
+
+	containers, err := h.dockerClient.ListContainers(r.Context(), true, map[string]string{
+		docker.ManagedByLabel: docker.ManagedByValue,
+		docker.RequestIDLabel: requestID,
+	})
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to list containers", err)
+		return
+	}
+	if len(containers) == 0 {
+		respondWithError(w, http.StatusNotFound, "No containers found for request", requestID)
+		return
+	}
+	for i := range containers {
+		containers[i].Name = h.logicalName(containers[i].Name)
+	}
+
+	respondWithJSON(w, http.StatusOK, containers)
+}
+
+// ContainerGroup counts the containers sharing one value of the groupBy label
+type ContainerGroup struct {
+	Value  string         `json:"value"`
+	Count  int            `json:"count"`
+	States map[string]int `json:"states"`
+}
+
+// ContainerSummaryResponse is the result of grouping managed containers by a label
+type ContainerSummaryResponse struct {
+	GroupBy string           `json:"groupBy"`
+	Total   int              `json:"total"`
+	Groups  []ContainerGroup `json:"groups"`
+}
+
+// labelKeyPattern matches Docker label keys: alphanumerics separated by dots, dashes,
+// underscores or slashes
+var labelKeyPattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._/-]*[a-zA-Z0-9])?$`)
+
+// parseGroupBy parses a groupBy expression of the form label:<key> and returns the key
+func parseGroupBy(expr string) (string, error) {
+	key, ok := strings.CutPrefix(expr, "label:")
+	if !ok {
+		return "", fmt.Errorf("groupBy must have the form label:<key>, got %q", expr)
+	}
+	if !labelKeyPattern.MatchString(key) {
+		return "", fmt.Errorf("invalid label key %q in groupBy", key)
+	}
+	return key, nil
+}
+
+// @Summary Summarize containers by label
+// @Description Group managed containers by the value of a label and count them per state. Containers without the label are grouped under an empty value.
+// @Tags containers
+// @Produce json
+// @Param groupBy query string true "Grouping expression, label:<key>"
+// @Param label query []string false "Label filter as key=value, or key to match any value; may be repeated" collectionFormat(multi)
+// @Success 200 {object} ContainerSummaryResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/summary [get]
+func (h *ContainerHandler) SummarizeContainers(w http.ResponseWriter, r *http.Request) {
+	groupBy := r.URL.Query().Get("groupBy")
+	key, err := parseGroupBy(groupBy)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid groupBy", err.Error())
+		return
+	}
+
+	labelFilter, err := parseLabelFilters(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid label filter", err.Error())
+		return
+	}
+	if labelFilter == nil {
+		labelFilter = make(map[string]string)
+	}
+	labelFilter[docker.ManagedByLabel] = docker.ManagedByValue
+
+	containers, err := h.dockerClient.ListContainers(r.Context(), true, labelFilter)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to list containers", err)
+		return
+	}
+
+	summary := ContainerSummaryResponse{GroupBy: groupBy, Groups: []ContainerGroup{}}
+	index := make(map[string]int)
+	for _, c := range containers {
+		if c.Labels[docker.ManagedByLabel] != docker.ManagedByValue {
+			continue
+		}
+		value := c.Labels[key]
+		i, ok := index[value]
+		if !ok {
+			i = len(summary.Groups)
+			index[value] = i
+			summary.Groups = append(summary.Groups, ContainerGroup{Value: value, States: make(map[string]int)})
+		}
+		summary.Groups[i].Count++
+		summary.Groups[i].States[c.State]++
+		summary.Total++
+	}
+	sort.Slice(summary.Groups, func(i, j int) bool {
+		return summary.Groups[i].Value < summary.Groups[j].Value
+	})
+
+	respondWithJSONETag(w, r, summary)
+}
+
+// @Summary Get container by ID
+// @Description Get detailed information about a container
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container ID"
+// @Success 200 {object} docker.Container
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id} [get]
+func (h *ContainerHandler) GetContainer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	// Try to get all containers first
+	containers, err := h.dockerClient.ListContainers(r.Context(), true, nil)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to list containers", err)
+		return
+	}
+
+	// Find container by either full ID or prefix
+	var targetContainer *docker.ContainerInfo
+	for _, container := range containers {
+		if container.ID == containerID || strings.HasPrefix(container.ID, containerID) {
+			targetContainer = &container
+			break
+		}
+	}
+
+	if targetContainer == nil {
+		respondWithErrorCode(w, http.StatusNotFound, apperrors.CodeContainerNotFound, "Container not found", "")
+		return
+	}
+
+	// Get detailed container info using the full ID
+	container, err := h.dockerClient.GetContainer(r.Context(), targetContainer.ID)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container details", err)
+		return
+	}
+
+	container.Name = h.logicalName(container.Name)
+	respondWithContainerETag(w, r, container)
+}
+
+// describeLogTail is how many log lines the describe view includes
+const describeLogTail = "50"
+
+// ContainerDescription is a triage view of a container. Each section is fetched separately,
+// and one that failed is left out with its error in Errors, keyed by the section name.
+type ContainerDescription struct {
+	Container *docker.ContainerInfo  `json:"container,omitempty"`
+	Logs      *string                `json:"logs,omitempty"`
+	Stats     *docker.ContainerUsage `json:"stats,omitempty"`
+	Errors    map[string]string      `json:"errors,omitempty"`
+}
+
+// @Summary Describe a container
+// @Description Get a container's details, its last 50 log lines and a stats snapshot in one call. Sections that could not be read are left out and explained in errors; a stopped container has no stats.
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container ID"
+// @Success 200 {object} ContainerDescription
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/describe [get]
+func (h *ContainerHandler) DescribeContainer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+	ctx := r.Context()
+
+	var (
+		wg                         sync.WaitGroup
+		info                       *docker.ContainerInfo
+		logs                       string
+		stats                      *docker.ContainerUsage
+		infoErr, logsErr, statsErr error
+	)
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		info, infoErr = h.dockerClient.GetContainer(ctx, containerID)
+	}()
+	go func() {
+		defer wg.Done()
+		logs, logsErr = h.dockerClient.GetContainerLogs(ctx, containerID, docker.LogOptions{Tail: describeLogTail})
+	}()
+	go func() {
+		defer wg.Done()
+		stats, statsErr = h.dockerClient.GetContainerUsage(ctx, containerID)
+	}()
+	wg.Wait()
+
+	if docker.IsContainerNotFoundError(infoErr) {
+		respondWithDockerError(w, http.StatusNotFound, "Container not found", infoErr)
+		return
+	}
+	if infoErr != nil && logsErr != nil && statsErr != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to describe container", infoErr)
+		return
+	}
+
+	description := ContainerDescription{Errors: make(map[string]string)}
+	if infoErr != nil {
+		description.Errors["container"] = infoErr.Error()
+	} else {
+		info.Name = h.logicalName(info.Name)
+		description.Container = info
+		// The daemon reports zeros for a stopped container, which would read as an idle one
+		if info.State != "running" {
+			stats, statsErr = nil, fmt.Errorf("container is %s", info.State)
+		}
+	}
+	if logsErr != nil {
+		description.Errors["logs"] = logsErr.Error()
+	}
+	if logsErr == nil || errors.Is(logsErr, docker.ErrPartialLogs) {
+		description.Logs = &logs
+	}
+	if statsErr != nil {
+		description.Errors["stats"] = statsErr.Error()
+	} else {
+		description.Stats = stats
+	}
+	if len(description.Errors) == 0 {
+		description.Errors = nil
+	}
+
+	respondWithJSON(w, http.StatusOK, description)
+}
+
+// @Summary Inspect a container
+// @Description Get a container's details. With raw=true the unmodified Docker inspect result is returned, including fields the default response leaves out
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container ID"
+// @Param raw query bool false "Return Docker's inspect output unchanged"
+// @Success 200 {object} docker.ContainerInfo
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/inspect [get]
+func (h *ContainerHandler) InspectContainer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	raw := false
+	if value := r.URL.Query().Get("raw"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid raw parameter", "raw must be true or false")
+			return
+		}
+		raw = parsed
+	}
+
+	if raw {
+		inspect, err := h.dockerClient.InspectContainerRaw(r.Context(), containerID)
+		if err != nil {
+			if docker.IsContainerNotFoundError(err) {
+				respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+				return
+			}
+			respondWithDockerError(w, http.StatusInternalServerError, "Failed to inspect container", err)
+			return
+		}
+		respondWithJSONETag(w, r, inspect)
+		return
+	}
+
+	container, err := h.dockerClient.GetContainer(r.Context(), containerID)
+	if err != nil {
+		if docker.IsContainerNotFoundError(err) {
+			respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container details", err)
+		return
+	}
+
+	container.Name = h.logicalName(container.Name)
+	respondWithContainerETag(w, r, container)
+}
+
+// @Summary Get container logs
+// @Description Get logs from a container. With format=json, logs are returned as timestamped entries per stream
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container ID"
+// @Param tail query string false "Number of lines from the end of the logs, or 'all'"
+// @Param since query string false "Only logs since this RFC3339 timestamp, Unix timestamp or relative duration (e.g. 10m)"
+// @Param lastMinutes query int false "Only logs from the last N minutes; cannot be combined with since"
+// @Param format query string false "Response format: text (default) or json"
+// @Param grep query string false "Only return lines matching this regular expression"
+// @Param invert query bool false "Return lines that do not match grep instead"
+// @Param stream query string false "Only return this stream: stdout or stderr (default both)"
+// @Success 200 {object} map[string]interface{} "Container logs, with truncated set when tail=all could not return logs lost to rotation, and warning set when the log stream broke off part way"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/logs [get]
+func (h *ContainerHandler) GetContainerLogs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	opts := parseLogOptions(r)
+
+	if err := parseLogWindow(r, &opts, time.Now()); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid lastMinutes", err.Error())
+		return
+	}
+	if err := parseLogFilter(r, &opts); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid grep pattern", err.Error())
+		return
+	}
+	if err := parseLogStream(r, &opts); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid stream", err.Error())
+		return
+	}
+
+	switch r.URL.Query().Get("format") {
+	case "", "text":
+	case "json":
+		entries, err := h.dockerClient.GetContainerLogEntries(r.Context(), containerID, opts)
+		if err != nil && !errors.Is(err, docker.ErrPartialLogs) {
+			respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container logs", err)
+			return
+		}
+		response := map[string]interface{}{"logs": entries}
+		if err != nil {
+			logging.LogError(r.Context(), "container log stream ended early", err, zap.String("container_id", containerID))
+			response["warning"] = err.Error()
+		}
+		if h.logsTruncated(r, containerID, opts) {
+			response["truncated"] = true
+		}
+		respondWithJSON(w, http.StatusOK, response)
+		return
+	default:
+		respondWithError(w, http.StatusBadRequest, "Invalid format", "format must be 'text' or 'json'")
+		return
+	}
+
+	logs, err := h.dockerClient.GetContainerLogs(r.Context(), containerID, opts)
+	if err != nil && !errors.Is(err, docker.ErrPartialLogs) {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container logs", err)
+		return
+	}
+
+	response := map[string]interface{}{"logs": logs}
+	if err != nil {
+		logging.LogError(r.Context(), "container log stream ended early", err, zap.String("container_id", containerID))
+		response["warning"] = err.Error()
+	}
+	if h.logsTruncated(r, containerID, opts) {
+		response["truncated"] = true
+	}
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// logsTruncated reports whether a request for the full log history got less than the
+// container wrote because the log driver rotated older segments away. A failed check is
+// logged and treated as not truncated so it never fails the logs request itself.
+func (h *ContainerHandler) logsTruncated(r *http.Request, containerID string, opts docker.LogOptions) bool {
+	if opts.Tail != "all" || opts.Since != "" {
+		return false
+	}
+	truncated, err := h.dockerClient.LogsTruncated(r.Context(), containerID)
+	if err != nil {
+		logging.LogError(r.Context(), "failed to check log rotation", err, zap.String("container_id", containerID))
+		return false
+	}
+	return truncated
+}
+
+// @Summary Attach to a container
+// @Description Open a websocket to a running container's stdin, stdout and stderr for interactive debugging, e.g. a Node.js REPL.
+// @Description Messages from the client are written to stdin and output is sent back as binary messages. Closing the socket detaches without stopping the container.
+// @Tags containers
+// @Param id path string true "Container ID"
+// @Success 101 {string} string "Switching Protocols"
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse "Upgrade started by a page on another origin"
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/attach [get]
+func (h *ContainerHandler) AttachContainer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		respondWithError(w, http.StatusBadRequest, "Websocket upgrade required", "connect with a websocket client")
+		return
+	}
+	if _, ok := w.(http.Hijacker); !ok {
+		respondWithError(w, http.StatusInternalServerError, "Websocket not supported", "the connection cannot be upgraded")
+		return
+	}
+	// Checked before attaching too, so a refused page gets an error response and no audit record
+	if err := checkSameOrigin(nil, r); err != nil {
+		respondWithError(w, http.StatusForbidden, "Cross-origin websocket refused", err.Error())
+		return
+	}
+
+	session, err := h.dockerClient.AttachContainer(r.Context(), containerID)
+	if err != nil {
+		switch {
+		case docker.IsContainerNotFoundError(err):
+			respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+		case docker.IsContainerNotRunningError(err):
+			respondWithDockerError(w, http.StatusConflict, "Container is not running", err)
+		default:
+			respondWithDockerError(w, http.StatusInternalServerError, "Failed to attach to container", err)
+		}
+		return
+	}
+	// Detach on every path, including a failed websocket handshake
+	defer session.Close()
+	logging.LogAudit(r.Context(), "attach", containerID, logging.ActorFromContext(r.Context()), true)
+
+	websocket.Server{Handshake: checkSameOrigin, Handler: func(ws *websocket.Conn) {
+		ws.PayloadType = websocket.BinaryFrame
+		// The hijacked connection keeps the server's deadlines, which would end the session
+		ws.SetDeadline(time.Time{})
+
+		go func() {
+			// The socket closing ends stdin and detaches, which in turn ends the output copy
+			io.Copy(session.Stdin(), ws)
+			session.Close()
+		}()
+
+		if err := session.CopyOutput(ws); err != nil && !errors.Is(err, net.ErrClosed) {
+			logging.LogError(r.Context(), "container attach stream failed", err, zap.String("container_id", containerID))
+		}
+		ws.Close()
+	}}.ServeHTTP(w, r)
+}
+
+// checkSameOrigin refuses websocket upgrades that a web page on another origin started, which
+// would otherwise let any page the operator opens drive a container's stdin. Browsers always
+// send Origin, so requests without one come from other clients and are allowed.
+func checkSameOrigin(_ *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || !strings.EqualFold(u.Host, r.Host) {
+		return fmt.Errorf("origin %s does not match host %s", origin, r.Host)
+	}
+	return nil
+}
+
+// @Summary Export container as docker-compose
+// @Description Reconstruct the container's configuration as a docker-compose.yml service, with secret-looking environment values redacted
+// @Tags containers
+// @Produce plain
+// @Param id path string true "Container ID"
+// @Success 200 {string} string "docker-compose.yml"
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/compose [get]
+func (h *ContainerHandler) ExportCompose(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	compose, err := h.dockerClient.ExportCompose(r.Context(), containerID)
+	if err != nil {
+		if docker.IsContainerNotFoundError(err) {
+			respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to export container", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(compose)
+}
+
+// @Summary Get container environment
+// @Description Get the container's environment variables by name. Values of secret-looking names are redacted unless reveal=true, which requires an authenticated caller and is audited.
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container ID"
+// @Param reveal query bool false "Show secret-looking values"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/env [get]
+func (h *ContainerHandler) GetContainerEnv(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	reveal := false
+	if value := r.URL.Query().Get("reveal"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid reveal parameter", "reveal must be true or false")
+			return
+		}
+		reveal = parsed
+	}
+	actor := logging.ActorFromContext(r.Context())
+	if reveal && actor == logging.AnonymousActor {
+		respondWithError(w, http.StatusUnauthorized, "Authentication required", "revealing secret values requires an authenticated caller")
+		return
+	}
+
+	env, err := h.dockerClient.ContainerEnv(r.Context(), containerID, reveal)
+	if err != nil {
+		if docker.IsContainerNotFoundError(err) {
+			respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to inspect container", err)
+		return
+	}
+	if reveal {
+		logging.LogAudit(r.Context(), "reveal_env", containerID, actor, true)
+	}
+
+	respondWithJSON(w, http.StatusOK, env)
+}
+
+// @Summary Download container logs
+// @Description Stream the full container logs as a plain-text attachment, each line prefixed with its stream
+// @Tags containers
+// @Produce plain
+// @Param id path string true "Container ID"
+// @Param tail query string false "Number of lines from the end of the logs, or 'all'"
+// @Param since query string false "Only logs since this RFC3339 timestamp, Unix timestamp or relative duration (e.g. 10m)"
+// @Param lastMinutes query int false "Only logs from the last N minutes; cannot be combined with since"
+// @Success 200 {string} string "Container logs"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/logs/download [get]
+func (h *ContainerHandler) DownloadContainerLogs(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	opts := parseLogOptions(r)
+	if err := parseLogWindow(r, &opts, time.Now()); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid lastMinutes", err.Error())
+		return
+	}
+
+	container, err := h.dockerClient.GetContainer(r.Context(), containerID)
+	if err != nil {
+		if docker.IsContainerNotFoundError(err) {
+			respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container details", err)
+		return
+	}
+
+	logs, err := h.dockerClient.OpenContainerLogs(r.Context(), container.ID, opts)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container logs", err)
+		return
+	}
+	defer logs.Close()
+
+	clearWriteDeadline(w)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", logFileName(h.logicalName(container.Name))))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are already sent, so a mid-stream failure can only be logged
+	if err := docker.CopyLogsWithMarkers(w, logs); err != nil {
+		logging.LogError(r.Context(), "failed to stream container logs", err, zap.String("container_id", container.ID))
+	}
+}
+
+// @Summary Delete a container
+// @Description Delete a container by ID. With graceful=true the container is first stopped like docker stop, giving the app timeout to shut down, instead of being killed by a forced removal.
+// @Tags containers
+// @Produce json
+// @Param id path string true "Container ID"
+// @Param force query bool false "Kill and remove a running container"
+// @Param graceful query bool false "Stop the container before removing it"
+// @Param timeout query string false "Grace period for graceful deletes, in seconds or as a duration such as 30s (defaults to the container's stopTimeout, else the configured stop timeout)"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id} [delete]
+func (h *ContainerHandler) DeleteContainer(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	force := r.URL.Query().Get("force") == "true"
+	graceful := r.URL.Query().Get("graceful") == "true"
+
+	timeout, err := parseStopTimeout(r.URL.Query().Get("timeout"), h.defaults.StopTimeout)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid timeout", err.Error())
+		return
+	}
+	if r.URL.Query().Has("timeout") && !graceful {
+		respondWithError(w, http.StatusBadRequest, "Invalid timeout", "timeout only applies to graceful deletes")
+		return
+	}
+
+	// The secrets label is read first: stopping an auto-remove container already deletes it
+	secretsDir := h.containerSecretsDir(r.Context(), containerID)
+
+	// Stopping an exited container succeeds, so a graceful delete works whatever the state
+	if graceful {
+		// The grace period can outlast the WriteTimeout, which would drop the response
+		clearWriteDeadline(w)
+		if !r.URL.Query().Has("timeout") {
+			timeout, err = h.dockerClient.StopTimeout(r.Context(), containerID, timeout)
+		}
+		if err == nil {
+			err = h.dockerClient.StopContainer(r.Context(), containerID, timeout)
+		}
+		if err != nil {
+			logging.LogAudit(r.Context(), "delete", containerID, logging.ActorFromContext(r.Context()), false)
+			if docker.IsContainerNotFoundError(err) {
+				respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+				return
+			}
+			respondWithDockerError(w, http.StatusInternalServerError, "Failed to stop container", err)
+			return
+		}
+	}
+
+	err = h.dockerClient.RemoveContainer(r.Context(), containerID, force)
+	// An auto-remove container is deleted by the daemon once stopped, which completes the delete
+	if graceful && (docker.IsContainerNotFoundError(err) || docker.IsRemovalInProgressError(err)) {
+		err = nil
+	}
+	if err != nil {
+		logging.LogAudit(r.Context(), "delete", containerID, logging.ActorFromContext(r.Context()), false)
+		if docker.IsContainerNotFoundError(err) {
+			respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to remove container", err)
+		return
+	}
+	h.removeSecrets(r.Context(), containerID, secretsDir)
+	logging.LogAudit(r.Context(), "delete", containerID, logging.ActorFromContext(r.Context()), true)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateLabelsRequest represents the request body for updating container labels
+type UpdateLabelsRequest struct {
+	Labels map[string]string `json:"labels" binding:"required" description:"Labels to add or update"`
+}
+
+// @Summary Update container labels
+// @Description Add or update labels. Docker cannot change labels in place, so the container is recreated with the same configuration and name; the response carries the new container ID.
+// @Tags containers
+// @Accept json
+// @Produce json
+// @Param id path string true "Container ID"
+// @Param request body UpdateLabelsRequest true "Labels to merge"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/labels [post]
+func (h *ContainerHandler) UpdateContainerLabels(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	var req UpdateLabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if len(req.Labels) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", "labels must not be empty")
+		return
+	}
+	for _, key := range serviceLabels {
+		if _, ok := req.Labels[key]; ok {
+			respondWithError(w, http.StatusBadRequest, "Invalid labels", fmt.Sprintf("label %s is set by this service and cannot be changed", key))
+			return
+		}
+	}
+
+	container, err := h.dockerClient.GetContainer(r.Context(), containerID)
+	if err != nil {
+		if docker.IsContainerNotFoundError(err) {
+			respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container details", err)
+		return
+	}
+	if container.Labels[docker.ManagedByLabel] != docker.ManagedByValue {
+		respondWithError(w, http.StatusForbidden, "Container is not managed by this service", "")
+		return
+	}
+
+	// Stopping the container for the recreate can outlast the WriteTimeout, which would drop
+	// the response after the container was already replaced
+	clearWriteDeadline(w)
+	timeout, err := h.dockerClient.StopTimeout(r.Context(), container.ID, h.defaults.StopTimeout)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container details", err)
+		return
+	}
+
+	labels := mergeLabels(container.Labels, req.Labels)
+	newID, err := h.dockerClient.RecreateWithLabels(r.Context(), container.ID, labels, timeout)
+	if err != nil {
+		logging.LogAudit(r.Context(), "update_labels", container.ID, logging.ActorFromContext(r.Context()), false)
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to recreate container", err)
+		return
+	}
+	logging.LogAudit(r.Context(), "update_labels", newID, logging.ActorFromContext(r.Context()), true)
+
+	respondWithJSON(w, http.StatusOK, map[string]string{
+		"containerId":         newID,
+		"previousContainerId": container.ID,
+	})
+}
+
+// maxCopyFilesBody bounds the size of a copy request, since the archive is built in memory
+const maxCopyFilesBody = 16 << 20
+
+// CopyFilesRequest represents the request body for copying files into a container
+type CopyFilesRequest struct {
+	Files []CopyFileEntry `json:"files" binding:"required" description:"Files to write into the container"`
+}
+
+// CopyFileEntry is one file to write into a container
+type CopyFileEntry struct {
+	Path    string `json:"path" example:"/app/config/production.json" description:"Absolute destination path inside the container"`
+	Content string `json:"content" description:"File content"`
+	Mode    int64  `json:"mode,omitempty" example:"420" description:"Permission bits (defaults to 0644)"`
+}
+
+// @Summary Copy files into a container
+// @Description Writes a set of files into a managed container in a single copy, creating missing parent directories and overwriting existing files. Useful for injecting config files into a running container.
+// @Tags containers
+// @Accept json
+// @Produce json
+// @Param id path string true "Container ID"
+// @Param request body CopyFilesRequest true "Files to write"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/{id}/files [post]
+func (h *ContainerHandler) CopyFiles(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	containerID := vars["id"]
+
+	var req CopyFilesRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCopyFilesBody)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if len(req.Files) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", "files must not be empty")
+		return
+	}
+
+	// Every path is checked before anything is copied so a bad entry cannot leave a partial copy
+	files := make([]docker.FileEntry, 0, len(req.Files))
+	seen := make(map[string]bool, len(req.Files))
+	for _, file := range req.Files {
+		if err := docker.ValidateFilePath(file.Path); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid file path", err.Error())
+			return
+		}
+		if seen[file.Path] {
+			respondWithError(w, http.StatusBadRequest, "Invalid file path", fmt.Sprintf("path %s is listed more than once", file.Path))
+			return
+		}
+		seen[file.Path] = true
+		if file.Mode&^0o777 != 0 {
+			respondWithError(w, http.StatusBadRequest, "Invalid file mode", fmt.Sprintf("mode %o for %s must only have permission bits", file.Mode, file.Path))
+			return
+		}
+		files = append(files, docker.FileEntry{Path: file.Path, Content: []byte(file.Content), Mode: file.Mode})
+	}
+
+	container, err := h.dockerClient.GetContainer(r.Context(), containerID)
+	if err != nil {
+		if docker.IsContainerNotFoundError(err) {
+			respondWithDockerError(w, http.StatusNotFound, "Container not found", err)
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to get container details", err)
+		return
+	}
+	if container.Labels[docker.ManagedByLabel] != docker.ManagedByValue {
+		respondWithError(w, http.StatusForbidden, "Container is not managed by this service", "")
+		return
+	}
+
+	if err := h.dockerClient.CopyFilesToContainer(r.Context(), container.ID, files); err != nil {
+		logging.LogAudit(r.Context(), "copy_files", container.ID, logging.ActorFromContext(r.Context()), false)
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to copy files", err)
+		return
+	}
+	logging.LogAudit(r.Context(), "copy_files", container.ID, logging.ActorFromContext(r.Context()), true)
+
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"containerId": container.ID,
+		"copied":      len(files),
+	})
+}
+
+// parseStopTimeout parses a stop grace period given in whole seconds or as a duration such as
+// "30s", returning fallback when value is empty
+func parseStopTimeout(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		return 0, errors.New("timeout must be a non-negative number of seconds or a duration such as 30s")
+	}
+	return parsed, nil
+}
+
+// StopAllContainersResponse reports the outcome of a stop-all request
+type StopAllContainersResponse struct {
+	Results []docker.StopResult `json:"results"`
+	Stopped int                 `json:"stopped"`
+	Failed  int                 `json:"failed"`
+}
+
+// @Summary Stop all managed containers
+// @Description Stop every running container labeled managed-by=block-builder, at most container.stopConcurrency at a time. Containers without the label are never touched.
+// @Tags containers
+// @Produce json
+// @Param timeout query string false "Grace period before a container is killed, in seconds or as a duration such as 30s; defaults to each container's stopTimeout, then container.stopTimeout"
+// @Success 200 {object} StopAllContainersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/stop-all [post]
+func (h *ContainerHandler) StopAllContainers(w http.ResponseWriter, r *http.Request) {
+	timeout, err := parseStopTimeout(r.URL.Query().Get("timeout"), h.defaults.StopTimeout)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid timeout", err.Error())
+		return
+	}
+
+	// Each batch of stops can take the full grace period, which quickly exceeds the WriteTimeout
+	clearWriteDeadline(w)
+
+	// Without an explicit timeout each container gets its own, as docker stop would give it
+	containerDefault := !r.URL.Query().Has("timeout")
+	results, err := h.dockerClient.StopManagedContainers(r.Context(), timeout, containerDefault, h.defaults.StopConcurrency)
+	if results == nil && err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to list containers", err)
+		return
+	}
+	if err != nil {
+		logging.LogError(r.Context(), "failed to stop some managed containers", err)
+	}
+
+	for i := range results {
+		results[i].Name = h.logicalName(results[i].Name)
+	}
+	response := StopAllContainersResponse{Results: results}
+	actor := logging.ActorFromContext(r.Context())
+	for _, result := range results {
+		logging.LogAudit(r.Context(), "stop", result.ContainerID, actor, result.Stopped)
+		if result.Stopped {
+			response.Stopped++
+		} else {
+			response.Failed++
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// restartConcurrency bounds how many exited containers restart-exited starts at once
+const restartConcurrency = 4
+
+// RestartExitedContainersResponse reports the outcome of a restart-exited request
+type RestartExitedContainersResponse struct {
+	Results   []docker.RestartResult `json:"results"`
+	Restarted int                    `json:"restarted"`
+	Skipped   int                    `json:"skipped"`
+	Failed    int                    `json:"failed"`
+}
+
+// @Summary Restart exited containers
+// @Description Start every managed container that exited with a non-zero code, e.g. after a host reboot left restart-policy "no" containers down. Containers that exited cleanly are skipped unless includeClean=true.
+// @Tags containers
+// @Produce json
+// @Param includeClean query bool false "Also restart containers that exited with code 0"
+// @Success 200 {object} RestartExitedContainersResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/restart-exited [post]
+func (h *ContainerHandler) RestartExitedContainers(w http.ResponseWriter, r *http.Request) {
+	includeClean := false
+	if value := r.URL.Query().Get("includeClean"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid includeClean parameter", "includeClean must be true or false")
+			return
+		}
+		includeClean = parsed
+	}
+
+	// Starting many containers a few at a time can outlast the WriteTimeout
+	clearWriteDeadline(w)
+
+	results, err := h.dockerClient.RestartExitedContainers(r.Context(), includeClean, restartConcurrency)
+	if results == nil && err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to list containers", err)
+		return
+	}
+	if err != nil {
+		logging.LogError(r.Context(), "failed to restart some exited containers", err)
+	}
+
+	response := RestartExitedContainersResponse{Results: results}
+	actor := logging.ActorFromContext(r.Context())
+	for i, result := range results {
+		results[i].Name = h.logicalName(result.Name)
+		switch {
+		case result.Skipped:
+			response.Skipped++
+			continue
+		case result.Restarted:
+			response.Restarted++
+		default:
+			response.Failed++
+		}
+		logging.LogAudit(r.Context(), "start", result.ContainerID, actor, result.Restarted)
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// rebuildImage builds a managed container's image again from its recorded project, resolved and
+// built the same way as by a create with the settings the container records, and returns the
+// image's ID, so a later build that moves the tag cannot change what the replacement runs. The
+// base image is always pulled again. Containers created before their build settings were
+// recorded are built with the defaults, and those that needed an npm token are skipped, as the
+// token is not recorded.
+func (h *ContainerHandler) rebuildImage(ctx context.Context, info docker.ContainerInfo) (string, error) {
+	var spec buildSpec
+	if raw := info.Labels[docker.BuildSpecLabel]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &spec); err != nil {
+			return "", fmt.Errorf("invalid %s label: %w", docker.BuildSpecLabel, err)
+		}
+	}
+	if spec.NpmToken {
+		return "", fmt.Errorf("%w: npm registry token not recorded; create the container again to rebuild it", docker.ErrRebuildSkipped)
+	}
+	build, _, createErr := h.resolveProjectBuild(spec.request(info.Labels[docker.ProjectPathLabel]))
+	if createErr != nil {
+		return "", fmt.Errorf("%s: %s", createErr.message, createErr.details)
+	}
+
+	tag := docker.ManagedImageTag(strings.TrimPrefix(info.Name, "/"))
+	imageID, _, generated, createErr := h.buildProjectImage(ctx, build, tag, docker.PullAlways)
+	if createErr != nil {
+		h.removeGeneratedArtifacts(ctx, build.contextDir, generated)
+		return "", fmt.Errorf("%s: %s", createErr.message, createErr.details)
+	}
+	return imageID, nil
+}
+
+// RebuildAllContainersResponse reports the outcome of a rebuild-all request
+type RebuildAllContainersResponse struct {
+	Results []docker.RebuildResult `json:"results"`
+	Rebuilt int                    `json:"rebuilt"`
+	Skipped int                    `json:"skipped"`
+	Failed  int                    `json:"failed"`
+}
+
+// @Summary Rebuild all managed containers
+// @Description Redeploy every managed container from its recorded project: the project is built into a new image with the settings it was created with, and a container with the same configuration is created from it under a temporary name and started if the original was running. Only then is the original removed and the replacement renamed, so a failed build or start leaves the original in place, except an autoRemove container that had to be stopped first for its fixed host ports or static address. Containers without a recorded project path, or created with an npm token, which is not recorded, are skipped. At most the configured build concurrency run at once.
+// @Tags containers
+// @Produce json
+// @Success 200 {object} RebuildAllContainersResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /containers/rebuild-all [post]
+func (h *ContainerHandler) RebuildAllContainers(w http.ResponseWriter, r *http.Request) {
+	// Rebuilding every managed container takes far longer than the WriteTimeout allows
+	clearWriteDeadline(w)
+
+	results, err := h.dockerClient.RebuildManagedContainers(r.Context(), h.defaults.StopTimeout, h.defaults.BuildConcurrency, h.rebuildImage)
+	if results == nil && err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to list containers", err)
+		return
+	}
+	if err != nil {
+		logging.LogError(r.Context(), "failed to rebuild some managed containers", err)
+	}
+
+	response := RebuildAllContainersResponse{Results: results}
+	actor := logging.ActorFromContext(r.Context())
+	for i, result := range results {
+		results[i].Name = h.logicalName(result.Name)
+		switch {
+		case result.Skipped:
+			response.Skipped++
+			continue
+		case result.Rebuilt:
+			response.Rebuilt++
+			logging.LogAudit(r.Context(), "rebuild", result.NewContainerID, actor, true)
+		default:
+			response.Failed++
+			logging.LogAudit(r.Context(), "rebuild", result.ContainerID, actor, false)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, response)
+}
+
+// Helper functions
+
+// parseLogOptions reads the tail and since query parameters; tail defaults to "all"
+func parseLogOptions(r *http.Request) docker.LogOptions {
+	tail := r.URL.Query().Get("tail")
+	if tail == "" {
+		tail = "all"
+	}
+	return docker.LogOptions{
+		Tail:  tail,
+		Since: r.URL.Query().Get("since"),
+	}
+}
+
+// parseLogWindow reads the lastMinutes query parameter into opts as a Since timestamp that
+// many minutes before now, so clients need not format timestamps themselves
+func parseLogWindow(r *http.Request, opts *docker.LogOptions, now time.Time) error {
+	value := r.URL.Query().Get("lastMinutes")
+	if value == "" {
+		return nil
+	}
+	if opts.Since != "" {
+		return fmt.Errorf("lastMinutes cannot be combined with since")
+	}
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes <= 0 {
+		return fmt.Errorf("lastMinutes must be a positive number of minutes")
+	}
+	opts.Since = strconv.FormatInt(now.Add(-time.Duration(minutes)*time.Minute).Unix(), 10)
+	return nil
+}
+
+// parseLogStream reads the stream query parameter into opts
+func parseLogStream(r *http.Request, opts *docker.LogOptions) error {
+	switch stream := r.URL.Query().Get("stream"); stream {
+	case "":
+	case docker.LogStreamStdout, docker.LogStreamStderr:
+		opts.Stream = stream
+	default:
+		return fmt.Errorf("stream must be %q or %q", docker.LogStreamStdout, docker.LogStreamStderr)
+	}
+	return nil
+}
+
+// maxGrepPatternLength bounds user-supplied log patterns. Go's RE2 engine matches in linear
+// time, so together with the length limit a pattern cannot stall a request.
+const maxGrepPatternLength = 256
+
+// parseLogFilter reads the grep and invert query parameters into opts
+func parseLogFilter(r *http.Request, opts *docker.LogOptions) error {
+	pattern := r.URL.Query().Get("grep")
+	if pattern == "" {
+		return nil
+	}
+	if len(pattern) > maxGrepPatternLength {
+		return fmt.Errorf("pattern exceeds %d characters", maxGrepPatternLength)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	opts.Grep = re
+
+	if value := r.URL.Query().Get("invert"); value != "" {
+		invert, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invert must be true or false")
+		}
+		opts.Invert = invert
+	}
+	return nil
+}
+
+// logFileName derives a safe download file name from a container name
+func logFileName(containerName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return -1
+	}, strings.TrimPrefix(containerName, "/"))
+	if name == "" {
+		name = "container"
+	}
+	return name + ".log"
+}
+
+// parseLabelFilters parses repeated ?label=key=value query parameters into a label filter.
+// A bare ?label=key matches any resource carrying that label; the key maps to an empty value.
+func parseLabelFilters(r *http.Request) (map[string]string, error) {
+	values := r.URL.Query()["label"]
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	labelFilter := make(map[string]string, len(values))
+	for _, value := range values {
+		key, val, _ := strings.Cut(value, "=")
+		if strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("label filter %q has an empty key", value)
+		}
+		labelFilter[key] = val
+	}
+	return labelFilter, nil
+}
+
+// reservedLabels are set by the service itself and cannot be overridden by config or requests
+var reservedLabels = map[string]string{
+	docker.ManagedByLabel: docker.ManagedByValue,
+}
+
+// serviceLabels record state the service acts on, such as the secrets directory a delete
+// removes, so label updates may not change them
+var serviceLabels = []string{
+	docker.SecretsLabel,
+	docker.ProjectPathLabel,
+	docker.BuildSpecLabel,
+	docker.RequestIDLabel,
+}
+
+// mergeLabels combines configured default labels with request labels. Request labels
+// take precedence over defaults, and reserved labels always win over both.
+func mergeLabels(defaults, requested map[string]string) map[string]string {
+	labels := make(map[string]string, len(defaults)+len(requested)+len(reservedLabels))
+	for k, v := range defaults {
+		labels[k] = v
+	}
+	for k, v := range requested {
+		labels[k] = v
+	}
+	for k, v := range reservedLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
+// logLevelEnv is the variable a create's logLevel is passed to the app in
+const logLevelEnv = "LOG_LEVEL"
+
+// logLevels are the values accepted for a create's logLevel
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+// mergeEnv returns the base KEY=VALUE entries followed by the overrides, dropping base entries
+// whose variable an override sets
+func mergeEnv(base, overrides []string) []string {
+	overridden := make(map[string]bool, len(overrides))
+	for _, entry := range overrides {
+		key, _, _ := strings.Cut(entry, "=")
+		overridden[key] = true
+	}
+	env := make([]string, 0, len(base)+len(overrides))
+	for _, entry := range base {
+		key, _, _ := strings.Cut(entry, "=")
+		if !overridden[key] {
+			env = append(env, entry)
+		}
+	}
+	return append(env, overrides...)
+}
+
+// validateNodeProject checks that projectPath has a parseable package.json with a name and
+// version. Syntax errors are returned as *nodeproject.PackageJSONError.
+func validateNodeProject(projectPath string) error {
+	packageJSONPath := filepath.Join(projectPath, "package.json")
+	if _, err := os.Stat(packageJSONPath); err != nil {
+		return fmt.Errorf("missing package.json")
+	}
+
+	// Read and parse package.json to verify it's valid
+	data, err := os.ReadFile(packageJSONPath)
+	if err != nil {
+		return fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var packageJSON map[string]interface{}
+	if err := nodeproject.UnmarshalPackageJSON(data, &packageJSON); err != nil {
+		return err
+	}
+
+	// Check for required fields
+	_, hasName := packageJSON["name"]
+	_, hasVersion := packageJSON["version"]
+	if !hasName || !hasVersion {
+		return fmt.Errorf("package.json must define name and version")
+	}
+	return nil
+}
+
+// defaultCapDrop drops NET_RAW unless the request chose its own capabilities to drop
+// or explicitly adds NET_RAW back
+func defaultCapDrop(capAdd, capDrop []string) []string {
+	if capDrop != nil {
+		return capDrop
+	}
+	for _, capability := range capAdd {
+		switch strings.TrimPrefix(strings.ToUpper(capability), "CAP_") {
+		case "NET_RAW", "ALL":
+			return nil
+		}
+	}
+	return []string{"NET_RAW"}
+}
+
+// defaultDockerignore is written to projects that do not have their own .dockerignore
+const defaultDockerignore = `node_modules
+npm-debug.log
+.git
+.gitignore
+`
+
+// npmrcSecretID is the BuildKit secret ID the generated Dockerfile mounts the registry .npmrc from
+const npmrcSecretID = "npmrc"
+
+// validateNpmRegistry checks the private registry settings of a create request
+func validateNpmRegistry(registry, token string) error {
+	if registry == "" {
+		if token != "" {
+			return errors.New("npmToken requires npmRegistry")
+		}
+		return nil
+	}
+	u, err := url.Parse(registry)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("npmRegistry must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("npmRegistry must not contain credentials; use npmToken")
+	}
+	if strings.ContainsAny(token, "\r\n") {
+		return errors.New("npmToken must be a single line")
+	}
+	return nil
+}
+
+// writeNpmrcSecret writes a temporary .npmrc for registry into dir, a build's staging directory
+// outside the project, and returns its path. The file is readable only by the owner; it reaches
+// the build only as a secret mount.
+func writeNpmrcSecret(dir, registry, token string) (string, error) {
+	u, err := url.Parse(registry)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	content := fmt.Sprintf("registry=%s\n", u.String())
+	if token != "" {
+		// npm matches auth entries on the registry URL without its scheme
+		content += fmt.Sprintf("//%s%s:_authToken=%s\n", u.Host, u.Path, token)
+	}
+
+	f, err := os.CreateTemp(dir, ".npmrc-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// dockerfileSyntax is the header that enables BuildKit's RUN --mount in generated Dockerfiles
+const dockerfileSyntax = "# syntax=docker/dockerfile:1\n"
+
+// isInstallStep reports whether a Dockerfile line installs dependencies
+func isInstallStep(line string) bool {
+	return strings.HasPrefix(line, "RUN ") && (strings.Contains(line, "install") || strings.Contains(line, "npm ci"))
+}
+
+// mountInstallSteps adds a RUN --mount flag chosen by mount to each install step of a Dockerfile
+// and makes sure the BuildKit syntax header is present. mount returns "" to leave a step alone.
+func mountInstallSteps(dockerfile string, mount func(step string) string) string {
+	lines := strings.Split(dockerfile, "\n")
+	for i, line := range lines {
+		if !isInstallStep(line) {
+			continue
+		}
+		if flags := mount(line); flags != "" {
+			lines[i] = "RUN " + flags + " " + strings.TrimPrefix(line, "RUN ")
+		}
+	}
+	dockerfile = strings.Join(lines, "\n")
+	if !strings.HasPrefix(dockerfile, dockerfileSyntax) {
+		dockerfile = dockerfileSyntax + dockerfile
+	}
+	return dockerfile
+}
+
+// mountNpmrcSecret rewrites the install steps of a generated Dockerfile to read the registry
+// .npmrc from a BuildKit secret, which is never written to an image layer
+func mountNpmrcSecret(dockerfile string) string {
+	return mountInstallSteps(dockerfile, func(string) string {
+		return "--mount=type=secret,id=" + npmrcSecretID + ",target=/root/.npmrc"
+	})
+}
+
+// buildCacheDirs are the package managers' download caches as seen by root in the node image.
+// Yarn 1 keeps its cache under /usr/local/share rather than ~/.cache/yarn when run as root, and
+// corrupts it when two installs write at once, so concurrent builds take turns with it.
+var buildCacheDirs = []struct {
+	manager nodeproject.PackageManager
+	target  string
+	locked  bool
+}{
+	{nodeproject.PackageManagerNPM, "/root/.npm", false},
+	{nodeproject.PackageManagerYarn, "/usr/local/share/.cache/yarn", true},
+	{nodeproject.PackageManagerPNPM, "/root/.local/share/pnpm/store", false},
+}
+
+// mountBuildCache rewrites the install steps of a generated Dockerfile to keep the package
+// manager's download cache in a BuildKit cache mount. The mounts have fixed IDs, so every
+// build on the daemon shares them and repeated installs skip downloads.
+func mountBuildCache(dockerfile string) string {
+	return mountInstallSteps(dockerfile, func(step string) string {
+		commands := make(map[string]bool)
+		for _, word := range strings.Fields(step) {
+			commands[word] = true
+		}
+		var mounts []string
+		for _, dir := range buildCacheDirs {
+			if !commands[string(dir.manager)] {
+				continue
+			}
+			mount := fmt.Sprintf("--mount=type=cache,id=block-builder-%s,target=%s", dir.manager, dir.target)
+			if dir.locked {
+				mount += ",sharing=locked"
+			}
+			mounts = append(mounts, mount)
+		}
+		return strings.Join(mounts, " ")
+	})
+}
+
+// resolveWorkdir resolves a monorepo app subdirectory, rejecting paths that escape projectPath
+func resolveWorkdir(projectPath, workdir string) (string, error) {
+	if workdir == "" {
+		return projectPath, nil
+	}
+	if filepath.IsAbs(workdir) {
+		return "", fmt.Errorf("workdir must be relative to projectPath: %s", workdir)
+	}
+
+	appDir := filepath.Join(projectPath, workdir)
+	if !isWithin(projectPath, appDir) {
+		return "", fmt.Errorf("workdir escapes projectPath: %s", workdir)
+	}
+
+	// Guard against symlinks pointing outside the project as well
+	realRoot, err := filepath.EvalSymlinks(projectPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve projectPath: %w", err)
+	}
+	realAppDir, err := filepath.EvalSymlinks(appDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve workdir: %w", err)
+	}
+	if !isWithin(realRoot, realAppDir) {
+		return "", fmt.Errorf("workdir escapes projectPath: %s", workdir)
+	}
+
+	return appDir, nil
+}
+
+// isWithin reports whether target is root or a path below it
+func isWithin(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolveBuildContext picks the build context for an app inside projectPath. When the
+// repository root has its own package.json or pnpm workspace config, shared dependencies
+// live there, so the root becomes the context and appSubdir locates the app within it.
+func resolveBuildContext(projectPath, appDir string) (contextDir, appSubdir string) {
+	rel, err := filepath.Rel(projectPath, appDir)
+	if err != nil || rel == "." {
+		return appDir, ""
+	}
+
+	for _, name := range []string{"package.json", "pnpm-workspace.yaml"} {
+		if _, err := os.Stat(filepath.Join(projectPath, name)); err == nil {
+			return projectPath, filepath.ToSlash(rel)
+		}
+	}
+	return appDir, ""
+}
+
+// createDockerfile writes the Dockerfile into contextDir and reports whether the file did
+// not exist before. A non-empty appSubdir builds an app nested in a monorepo root; when the
+// root is a workspace containing the app, the workspace-aware Dockerfile is used instead.
+// Generated Dockerfiles build on baseImage, with the native module build tools added on Alpine;
+// the returned warning says when they were.
+// A non-empty stopSignal is recorded with STOPSIGNAL so images run elsewhere stop the same way.
+// Every port mapping gets an EXPOSE entry; no mappings expose the default port. With
+// production the runtime image installs only production dependencies. With buildCache the
+// install steps keep the package manager's cache in a BuildKit cache mount. Any labels are
+// added with a LABEL instruction.
+func createDockerfile(contextDir, appSubdir, baseImage string, workspace *nodeproject.Workspace, npmSecret, production, buildCache bool, stopSignal string, ports []PortMapping, custom *nodeproject.DockerfileTemplate, labels map[string]string) (bool, string, error) {
+	if len(ports) == 0 {
+		ports = defaultPorts
+	}
+	expose := exposedPorts(ports)
+
+	dockerfileContent := fmt.Sprintf(`FROM %s
+
+WORKDIR /app
+
+# Copy package files
+COPY package*.json ./
+
+# Install dependencies
+RUN npm install
+
+# Copy project files
+COPY . .
+
+# Expose the app's ports
+EXPOSE %s
+
+# Start the application
+CMD ["npm", "start"]
+`, baseImage, expose)
+	if appSubdir != "" {
+		dockerfileContent = fmt.Sprintf(`FROM %[3]s
+
+WORKDIR /app
+
+# Copy the root and app package files first so installs are cached until dependencies change
+COPY package*.json ./
+COPY %[1]s/package*.json %[1]s/
+
+# Install shared root dependencies, then the app's own
+RUN npm install && cd %[1]s && npm install
+
+# Copy the rest of the repository
+COPY . .
+
+WORKDIR /app/%[1]s
+
+# Expose the app's ports
+EXPOSE %[2]s
+
+# Start the application
+CMD ["npm", "start"]
+`, appSubdir, expose, baseImage)
+	}
+	if production {
+		content, err := nodeproject.ProductionDockerfile(contextDir, baseImage, expose)
+		if err != nil {
+			return false, "", err
+		}
+		dockerfileContent = content
+	}
+	if workspace != nil {
+		content, err := workspace.GenerateDockerfile(appSubdir, baseImage, expose)
+		if err != nil {
+			return false, "", err
+		}
+		dockerfileContent = content
+	}
+	// A custom template chooses its own stages, so only generated Dockerfiles get build tools
+	warning := ""
+	if custom != nil {
+		content, err := custom.Render(dockerfileData(filepath.Join(contextDir, appSubdir), baseImage, ports))
+		if err != nil {
+			return false, "", err
+		}
+		dockerfileContent = content
+	} else {
+		var pkg nodeproject.PackageJSON
+		if raw, err := os.ReadFile(filepath.Join(contextDir, appSubdir, "package.json")); err == nil && nodeproject.UnmarshalPackageJSON(raw, &pkg) == nil {
+			dockerfileContent, warning = nodeproject.AddNativeBuildTools(dockerfileContent, &pkg)
+		}
+	}
+	if npmSecret {
+		dockerfileContent = mountNpmrcSecret(dockerfileContent)
+	}
+	if buildCache {
+		dockerfileContent = mountBuildCache(dockerfileContent)
+	}
+	if stopSignal != "" {
+		dockerfileContent += fmt.Sprintf("\nSTOPSIGNAL %s\n", stopSignal)
+	}
+	if len(labels) > 0 {
+		dockerfileContent += imageLabelInstruction(labels)
+	}
+
+	dockerfilePath := filepath.Join(contextDir, "Dockerfile")
+	_, statErr := os.Stat(dockerfilePath)
+	if err := os.WriteFile(dockerfilePath, []byte(dockerfileContent), 0644); err != nil {
+		return false, "", err
+	}
+	return os.IsNotExist(statErr), warning, nil
+}
+
+// dockerfileData describes the app at appDir to a user-supplied Dockerfile template. The
+// first port is the one the app serves on.
+func dockerfileData(appDir, baseImage string, ports []PortMapping) nodeproject.DockerfileData {
+	manager, _ := nodeproject.DetectPackageManager(appDir)
+	data := nodeproject.DockerfileData{
+		BaseImage:      baseImage,
+		Port:           strconv.Itoa(ports[0].ContainerPort),
+		PackageManager: string(manager),
+		BuildOutputDir: "dist",
+	}
+
+	var pkg nodeproject.PackageJSON
+	if raw, err := os.ReadFile(filepath.Join(appDir, "package.json")); err == nil && nodeproject.UnmarshalPackageJSON(raw, &pkg) == nil {
+		data.BuildOutputDir = nodeproject.BuildOutputDir(&pkg)
+	}
+	return data
+}
+
+// imageLabelInstruction renders a LABEL instruction that marks the built image as managed,
+// with keys sorted so the Dockerfile is stable apart from the build time
+func imageLabelInstruction(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString("\n# Identify images built by this service\nLABEL")
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, strconv.Quote(labels[k]))
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// writeFileIfMissing writes a file only if it does not already exist and reports whether it did
+func writeFileIfMissing(path string, content []byte) (bool, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		os.Remove(path)
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// cleanupGeneratedArtifacts removes files the service generated in a project. Only the
+// files listed in created are touched, so files the user already had are never removed.
+func cleanupGeneratedArtifacts(projectPath string, created []string) error {
+	var errs []error
+	for _, name := range created {
+		if err := os.Remove(filepath.Join(projectPath, name)); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("failed to remove %s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// statusErrorCodes are the error codes of failures that have none more specific than their status
+var statusErrorCodes = map[int]apperrors.ErrorCode{
+	http.StatusBadRequest:          apperrors.CodeValidationFailed,
+	http.StatusUnauthorized:        apperrors.CodeUnauthorized,
+	http.StatusInternalServerError: apperrors.CodeInternal,
+}
+
+func respondWithError(w http.ResponseWriter, code int, message string, details string) {
+	respondWithErrorCode(w, code, statusErrorCodes[code], message, details)
+}
+
+// respondWithErrorCode is respondWithError with an error code more specific than the status
+func respondWithErrorCode(w http.ResponseWriter, code int, errorCode apperrors.ErrorCode, message string, details string) {
+	respondWithJSON(w, code, ErrorResponse{
+		Error:     message,
+		Details:   details,
+		ErrorCode: string(errorCode),
+	})
+}
+
+// respondWithDockerError is respondWithError for a failed Docker call, with the error code
+// that identifies the failure so clients need not match on the message. An error that maps to
+// a code gets that code's status as well, so the two never disagree.
+func respondWithDockerError(w http.ResponseWriter, code int, message string, err error) {
+	appErr := appErrorFromDocker(err)
+	if appErr.ErrorCode != apperrors.CodeInternal {
+		code = appErr.Code
+	}
+	respondWithErrorCode(w, code, appErr.ErrorCode, message, err.Error())
+}
+
+// respondWithJSONETag writes a 200 JSON response tagged with a hash of its body, or a bodiless
+// 304 when the client's If-None-Match already names that hash
+func respondWithJSONETag(w http.ResponseWriter, r *http.Request, payload interface{}) {
+	respondWithJSONTaggedBy(w, r, payload, payload)
+}
+
+// respondWithContainerETag tags container details without their age and uptime, which grow
+// every second, so an unchanged container still answers If-None-Match with a 304
+func respondWithContainerETag(w http.ResponseWriter, r *http.Request, container *docker.ContainerInfo) {
+	tagged := *container
+	tagged.AgeSeconds, tagged.UptimeSeconds = 0, 0
+	respondWithJSONTaggedBy(w, r, container, tagged)
+}
+
+// respondWithJSONTaggedBy is respondWithJSONETag with the hash taken over tagged rather than
+// the payload itself
+func respondWithJSONTaggedBy(w http.ResponseWriter, r *http.Request, payload, tagged interface{}) {
+	response, _ := json.Marshal(payload)
+	tag, _ := json.Marshal(tagged)
+	sum := sha256.Sum256(tag)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// etagMatches checks an If-None-Match header, which may list several tags, weak tags or "*"
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
 }
 
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
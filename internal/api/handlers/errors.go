@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"docker-management-system/internal/docker"
+	apperrors "docker-management-system/internal/errors"
+)
+
+// dockerErrors maps the Docker client's sentinel errors to their status, type and code
+var dockerErrors = []struct {
+	err       error
+	status    int
+	errorType apperrors.ErrorType
+	code      apperrors.ErrorCode
+}{
+	{docker.ErrContainerNotFound, http.StatusNotFound, apperrors.TypeNotFound, apperrors.CodeContainerNotFound},
+	{docker.ErrImageNotFound, http.StatusNotFound, apperrors.TypeNotFound, apperrors.CodeImageNotFound},
+	{docker.ErrContainerAlreadyExists, http.StatusConflict, apperrors.TypeConflict, apperrors.CodeContainerAlreadyExists},
+	{docker.ErrInvalidConfig, http.StatusBadRequest, apperrors.TypeValidation, apperrors.CodeInvalidConfig},
+	{docker.ErrContainerNotRunning, http.StatusConflict, apperrors.TypeConflict, apperrors.CodeContainerNotRunning},
+	{docker.ErrContainerExited, http.StatusUnprocessableEntity, apperrors.TypeApplication, apperrors.CodeContainerExited},
+}
+
+// appErrorFromDocker converts an error from the Docker client into an AppError carrying the
+// matching code. Errors that match no sentinel become internal server errors.
+func appErrorFromDocker(err error) *apperrors.AppError {
+	if docker.IsDaemonUnavailableError(err) {
+		return &apperrors.AppError{
+			Code:      http.StatusServiceUnavailable,
+			Message:   "Docker daemon unavailable",
+			Internal:  err,
+			ErrorType: apperrors.TypeUnavailable,
+			ErrorCode: apperrors.CodeDockerUnavailable,
+		}
+	}
+
+	parsed := docker.ParseContainerError(err)
+	for _, mapping := range dockerErrors {
+		if errors.Is(parsed, mapping.err) {
+			return &apperrors.AppError{
+				Code:      mapping.status,
+				Message:   mapping.err.Error(),
+				Internal:  err,
+				ErrorType: mapping.errorType,
+				ErrorCode: mapping.code,
+			}
+		}
+	}
+	return &apperrors.AppError{
+		Code:      http.StatusInternalServerError,
+		Message:   "Internal server error",
+		Internal:  err,
+		ErrorType: apperrors.TypeServer,
+		ErrorCode: apperrors.CodeInternal,
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"docker-management-system/internal/docker"
+	apperrors "docker-management-system/internal/errors"
+
+	"github.com/docker/docker/errdefs"
+)
+
+func TestAppErrorFromDocker(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		status    int
+		errorType apperrors.ErrorType
+		code      apperrors.ErrorCode
+	}{
+		{"container not found", docker.ErrContainerNotFound, http.StatusNotFound, apperrors.TypeNotFound, apperrors.CodeContainerNotFound},
+		{"daemon container not found", errdefs.NotFound(errors.New("No such container: abc123")), http.StatusNotFound, apperrors.TypeNotFound, apperrors.CodeContainerNotFound},
+		{"image not found", docker.ErrImageNotFound, http.StatusNotFound, apperrors.TypeNotFound, apperrors.CodeImageNotFound},
+		{"daemon image not found", errors.New("No such image: node:99"), http.StatusNotFound, apperrors.TypeNotFound, apperrors.CodeImageNotFound},
+		{"name conflict", errors.New("Conflict. The container name \"/api\" is already in use"), http.StatusConflict, apperrors.TypeConflict, apperrors.CodeContainerAlreadyExists},
+		{"invalid config", fmt.Errorf("%w: memory limit too low", docker.ErrInvalidConfig), http.StatusBadRequest, apperrors.TypeValidation, apperrors.CodeInvalidConfig},
+		{"not running", docker.ErrContainerNotRunning, http.StatusConflict, apperrors.TypeConflict, apperrors.CodeContainerNotRunning},
+		{"exited", docker.ErrContainerExited, http.StatusUnprocessableEntity, apperrors.TypeApplication, apperrors.CodeContainerExited},
+		{"daemon unavailable", fmt.Errorf("dial unix /var/run/docker.sock: %w", syscall.ECONNREFUSED), http.StatusServiceUnavailable, apperrors.TypeUnavailable, apperrors.CodeDockerUnavailable},
+		{"wrapped daemon unavailable", &docker.ClientError{Op: "list", Err: syscall.ECONNREFUSED}, http.StatusServiceUnavailable, apperrors.TypeUnavailable, apperrors.CodeDockerUnavailable},
+		{"wrapped container not found", &docker.ClientError{Op: "inspect", Err: errdefs.NotFound(errors.New("No such container: abc123"))}, http.StatusNotFound, apperrors.TypeNotFound, apperrors.CodeContainerNotFound},
+		{"unknown", errors.New("disk on fire"), http.StatusInternalServerError, apperrors.TypeServer, apperrors.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			appErr := appErrorFromDocker(tt.err)
+			if appErr.Code != tt.status || appErr.ErrorType != tt.errorType || appErr.ErrorCode != tt.code {
+				t.Errorf("appErrorFromDocker() = %d %s %s, want %d %s %s",
+					appErr.Code, appErr.ErrorType, appErr.ErrorCode, tt.status, tt.errorType, tt.code)
+			}
+			if appErr.Internal != tt.err {
+				t.Errorf("Internal = %v, want the original error", appErr.Internal)
+			}
+		})
+	}
+}
+
+func TestAppErrorFromDockerUnwrap(t *testing.T) {
+	clientErr := &docker.ClientError{Op: "inspect", Err: fmt.Errorf("%w: abc123", docker.ErrContainerNotFound)}
+	appErr := appErrorFromDocker(clientErr)
+
+	var err error = fmt.Errorf("handling request: %w", appErr)
+	if !errors.Is(err, docker.ErrContainerNotFound) {
+		t.Errorf("errors.Is(%v, ErrContainerNotFound) = false", err)
+	}
+	var target *docker.ClientError
+	if !errors.As(err, &target) || target != clientErr {
+		t.Errorf("errors.As did not reach the ClientError through %v", err)
+	}
+}
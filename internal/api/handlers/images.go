@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/distribution/reference"
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"docker-management-system/internal/docker"
+	"docker-management-system/internal/logging"
+)
+
+// ImageHandler handles image-related HTTP requests
+type ImageHandler struct {
+	dockerClient *docker.Client
+}
+
+// NewImageHandler creates a new ImageHandler instance
+func NewImageHandler(dockerClient *docker.Client) *ImageHandler {
+	return &ImageHandler{
+		dockerClient: dockerClient,
+	}
+}
+
+// @Summary Prune unused images
+// @Description Remove unused images. Only dangling images are pruned unless dangling=false, so tagged base images stay in place; label filters narrow the prune further.
+// @Tags images
+// @Produce json
+// @Param dangling query bool false "Prune only dangling images (default true); false prunes every image not used by a container"
+// @Param label query []string false "Label filter as key=value, or key to match any value; may be repeated" collectionFormat(multi)
+// @Success 200 {object} docker.ImagePruneReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /images/prune [post]
+func (h *ImageHandler) PruneImages(w http.ResponseWriter, r *http.Request) {
+	dangling := true
+	if value := r.URL.Query().Get("dangling"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid dangling parameter", "dangling must be true or false")
+			return
+		}
+		dangling = parsed
+	}
+
+	labelFilter, err := parseLabelFilters(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid label filter", err.Error())
+		return
+	}
+
+	report, err := h.dockerClient.PruneImages(r.Context(), dangling, labelFilter)
+	if err != nil {
+		logging.LogAudit(r.Context(), "prune_images", "", logging.ActorFromContext(r.Context()), false)
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to prune images", err)
+		return
+	}
+	logging.LogAudit(r.Context(), "prune_images", "", logging.ActorFromContext(r.Context()), true)
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// @Summary List managed images
+// @Description List the images built by this service, with the project they were built from and when
+// @Tags images
+// @Produce json
+// @Param label query []string false "Label filter as key=value, or key to match any value; may be repeated" collectionFormat(multi)
+// @Success 200 {array} docker.ManagedImage
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /images/managed [get]
+func (h *ImageHandler) ListManagedImages(w http.ResponseWriter, r *http.Request) {
+	labelFilter, err := parseLabelFilters(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid label filter", err.Error())
+		return
+	}
+
+	images, err := h.dockerClient.ListManagedImages(r.Context(), labelFilter)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to list images", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, images)
+}
+
+// @Summary Inspect an image
+// @Description Get an image's details, including the source commit, build time, framework and Block-Builder version it was built with
+// @Tags images
+// @Produce json
+// @Param id path string true "Image ID"
+// @Success 200 {object} docker.ImageDetails
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /images/{id} [get]
+func (h *ImageHandler) InspectImage(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+
+	details, err := h.dockerClient.InspectImage(r.Context(), id)
+	if err != nil {
+		if docker.IsImageNotFoundError(err) {
+			respondWithDockerError(w, http.StatusNotFound, "Image not found", err)
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to inspect image", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, details)
+}
+
+// maxImageLoadBody bounds the size of an uploaded image archive
+const maxImageLoadBody = 2 << 30
+
+// LoadImageResponse lists the images loaded from an uploaded archive
+type LoadImageResponse struct {
+	Images []string `json:"images" example:"node:20-alpine" description:"Tags of the loaded images, or IDs for images saved without a tag"`
+}
+
+// readErrorRecorder keeps the last error reading the upload, since the Docker client reports
+// a failed upload as its own error
+type readErrorRecorder struct {
+	io.Reader
+	err error
+}
+
+func (r *readErrorRecorder) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// @Summary Load images from an archive
+// @Description Load the images in a tar archive produced by docker save and return the tags they were loaded under. Images saved without a tag are reported by ID.
+// @Tags images
+// @Accept application/x-tar
+// @Produce json
+// @Param archive body string true "Image archive in docker save format"
+// @Success 200 {object} LoadImageResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /images/load [post]
+func (h *ImageHandler) LoadImage(w http.ResponseWriter, r *http.Request) {
+	if r.ContentLength > maxImageLoadBody {
+		respondWithError(w, http.StatusRequestEntityTooLarge, "Image archive too large",
+			fmt.Sprintf("archive must not exceed %d bytes", maxImageLoadBody))
+		return
+	}
+
+	// A multi-gigabyte archive takes longer to upload and load than the server timeouts allow
+	clearReadDeadline(w)
+	clearWriteDeadline(w)
+
+	body := &readErrorRecorder{Reader: http.MaxBytesReader(w, r.Body, maxImageLoadBody)}
+	images, err := h.dockerClient.LoadImage(r.Context(), body)
+	if err != nil {
+		logging.LogAudit(r.Context(), "load_image", "", logging.ActorFromContext(r.Context()), false)
+		var tooLarge *http.MaxBytesError
+		if errors.As(body.err, &tooLarge) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, "Image archive too large",
+				fmt.Sprintf("archive must not exceed %d bytes", maxImageLoadBody))
+			return
+		}
+		if body.err != nil {
+			respondWithError(w, http.StatusBadRequest, "Failed to read image archive", body.err.Error())
+			return
+		}
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to load image", err)
+		return
+	}
+	logging.LogAudit(r.Context(), "load_image", strings.Join(images, ","), logging.ActorFromContext(r.Context()), true)
+
+	respondWithJSON(w, http.StatusOK, LoadImageResponse{Images: images})
+}
+
+// @Summary Save images to an archive
+// @Description Download images as a tar archive in docker save format, for transfer to another host with POST /images/load. The archive is streamed as the daemon produces it.
+// @Tags images
+// @Produce application/x-tar
+// @Param refs query string true "Comma-separated image references or IDs"
+// @Success 200 {file} file "Image archive"
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /images/save [get]
+func (h *ImageHandler) SaveImage(w http.ResponseWriter, r *http.Request) {
+	var refs []string
+	for _, ref := range strings.Split(r.URL.Query().Get("refs"), ",") {
+		if ref = strings.TrimSpace(ref); ref != "" {
+			refs = append(refs, ref)
+		}
+	}
+	if len(refs) == 0 {
+		respondWithError(w, http.StatusBadRequest, "Invalid refs parameter", "refs must list at least one image")
+		return
+	}
+	for _, ref := range refs {
+		if _, err := reference.ParseAnyReference(ref); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Invalid image reference", fmt.Sprintf("%s: %v", ref, err))
+			return
+		}
+		// Checked up front, since a missing image would otherwise only surface mid-stream
+		if _, err := h.dockerClient.InspectImage(r.Context(), ref); err != nil {
+			if docker.IsImageNotFoundError(err) {
+				respondWithDockerError(w, http.StatusNotFound, "Image not found", err)
+				return
+			}
+			respondWithDockerError(w, http.StatusInternalServerError, "Failed to inspect image", err)
+			return
+		}
+	}
+
+	archive, err := h.dockerClient.SaveImage(r.Context(), refs)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to save image", err)
+		return
+	}
+	defer archive.Close()
+
+	clearWriteDeadline(w)
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", imageArchiveName(refs)))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are already sent, so a mid-stream failure can only be logged
+	if _, err := io.Copy(w, archive); err != nil {
+		logging.LogError(r.Context(), "failed to stream image archive", err, zap.Strings("refs", refs))
+	}
+}
+
+// imageArchiveName derives a download file name from the saved refs: the ref itself with
+// path and tag separators replaced when there is one, and a generic name otherwise
+func imageArchiveName(refs []string) string {
+	if len(refs) != 1 {
+		return "images.tar"
+	}
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		case r == '/' || r == ':' || r == '@':
+			return '-'
+		}
+		return -1
+	}, refs[0])
+	if name == "" {
+		name = "image"
+	}
+	return name + ".tar"
+}
+
+// PullImageRequest represents the request body for pulling an image
+type PullImageRequest struct {
+	Image string `json:"image" example:"node:20-alpine" binding:"required" description:"Image reference to pull"`
+}
+
+// @Summary Pull an image with progress
+// @Description Pull an image and stream the daemon's layer-by-layer progress as server-sent events. Each progress event carries a JSON message; the stream ends with a done or error event. Closing the connection cancels the pull.
+// @Tags images
+// @Accept json
+// @Produce text/event-stream
+// @Param request body PullImageRequest true "Image to pull"
+// @Success 200 {string} string "Event stream of docker.PullProgress messages"
+// @Failure 400 {object} ErrorResponse
+// @Router /images/pull/stream [post]
+func (h *ImageHandler) PullImageStream(w http.ResponseWriter, r *http.Request) {
+	var req PullImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if _, err := reference.ParseNormalizedNamed(req.Image); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid image reference", err.Error())
+		return
+	}
+
+	clearWriteDeadline(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+
+	// The request context ends when the client disconnects, which aborts the pull
+	err := h.dockerClient.PullImageWithProgress(r.Context(), req.Image, func(progress docker.PullProgress) error {
+		return writeEvent(w, "progress", progress)
+	})
+	if err != nil {
+		logging.LogAudit(r.Context(), "pull_image", req.Image, logging.ActorFromContext(r.Context()), false)
+		if r.Context().Err() == nil {
+			writeEvent(w, "error", ErrorResponse{Error: "Failed to pull image", Details: err.Error()})
+		}
+		return
+	}
+	logging.LogAudit(r.Context(), "pull_image", req.Image, logging.ActorFromContext(r.Context()), true)
+	writeEvent(w, "done", map[string]string{"image": req.Image})
+}
+
+// writeEvent writes a server-sent event with a JSON payload and flushes it to the client
+func writeEvent(w http.ResponseWriter, event string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+		return err
+	}
+	flush(w)
+	return nil
+}
+
+// flush sends buffered output to the client if the writer supports it
+func flush(w http.ResponseWriter) {
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// clearWriteDeadline lifts the server's WriteTimeout for a response that streams, or waits,
+// for longer than it allows. Writers without deadlines, such as test recorders, are left as is.
+func clearWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+}
+
+// clearReadDeadline lifts the server's ReadTimeout for a request body too large to arrive
+// within it
+func clearReadDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetReadDeadline(time.Time{})
+}
@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"docker-management-system/internal/docker"
+)
+
+// sseEvent is a parsed server-sent event
+type sseEvent struct {
+	name string
+	data string
+}
+
+// readEvents parses a server-sent event stream
+func readEvents(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			events = append(events, current)
+			current = sseEvent{}
+		case strings.HasPrefix(line, "event: "):
+			current.name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			current.data = strings.TrimPrefix(line, "data: ")
+		default:
+			t.Fatalf("Unexpected line in event stream: %q", line)
+		}
+	}
+	return events
+}
+
+func TestPullImageStream(t *testing.T) {
+	fake := &fakeDockerAPI{pullStream: `{"status":"Pulling from library/node","id":"20-alpine"}
+{"status":"Pulling fs layer","progressDetail":{},"id":"a1b2"}
+{"status":"Downloading","progressDetail":{"current":1048576,"total":4194304},"progress":"[====>    ]","id":"a1b2"}
+{"status":"Pull complete","progressDetail":{},"id":"a1b2"}
+{"status":"Status: Downloaded newer image for node:20-alpine"}
+`}
+	h := NewImageHandler(docker.NewClientFromAPI(fake))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/images/pull/stream", strings.NewReader(`{"image": "node:20-alpine"}`))
+	rec := httptest.NewRecorder()
+	h.PullImageStream(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q, want text/event-stream", ct)
+	}
+	if !reflect.DeepEqual(fake.pulled, []string{"node:20-alpine"}) {
+		t.Errorf("Pulled %v, want node:20-alpine", fake.pulled)
+	}
+
+	events := readEvents(t, rec.Body.String())
+	if len(events) != 6 {
+		t.Fatalf("Expected 5 progress events and done, got %d: %+v", len(events), events)
+	}
+	var downloading docker.PullProgress
+	if err := json.Unmarshal([]byte(events[2].data), &downloading); err != nil {
+		t.Fatalf("Progress event is not JSON: %v", err)
+	}
+	want := docker.PullProgress{
+		ID:             "a1b2",
+		Status:         "Downloading",
+		Progress:       "[====>    ]",
+		ProgressDetail: docker.ProgressDetail{Current: 1048576, Total: 4194304},
+	}
+	if events[2].name != "progress" || downloading != want {
+		t.Errorf("Event 2 = %s %+v, want progress %+v", events[2].name, downloading, want)
+	}
+	if last := events[len(events)-1]; last.name != "done" {
+		t.Errorf("Last event = %+v, want done", last)
+	}
+}
+
+func TestPullImageStreamError(t *testing.T) {
+	fake := &fakeDockerAPI{pullStream: `{"status":"Pulling from library/node","id":"99"}
+{"errorDetail":{"message":"manifest for node:99 not found"},"error":"manifest for node:99 not found"}
+`}
+	h := NewImageHandler(docker.NewClientFromAPI(fake))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/images/pull/stream", strings.NewReader(`{"image": "node:99"}`))
+	rec := httptest.NewRecorder()
+	h.PullImageStream(rec, req)
+
+	events := readEvents(t, rec.Body.String())
+	if len(events) != 3 || events[1].name != "progress" || events[2].name != "error" {
+		t.Fatalf("Expected progress, progress and error events, got %+v", events)
+	}
+	if !strings.Contains(events[2].data, "manifest for node:99 not found") {
+		t.Errorf("Error event = %s, want the daemon's message", events[2].data)
+	}
+
+	rec = httptest.NewRecorder()
+	h.PullImageStream(rec, httptest.NewRequest(http.MethodPost, "/api/v1/images/pull/stream", strings.NewReader(`{"image": "Node:Latest"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Invalid reference: expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestPullImageStreamClientDisconnect(t *testing.T) {
+	fake := &fakeDockerAPI{pullStarted: make(chan struct{})}
+	h := NewImageHandler(docker.NewClientFromAPI(fake))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-fake.pullStarted
+		cancel()
+	}()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/images/pull/stream", strings.NewReader(`{"image": "node:20-alpine"}`)).WithContext(ctx)
+	rec := httptest.NewRecorder()
+	h.PullImageStream(rec, req)
+
+	// The pull observed the cancellation and nothing more was sent to the gone client
+	if events := readEvents(t, rec.Body.String()); len(events) != 0 {
+		t.Errorf("Expected no events after disconnect, got %+v", events)
+	}
+}
+
+func TestLoadImage(t *testing.T) {
+	fake := &fakeDockerAPI{loadStream: `{"stream":"Loaded image: node:20-alpine\n"}`}
+	h := NewImageHandler(docker.NewClientFromAPI(fake))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/images/load", strings.NewReader("image archive"))
+	rec := httptest.NewRecorder()
+	h.LoadImage(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp LoadImageResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if want := []string{"node:20-alpine"}; !reflect.DeepEqual(resp.Images, want) {
+		t.Errorf("Images = %v, want %v", resp.Images, want)
+	}
+	if fake.loadedBytes != len("image archive") {
+		t.Errorf("Daemon received %d bytes, want the whole archive", fake.loadedBytes)
+	}
+}
+
+func TestLoadImageTooLarge(t *testing.T) {
+	h := NewImageHandler(docker.NewClientFromAPI(&fakeDockerAPI{}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/images/load", strings.NewReader("image archive"))
+	req.ContentLength = maxImageLoadBody + 1
+	rec := httptest.NewRecorder()
+	h.LoadImage(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, rec.Code, rec.Body.String())
+	}
+}
+
+func TestSaveImage(t *testing.T) {
+	fake := &fakeDockerAPI{}
+	h := NewImageHandler(docker.NewClientFromAPI(fake))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/images/save?refs=blockbuilder/api:latest", nil)
+	rec := httptest.NewRecorder()
+	h.SaveImage(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="blockbuilder-api-latest.tar"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/x-tar" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := rec.Body.String(); got != "archive of blockbuilder/api:latest" {
+		t.Errorf("Body = %q, want the daemon's archive", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/images/save?refs=node:20,blockbuilder/api:latest", nil)
+	rec = httptest.NewRecorder()
+	h.SaveImage(rec, req)
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="images.tar"` {
+		t.Errorf("Content-Disposition = %q for several images", got)
+	}
+	if want := []string{"node:20", "blockbuilder/api:latest"}; !reflect.DeepEqual(fake.saved, want) {
+		t.Errorf("Saved %v, want %v", fake.saved, want)
+	}
+}
+
+func TestSaveImageValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		refs    string
+		missing bool
+		want    int
+	}{
+		{name: "no refs", refs: "", want: http.StatusBadRequest},
+		{name: "invalid ref", refs: "Not%20Valid", want: http.StatusBadRequest},
+		{name: "missing image", refs: "node:20", missing: true, want: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDockerAPI{imageMissing: tt.missing}
+			h := NewImageHandler(docker.NewClientFromAPI(fake))
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/images/save?refs="+tt.refs, nil)
+			rec := httptest.NewRecorder()
+			h.SaveImage(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("Expected status %d, got %d: %s", tt.want, rec.Code, rec.Body.String())
+			}
+			if fake.saved != nil {
+				t.Errorf("Saved %v despite the invalid request", fake.saved)
+			}
+		})
+	}
+}
+
+func TestListManagedImagesInvalidLabel(t *testing.T) {
+	h := NewImageHandler(docker.NewClientFromAPI(&fakeDockerAPI{}))
+	rec := httptest.NewRecorder()
+	h.ListManagedImages(rec, httptest.NewRequest(http.MethodGet, "/images/managed?label==web", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"docker-management-system/internal/docker/nodeproject"
+	"docker-management-system/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// ProjectHandler handles project-related HTTP requests that do not touch Docker
+type ProjectHandler struct{}
+
+// NewProjectHandler creates a new ProjectHandler instance
+func NewProjectHandler() *ProjectHandler {
+	return &ProjectHandler{}
+}
+
+// ValidateProjectRequest represents the request body for project validation
+type ValidateProjectRequest struct {
+	ProjectPath string `json:"projectPath" example:"/path/to/nodejs/project" binding:"required" description:"Path to the Node.js project containing package.json"`
+}
+
+// @Summary Validate a Node.js project
+// @Description Check a project's structure and detect its framework and package manager without creating anything
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Param request body ValidateProjectRequest true "Project to validate"
+// @Success 200 {object} nodeproject.ValidationReport
+// @Failure 400 {object} ErrorResponse
+// @Router /projects/validate [post]
+func (h *ProjectHandler) ValidateProject(w http.ResponseWriter, r *http.Request) {
+	var req ValidateProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.ProjectPath == "" {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", "projectPath is required")
+		return
+	}
+
+	report := nodeproject.NewProjectHandler(req.ProjectPath, nil).Report()
+	if report.Valid {
+		if err := validateNodeProject(req.ProjectPath); err != nil {
+			report.Valid = false
+			report.Errors = append(report.Errors, err.Error())
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, report)
+}
+
+// ScaffoldProjectRequest represents the request body for project scaffolding
+type ScaffoldProjectRequest struct {
+	ProjectPath string `json:"projectPath,omitempty" example:"/srv/projects/hello" description:"Absolute directory to create the project in; omit to download it as a tar archive"`
+	Name        string `json:"name,omitempty" example:"hello" description:"package.json name (defaults to node-project)"`
+}
+
+// ScaffoldProjectResponse is returned when a scaffold was written to disk
+type ScaffoldProjectResponse struct {
+	ProjectPath string   `json:"projectPath"`
+	Files       []string `json:"files"`
+}
+
+// @Summary Scaffold a Node.js project
+// @Description Generate a minimal Express app (package.json, src/index.js and Dockerfile) that passes validation.
+// @Description With projectPath the files are written there; without it they are returned as a tar archive.
+// @Tags projects
+// @Accept json
+// @Produce json
+// @Produce application/x-tar
+// @Param request body ScaffoldProjectRequest true "Where and under which name to scaffold"
+// @Success 200 {file} file "Project as a tar archive"
+// @Success 201 {object} ScaffoldProjectResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /projects/scaffold [post]
+func (h *ProjectHandler) ScaffoldProject(w http.ResponseWriter, r *http.Request) {
+	var req ScaffoldProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid request body", err.Error())
+		return
+	}
+	if req.Name == "" {
+		req.Name = "node-project"
+	}
+	if err := nodeproject.ValidatePackageName(req.Name); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid project name", err.Error())
+		return
+	}
+
+	if req.ProjectPath == "" {
+		h.downloadScaffold(w, r, req.Name)
+		return
+	}
+
+	// Relative paths would resolve against the server's working directory
+	if !filepath.IsAbs(req.ProjectPath) {
+		respondWithError(w, http.StatusBadRequest, "Invalid project path", "projectPath must be absolute")
+		return
+	}
+	if err := os.MkdirAll(req.ProjectPath, 0755); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to create project directory", err.Error())
+		return
+	}
+	if err := nodeproject.NewProjectHandler(req.ProjectPath, nil).ScaffoldExpressApp(req.Name); err != nil {
+		if errors.Is(err, nodeproject.ErrProjectExists) {
+			respondWithError(w, http.StatusConflict, "Project already exists", err.Error())
+			return
+		}
+		respondWithError(w, http.StatusInternalServerError, "Failed to scaffold project", err.Error())
+		return
+	}
+
+	files, err := projectFiles(req.ProjectPath)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to list scaffolded files", err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, ScaffoldProjectResponse{ProjectPath: req.ProjectPath, Files: files})
+}
+
+// downloadScaffold generates the project in a temporary directory and streams it as a tar
+// archive rooted at a directory named after the project
+func (h *ProjectHandler) downloadScaffold(w http.ResponseWriter, r *http.Request, name string) {
+	dir, err := os.MkdirTemp("", "scaffold-*")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to scaffold project", err.Error())
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	if err := nodeproject.NewProjectHandler(dir, nil).ScaffoldExpressApp(name); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Failed to scaffold project", err.Error())
+		return
+	}
+
+	// Scoped names such as @acme/api would otherwise nest the archive root
+	root := strings.ReplaceAll(strings.TrimPrefix(name, "@"), "/", "-")
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", root+".tar"))
+	w.WriteHeader(http.StatusOK)
+
+	// Headers are already sent, so a mid-stream failure can only be logged
+	if err := writeTarDir(w, dir, root); err != nil {
+		logging.LogError(r.Context(), "failed to stream project scaffold", err, zap.String("project_name", name))
+	}
+}
+
+// projectFiles lists the regular files under dir as slash-separated relative paths
+func projectFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	})
+	return files, err
+}
+
+// writeTarDir writes the tree under dir to w as a tar archive with every entry below root
+func writeTarDir(w io.Writer, dir, root string) error {
+	tw := tar.NewWriter(w)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = path.Join(root, filepath.ToSlash(rel))
+		if d.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
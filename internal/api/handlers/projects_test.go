@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"docker-management-system/internal/docker/nodeproject"
+)
+
+func doValidate(t *testing.T, projectPath string) nodeproject.ValidationReport {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"projectPath": projectPath})
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/projects/validate", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	NewProjectHandler().ValidateProject(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var report nodeproject.ValidationReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("Failed to decode report: %v", err)
+	}
+	return report
+}
+
+func TestValidateProjectValid(t *testing.T) {
+	dir := newTestProject(t)
+	if err := os.WriteFile(filepath.Join(dir, "yarn.lock"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write yarn.lock: %v", err)
+	}
+
+	report := doValidate(t, dir)
+
+	if !report.Valid {
+		t.Fatalf("Expected project to be valid, errors: %v", report.Errors)
+	}
+	if report.Framework != "express" {
+		t.Errorf("Framework = %q, want express", report.Framework)
+	}
+	if report.PackageManager != "yarn" {
+		t.Errorf("PackageManager = %q, want yarn", report.PackageManager)
+	}
+	if len(report.MissingDeps) != 0 {
+		t.Errorf("MissingDeps = %v, want none", report.MissingDeps)
+	}
+	// The test project has no start script
+	if len(report.Warnings) != 1 {
+		t.Errorf("Warnings = %v, want one start script warning", report.Warnings)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Failed to read project dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected validation to leave the project untouched, found %d entries", len(entries))
+	}
+}
+
+func TestValidateProjectMissingExpress(t *testing.T) {
+	dir := t.TempDir()
+	pkg := `{"name": "api", "version": "1.0.0", "dependencies": {"fastify": "^4.0.0"}, "scripts": {"start": "node index.js"}}`
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(pkg), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+
+	report := doValidate(t, dir)
+
+	if report.Valid {
+		t.Fatal("Expected project without express to be invalid")
+	}
+	if len(report.MissingDeps) != 1 || report.MissingDeps[0] != "express" {
+		t.Errorf("MissingDeps = %v, want [express]", report.MissingDeps)
+	}
+	if report.Framework != "fastify" {
+		t.Errorf("Framework = %q, want fastify", report.Framework)
+	}
+	if report.PackageManager != "npm" {
+		t.Errorf("PackageManager = %q, want npm", report.PackageManager)
+	}
+}
+
+func doScaffold(t *testing.T, req ScaffoldProjectRequest) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(req)
+	rec := httptest.NewRecorder()
+	NewProjectHandler().ScaffoldProject(rec, httptest.NewRequest(http.MethodPost, "/api/v1/projects/scaffold", bytes.NewReader(body)))
+	return rec
+}
+
+func TestScaffoldProject(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hello")
+
+	rec := doScaffold(t, ScaffoldProjectRequest{ProjectPath: dir, Name: "hello"})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var resp ScaffoldProjectResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if want := []string{"Dockerfile", "package.json", "src/index.js"}; !reflect.DeepEqual(resp.Files, want) {
+		t.Errorf("files = %v, want %v", resp.Files, want)
+	}
+	if report := doValidate(t, dir); !report.Valid {
+		t.Errorf("Scaffolded project is invalid: %v", report.Errors)
+	}
+
+	if rec := doScaffold(t, ScaffoldProjectRequest{ProjectPath: dir, Name: "hello"}); rec.Code != http.StatusConflict {
+		t.Errorf("Expected status %d when scaffolding over a project, got %d", http.StatusConflict, rec.Code)
+	}
+	if rec := doScaffold(t, ScaffoldProjectRequest{ProjectPath: "relative/dir"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for a relative path, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if rec := doScaffold(t, ScaffoldProjectRequest{Name: "Not Valid"}); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for an invalid name, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestScaffoldProjectDownload(t *testing.T) {
+	rec := doScaffold(t, ScaffoldProjectRequest{Name: "@acme/hello"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Disposition"); got != `attachment; filename="acme-hello.tar"` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+
+	var files []string
+	tr := tar.NewReader(rec.Body)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Failed to read archive: %v", err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			files = append(files, header.Name)
+		}
+	}
+	sort.Strings(files)
+	if want := []string{"acme-hello/Dockerfile", "acme-hello/package.json", "acme-hello/src/index.js"}; !reflect.DeepEqual(files, want) {
+		t.Errorf("archive files = %v, want %v", files, want)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"docker-management-system/internal/docker"
+)
+
+// usageConcurrency bounds how many containers' stats are read at once. Each read takes about a
+// second because the daemon samples CPU usage twice.
+const usageConcurrency = 8
+
+// SystemHandler handles host-level HTTP requests
+type SystemHandler struct {
+	dockerClient *docker.Client
+}
+
+// NewSystemHandler creates a new SystemHandler instance
+func NewSystemHandler(dockerClient *docker.Client) *SystemHandler {
+	return &SystemHandler{
+		dockerClient: dockerClient,
+	}
+}
+
+// @Summary Get resource usage of managed containers
+// @Description Reads the stats of every running managed container and returns the totals with a per-container breakdown. Containers whose stats cannot be read are listed with an error and left out of the totals.
+// @Tags system
+// @Produce json
+// @Success 200 {object} docker.UsageSummary
+// @Failure 500 {object} ErrorResponse
+// @Router /system/usage [get]
+func (h *SystemHandler) GetUsage(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.dockerClient.ManagedUsage(r.Context(), usageConcurrency)
+	if err != nil {
+		respondWithDockerError(w, http.StatusInternalServerError, "Failed to read container usage", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, summary)
+}
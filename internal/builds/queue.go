@@ -0,0 +1,265 @@
+package builds
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"docker-management-system/internal/logging"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Status is the lifecycle state of a build job
+type Status string
+
+const (
+	StatusQueued    Status = "queued"
+	StatusBuilding  Status = "building"
+	StatusSuccess   Status = "success"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+)
+
+// maxPendingJobs bounds how many jobs can wait for a worker before Submit refuses new ones
+const maxPendingJobs = 100
+
+// ErrQueueFull is returned by Submit when maxPendingJobs jobs are already waiting
+var ErrQueueFull = errors.New("build queue is full")
+
+// ErrQueueClosed is returned by Submit after Close
+var ErrQueueClosed = errors.New("build queue is closed")
+
+// ErrJobNotFound is returned by Cancel for an unknown or expired job
+var ErrJobNotFound = errors.New("build job not found")
+
+// ErrJobFinished is returned by Cancel for a job that has already completed
+var ErrJobFinished = errors.New("build job already finished")
+
+// Result is what a successful build produced
+type Result struct {
+	ImageID     string
+	ContainerID string
+	Name        string
+	Warnings    []string
+}
+
+// Func runs a build. It should stop when ctx is cancelled and may call ReportProgress.
+type Func func(ctx context.Context) (Result, error)
+
+// Job is a snapshot of a build job's state
+type Job struct {
+	ID          string     `json:"jobId"`
+	Status      Status     `json:"status"`
+	Progress    string     `json:"progress,omitempty"` // Current build stage
+	ImageID     string     `json:"imageId,omitempty"`
+	ContainerID string     `json:"containerId,omitempty"`
+	Name        string     `json:"name,omitempty"`
+	Warnings    []string   `json:"warnings,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+}
+
+// job is a queued build with the state guarded by Queue.mu
+type job struct {
+	Job
+	ctx    context.Context
+	cancel context.CancelFunc
+	fn     Func
+}
+
+// Queue runs build jobs on a fixed number of workers and keeps their state in memory until
+// ttl after they finish
+type Queue struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	pending chan *job
+	closed  bool
+	wg      sync.WaitGroup
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewQueue starts a queue with the given number of workers. Finished jobs are forgotten ttl
+// after they finish; a ttl of 0 keeps them until the queue is closed.
+func NewQueue(concurrency int, ttl time.Duration) *Queue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	q := &Queue{
+		jobs:    make(map[string]*job),
+		pending: make(chan *job, maxPendingJobs),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+	for i := 0; i < concurrency; i++ {
+		q.wg.Add(1)
+		go q.worker()
+	}
+	return q
+}
+
+// Submit enqueues fn and returns the new job's ID. The job runs with ctx, which should not be
+// tied to an HTTP request; see logging.DetachedContext.
+func (q *Queue) Submit(ctx context.Context, fn Func) (string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return "", ErrQueueClosed
+	}
+	q.pruneLocked()
+
+	ctx, cancel := context.WithCancel(ctx)
+	j := &job{
+		Job: Job{
+			ID:        uuid.New().String(),
+			Status:    StatusQueued,
+			CreatedAt: q.now(),
+		},
+		ctx:    ctx,
+		cancel: cancel,
+		fn:     fn,
+	}
+	select {
+	case q.pending <- j:
+	default:
+		cancel()
+		return "", ErrQueueFull
+	}
+	q.jobs[j.ID] = j
+	return j.ID, nil
+}
+
+// Get returns a snapshot of the job with the given ID
+func (q *Queue) Get(id string) (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pruneLocked()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.Job, true
+}
+
+// Cancel stops a queued or running job and marks it cancelled. A running job's context is
+// cancelled, so the build stops at its next Docker call and cleans up after itself; the job's
+// finish time is set once it has done so.
+func (q *Queue) Cancel(id string) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.pruneLocked()
+
+	j, ok := q.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	switch j.Status {
+	case StatusSuccess, StatusFailed, StatusCancelled:
+		return j.Job, ErrJobFinished
+	case StatusQueued:
+		// The worker skips it when it comes up
+		finished := q.now()
+		j.FinishedAt = &finished
+	}
+	j.Status = StatusCancelled
+	j.cancel()
+	logging.GetLogger(j.ctx).Info("build job cancelled", zap.String("job_id", j.ID))
+	return j.Job, nil
+}
+
+// Close stops accepting jobs and waits for the workers to finish the jobs already queued
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.pending)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+func (q *Queue) worker() {
+	defer q.wg.Done()
+	for j := range q.pending {
+		q.run(j)
+	}
+}
+
+// run executes a job and records its outcome
+func (q *Queue) run(j *job) {
+	defer j.cancel()
+
+	q.mu.Lock()
+	if j.Status == StatusCancelled {
+		q.mu.Unlock()
+		return
+	}
+	started := q.now()
+	j.Status = StatusBuilding
+	j.StartedAt = &started
+	q.mu.Unlock()
+
+	ctx := context.WithValue(j.ctx, progressKey, func(stage string) {
+		q.mu.Lock()
+		j.Progress = stage
+		q.mu.Unlock()
+	})
+	result, err := j.fn(ctx)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	finished := q.now()
+	j.FinishedAt = &finished
+	if j.Status == StatusCancelled {
+		return
+	}
+	if err != nil {
+		j.Status = StatusFailed
+		j.Error = err.Error()
+		logging.LogError(j.ctx, "build job failed", err, zap.String("job_id", j.ID))
+		return
+	}
+	j.Status = StatusSuccess
+	j.ImageID = result.ImageID
+	j.ContainerID = result.ContainerID
+	j.Name = result.Name
+	j.Warnings = result.Warnings
+	logging.GetLogger(j.ctx).Info("build job finished",
+		zap.String("job_id", j.ID),
+		zap.Duration("duration", finished.Sub(started)),
+	)
+}
+
+// pruneLocked forgets jobs that finished more than ttl ago. q.mu must be held.
+func (q *Queue) pruneLocked() {
+	if q.ttl <= 0 {
+		return
+	}
+	cutoff := q.now().Add(-q.ttl)
+	for id, j := range q.jobs {
+		if j.FinishedAt != nil && j.FinishedAt.Before(cutoff) {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+type contextKey string
+
+const progressKey contextKey = "build_progress"
+
+// ReportProgress records the current stage of the build job running with ctx. It does nothing
+// outside a job, so build steps shared with synchronous requests can call it unconditionally.
+func ReportProgress(ctx context.Context, stage string) {
+	if report, ok := ctx.Value(progressKey).(func(string)); ok {
+		report(stage)
+	}
+}
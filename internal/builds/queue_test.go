@@ -0,0 +1,254 @@
+package builds
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor polls the job until cond holds or the test times out
+func waitFor(t *testing.T, q *Queue, id string, cond func(Job) bool) Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := q.Get(id)
+		if !ok {
+			t.Fatalf("job %s not found", id)
+		}
+		if cond(job) {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for job %s, last state %+v", id, job)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func hasStatus(status Status) func(Job) bool {
+	return func(job Job) bool { return job.Status == status }
+}
+
+func TestQueueJobLifecycle(t *testing.T) {
+	q := NewQueue(1, 0)
+	defer q.Close()
+
+	release := make(chan struct{})
+	id, err := q.Submit(context.Background(), func(ctx context.Context) (Result, error) {
+		ReportProgress(ctx, "creating container")
+		<-release
+		return Result{ContainerID: "abc123"}, nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+
+	job := waitFor(t, q, id, func(job Job) bool { return job.Progress == "creating container" })
+	if job.Status != StatusBuilding || job.StartedAt == nil || job.FinishedAt != nil {
+		t.Errorf("running job = %+v, want building with a start time", job)
+	}
+
+	close(release)
+	job = waitFor(t, q, id, hasStatus(StatusSuccess))
+	if job.ContainerID != "abc123" || job.FinishedAt == nil || job.Error != "" {
+		t.Errorf("finished job = %+v, want container abc123", job)
+	}
+
+	id, err = q.Submit(context.Background(), func(ctx context.Context) (Result, error) {
+		return Result{}, errors.New("npm install failed")
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	job = waitFor(t, q, id, hasStatus(StatusFailed))
+	if job.Error != "npm install failed" || job.ContainerID != "" {
+		t.Errorf("failed job = %+v, want the build error", job)
+	}
+
+	if _, ok := q.Get("missing"); ok {
+		t.Error("Get returned a job for an unknown ID")
+	}
+}
+
+func TestQueueConcurrencyCap(t *testing.T) {
+	const concurrency = 2
+	q := NewQueue(concurrency, 0)
+	defer q.Close()
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	release := make(chan struct{})
+	ids := make([]string, 5)
+	for i := range ids {
+		id, err := q.Submit(context.Background(), func(ctx context.Context) (Result, error) {
+			mu.Lock()
+			running++
+			if running > peak {
+				peak = running
+			}
+			mu.Unlock()
+			<-release
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return Result{}, nil
+		})
+		if err != nil {
+			t.Fatalf("Submit failed: %v", err)
+		}
+		ids[i] = id
+	}
+
+	// Wait until the workers are saturated; the rest must stay queued
+	waitFor(t, q, ids[1], hasStatus(StatusBuilding))
+	time.Sleep(20 * time.Millisecond)
+	queued := 0
+	for _, id := range ids {
+		if job, _ := q.Get(id); job.Status == StatusQueued {
+			queued++
+		}
+	}
+	if queued != len(ids)-concurrency {
+		t.Errorf("%d jobs queued while workers are busy, want %d", queued, len(ids)-concurrency)
+	}
+
+	close(release)
+	for _, id := range ids {
+		waitFor(t, q, id, hasStatus(StatusSuccess))
+	}
+	if peak != concurrency {
+		t.Errorf("peak concurrency = %d, want %d", peak, concurrency)
+	}
+}
+
+func TestQueueTTLCleanup(t *testing.T) {
+	q := NewQueue(1, time.Minute)
+	defer q.Close()
+
+	now := time.Now()
+	var mu sync.Mutex
+	q.mu.Lock()
+	q.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	q.mu.Unlock()
+
+	id, err := q.Submit(context.Background(), func(ctx context.Context) (Result, error) {
+		return Result{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	waitFor(t, q, id, hasStatus(StatusSuccess))
+
+	mu.Lock()
+	now = now.Add(30 * time.Second)
+	mu.Unlock()
+	if _, ok := q.Get(id); !ok {
+		t.Fatal("job forgotten before its TTL")
+	}
+
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	if _, ok := q.Get(id); ok {
+		t.Error("job still available after its TTL")
+	}
+}
+
+func TestQueueFull(t *testing.T) {
+	q := NewQueue(1, 0)
+	release := make(chan struct{})
+	block := func(ctx context.Context) (Result, error) {
+		<-release
+		return Result{}, nil
+	}
+
+	// One job occupies the worker, the rest fill the pending buffer
+	first, err := q.Submit(context.Background(), block)
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	waitFor(t, q, first, hasStatus(StatusBuilding))
+	for i := 0; i < maxPendingJobs; i++ {
+		if _, err := q.Submit(context.Background(), block); err != nil {
+			t.Fatalf("Submit %d failed: %v", i, err)
+		}
+	}
+	if _, err := q.Submit(context.Background(), block); !errors.Is(err, ErrQueueFull) {
+		t.Errorf("Submit on a full queue = %v, want ErrQueueFull", err)
+	}
+
+	close(release)
+	q.Close()
+	if _, err := q.Submit(context.Background(), block); !errors.Is(err, ErrQueueClosed) {
+		t.Errorf("Submit after Close = %v, want ErrQueueClosed", err)
+	}
+}
+
+func TestQueueCancel(t *testing.T) {
+	q := NewQueue(1, 0)
+	defer q.Close()
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	running, err := q.Submit(context.Background(), func(ctx context.Context) (Result, error) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return Result{}, ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	ran := false
+	queued, err := q.Submit(context.Background(), func(ctx context.Context) (Result, error) {
+		ran = true
+		return Result{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	<-started
+
+	job, err := q.Cancel(queued)
+	if err != nil || job.Status != StatusCancelled || job.FinishedAt == nil {
+		t.Fatalf("Cancel(queued) = %+v, %v; want cancelled and finished", job, err)
+	}
+
+	if job, err = q.Cancel(running); err != nil || job.Status != StatusCancelled {
+		t.Fatalf("Cancel(running) = %+v, %v; want cancelled", job, err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("running build did not observe the cancellation")
+	}
+	job = waitFor(t, q, running, func(job Job) bool { return job.FinishedAt != nil })
+	if job.Status != StatusCancelled || job.Error != "" {
+		t.Errorf("cancelled job = %+v, want cancelled without an error", job)
+	}
+
+	// The worker is free again and the cancelled job never ran
+	done, err := q.Submit(context.Background(), func(ctx context.Context) (Result, error) {
+		return Result{}, nil
+	})
+	if err != nil {
+		t.Fatalf("Submit failed: %v", err)
+	}
+	waitFor(t, q, done, hasStatus(StatusSuccess))
+	if ran {
+		t.Error("cancelled queued job ran")
+	}
+
+	if _, err := q.Cancel(done); !errors.Is(err, ErrJobFinished) {
+		t.Errorf("Cancel(finished) = %v, want ErrJobFinished", err)
+	}
+	if _, err := q.Cancel("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("Cancel(missing) = %v, want ErrJobNotFound", err)
+	}
+}
@@ -2,19 +2,32 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// namePrefixPattern matches the characters Docker allows at the start of a container name
+var namePrefixPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// logMaxSizePattern matches the sizes the json-file and local log drivers accept for max-size
+var logMaxSizePattern = regexp.MustCompile(`^[0-9]+[kmg]?$`)
+
 // Config holds all configuration settings for the application
 type Config struct {
 	Server    ServerConfig    `yaml:"server"`
 	Docker    DockerConfig    `yaml:"docker"`
-	Container ContainerConfig `yaml:"container"logger := logging.GetLogger(ctx)
-	logger.Info("processing request", zap.String("user_id", userID))`
+	Container ContainerConfig `yaml:"container"`
+	Logging   LoggingConfig   `yaml:"logging"`
+	// Notifications configures outgoing alerts about managed containers
+	Notifications NotificationsConfig `yaml:"notifications"`
 }
 
 // ServerConfig holds server-specific configuration
@@ -23,6 +36,9 @@ type ServerConfig struct {
 	ReadTimeout     time.Duration `yaml:"readTimeout" env:"SERVER_READ_TIMEOUT" default:"60s"`
 	WriteTimeout    time.Duration `yaml:"writeTimeout" env:"SERVER_WRITE_TIMEOUT" default:"30s"`
 	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" env:"SERVER_SHUTDOWN_TIMEOUT" default:"10s"`
+	// APITokens maps actor names to bearer tokens. A request presenting a token is attributed to
+	// its actor in audit logs; requests without one stay anonymous.
+	APITokens map[string]string `yaml:"apiTokens" env:"SERVER_API_TOKENS" default:""`
 }
 
 // DockerConfig holds Docker connection settings
@@ -31,6 +47,17 @@ type DockerConfig struct {
 	APIVersion string `yaml:"apiVersion" env:"DOCKER_API_VERSION" default:"1.41"`
 	TLSVerify  bool   `yaml:"tlsVerify" env:"DOCKER_TLS_VERIFY" default:"false"`
 	CertPath   string `yaml:"certPath" env:"DOCKER_CERT_PATH" default:""`
+	// PingTimeout bounds the startup reachability check against the Docker daemon
+	PingTimeout time.Duration `yaml:"pingTimeout" env:"DOCKER_PING_TIMEOUT" default:"5s"`
+	// AllowDegradedStart starts the server even if the daemon is unreachable; health then reports docker DOWN
+	AllowDegradedStart bool `yaml:"allowDegradedStart" env:"DOCKER_ALLOW_DEGRADED_START" default:"false"`
+	// ListCacheTTL caches container lists for dashboard polling; 0 disables the cache
+	ListCacheTTL time.Duration `yaml:"listCacheTTL" env:"DOCKER_LIST_CACHE_TTL" default:"0s"`
+	// InspectCacheTTL caches single-container reads, evicted on container events; 0 disables the cache
+	InspectCacheTTL time.Duration `yaml:"inspectCacheTTL" env:"DOCKER_INSPECT_CACHE_TTL" default:"0s"`
+	// BuildTempDir is where build files kept out of the project, such as the npm registry secret,
+	// are staged; empty uses the OS temp directory
+	BuildTempDir string `yaml:"buildTempDir" env:"DOCKER_BUILD_TEMP_DIR" default:""`
 }
 
 // ContainerConfig holds default container settings
@@ -39,6 +66,59 @@ type ContainerConfig struct {
 	DefaultMemoryLimit   int64  `yaml:"memoryLimit" env:"CONTAINER_MEMORY_LIMIT" default:"512000000"`
 	DefaultNetworkMode   string `yaml:"networkMode" env:"CONTAINER_NETWORK_MODE" default:"bridge"`
 	DefaultRestartPolicy string `yaml:"restartPolicy" env:"CONTAINER_RESTART_POLICY" default:"unless-stopped"`
+	// DefaultLabels are applied to every created container; request labels take precedence
+	DefaultLabels map[string]string `yaml:"defaultLabels" env:"CONTAINER_DEFAULT_LABELS" default:""`
+	// StopTimeout is the grace period before a stopped container is killed
+	StopTimeout time.Duration `yaml:"stopTimeout" env:"CONTAINER_STOP_TIMEOUT" default:"10s"`
+	// StopConcurrency bounds how many containers are stopped in parallel by stop-all
+	StopConcurrency int `yaml:"stopConcurrency" env:"CONTAINER_STOP_CONCURRENCY" default:"4"`
+	// DefaultPullPolicy decides when images are pulled before create: always, missing or never
+	DefaultPullPolicy string `yaml:"pullPolicy" env:"CONTAINER_PULL_POLICY" default:"missing"`
+	// OnNameConflict is what a create does when its name is taken, unless the request says:
+	// fail (or error), suffix or replace. replace only ever removes containers this service manages.
+	OnNameConflict string `yaml:"onNameConflict" env:"CONTAINER_ON_NAME_CONFLICT" default:"fail"`
+	// NamePrefix namespaces every created container's Docker name, e.g. "bb-"; responses omit it
+	NamePrefix string `yaml:"namePrefix" env:"CONTAINER_NAME_PREFIX" default:""`
+	// SecretsDir holds secret files mounted into containers; it should be a tmpfs so values never reach disk
+	SecretsDir string `yaml:"secretsDir" env:"CONTAINER_SECRETS_DIR" default:"/dev/shm/block-builder-secrets"`
+	// SecretSourcesDir is the only host directory secrets may be read from by source; empty
+	// allows secrets given by value only
+	SecretSourcesDir string `yaml:"secretSourcesDir" env:"CONTAINER_SECRET_SOURCES_DIR" default:""`
+	// BuildConcurrency bounds how many async build jobs run at once
+	BuildConcurrency int `yaml:"buildConcurrency" env:"CONTAINER_BUILD_CONCURRENCY" default:"2"`
+	// BuildJobTTL is how long a finished build job's status stays available
+	BuildJobTTL time.Duration `yaml:"buildJobTTL" env:"CONTAINER_BUILD_JOB_TTL" default:"1h"`
+	// DefaultBindIP is the host address published ports listen on; 0.0.0.0 exposes all interfaces
+	DefaultBindIP string `yaml:"bindIP" env:"CONTAINER_BIND_IP" default:"127.0.0.1"`
+	// DefaultLogDriver is the log driver of containers whose create request names none
+	DefaultLogDriver string `yaml:"logDriver" env:"CONTAINER_LOG_DRIVER" default:"json-file"`
+	// LogMaxSize and LogMaxFile rotate json-file and local logs unless the request sets its own
+	// max-size and max-file log options
+	LogMaxSize string `yaml:"logMaxSize" env:"CONTAINER_LOG_MAX_SIZE" default:"10m"`
+	LogMaxFile int    `yaml:"logMaxFile" env:"CONTAINER_LOG_MAX_FILE" default:"3"`
+	// AllowPrivilegedDevices lets requests map devices that expose host memory, hardware or
+	// block storage, such as /dev/mem or /dev/sda
+	AllowPrivilegedDevices bool `yaml:"allowPrivilegedDevices" env:"CONTAINER_ALLOW_PRIVILEGED_DEVICES" default:"false"`
+	// MaxShmSize caps the /dev/shm size, in bytes, a create request may ask for
+	MaxShmSize int64 `yaml:"maxShmSize" env:"CONTAINER_MAX_SHM_SIZE" default:"2147483648"`
+}
+
+// LoggingConfig holds log output settings
+type LoggingConfig struct {
+	File        string `yaml:"file" env:"LOG_FILE" default:""`
+	MaxSizeMB   int    `yaml:"maxSizeMB" env:"LOG_MAX_SIZE_MB" default:"100"`
+	MaxBackups  int    `yaml:"maxBackups" env:"LOG_MAX_BACKUPS" default:"3"`
+	Development bool   `yaml:"development" env:"LOG_DEVELOPMENT" default:"false"`
+}
+
+// NotificationsConfig holds settings for the container lifecycle webhook
+type NotificationsConfig struct {
+	// WebhookURL receives a POST when a managed container dies or is removed; empty disables it
+	WebhookURL string `yaml:"webhookURL" env:"NOTIFY_WEBHOOK_URL" default:""`
+	// WebhookTimeout bounds each delivery attempt
+	WebhookTimeout time.Duration `yaml:"webhookTimeout" env:"NOTIFY_WEBHOOK_TIMEOUT" default:"5s"`
+	// WebhookMaxRetries is how often a failed delivery is retried, with exponential backoff
+	WebhookMaxRetries int `yaml:"webhookMaxRetries" env:"NOTIFY_WEBHOOK_MAX_RETRIES" default:"3"`
 }
 
 // ConfigError represents configuration-related errors
@@ -113,6 +193,16 @@ func (c *Config) loadAndValidate() error {
 		return err
 	}
 
+	// Load logging config
+	if err := c.loadLoggingConfig(); err != nil {
+		return err
+	}
+
+	// Load notifications config
+	if err := c.loadNotificationsConfig(); err != nil {
+		return err
+	}
+
 	return c.validate()
 }
 
@@ -141,6 +231,12 @@ func (c *Config) loadServerConfig() error {
 	}
 	c.Server.ShutdownTimeout = shutdownTimeout
 
+	apiTokens, err := getEnvStringMap("SERVER_API_TOKENS", c.Server.APITokens)
+	if err != nil {
+		return &ConfigError{Field: "SERVER_API_TOKENS", Message: err.Error()}
+	}
+	c.Server.APITokens = apiTokens
+
 	return nil
 }
 
@@ -150,6 +246,30 @@ func (c *Config) loadDockerConfig() error {
 	c.Docker.TLSVerify = getEnvBool("DOCKER_TLS_VERIFY", false)
 	c.Docker.CertPath = getEnvString("DOCKER_CERT_PATH", "")
 
+	if c.Docker.PingTimeout == 0 {
+		c.Docker.PingTimeout = 5 * time.Second
+	}
+	pingTimeout, err := getEnvDuration("DOCKER_PING_TIMEOUT", c.Docker.PingTimeout)
+	if err != nil {
+		return &ConfigError{Field: "DOCKER_PING_TIMEOUT", Message: err.Error()}
+	}
+	c.Docker.PingTimeout = pingTimeout
+
+	c.Docker.AllowDegradedStart = getEnvBool("DOCKER_ALLOW_DEGRADED_START", c.Docker.AllowDegradedStart)
+
+	listCacheTTL, err := getEnvDuration("DOCKER_LIST_CACHE_TTL", c.Docker.ListCacheTTL)
+	if err != nil {
+		return &ConfigError{Field: "DOCKER_LIST_CACHE_TTL", Message: err.Error()}
+	}
+	c.Docker.ListCacheTTL = listCacheTTL
+
+	inspectCacheTTL, err := getEnvDuration("DOCKER_INSPECT_CACHE_TTL", c.Docker.InspectCacheTTL)
+	if err != nil {
+		return &ConfigError{Field: "DOCKER_INSPECT_CACHE_TTL", Message: err.Error()}
+	}
+	c.Docker.InspectCacheTTL = inspectCacheTTL
+	c.Docker.BuildTempDir = getEnvString("DOCKER_BUILD_TEMP_DIR", c.Docker.BuildTempDir)
+
 	return nil
 }
 
@@ -169,6 +289,151 @@ func (c *Config) loadContainerConfig() error {
 	c.Container.DefaultNetworkMode = getEnvString("CONTAINER_NETWORK_MODE", "bridge")
 	c.Container.DefaultRestartPolicy = getEnvString("CONTAINER_RESTART_POLICY", "unless-stopped")
 
+	defaultLabels, err := getEnvStringMap("CONTAINER_DEFAULT_LABELS", c.Container.DefaultLabels)
+	if err != nil {
+		return &ConfigError{Field: "CONTAINER_DEFAULT_LABELS", Message: err.Error()}
+	}
+	c.Container.DefaultLabels = defaultLabels
+
+	if c.Container.StopTimeout == 0 {
+		c.Container.StopTimeout = 10 * time.Second
+	}
+	stopTimeout, err := getEnvDuration("CONTAINER_STOP_TIMEOUT", c.Container.StopTimeout)
+	if err != nil {
+		return &ConfigError{Field: "CONTAINER_STOP_TIMEOUT", Message: err.Error()}
+	}
+	c.Container.StopTimeout = stopTimeout
+
+	if c.Container.StopConcurrency == 0 {
+		c.Container.StopConcurrency = 4
+	}
+	stopConcurrency, err := getEnvInt("CONTAINER_STOP_CONCURRENCY", c.Container.StopConcurrency)
+	if err != nil {
+		return &ConfigError{Field: "CONTAINER_STOP_CONCURRENCY", Message: err.Error()}
+	}
+	c.Container.StopConcurrency = stopConcurrency
+
+	if c.Container.DefaultPullPolicy == "" {
+		c.Container.DefaultPullPolicy = "missing"
+	}
+	c.Container.DefaultPullPolicy = getEnvString("CONTAINER_PULL_POLICY", c.Container.DefaultPullPolicy)
+
+	if c.Container.OnNameConflict == "" {
+		c.Container.OnNameConflict = "fail"
+	}
+	c.Container.OnNameConflict = getEnvString("CONTAINER_ON_NAME_CONFLICT", c.Container.OnNameConflict)
+
+	c.Container.NamePrefix = getEnvString("CONTAINER_NAME_PREFIX", c.Container.NamePrefix)
+
+	if c.Container.SecretsDir == "" {
+		c.Container.SecretsDir = "/dev/shm/block-builder-secrets"
+	}
+	c.Container.SecretsDir = getEnvString("CONTAINER_SECRETS_DIR", c.Container.SecretsDir)
+	c.Container.SecretSourcesDir = getEnvString("CONTAINER_SECRET_SOURCES_DIR", c.Container.SecretSourcesDir)
+
+	if c.Container.BuildConcurrency == 0 {
+		c.Container.BuildConcurrency = 2
+	}
+	buildConcurrency, err := getEnvInt("CONTAINER_BUILD_CONCURRENCY", c.Container.BuildConcurrency)
+	if err != nil {
+		return &ConfigError{Field: "CONTAINER_BUILD_CONCURRENCY", Message: err.Error()}
+	}
+	c.Container.BuildConcurrency = buildConcurrency
+
+	if c.Container.BuildJobTTL == 0 {
+		c.Container.BuildJobTTL = time.Hour
+	}
+	buildJobTTL, err := getEnvDuration("CONTAINER_BUILD_JOB_TTL", c.Container.BuildJobTTL)
+	if err != nil {
+		return &ConfigError{Field: "CONTAINER_BUILD_JOB_TTL", Message: err.Error()}
+	}
+	c.Container.BuildJobTTL = buildJobTTL
+
+	if c.Container.DefaultBindIP == "" {
+		c.Container.DefaultBindIP = "127.0.0.1"
+	}
+	c.Container.DefaultBindIP = getEnvString("CONTAINER_BIND_IP", c.Container.DefaultBindIP)
+
+	if c.Container.DefaultLogDriver == "" {
+		c.Container.DefaultLogDriver = "json-file"
+	}
+	c.Container.DefaultLogDriver = getEnvString("CONTAINER_LOG_DRIVER", c.Container.DefaultLogDriver)
+
+	if c.Container.LogMaxSize == "" {
+		c.Container.LogMaxSize = "10m"
+	}
+	c.Container.LogMaxSize = getEnvString("CONTAINER_LOG_MAX_SIZE", c.Container.LogMaxSize)
+
+	if c.Container.LogMaxFile == 0 {
+		c.Container.LogMaxFile = 3
+	}
+	logMaxFile, err := getEnvInt("CONTAINER_LOG_MAX_FILE", c.Container.LogMaxFile)
+	if err != nil {
+		return &ConfigError{Field: "CONTAINER_LOG_MAX_FILE", Message: err.Error()}
+	}
+	c.Container.LogMaxFile = logMaxFile
+
+	c.Container.AllowPrivilegedDevices = getEnvBool("CONTAINER_ALLOW_PRIVILEGED_DEVICES", c.Container.AllowPrivilegedDevices)
+
+	if c.Container.MaxShmSize == 0 {
+		c.Container.MaxShmSize = 2147483648
+	}
+	maxShmSize, err := getEnvInt64("CONTAINER_MAX_SHM_SIZE", c.Container.MaxShmSize)
+	if err != nil {
+		return &ConfigError{Field: "CONTAINER_MAX_SHM_SIZE", Message: err.Error()}
+	}
+	c.Container.MaxShmSize = maxShmSize
+
+	return nil
+}
+
+func (c *Config) loadLoggingConfig() error {
+	c.Logging.File = getEnvString("LOG_FILE", c.Logging.File)
+
+	if c.Logging.MaxSizeMB == 0 {
+		c.Logging.MaxSizeMB = 100
+	}
+	maxSize, err := getEnvInt("LOG_MAX_SIZE_MB", c.Logging.MaxSizeMB)
+	if err != nil {
+		return &ConfigError{Field: "LOG_MAX_SIZE_MB", Message: err.Error()}
+	}
+	c.Logging.MaxSizeMB = maxSize
+
+	if c.Logging.MaxBackups == 0 {
+		c.Logging.MaxBackups = 3
+	}
+	maxBackups, err := getEnvInt("LOG_MAX_BACKUPS", c.Logging.MaxBackups)
+	if err != nil {
+		return &ConfigError{Field: "LOG_MAX_BACKUPS", Message: err.Error()}
+	}
+	c.Logging.MaxBackups = maxBackups
+
+	c.Logging.Development = getEnvBool("LOG_DEVELOPMENT", c.Logging.Development)
+
+	return nil
+}
+
+func (c *Config) loadNotificationsConfig() error {
+	c.Notifications.WebhookURL = getEnvString("NOTIFY_WEBHOOK_URL", c.Notifications.WebhookURL)
+
+	if c.Notifications.WebhookTimeout == 0 {
+		c.Notifications.WebhookTimeout = 5 * time.Second
+	}
+	webhookTimeout, err := getEnvDuration("NOTIFY_WEBHOOK_TIMEOUT", c.Notifications.WebhookTimeout)
+	if err != nil {
+		return &ConfigError{Field: "NOTIFY_WEBHOOK_TIMEOUT", Message: err.Error()}
+	}
+	c.Notifications.WebhookTimeout = webhookTimeout
+
+	if c.Notifications.WebhookMaxRetries == 0 {
+		c.Notifications.WebhookMaxRetries = 3
+	}
+	webhookMaxRetries, err := getEnvInt("NOTIFY_WEBHOOK_MAX_RETRIES", c.Notifications.WebhookMaxRetries)
+	if err != nil {
+		return &ConfigError{Field: "NOTIFY_WEBHOOK_MAX_RETRIES", Message: err.Error()}
+	}
+	c.Notifications.WebhookMaxRetries = webhookMaxRetries
+
 	return nil
 }
 
@@ -183,6 +448,16 @@ func (c *Config) validate() error {
 	if c.Server.WriteTimeout <= 0 {
 		return &ConfigError{Field: "Server.WriteTimeout", Message: "must be positive"}
 	}
+	actorsByToken := make(map[string]string, len(c.Server.APITokens))
+	for actor, token := range c.Server.APITokens {
+		if token == "" {
+			return &ConfigError{Field: "Server.APITokens", Message: fmt.Sprintf("token for %q cannot be empty", actor)}
+		}
+		if other, exists := actorsByToken[token]; exists {
+			return &ConfigError{Field: "Server.APITokens", Message: fmt.Sprintf("%q and %q share a token", other, actor)}
+		}
+		actorsByToken[token] = actor
+	}
 
 	// Validate Docker config
 	if c.Docker.Host == "" {
@@ -191,6 +466,17 @@ func (c *Config) validate() error {
 	if c.Docker.APIVersion == "" {
 		return &ConfigError{Field: "Docker.APIVersion", Message: "cannot be empty"}
 	}
+	if c.Docker.PingTimeout < 0 {
+		return &ConfigError{Field: "Docker.PingTimeout", Message: "must be non-negative"}
+	}
+	if c.Docker.ListCacheTTL < 0 {
+		return &ConfigError{Field: "Docker.ListCacheTTL", Message: "must be non-negative"}
+	}
+	if c.Docker.BuildTempDir != "" {
+		if err := checkWritableDir(c.Docker.BuildTempDir); err != nil {
+			return &ConfigError{Field: "Docker.BuildTempDir", Message: err.Error()}
+		}
+	}
 
 	// Validate Container config
 	if c.Container.DefaultCPUShares < 0 {
@@ -199,6 +485,76 @@ func (c *Config) validate() error {
 	if c.Container.DefaultMemoryLimit < 0 {
 		return &ConfigError{Field: "Container.DefaultMemoryLimit", Message: "must be non-negative"}
 	}
+	if c.Container.StopTimeout < 0 {
+		return &ConfigError{Field: "Container.StopTimeout", Message: "must be non-negative"}
+	}
+	if c.Container.StopConcurrency < 0 {
+		return &ConfigError{Field: "Container.StopConcurrency", Message: "must be non-negative"}
+	}
+	if c.Container.BuildConcurrency < 0 {
+		return &ConfigError{Field: "Container.BuildConcurrency", Message: "must be non-negative"}
+	}
+	if c.Container.BuildJobTTL < 0 {
+		return &ConfigError{Field: "Container.BuildJobTTL", Message: "must be non-negative"}
+	}
+	switch c.Container.DefaultPullPolicy {
+	case "", "always", "missing", "never":
+	default:
+		return &ConfigError{Field: "Container.DefaultPullPolicy", Message: "must be always, missing or never"}
+	}
+	switch c.Container.OnNameConflict {
+	case "", "fail", "error", "suffix", "replace":
+	default:
+		return &ConfigError{Field: "Container.OnNameConflict", Message: "must be fail, error, suffix or replace"}
+	}
+	if c.Container.NamePrefix != "" && !namePrefixPattern.MatchString(c.Container.NamePrefix) {
+		return &ConfigError{Field: "Container.NamePrefix", Message: "must start with a letter or digit and contain only letters, digits, '_', '.' and '-'"}
+	}
+	if c.Container.DefaultBindIP != "" && net.ParseIP(c.Container.DefaultBindIP) == nil {
+		return &ConfigError{Field: "Container.DefaultBindIP", Message: "must be a valid IP address"}
+	}
+	if c.Container.SecretsDir != "" && !filepath.IsAbs(c.Container.SecretsDir) {
+		return &ConfigError{Field: "Container.SecretsDir", Message: "must be an absolute path"}
+	}
+	if c.Container.SecretSourcesDir != "" && !filepath.IsAbs(c.Container.SecretSourcesDir) {
+		return &ConfigError{Field: "Container.SecretSourcesDir", Message: "must be an absolute path"}
+	}
+	switch c.Container.DefaultLogDriver {
+	case "", "json-file", "local", "journald", "none", "syslog", "gelf", "fluentd", "awslogs", "splunk", "etwlogs", "gcplogs", "logentries":
+	default:
+		return &ConfigError{Field: "Container.DefaultLogDriver", Message: "must be a Docker log driver such as json-file, local or journald"}
+	}
+	if c.Container.LogMaxSize != "" && !logMaxSizePattern.MatchString(c.Container.LogMaxSize) {
+		return &ConfigError{Field: "Container.LogMaxSize", Message: "must be a size such as 10m, 512k or 1g"}
+	}
+	if c.Container.LogMaxFile < 0 {
+		return &ConfigError{Field: "Container.LogMaxFile", Message: "must be non-negative"}
+	}
+	if c.Container.MaxShmSize < 0 {
+		return &ConfigError{Field: "Container.MaxShmSize", Message: "must be non-negative"}
+	}
+
+	// Validate Logging config
+	if c.Logging.MaxSizeMB < 0 {
+		return &ConfigError{Field: "Logging.MaxSizeMB", Message: "must be non-negative"}
+	}
+	if c.Logging.MaxBackups < 0 {
+		return &ConfigError{Field: "Logging.MaxBackups", Message: "must be non-negative"}
+	}
+
+	// Validate Notifications config
+	if c.Notifications.WebhookURL != "" {
+		u, err := url.Parse(c.Notifications.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return &ConfigError{Field: "Notifications.WebhookURL", Message: "must be an absolute http or https URL"}
+		}
+	}
+	if c.Notifications.WebhookTimeout < 0 {
+		return &ConfigError{Field: "Notifications.WebhookTimeout", Message: "must be non-negative"}
+	}
+	if c.Notifications.WebhookMaxRetries < 0 {
+		return &ConfigError{Field: "Notifications.WebhookMaxRetries", Message: "must be non-negative"}
+	}
 
 	return nil
 }
@@ -236,9 +592,48 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// getEnvStringMap parses a comma-separated list of key=value pairs, e.g. "team=core,env=prod"
+func getEnvStringMap(key string, defaultValue map[string]string) (map[string]string, error) {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return defaultValue, nil
+	}
+
+	result := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, found := strings.Cut(pair, "=")
+		if !found || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid key=value pair %q", pair)
+		}
+		result[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return result, nil
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) (time.Duration, error) {
 	if value, exists := os.LookupEnv(key); exists {
 		return time.ParseDuration(value)
 	}
 	return defaultValue, nil
 }
+
+// checkWritableDir verifies that dir exists, is a directory and accepts new files
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("must exist: %v", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	probe, err := os.CreateTemp(dir, ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("must be writable: %v", err)
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
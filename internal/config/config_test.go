@@ -66,6 +66,32 @@ func TestNewConfig(t *testing.T) {
 	}
 }
 
+func TestLogLimits(t *testing.T) {
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig failed: %v", err)
+	}
+	if cfg.Container.DefaultLogDriver != "json-file" || cfg.Container.LogMaxSize != "10m" || cfg.Container.LogMaxFile != 3 {
+		t.Errorf("Log defaults = %q, %q, %d; want json-file, 10m, 3",
+			cfg.Container.DefaultLogDriver, cfg.Container.LogMaxSize, cfg.Container.LogMaxFile)
+	}
+
+	os.Setenv("CONTAINER_LOG_MAX_SIZE", "50m")
+	os.Setenv("CONTAINER_LOG_MAX_FILE", "5")
+	defer func() {
+		os.Unsetenv("CONTAINER_LOG_MAX_SIZE")
+		os.Unsetenv("CONTAINER_LOG_MAX_FILE")
+	}()
+
+	cfg, err = NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig failed: %v", err)
+	}
+	if cfg.Container.LogMaxSize != "50m" || cfg.Container.LogMaxFile != 5 {
+		t.Errorf("Log limits = %q, %d; want 50m, 5", cfg.Container.LogMaxSize, cfg.Container.LogMaxFile)
+	}
+}
+
 func TestConfigValidation(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -94,6 +120,150 @@ func TestConfigValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid name prefix",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:       "unix:///var/run/docker.sock",
+					APIVersion: "1.41",
+				},
+				Container: ContainerConfig{NamePrefix: "-bb/"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative secrets dir",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:       "unix:///var/run/docker.sock",
+					APIVersion: "1.41",
+				},
+				Container: ContainerConfig{SecretsDir: "secrets"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative secret sources dir",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:       "unix:///var/run/docker.sock",
+					APIVersion: "1.41",
+				},
+				Container: ContainerConfig{SecretSourcesDir: "secrets"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid bind IP",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:       "unix:///var/run/docker.sock",
+					APIVersion: "1.41",
+				},
+				Container: ContainerConfig{DefaultBindIP: "localhost"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing build temp dir",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:         "unix:///var/run/docker.sock",
+					APIVersion:   "1.41",
+					BuildTempDir: filepath.Join(os.TempDir(), "block-builder-missing-build-dir"),
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative webhook URL",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:       "unix:///var/run/docker.sock",
+					APIVersion: "1.41",
+				},
+				Notifications: NotificationsConfig{WebhookURL: "hooks.example.com/docker"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown name conflict strategy",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:       "unix:///var/run/docker.sock",
+					APIVersion: "1.41",
+				},
+				Container: ContainerConfig{OnNameConflict: "overwrite"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown log driver",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:       "unix:///var/run/docker.sock",
+					APIVersion: "1.41",
+				},
+				Container: ContainerConfig{DefaultLogDriver: "stdout"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid log max size",
+			config: Config{
+				Server: ServerConfig{
+					Port:         8080,
+					ReadTimeout:  30 * time.Second,
+					WriteTimeout: 30 * time.Second,
+				},
+				Docker: DockerConfig{
+					Host:       "unix:///var/run/docker.sock",
+					APIVersion: "1.41",
+				},
+				Container: ContainerConfig{LogMaxSize: "10MB"},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -105,3 +275,64 @@ func TestConfigValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestDefaultLabels(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := []byte(`
+container:
+  defaultLabels:
+    team: platform
+    cost-center: "1234"
+`)
+	if err := os.WriteFile(configPath, configContent, 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.Container.DefaultLabels["team"] != "platform" || cfg.Container.DefaultLabels["cost-center"] != "1234" {
+		t.Errorf("Expected labels from YAML, got %v", cfg.Container.DefaultLabels)
+	}
+
+	os.Setenv("CONTAINER_DEFAULT_LABELS", "team=core, environment=prod")
+	defer os.Unsetenv("CONTAINER_DEFAULT_LABELS")
+
+	cfg, err = LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if len(cfg.Container.DefaultLabels) != 2 || cfg.Container.DefaultLabels["team"] != "core" || cfg.Container.DefaultLabels["environment"] != "prod" {
+		t.Errorf("Expected labels from env, got %v", cfg.Container.DefaultLabels)
+	}
+
+	os.Setenv("CONTAINER_DEFAULT_LABELS", "team")
+	if _, err := LoadConfig(configPath); err == nil {
+		t.Error("Expected error for malformed CONTAINER_DEFAULT_LABELS")
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	os.Setenv("SERVER_API_TOKENS", "ci=s3cret, alice=abc==")
+	defer os.Unsetenv("SERVER_API_TOKENS")
+
+	cfg, err := NewConfig()
+	if err != nil {
+		t.Fatalf("NewConfig failed: %v", err)
+	}
+	if len(cfg.Server.APITokens) != 2 || cfg.Server.APITokens["ci"] != "s3cret" || cfg.Server.APITokens["alice"] != "abc==" {
+		t.Errorf("Expected tokens from env, got %v", cfg.Server.APITokens)
+	}
+
+	os.Setenv("SERVER_API_TOKENS", "ci=")
+	if _, err := NewConfig(); err == nil {
+		t.Error("Expected error for an empty token")
+	}
+
+	os.Setenv("SERVER_API_TOKENS", "ci=s3cret,deploy=s3cret")
+	if _, err := NewConfig(); err == nil {
+		t.Error("Expected error for a token shared by two actors")
+	}
+}
@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// AttachSession is an interactive connection to a running container's stdin, stdout and stderr
+type AttachSession struct {
+	conn types.HijackedResponse
+	// TTY reports whether the container has a terminal, in which case output is a raw stream
+	TTY bool
+}
+
+// AttachContainer attaches to the stdio streams of a running container. Closing the session
+// detaches without stopping the container. The caller must call Close.
+func (c *Client) AttachContainer(ctx context.Context, containerID string) (*AttachSession, error) {
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, &ClientError{
+			Op:  "attach",
+			Err: err,
+		}
+	}
+	if inspect.ContainerJSONBase == nil || inspect.State == nil || !inspect.State.Running {
+		return nil, &ClientError{
+			Op:  "attach",
+			Err: fmt.Errorf("%w: %s", ErrContainerNotRunning, containerID),
+		}
+	}
+
+	conn, err := c.api().ContainerAttach(ctx, inspect.ID, container.AttachOptions{
+		Stream: true,
+		Stdin:  true,
+		Stdout: true,
+		Stderr: true,
+	})
+	if err != nil {
+		return nil, &ClientError{
+			Op:  "attach",
+			Err: err,
+		}
+	}
+	// Newer daemons state the stream type; older ones require checking the container config
+	tty := inspect.Config != nil && inspect.Config.Tty
+	if mediaType, ok := conn.MediaType(); ok {
+		tty = mediaType == types.MediaTypeRawStream
+	}
+	return &AttachSession{conn: conn, TTY: tty}, nil
+}
+
+// Stdin returns the writer that feeds the container's stdin
+func (s *AttachSession) Stdin() io.Writer {
+	return s.conn.Conn
+}
+
+// CopyOutput copies the container's output to dst until the stream ends
+func (s *AttachSession) CopyOutput(dst io.Writer) error {
+	return copyAttachOutput(dst, s.conn.Reader, s.TTY)
+}
+
+// Close detaches from the container
+func (s *AttachSession) Close() {
+	s.conn.Close()
+}
+
+// copyAttachOutput writes attach output to dst. Containers with a TTY send a raw stream;
+// without one, Docker multiplexes stdout and stderr and the frame headers must be stripped.
+func copyAttachOutput(dst io.Writer, src io.Reader, tty bool) error {
+	if tty {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	_, err := stdcopy.StdCopy(dst, dst, src)
+	return err
+}
@@ -0,0 +1,193 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"docker-management-system/internal/logging"
+
+	"github.com/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/patternmatcher/ignorefile"
+	"go.uber.org/zap"
+)
+
+// BuildOptions configures an image build
+type BuildOptions struct {
+	// Tag names the built image. The image previously built under it seeds the build cache.
+	Tag string
+	// Labels are set on the built image
+	Labels map[string]string
+	// Platform builds for another platform than the daemon's, e.g. linux/arm64
+	Platform string
+	// Secrets maps BuildKit secret IDs to the files holding them. The files are read by the
+	// builder through a session as RUN --mount=type=secret asks for them, and are never sent
+	// with the build context, even when they are inside it.
+	Secrets map[string]string
+}
+
+// inlineCache asks BuildKit to embed cache metadata in the image so the next build of the
+// same tag can reuse its layers
+var inlineCache = "1"
+
+// ManagedImageTag returns the tag the image built for a container name is stored under, in
+// ManagedImageNamespace. Repository names are lowercase while container names are not, so a
+// name with uppercase letters gets a short hash of it appended, keeping MyApp and myapp apart.
+// A name that is not a valid repository name once lowercased, e.g. one with consecutive dots,
+// is replaced by a hash of it.
+func ManagedImageTag(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	sum := sha256.Sum256([]byte(name))
+	repository := ManagedImageNamespace + strings.ToLower(name)
+	if strings.ToLower(name) != name {
+		repository += "-" + hex.EncodeToString(sum[:3])
+	}
+	if _, err := reference.ParseNormalizedNamed(repository); err != nil {
+		repository = ManagedImageNamespace + "app-" + hex.EncodeToString(sum[:6])
+	}
+	return repository + ":latest"
+}
+
+// BuildImage builds the Dockerfile at the root of contextDir with BuildKit and returns the ID
+// of the built image. Files excluded by the context's .dockerignore are not sent, apart from
+// the Dockerfile and .dockerignore themselves. Each step is logged as it starts, and a failed
+// build's error names the step that failed. Cancelling ctx aborts the build.
+func (c *Client) BuildImage(ctx context.Context, contextDir string, opts BuildOptions) (string, error) {
+	// The service stages its own secrets outside the context; this keeps a caller that passes a
+	// secret from inside the context, such as a project's own .npmrc, from also sending it
+	var secretFiles []string
+	for _, path := range opts.Secrets {
+		secretFiles = append(secretFiles, path)
+	}
+	buildContext, err := tarBuildContext(contextDir, secretFiles)
+	if err != nil {
+		return "", &ClientError{
+			Op:  "build_image",
+			Err: err,
+		}
+	}
+	defer buildContext.Close()
+
+	options := types.ImageBuildOptions{
+		Version:     types.BuilderBuildKit,
+		Tags:        []string{opts.Tag},
+		Dockerfile:  "Dockerfile",
+		Labels:      opts.Labels,
+		Platform:    opts.Platform,
+		Remove:      true,
+		ForceRemove: true,
+		// A tag that has not been built yet only loses the cache, it does not fail the build
+		CacheFrom: []string{opts.Tag},
+		BuildArgs: map[string]*string{"BUILDKIT_INLINE_CACHE": &inlineCache},
+	}
+	// Without a session the builder treats secret mounts as empty rather than failing
+	if len(opts.Secrets) > 0 {
+		sessionID, closeSession, err := c.startSecretsSession(ctx, opts.Secrets)
+		if err != nil {
+			return "", &ClientError{
+				Op:  "build_image",
+				Err: err,
+			}
+		}
+		defer closeSession()
+		options.SessionID = sessionID
+	}
+
+	resp, err := c.api().ImageBuild(ctx, buildContext, options)
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return "", &ClientError{
+			Op:  "build_image",
+			Err: err,
+		}
+	}
+	defer resp.Body.Close()
+
+	output, err := ParseBuildOutput(ctx, resp.Body)
+	var stepErr *BuildStepError
+	if errors.As(err, &stepErr) {
+		return "", &ClientError{
+			Op:      "build_image",
+			Err:     err,
+			Details: opts.Tag,
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if output.ImageID == "" {
+		return "", &ClientError{
+			Op:      "build_image",
+			Err:     errors.New("build finished without reporting an image"),
+			Details: opts.Tag,
+		}
+	}
+	return output.ImageID, nil
+}
+
+// startSecretsSession opens a BuildKit session that serves secrets, mapping IDs to files, to
+// the builder. It returns the session's ID and a function that closes it.
+func (c *Client) startSecretsSession(ctx context.Context, secrets map[string]string) (string, func(), error) {
+	sources := make([]secretsprovider.Source, 0, len(secrets))
+	for id, path := range secrets {
+		sources = append(sources, secretsprovider.Source{ID: id, FilePath: path})
+	}
+	store, err := secretsprovider.NewStore(sources)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load build secrets: %w", err)
+	}
+
+	s, err := session.NewSession(ctx, "")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create build session: %w", err)
+	}
+	s.Allow(secretsprovider.NewSecretProvider(store))
+
+	api := c.api()
+	go func() {
+		dialer := func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+			return api.DialHijack(ctx, "/session", proto, meta)
+		}
+		if err := s.Run(ctx, dialer); err != nil && ctx.Err() == nil {
+			logging.LogError(ctx, "build session failed", err, zap.String("operation", "build_image"))
+		}
+	}()
+	return s.ID(), func() { s.Close() }, nil
+}
+
+// tarBuildContext streams contextDir as a tar archive without the files its .dockerignore
+// excludes, or any of skip
+func tarBuildContext(contextDir string, skip []string) (io.ReadCloser, error) {
+	var excludes []string
+	f, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	switch {
+	case err == nil:
+		excludes, err = ignorefile.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+		}
+		// The daemon needs both files even when the user's rules exclude them
+		excludes = append(excludes, "!Dockerfile", "!.dockerignore")
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("failed to read .dockerignore: %w", err)
+	}
+	for _, path := range skip {
+		if rel, err := filepath.Rel(contextDir, path); err == nil && filepath.IsLocal(rel) {
+			excludes = append(excludes, filepath.ToSlash(rel))
+		}
+	}
+
+	return archive.TarWithOptions(contextDir, &archive.TarOptions{ExcludePatterns: excludes})
+}
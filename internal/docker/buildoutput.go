@@ -0,0 +1,198 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"docker-management-system/internal/logging"
+
+	"github.com/docker/docker/api/types"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"go.uber.org/zap"
+)
+
+var (
+	// legacyStepPattern matches the classic builder's "Step 3/7 : RUN npm ci"
+	legacyStepPattern = regexp.MustCompile(`^Step (\d+)/(\d+) : (.+)$`)
+	// buildkitStepPattern matches BuildKit's plain progress, e.g. "#7 [builder 3/7] RUN npm ci";
+	// internal steps such as "#1 [internal] load build definition" carry no step count
+	buildkitStepPattern = regexp.MustCompile(`^#\d+ \[(?:[\w.-]+ )?(\d+)/(\d+)\] (.+)$`)
+	// vertexStepPattern matches the name of a BuildKit vertex, e.g. "[builder 3/7] RUN npm ci"
+	vertexStepPattern = regexp.MustCompile(`^\[(?:[\w.-]+ )?(\d+)/(\d+)\] (.+)$`)
+)
+
+// IDs of the auxiliary messages in a build's output stream
+const (
+	// buildkitTraceAux carries a BuildKit status update as a protobuf StatusResponse
+	buildkitTraceAux = "moby.buildkit.trace"
+	// imageIDAux carries the ID of the built image
+	imageIDAux = "moby.image.id"
+)
+
+// BuildStep is one Dockerfile instruction as reported in a build's output
+type BuildStep struct {
+	Number  int    `json:"step"`
+	Total   int    `json:"total"`
+	Command string `json:"command"`
+}
+
+// BuildOutput is what a build's output stream reported
+type BuildOutput struct {
+	Steps   []BuildStep
+	ImageID string
+}
+
+// BuildStepError reports a build that failed, with the step that was running when it did.
+// Step is nil when the build failed before its first step, e.g. on a Dockerfile syntax error.
+type BuildStepError struct {
+	Step    *BuildStep
+	Message string
+}
+
+func (e *BuildStepError) Error() string {
+	if e.Step == nil {
+		return fmt.Sprintf("build failed: %s", e.Message)
+	}
+	return fmt.Sprintf("build failed at step %d/%d (%s): %s", e.Step.Number, e.Step.Total, e.Step.Command, e.Message)
+}
+
+// ParseBuildOutput reads an image build's JSON output stream, as returned by the daemon's
+// build endpoint, and logs each step as it starts. The classic builder's "Step N/M" lines,
+// BuildKit's plain progress lines and the status updates BuildKit sends through the API are
+// all recognized. A build that fails part way is reported in the stream rather than by the
+// API call, so an error message in the stream is returned as a *BuildStepError naming the
+// step that failed, or the one that was running when BuildKit does not say.
+func ParseBuildOutput(ctx context.Context, stream io.Reader) (BuildOutput, error) {
+	logger := logging.GetLogger(ctx)
+	decoder := json.NewDecoder(stream)
+	var output BuildOutput
+	// BuildKit repeats a vertex in every update about it, so each is recorded once
+	seen := map[string]bool{}
+	var failed *BuildStep
+	addStep := func(step BuildStep) {
+		output.Steps = append(output.Steps, step)
+		logger.Info("build step",
+			zap.Int("step", step.Number),
+			zap.Int("total", step.Total),
+			zap.String("command", step.Command),
+		)
+	}
+	for {
+		var message struct {
+			ID          string          `json:"id"`
+			Aux         json.RawMessage `json:"aux"`
+			Stream      string          `json:"stream"`
+			Error       string          `json:"error"`
+			ErrorDetail *struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return output, nil
+		} else if err != nil {
+			return output, &ClientError{Op: "read_build_output", Err: err}
+		}
+
+		for _, line := range strings.Split(message.Stream, "\n") {
+			step, ok := parseBuildStep(strings.TrimSpace(line))
+			if !ok {
+				continue
+			}
+			// BuildKit's plain progress repeats a step's header as its status changes
+			if n := len(output.Steps); n > 0 && output.Steps[n-1] == step {
+				continue
+			}
+			addStep(step)
+		}
+
+		switch message.ID {
+		case imageIDAux:
+			var result types.BuildResult
+			if err := json.Unmarshal(message.Aux, &result); err != nil {
+				return output, &ClientError{Op: "read_build_output", Err: err}
+			}
+			output.ImageID = result.ID
+		case buildkitTraceAux:
+			status, err := decodeBuildkitStatus(message.Aux)
+			if err != nil {
+				return output, &ClientError{Op: "read_build_output", Err: err}
+			}
+			for _, vertex := range status.Vertexes {
+				match := vertexStepPattern.FindStringSubmatch(vertex.Name)
+				if match == nil || vertex.Started == nil {
+					continue
+				}
+				step := newBuildStep(match)
+				if !seen[vertex.Digest] {
+					seen[vertex.Digest] = true
+					addStep(step)
+				}
+				if vertex.Error != "" && failed == nil {
+					failed = &step
+				}
+			}
+		}
+
+		errMessage := message.Error
+		if errMessage == "" && message.ErrorDetail != nil {
+			errMessage = message.ErrorDetail.Message
+		}
+		if errMessage != "" {
+			buildErr := &BuildStepError{Step: failed, Message: strings.TrimSpace(errMessage)}
+			if n := len(output.Steps); buildErr.Step == nil && n > 0 {
+				buildErr.Step = &output.Steps[n-1]
+			}
+			logging.LogError(ctx, "build step failed", errors.New(buildErr.Message), buildStepFields(buildErr.Step)...)
+			return output, buildErr
+		}
+	}
+}
+
+// decodeBuildkitStatus decodes the base64 protobuf status update of a BuildKit trace message
+func decodeBuildkitStatus(aux json.RawMessage) (*controlapi.StatusResponse, error) {
+	var data []byte
+	if err := json.Unmarshal(aux, &data); err != nil {
+		return nil, err
+	}
+	var status controlapi.StatusResponse
+	if err := status.UnmarshalVT(data); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// parseBuildStep recognizes a step header in a line of build output
+func parseBuildStep(line string) (BuildStep, bool) {
+	match := legacyStepPattern.FindStringSubmatch(line)
+	if match == nil {
+		match = buildkitStepPattern.FindStringSubmatch(line)
+	}
+	if match == nil {
+		return BuildStep{}, false
+	}
+	return newBuildStep(match), true
+}
+
+// newBuildStep converts a step pattern match into a BuildStep
+func newBuildStep(match []string) BuildStep {
+	number, _ := strconv.Atoi(match[1])
+	total, _ := strconv.Atoi(match[2])
+	return BuildStep{Number: number, Total: total, Command: match[3]}
+}
+
+func buildStepFields(step *BuildStep) []zap.Field {
+	if step == nil {
+		return nil
+	}
+	return []zap.Field{
+		zap.Int("step", step.Number),
+		zap.Int("total", step.Total),
+		zap.String("command", step.Command),
+	}
+}
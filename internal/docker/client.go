@@ -1,24 +1,44 @@
 package docker
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"docker-management-system/internal/logging"
+
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/client"
 	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/docker/go-connections/nat"
+	"go.uber.org/zap"
 )
 
 // Client wraps the Docker client
 type Client struct {
-	cli *client.Client
+	mu  sync.RWMutex
+	cli client.APIClient
+	// connect creates a new API client with the original options; nil for wrapped clients
+	connect     func() (client.APIClient, error)
+	reconnectMu sync.Mutex
+	listCache   *listCache
+	// inspectCache is nil unless EnableInspectCache was called
+	inspectCache *inspectCache
+	// buildTempDir is where build contexts are staged; empty uses the OS temp directory
+	buildTempDir string
 }
 
 // NewClient creates a new Docker client
@@ -36,7 +56,10 @@ func NewClient(host, version string, tlsVerify bool, certPath string) (*Client,
 		))
 	}
 
-	cli, err := client.NewClientWithOpts(opts...)
+	connect := func() (client.APIClient, error) {
+		return client.NewClientWithOpts(opts...)
+	}
+	cli, err := connect()
 	if err != nil {
 		return nil, &ClientError{
 			Op:  "connect",
@@ -44,7 +67,12 @@ func NewClient(host, version string, tlsVerify bool, certPath string) (*Client,
 		}
 	}
 
-	return &Client{cli: cli}, nil
+	return &Client{cli: cli, connect: connect}, nil
+}
+
+// NewClientFromAPI wraps an existing Docker API client, e.g. a fake in tests
+func NewClientFromAPI(cli client.APIClient) *Client {
+	return &Client{cli: cli}
 }
 
 // ClientError represents Docker client operation errors
@@ -61,18 +89,84 @@ func (e *ClientError) Error() string {
 	return fmt.Sprintf("docker %s failed: %v", e.Op, e.Err)
 }
 
+// Unwrap returns the underlying error, so errors.Is and errors.As see through ClientError
+func (e *ClientError) Unwrap() error {
+	return e.Err
+}
+
+// DefaultCPUPeriod is the CFS scheduler period Docker uses by default, in microseconds
+const DefaultCPUPeriod int64 = 100000
+
+const (
+	// ManagedByLabel is the label key marking containers created by this service
+	ManagedByLabel = "managed-by"
+	// ManagedByValue is the value of ManagedByLabel on containers created by this service
+	ManagedByValue = "block-builder"
+	// RequestIDLabel records the X-Request-ID of the API request that created a container
+	RequestIDLabel = "block-builder.request-id"
+	// LogLevelLabel records the log level a container was created with, see its LOG_LEVEL env var
+	LogLevelLabel = "log-level"
+	// ProjectPathLabel records the project directory a container or image was built from
+	ProjectPathLabel = "block-builder.project-path"
+	// BuildSpecLabel records, as JSON, the request settings a container's image was built with
+	BuildSpecLabel = "block-builder.build-spec"
+)
+
 // ContainerConfig represents the configuration for creating a container
 type ContainerConfig struct {
-	Image         string
-	Command       []string
-	Env           []string
-	WorkingDir    string
-	CPUShares     int64
-	MemoryLimit   int64
-	NetworkMode   string
-	RestartPolicy string
-	Labels        map[string]string
-	Ports         map[string]string // Format: "containerPort:hostPort", e.g., "3000:3000"
+	Image             string
+	Command           []string
+	Env               []string
+	WorkingDir        string
+	CPUShares         int64
+	CPUQuota          int64   // CFS quota in microseconds per period
+	CPUPeriod         int64   // CFS period in microseconds, defaults to DefaultCPUPeriod when CPULimit is set
+	CPULimit          float64 // Hard CPU cap in CPUs, e.g. 0.5; converted to CPUQuota/CPUPeriod
+	MemoryLimit       int64
+	MemoryReservation int64 // Soft memory limit in bytes
+	MemorySwap        int64 // Memory plus swap limit in bytes; -1 allows unlimited swap
+	NetworkMode       string
+	RestartPolicy     string
+	RestartMaxRetries int // Restart attempts before giving up; only valid with on-failure, 0 retries forever
+	Labels            map[string]string
+	Ports             map[string]string // Container port spec to host port, e.g. "3000": "3000" or "53/udp": "5353"
+	BindIP            string            // Host address ports are published on; empty binds all interfaces
+	ReadOnlyRootFS    bool
+	TmpfsMounts       []string // Format: "path[:options]", e.g., "/tmp:size=64m"
+	CapAdd            []string
+	CapDrop           []string
+	PidsLimit         int64
+	ShmSize           int64 // Size of /dev/shm in bytes; 0 uses the daemon default of 64MB
+	Ulimits           []UlimitSpec
+	AutoRemove        bool         // Remove the container when it exits; requires the "no" restart policy
+	ExtraHosts        []string     // Extra /etc/hosts entries in "hostname:ip" format
+	DNS               []string     // DNS server IPs, replacing the daemon defaults
+	DNSSearch         []string     // DNS search domains
+	DNSOptions        []string     // resolv.conf options, e.g. "ndots:2"
+	Init              bool         // Run Docker's init process (tini) as PID 1 to reap zombies and forward signals
+	StopSignal        string       // Signal sent by docker stop, e.g. "SIGINT"; empty uses the image default
+	StopTimeout       int          // Seconds docker stop waits before killing the container; 0 uses the daemon default
+	SecretFiles       []SecretFile // Host files bind-mounted read-only, written by WriteSecrets
+	Platform          string       // Platform to run, e.g. "linux/arm64"; empty uses the daemon's
+	User              string       // Numeric "uid[:gid]" the process runs as, overriding the image's USER
+	LogDriver         string            // Log driver, e.g. "json-file" or "journald"; empty uses DefaultLogConfig
+	LogOpts           map[string]string // Log driver options, e.g. "max-size": "10m"
+	Sysctls           map[string]string // Namespaced kernel parameters, e.g. "net.core.somaxconn": "1024"
+	Devices           []DeviceMapping   // Host devices exposed in the container
+}
+
+// DeviceMapping exposes a host device in the container, e.g. {"hostPath": "/dev/ttyUSB0", "permissions": "rw"}
+type DeviceMapping struct {
+	HostPath      string `json:"hostPath"`
+	ContainerPath string `json:"containerPath,omitempty"` // Defaults to HostPath
+	Permissions   string `json:"permissions,omitempty"`   // Cgroup permissions, any of r (read), w (write) and m (mknod); defaults to rwm
+}
+
+// UlimitSpec represents a process resource limit, e.g. {"name": "nofile", "soft": 1024, "hard": 4096}
+type UlimitSpec struct {
+	Name string `json:"name"`
+	Soft int64  `json:"soft"`
+	Hard int64  `json:"hard"`
 }
 
 // ContainerInfo represents container information
@@ -97,6 +191,8 @@ type ContainerInfo struct {
 	Mounts          []Mount           `json:"mounts"`
 	HostConfig      HostConfig        `json:"host_config"`
 	ExitCode        int               `json:"exit_code"`
+	AgeSeconds      int64             `json:"age_seconds,omitempty"`    // Seconds since the container was created
+	UptimeSeconds   int64             `json:"uptime_seconds,omitempty"` // Seconds since the container started; only set while it is running
 }
 
 // NetworkInfo represents container network settings
@@ -139,28 +235,114 @@ type HostConfig struct {
 	CPUPeriod  int64 `json:"cpu_period"`
 }
 
-// CreateContainer creates a new container with the given configuration
-func (c *Client) CreateContainer(ctx context.Context, name string, config ContainerConfig) (string, error) {
+// parsePortSpec parses a container port such as "3000" or "53/udp"; the protocol defaults to tcp
+func parsePortSpec(spec string) (nat.Port, error) {
+	port, proto, _ := strings.Cut(spec, "/")
+	if proto == "" {
+		proto = "tcp"
+	}
+	proto = strings.ToLower(proto)
+	if proto != "tcp" && proto != "udp" {
+		return "", fmt.Errorf("port %s: protocol must be tcp or udp", spec)
+	}
+	return nat.NewPort(proto, port)
+}
+
+// CreateContainer creates a new container with the given configuration. It returns the
+// container ID along with any warnings the daemon reported, such as ignored resource limits.
+func (c *Client) CreateContainer(ctx context.Context, name string, config ContainerConfig) (string, []string, error) {
+	defer c.invalidateListCache()
+
 	// Prepare port bindings
 	portBindings := nat.PortMap{}
 	exposedPorts := nat.PortSet{}
+	bindIP := config.BindIP
+	if bindIP == "" {
+		bindIP = "0.0.0.0"
+	}
 
-	// Configure default port for Node.js applications
 	for containerPort, hostPort := range config.Ports {
-		natPort, err := nat.NewPort("tcp", strings.Split(containerPort, "/")[0])
+		natPort, err := parsePortSpec(containerPort)
 		if err != nil {
-			return "", &ClientError{Op: "create container", Err: err, Details: "invalid port configuration"}
+			return "", nil, &ClientError{Op: "create container", Err: err, Details: "invalid port configuration"}
 		}
 
 		portBindings[natPort] = []nat.PortBinding{{
-			HostIP:   "0.0.0.0",
+			HostIP:   bindIP,
 			HostPort: hostPort,
 		}}
 		exposedPorts[natPort] = struct{}{}
 	}
 
+	// Writable tmpfs mounts, typically paired with a read-only root filesystem
+	var tmpfs map[string]string
+	if len(config.TmpfsMounts) > 0 {
+		tmpfs = make(map[string]string, len(config.TmpfsMounts))
+		for _, mount := range config.TmpfsMounts {
+			path, options, _ := strings.Cut(mount, ":")
+			tmpfs[path] = options
+		}
+	}
+
+	// Secret files are bind-mounted read-only so the values stay out of the config and image
+	var mounts []mount.Mount
+	for _, file := range config.SecretFiles {
+		mounts = append(mounts, mount.Mount{
+			Type:     mount.TypeBind,
+			Source:   file.Source,
+			Target:   file.Target,
+			ReadOnly: true,
+		})
+	}
+
+	// Resource constraints
+	resources := container.Resources{
+		Memory:            config.MemoryLimit,
+		MemoryReservation: config.MemoryReservation,
+		MemorySwap:        config.MemorySwap,
+		CPUShares:         config.CPUShares,
+	}
+	resources.CPUQuota, resources.CPUPeriod = cpuQuotaAndPeriod(config)
+	if config.PidsLimit > 0 {
+		pidsLimit := config.PidsLimit
+		resources.PidsLimit = &pidsLimit
+	}
+	for _, ulimit := range config.Ulimits {
+		resources.Ulimits = append(resources.Ulimits, &container.Ulimit{
+			Name: ulimit.Name,
+			Soft: ulimit.Soft,
+			Hard: ulimit.Hard,
+		})
+	}
+	for _, device := range config.Devices {
+		resources.Devices = append(resources.Devices, container.DeviceMapping{
+			PathOnHost:        device.HostPath,
+			PathInContainer:   device.ContainerPath,
+			CgroupPermissions: device.Permissions,
+		})
+	}
+
+	logConfig := container.LogConfig{Type: config.LogDriver, Config: config.LogOpts}
+	if logConfig.Type == "" {
+		logConfig = DefaultLogConfig()
+	}
+
+	platform, err := ParsePlatform(config.Platform)
+	if err != nil {
+		return "", nil, &ClientError{
+			Op:      "create_container",
+			Err:     err,
+			Details: "invalid platform",
+		}
+	}
+
+	var stopTimeout *int
+	if config.StopTimeout > 0 {
+		stopTimeout = &config.StopTimeout
+	}
+
 	// Create container
-	cont, err := c.cli.ContainerCreate(
+	cont, err := c.api().ContainerCreate(
 		ctx,
 		&container.Config{
 			Image:        config.Image,
@@ -169,25 +351,41 @@ func (c *Client) CreateContainer(ctx context.Context, name string, config Contai
 			WorkingDir:  config.WorkingDir,
 			Labels:      config.Labels,
 			ExposedPorts: exposedPorts,
+			StopSignal:  config.StopSignal,
+			StopTimeout: stopTimeout,
+			User:        config.User,
 		},
 		&container.HostConfig{
 			NetworkMode:   container.NetworkMode(config.NetworkMode),
 			PortBindings: portBindings,
-			Resources: container.Resources{
-				Memory:    config.MemoryLimit,
-				CPUShares: config.CPUShares,
-			},
+			Resources:    resources,
 			RestartPolicy: container.RestartPolicy{
-				Name: container.RestartPolicyMode(config.RestartPolicy),
+				Name:              container.RestartPolicyMode(config.RestartPolicy),
+				MaximumRetryCount: config.RestartMaxRetries,
 			},
+			ReadonlyRootfs: config.ReadOnlyRootFS,
+			Tmpfs:          tmpfs,
+			Mounts:         mounts,
+			CapAdd:         config.CapAdd,
+			CapDrop:        config.CapDrop,
+			AutoRemove:     config.AutoRemove,
+			ExtraHosts:     config.ExtraHosts,
+			DNS:            config.DNS,
+			DNSSearch:      config.DNSSearch,
+			DNSOptions:     config.DNSOptions,
+			Init:           &config.Init,
+			LogConfig:      logConfig,
+			Sysctls:        config.Sysctls,
+			ShmSize:        config.ShmSize,
 		},
 		nil,
-		nil,
+		platform,
 		name,
 	)
 
 	if err != nil {
-		return "", &ClientError{
+		c.checkConnection(ctx, err)
+		return "", nil, &ClientError{
 			Op:      "create_container",
 			Err:     err,
 			Details: "failed to create container",
@@ -195,29 +393,70 @@ func (c *Client) CreateContainer(ctx context.Context, name string, config Contai
 	}
 
 	for _, warning := range cont.Warnings {
-		fmt.Printf("Warning during container creation: %s\n", warning)
+		logging.GetLogger(ctx).Warn("container creation warning",
+			zap.String("operation", "create_container"),
+			zap.String("container_id", cont.ID),
+			zap.String("container_name", name),
+			zap.String("warning", warning),
+		)
 	}
 
-	return cont.ID, nil
+	return cont.ID, cont.Warnings, nil
+}
+
+// cpuQuotaAndPeriod resolves the CFS quota and period, deriving them from CPULimit when given
+func cpuQuotaAndPeriod(config ContainerConfig) (int64, int64) {
+	if config.CPULimit <= 0 {
+		return config.CPUQuota, config.CPUPeriod
+	}
+
+	period := config.CPUPeriod
+	if period == 0 {
+		period = DefaultCPUPeriod
+	}
+	return int64(math.Round(config.CPULimit * float64(period))), period
 }
 
 // StartContainer starts a container
 func (c *Client) StartContainer(ctx context.Context, containerID string) error {
-	return c.cli.ContainerStart(ctx, containerID, container.StartOptions{})
+	defer c.invalidateListCache()
+	defer c.evictInspect(containerID)
+	return c.checkConnection(ctx, c.api().ContainerStart(ctx, containerID, container.StartOptions{}))
 }
 
-// ListContainers returns a list of containers
-func (c *Client) ListContainers(ctx context.Context, all bool, labelFilter map[string]string) ([]ContainerInfo, error) {
+// labelFilterArgs builds Docker label filters; an empty value matches any container
+// carrying the key regardless of its value
+func labelFilterArgs(labelFilter map[string]string) filters.Args {
 	filterArgs := filters.NewArgs()
 	for k, v := range labelFilter {
+		if v == "" {
+			filterArgs.Add("label", k)
+			continue
+		}
 		filterArgs.Add("label", fmt.Sprintf("%s=%s", k, v))
 	}
+	return filterArgs
+}
 
-	containers, err := c.cli.ContainerList(ctx, container.ListOptions{
+// ListContainers returns a list of containers, served from the list cache when enabled
+func (c *Client) ListContainers(ctx context.Context, all bool, labelFilter map[string]string) ([]ContainerInfo, error) {
+	if c.listCache == nil {
+		return c.listContainers(ctx, all, labelFilter)
+	}
+	return c.listCache.get(ctx, listCacheKey(all, labelFilter), func() ([]ContainerInfo, error) {
+		return c.listContainers(ctx, all, labelFilter)
+	})
+}
+
+func (c *Client) listContainers(ctx context.Context, all bool, labelFilter map[string]string) ([]ContainerInfo, error) {
+	filterArgs := labelFilterArgs(labelFilter)
+
+	containers, err := c.api().ContainerList(ctx, container.ListOptions{
 		All:     all,
 		Filters: filterArgs,
 	})
 	if err != nil {
+		c.checkConnection(ctx, err)
 		return nil, &ClientError{
 			Op:  "list_containers",
 			Err: err,
@@ -240,23 +479,271 @@ func (c *Client) ListContainers(ctx context.Context, all bool, labelFilter map[s
 	return containerInfos, nil
 }
 
+// ContainerNames returns the names of all containers whose name contains substr. It bypasses
+// the list cache because callers use it to pick a name that must not be taken.
+func (c *Client) ContainerNames(ctx context.Context, substr string) ([]string, error) {
+	containers, err := c.api().ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("name", substr)),
+	})
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return nil, &ClientError{
+			Op:  "list_containers",
+			Err: err,
+		}
+	}
+
+	var names []string
+	for _, container := range containers {
+		for _, name := range container.Names {
+			names = append(names, strings.TrimPrefix(name, "/"))
+		}
+	}
+	return names, nil
+}
+
 // RemoveContainer removes a container
 func (c *Client) RemoveContainer(ctx context.Context, containerID string, force bool) error {
-	return c.cli.ContainerRemove(ctx, containerID, container.RemoveOptions{
+	defer c.invalidateListCache()
+	defer c.evictInspect(containerID)
+	return c.checkConnection(ctx, c.api().ContainerRemove(ctx, containerID, container.RemoveOptions{
 		Force: force,
-	})
+	}))
 }
 
-// GetContainerLogs retrieves container logs
-func (c *Client) GetContainerLogs(ctx context.Context, containerID string, tail string) (string, error) {
-	options := container.LogsOptions{
-		ShowStdout: true,
-		ShowStderr: true,
-		Tail:       tail,
+// StopContainer stops a container, killing it if it has not exited after timeout
+func (c *Client) StopContainer(ctx context.Context, containerID string, timeout time.Duration) error {
+	defer c.invalidateListCache()
+	defer c.evictInspect(containerID)
+
+	seconds := int(timeout.Seconds())
+	if err := c.api().ContainerStop(ctx, containerID, container.StopOptions{Timeout: &seconds}); err != nil {
+		c.checkConnection(ctx, err)
+		return &ClientError{
+			Op:  "stop",
+			Err: fmt.Errorf("container %s: %w", containerID, err),
+		}
+	}
+	return nil
+}
+
+// StopTimeout returns the grace period set on the container when it was created, or fallback
+// for containers created without one
+func (c *Client) StopTimeout(ctx context.Context, containerID string, fallback time.Duration) (time.Duration, error) {
+	inspect, err := c.InspectContainerRaw(ctx, containerID)
+	if err != nil {
+		return 0, err
+	}
+	if inspect.Config != nil && inspect.Config.StopTimeout != nil {
+		return time.Duration(*inspect.Config.StopTimeout) * time.Second, nil
+	}
+	return fallback, nil
+}
+
+// StopResult reports the outcome of stopping a single container
+type StopResult struct {
+	ContainerID string `json:"containerId"`
+	Name        string `json:"name"`
+	Stopped     bool   `json:"stopped"`
+	Error       string `json:"error,omitempty"`
+}
+
+// StopManagedContainers stops every running container carrying the managed-by label, at most
+// concurrency at a time. With containerDefault, each container is given the stop timeout it was
+// created with and timeout only applies to containers created without one. It returns a
+// result per container and the joined stop errors.
+func (c *Client) StopManagedContainers(ctx context.Context, timeout time.Duration, containerDefault bool, concurrency int) ([]StopResult, error) {
+	containers, err := c.ListContainers(ctx, false, map[string]string{ManagedByLabel: ManagedByValue})
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]StopResult, 0, len(containers))
+	errs := make([]error, 0, len(containers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, info := range containers {
+		// Never rely on the daemon-side filter alone to decide what may be stopped
+		if info.Labels[ManagedByLabel] != ManagedByValue {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(info ContainerInfo) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := StopResult{ContainerID: info.ID, Name: info.Name}
+			stopTimeout, err := timeout, error(nil)
+			if containerDefault {
+				stopTimeout, err = c.StopTimeout(ctx, info.ID, timeout)
+			}
+			if err == nil {
+				err = c.StopContainer(ctx, info.ID, stopTimeout)
+			}
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Stopped = true
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(info)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ContainerID < results[j].ContainerID })
+	return results, errors.Join(errs...)
+}
+
+// RestartResult reports the outcome of restarting a single exited container. Skipped is set
+// for a container that exited cleanly when clean exits were not included.
+type RestartResult struct {
+	ContainerID string `json:"containerId"`
+	Name        string `json:"name"`
+	ExitCode    int    `json:"exitCode"`
+	Restarted   bool   `json:"restarted"`
+	Skipped     bool   `json:"skipped,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// RestartExitedContainers starts every exited container carrying the managed-by label that
+// exited with a non-zero code, or with any code when includeClean is set, at most concurrency
+// at a time. It returns a result per exited container and the joined errors.
+func (c *Client) RestartExitedContainers(ctx context.Context, includeClean bool, concurrency int) ([]RestartResult, error) {
+	containers, err := c.ListContainers(ctx, true, map[string]string{ManagedByLabel: ManagedByValue})
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]RestartResult, 0, len(containers))
+	errs := make([]error, 0, len(containers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, info := range containers {
+		if info.Labels[ManagedByLabel] != ManagedByValue || info.State != "exited" {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(info ContainerInfo) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := RestartResult{ContainerID: info.ID, Name: info.Name}
+			// The list only reports the exit code inside its human-readable status
+			err := c.restartExited(ctx, info.ID, includeClean, &result)
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(info)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ContainerID < results[j].ContainerID })
+	return results, errors.Join(errs...)
+}
+
+// restartExited inspects an exited container for its exit code and starts it unless it
+// exited cleanly and clean exits are not included
+func (c *Client) restartExited(ctx context.Context, containerID string, includeClean bool, result *RestartResult) error {
+	inspect, err := c.GetContainer(ctx, containerID)
+	if err != nil {
+		return err
+	}
+	result.ExitCode = inspect.ExitCode
+	if inspect.ExitCode == 0 && !includeClean {
+		result.Skipped = true
+		return nil
+	}
+	if err := c.StartContainer(ctx, containerID); err != nil {
+		return &ClientError{Op: "restart_exited", Err: err, Details: containerID}
+	}
+	result.Restarted = true
+	return nil
+}
+
+// LogOptions selects which container logs to retrieve
+type LogOptions struct {
+	Tail   string         // Number of lines from the end, or "all"
+	Since  string         // RFC3339 timestamp, Unix timestamp or relative duration such as "10m"
+	Grep   *regexp.Regexp // Only return lines matching this pattern
+	Invert bool           // Return lines not matching Grep instead
+	Stream string         // LogStreamStdout or LogStreamStderr to return only that stream; empty for both
+}
+
+const (
+	// LogStreamStdout selects only a container's standard output
+	LogStreamStdout = "stdout"
+	// LogStreamStderr selects only a container's standard error
+	LogStreamStderr = "stderr"
+)
+
+// keep reports whether a log line passes the Grep filter
+func (o LogOptions) keep(line string) bool {
+	if o.Grep == nil {
+		return true
+	}
+	return o.Grep.MatchString(line) != o.Invert
+}
+
+// filterLines keeps the newline-terminated lines of logs that pass the Grep filter
+func (o LogOptions) filterLines(logs string) string {
+	if o.Grep == nil {
+		return logs
+	}
+	var b strings.Builder
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		if line != "" && o.keep(strings.TrimSuffix(line, "\n")) {
+			b.WriteString(line)
+		}
+	}
+	return b.String()
+}
+
+// logsOptions converts LogOptions into Docker's log options, requesting both streams unless
+// Stream selects one
+func (o LogOptions) logsOptions() container.LogsOptions {
+	return container.LogsOptions{
+		ShowStdout: o.Stream != LogStreamStderr,
+		ShowStderr: o.Stream != LogStreamStdout,
+		Tail:       o.Tail,
+		Since:      o.Since,
 	}
+}
 
-	logs, err := c.cli.ContainerLogs(ctx, containerID, options)
+// GetContainerLogs retrieves container logs
+func (c *Client) GetContainerLogs(ctx context.Context, containerID string, opts LogOptions) (string, error) {
+	options := opts.logsOptions()
+
+	logs, err := c.api().ContainerLogs(ctx, containerID, options)
 	if err != nil {
+		c.checkConnection(ctx, err)
 		return "", &ClientError{
 			Op:  "get_logs",
 			Err: err,
@@ -275,28 +762,200 @@ func (c *Client) GetContainerLogs(ctx context.Context, containerID string, tail
 	stdout = stdoutBuf
 	stderr = stderrBuf
 
+	// A stream that breaks off part way, e.g. a truncated frame, still returns what was read
+	// before it, with an error wrapping ErrPartialLogs
 	_, err = stdcopy.StdCopy(stdout, stderr, logs)
 	if err != nil {
-		return "", &ClientError{
+		err = &ClientError{
 			Op:  "read_logs",
-			Err: err,
+			Err: fmt.Errorf("%w: %w", ErrPartialLogs, err),
 		}
 	}
 
 	// Combine stdout and stderr
-	return fmt.Sprintf("STDOUT:\n%s\nSTDERR:\n%s", stdoutBuf.String(), stderrBuf.String()), nil
+	return fmt.Sprintf("STDOUT:\n%s\nSTDERR:\n%s", opts.filterLines(stdoutBuf.String()), opts.filterLines(stderrBuf.String())), err
+}
+
+// LogEntry is a single timestamped log line from a container
+type LogEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Stream    string    `json:"stream"`
+	Message   string    `json:"message"`
+}
+
+// GetContainerLogEntries retrieves container logs with timestamps as structured entries. Like
+// GetContainerLogs, a stream that breaks off part way returns the entries read before it, with
+// an error wrapping ErrPartialLogs.
+func (c *Client) GetContainerLogEntries(ctx context.Context, containerID string, opts LogOptions) ([]LogEntry, error) {
+	options := opts.logsOptions()
+	options.Timestamps = true
+
+	logs, err := c.api().ContainerLogs(ctx, containerID, options)
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return nil, &ClientError{
+			Op:  "get_logs",
+			Err: err,
+		}
+	}
+	defer logs.Close()
+
+	entries, err := parseLogEntries(logs)
+	if err != nil {
+		err = &ClientError{
+			Op:  "read_logs",
+			Err: fmt.Errorf("%w: %w", ErrPartialLogs, err),
+		}
+	}
+	if opts.Grep != nil {
+		matched := make([]LogEntry, 0, len(entries))
+		for _, entry := range entries {
+			if opts.keep(entry.Message) {
+				matched = append(matched, entry)
+			}
+		}
+		entries = matched
+	}
+	return entries, err
+}
+
+// parseLogEntries demultiplexes a Docker log stream produced with timestamps enabled
+// into entries, preserving the interleaving of stdout and stderr lines. A stream that fails
+// part way returns the entries read before it with the error.
+func parseLogEntries(r io.Reader) ([]LogEntry, error) {
+	var entries []LogEntry
+	stdout := &logEntryWriter{stream: LogStreamStdout, entries: &entries}
+	stderr := &logEntryWriter{stream: LogStreamStderr, entries: &entries}
+
+	_, err := stdcopy.StdCopy(stdout, stderr, r)
+	stdout.flush()
+	stderr.flush()
+
+	return entries, err
+}
+
+// logEntryWriter splits a demultiplexed stream into lines and appends them as entries.
+// Incomplete lines are held until the rest of the line arrives in a later frame.
+type logEntryWriter struct {
+	stream  string
+	entries *[]LogEntry
+	partial []byte
+}
+
+func (w *logEntryWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for {
+		idx := bytes.IndexByte(w.partial, '\n')
+		if idx < 0 {
+			break
+		}
+		w.appendLine(string(w.partial[:idx]))
+		w.partial = w.partial[idx+1:]
+	}
+	return len(p), nil
+}
+
+// flush emits any trailing line that was not newline-terminated
+func (w *logEntryWriter) flush() {
+	if len(w.partial) > 0 {
+		w.appendLine(string(w.partial))
+		w.partial = nil
+	}
+}
+
+func (w *logEntryWriter) appendLine(line string) {
+	entry := LogEntry{Stream: w.stream, Message: line}
+	if ts, msg, found := strings.Cut(line, " "); found {
+		if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			entry.Timestamp = parsed
+			entry.Message = msg
+		}
+	}
+	*w.entries = append(*w.entries, entry)
+}
+
+// OpenContainerLogs opens the raw multiplexed log stream of a container. The caller must close it.
+func (c *Client) OpenContainerLogs(ctx context.Context, containerID string, opts LogOptions) (io.ReadCloser, error) {
+	logs, err := c.api().ContainerLogs(ctx, containerID, opts.logsOptions())
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return nil, &ClientError{
+			Op:  "get_logs",
+			Err: err,
+		}
+	}
+	return logs, nil
+}
+
+// CopyLogsWithMarkers demultiplexes a log stream into dst, prefixing each line with
+// its stream ("[stdout] " or "[stderr] "). If dst is an http.Flusher it is flushed after
+// every frame so output reaches the client incrementally.
+func CopyLogsWithMarkers(dst io.Writer, src io.Reader) error {
+	stdout := &markerWriter{dst: dst, marker: "[stdout] ", atLineStart: true}
+	stderr := &markerWriter{dst: dst, marker: "[stderr] ", atLineStart: true}
+	_, err := stdcopy.StdCopy(stdout, stderr, src)
+	return err
+}
+
+// markerWriter prefixes every line written to dst with a stream marker
+type markerWriter struct {
+	dst         io.Writer
+	marker      string
+	atLineStart bool
+}
+
+func (w *markerWriter) Write(p []byte) (int, error) {
+	var out []byte
+	for _, b := range p {
+		if w.atLineStart {
+			out = append(out, w.marker...)
+		}
+		out = append(out, b)
+		w.atLineStart = b == '\n'
+	}
+	if _, err := w.dst.Write(out); err != nil {
+		return 0, err
+	}
+	if flusher, ok := w.dst.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	return len(p), nil
 }
 
 // CopyToContainer copies files to a container
 func (c *Client) CopyToContainer(ctx context.Context, containerID, dstPath string, content io.Reader) error {
-	return c.cli.CopyToContainer(ctx, containerID, dstPath, content, types.CopyToContainerOptions{})
+	return c.api().CopyToContainer(ctx, containerID, dstPath, content, types.CopyToContainerOptions{})
+}
+
+// InspectContainerRaw returns Docker's inspect result for a container unchanged
+func (c *Client) InspectContainerRaw(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
+	if err != nil {
+		c.checkConnection(ctx, err)
+		if client.IsErrNotFound(err) {
+			return types.ContainerJSON{}, &ClientError{
+				Op:      "inspect",
+				Err:     err,
+				Details: "Container not found",
+			}
+		}
+		return types.ContainerJSON{}, &ClientError{
+			Op:  "inspect",
+			Err: err,
+		}
+	}
+	return inspect, nil
 }
 
 // GetContainer returns detailed information about a specific container
 func (c *Client) GetContainer(ctx context.Context, containerID string) (*ContainerInfo, error) {
-	container, err := c.cli.ContainerInspect(ctx, containerID)
+	container, err := c.inspect(ctx, containerID)
 	if err != nil {
-		fmt.Printf("Error inspecting container %s: %v\n", containerID, err)
+		c.checkConnection(ctx, err)
+		logging.LogError(ctx, "failed to inspect container", err,
+			zap.String("operation", "inspect"),
+			zap.String("container_id", containerID),
+		)
 		if client.IsErrNotFound(err) {
 			return nil, &ClientError{
 				Op:      "inspect",
@@ -393,12 +1052,71 @@ func (c *Client) GetContainer(ctx context.Context, containerID string) (*Contain
 		ExitCode:     container.State.ExitCode,
 	}
 
+	// Durations are worked out here so clients need not compare the timestamps with their own clock
+	now := time.Now()
+	if !createdTime.IsZero() {
+		info.AgeSeconds = int64(now.Sub(createdTime).Seconds())
+	}
+	if container.State.Running && !startedTime.IsZero() {
+		info.UptimeSeconds = int64(now.Sub(startedTime).Seconds())
+	}
+
 	return info, nil
 }
 
+// ImagePruneReport summarizes the result of an image prune
+type ImagePruneReport struct {
+	ImagesDeleted  []string `json:"imagesDeleted"`
+	SpaceReclaimed uint64   `json:"spaceReclaimed"`
+}
+
+// PruneImages removes unused images. With dangling set only untagged images are removed;
+// otherwise every image not used by a container is. labelFilter restricts the prune to
+// images carrying the given labels.
+func (c *Client) PruneImages(ctx context.Context, dangling bool, labelFilter map[string]string) (ImagePruneReport, error) {
+	filterArgs := labelFilterArgs(labelFilter)
+	filterArgs.Add("dangling", strconv.FormatBool(dangling))
+
+	report, err := c.api().ImagesPrune(ctx, filterArgs)
+	if err != nil {
+		return ImagePruneReport{}, &ClientError{
+			Op:  "prune_images",
+			Err: err,
+		}
+	}
+
+	deleted := make([]string, 0, len(report.ImagesDeleted))
+	for _, item := range report.ImagesDeleted {
+		if item.Deleted != "" {
+			deleted = append(deleted, item.Deleted)
+		}
+	}
+	return ImagePruneReport{
+		ImagesDeleted:  deleted,
+		SpaceReclaimed: report.SpaceReclaimed,
+	}, nil
+}
+
+// Ping checks that the Docker daemon is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	if _, err := c.api().Ping(ctx); err != nil {
+		c.checkConnection(ctx, err)
+		return &ClientError{
+			Op:  "ping",
+			Err: err,
+		}
+	}
+	return nil
+}
+
+// Host returns the Docker daemon address the client connects to
+func (c *Client) Host() string {
+	return c.api().DaemonHost()
+}
+
 // Close closes the Docker client connection
 func (c *Client) Close() error {
-	if err := c.cli.Close(); err != nil {
+	if err := c.api().Close(); err != nil {
 		return &ClientError{
 			Op:  "close",
 			Err: err,
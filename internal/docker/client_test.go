@@ -0,0 +1,1229 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"docker-management-system/internal/docker/nodeproject"
+	"docker-management-system/internal/logging"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	controlapi "github.com/moby/buildkit/api/services/control"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// fakeAPI is a minimal Docker API fake; methods that are not overridden panic
+// through the nil embedded interface.
+type fakeAPI struct {
+	client.APIClient
+
+	inspectErr   error
+	inspect      types.ContainerJSON
+	inspectCalls atomic.Int32
+
+	events       chan events.Message
+	eventErrs    chan error
+	eventOptions events.ListOptions
+
+	loadStream string
+	logStream  io.Reader
+
+	buildStream  string
+	buildOptions types.ImageBuildOptions
+	buildFiles   []string
+	// sessions plays the daemon's side of build sessions; a build reads secretIDs through
+	// them into buildSecrets
+	sessions     *session.Manager
+	secretIDs    []string
+	buildSecrets map[string]string
+
+	pruneReport  image.PruneReport
+	pruneFilters filters.Args
+
+	images           []image.Summary
+	imageListOptions image.ListOptions
+	imageInspect     types.ImageInspect
+
+	list      []types.Container
+	listErr   error
+	listCalls atomic.Int32
+	listGate  chan struct{}
+
+	closed bool
+
+	createHostConfig *container.HostConfig
+	createConfig     *container.Config
+
+	stats         map[string]string
+	statsErrs     map[string]error
+	statsInFlight atomic.Int32
+	statsPeak     atomic.Int32
+}
+
+func (f *fakeAPI) ContainerCreate(ctx context.Context, config *container.Config, hostConfig *container.HostConfig, networkingConfig *network.NetworkingConfig, platform *ocispec.Platform, containerName string) (container.CreateResponse, error) {
+	f.createConfig = config
+	f.createHostConfig = hostConfig
+	return container.CreateResponse{ID: "abc123"}, nil
+}
+
+func (f *fakeAPI) ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	f.inspectCalls.Add(1)
+	if f.inspectErr != nil {
+		return types.ContainerJSON{}, f.inspectErr
+	}
+	return f.inspect, nil
+}
+
+func (f *fakeAPI) ContainerList(ctx context.Context, options container.ListOptions) ([]types.Container, error) {
+	f.listCalls.Add(1)
+	if f.listGate != nil {
+		<-f.listGate
+	}
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return f.list, nil
+}
+
+func (f *fakeAPI) ContainerStats(ctx context.Context, containerID string, stream bool) (container.StatsResponseReader, error) {
+	n := f.statsInFlight.Add(1)
+	defer f.statsInFlight.Add(-1)
+	for {
+		peak := f.statsPeak.Load()
+		if n <= peak || f.statsPeak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+	// Hold the slot briefly so concurrent reads overlap
+	time.Sleep(10 * time.Millisecond)
+
+	if err := f.statsErrs[containerID]; err != nil {
+		return container.StatsResponseReader{}, err
+	}
+	return container.StatsResponseReader{Body: io.NopCloser(strings.NewReader(f.stats[containerID]))}, nil
+}
+
+func (f *fakeAPI) Ping(ctx context.Context) (types.Ping, error) {
+	return types.Ping{}, nil
+}
+
+func (f *fakeAPI) Close() error {
+	f.closed = true
+	return nil
+}
+
+func (f *fakeAPI) ContainerStop(ctx context.Context, containerID string, options container.StopOptions) error {
+	return nil
+}
+
+func (f *fakeAPI) ContainerLogs(ctx context.Context, containerID string, options container.LogsOptions) (io.ReadCloser, error) {
+	return io.NopCloser(f.logStream), nil
+}
+
+func (f *fakeAPI) ImagesPrune(ctx context.Context, pruneFilter filters.Args) (image.PruneReport, error) {
+	f.pruneFilters = pruneFilter
+	return f.pruneReport, nil
+}
+
+func (f *fakeAPI) ImageLoad(ctx context.Context, input io.Reader, quiet bool) (image.LoadResponse, error) {
+	if _, err := io.Copy(io.Discard, input); err != nil {
+		return image.LoadResponse{}, err
+	}
+	return image.LoadResponse{Body: io.NopCloser(strings.NewReader(f.loadStream)), JSON: true}, nil
+}
+
+func (f *fakeAPI) ImageBuild(ctx context.Context, buildContext io.Reader, options types.ImageBuildOptions) (types.ImageBuildResponse, error) {
+	f.buildOptions = options
+	f.buildFiles = nil
+	tr := tar.NewReader(buildContext)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return types.ImageBuildResponse{}, err
+		}
+		f.buildFiles = append(f.buildFiles, hdr.Name)
+	}
+	if options.SessionID != "" && f.sessions != nil {
+		caller, err := f.sessions.Get(ctx, options.SessionID, false)
+		if err != nil {
+			return types.ImageBuildResponse{}, err
+		}
+		f.buildSecrets = map[string]string{}
+		for _, id := range f.secretIDs {
+			data, err := secrets.GetSecret(ctx, caller, id)
+			if err != nil {
+				return types.ImageBuildResponse{}, err
+			}
+			f.buildSecrets[id] = string(data)
+		}
+	}
+	return types.ImageBuildResponse{Body: io.NopCloser(strings.NewReader(f.buildStream))}, nil
+}
+
+func (f *fakeAPI) DialHijack(ctx context.Context, url, proto string, meta map[string][]string) (net.Conn, error) {
+	daemon, conn := net.Pipe()
+	go f.sessions.HandleConn(context.Background(), daemon, meta)
+	return conn, nil
+}
+
+func (f *fakeAPI) ImageList(ctx context.Context, options image.ListOptions) ([]image.Summary, error) {
+	f.imageListOptions = options
+	return f.images, nil
+}
+
+func (f *fakeAPI) ImageInspectWithRaw(ctx context.Context, imageID string) (types.ImageInspect, []byte, error) {
+	return f.imageInspect, nil, nil
+}
+
+func (f *fakeAPI) Events(ctx context.Context, options events.ListOptions) (<-chan events.Message, <-chan error) {
+	f.eventOptions = options
+	return f.events, f.eventErrs
+}
+
+// observedContext returns a context whose logger records entries for assertions
+func observedContext() (context.Context, *observer.ObservedLogs) {
+	core, logs := observer.New(zap.DebugLevel)
+	return logging.WithLogger(context.Background(), zap.New(core)), logs
+}
+
+func TestGetContainerInspectFailureLogsContext(t *testing.T) {
+	ctx, logs := observedContext()
+	c := NewClientFromAPI(&fakeAPI{inspectErr: errors.New("connection refused")})
+
+	if _, err := c.GetContainer(ctx, "deadbeef"); err == nil {
+		t.Fatal("Expected an error from GetContainer")
+	}
+
+	records := logs.FilterMessage("failed to inspect container").All()
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 log record, got %d", len(records))
+	}
+	fields := records[0].ContextMap()
+	if fields["container_id"] != "deadbeef" {
+		t.Errorf("container_id = %v, want deadbeef", fields["container_id"])
+	}
+	if fields["operation"] != "inspect" {
+		t.Errorf("operation = %v, want inspect", fields["operation"])
+	}
+}
+
+func TestGetContainerLogsPartialRead(t *testing.T) {
+	var buf bytes.Buffer
+	stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte("server listening on 3000\n"))
+	stdcopy.NewStdWriter(&buf, stdcopy.Stderr).Write([]byte("warning: slow query\n"))
+	// The connection drops after the first two frames
+	readErr := errors.New("read unix @->/var/run/docker.sock: connection reset by peer")
+	fake := &fakeAPI{logStream: io.MultiReader(&buf, iotest.ErrReader(readErr))}
+	c := NewClientFromAPI(fake)
+
+	logs, err := c.GetContainerLogs(context.Background(), "abc123", LogOptions{})
+	if !errors.Is(err, ErrPartialLogs) || !errors.Is(err, readErr) {
+		t.Fatalf("GetContainerLogs error = %v, want ErrPartialLogs wrapping the read error", err)
+	}
+	if !strings.Contains(logs, "server listening on 3000") || !strings.Contains(logs, "warning: slow query") {
+		t.Errorf("Partial logs lost: %q", logs)
+	}
+	// Structured entries keep what was read the same way
+	buf.Reset()
+	stdcopy.NewStdWriter(&buf, stdcopy.Stdout).Write([]byte("2024-05-01T10:00:00Z server listening on 3000\n"))
+	fake.logStream = io.MultiReader(&buf, iotest.ErrReader(readErr))
+	entries, err := c.GetContainerLogEntries(context.Background(), "abc123", LogOptions{})
+	if !errors.Is(err, ErrPartialLogs) || !errors.Is(err, readErr) {
+		t.Fatalf("GetContainerLogEntries error = %v, want ErrPartialLogs wrapping the read error", err)
+	}
+	if len(entries) != 1 || entries[0].Message != "server listening on 3000" {
+		t.Errorf("Partial entries lost: %+v", entries)
+	}
+}
+
+func TestParseLogEntries(t *testing.T) {
+	var buf bytes.Buffer
+	stdout := stdcopy.NewStdWriter(&buf, stdcopy.Stdout)
+	stderr := stdcopy.NewStdWriter(&buf, stdcopy.Stderr)
+
+	stdout.Write([]byte("2024-05-01T10:00:00.000000001Z server listening on 3000\n"))
+	stderr.Write([]byte("2024-05-01T10:00:01.5Z warning: deprecated option\n"))
+	// A line split across two frames
+	stdout.Write([]byte("2024-05-01T10:00:02Z request "))
+	stdout.Write([]byte("handled\n"))
+	stdout.Write([]byte("no timestamp here\n"))
+
+	entries, err := parseLogEntries(&buf)
+	if err != nil {
+		t.Fatalf("parseLogEntries failed: %v", err)
+	}
+
+	want := []LogEntry{
+		{Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 1, time.UTC), Stream: "stdout", Message: "server listening on 3000"},
+		{Timestamp: time.Date(2024, 5, 1, 10, 0, 1, 500000000, time.UTC), Stream: "stderr", Message: "warning: deprecated option"},
+		{Timestamp: time.Date(2024, 5, 1, 10, 0, 2, 0, time.UTC), Stream: "stdout", Message: "request handled"},
+		{Stream: "stdout", Message: "no timestamp here"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("Expected %d entries, got %d: %+v", len(want), len(entries), entries)
+	}
+	for i := range want {
+		if !entries[i].Timestamp.Equal(want[i].Timestamp) || entries[i].Stream != want[i].Stream || entries[i].Message != want[i].Message {
+			t.Errorf("Entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+}
+
+func TestLabelFilterArgs(t *testing.T) {
+	args := labelFilterArgs(map[string]string{"environment": "production", "managed-by": ""})
+
+	got := args.Get("label")
+	sort.Strings(got)
+	want := []string{"environment=production", "managed-by"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("label filters = %v, want %v", got, want)
+	}
+}
+
+// containerState builds an inspect result for a running container with the given health
+func containerState(running bool, health string, healthcheck bool) types.ContainerJSON {
+	state := &types.ContainerState{Running: running}
+	if health != "" {
+		state.Health = &types.Health{Status: health}
+	}
+	config := &container.Config{}
+	if healthcheck {
+		config.Healthcheck = &container.HealthConfig{Test: []string{"CMD", "true"}}
+	}
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{State: state},
+		Config:            config,
+	}
+}
+
+func TestWaitForHealthy(t *testing.T) {
+	tests := []struct {
+		name    string
+		inspect types.ContainerJSON
+		emit    []events.Action
+		wantErr error
+	}{
+		{
+			name:    "healthy event",
+			inspect: containerState(true, types.Starting, true),
+			emit:    []events.Action{events.ActionStart, events.ActionHealthStatusHealthy},
+		},
+		{
+			name:    "start event without health check",
+			inspect: containerState(false, "", false),
+			emit:    []events.Action{events.ActionStart},
+		},
+		{
+			name:    "already healthy",
+			inspect: containerState(true, types.Healthy, true),
+		},
+		{
+			name:    "container dies",
+			inspect: containerState(true, types.Starting, true),
+			emit:    []events.Action{events.ActionStart, events.ActionDie},
+			wantErr: ErrContainerExited,
+		},
+		{
+			name:    "timeout",
+			inspect: containerState(true, types.Starting, true),
+			emit:    []events.Action{events.ActionStart},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeAPI{
+				inspect:   tt.inspect,
+				events:    make(chan events.Message, len(tt.emit)),
+				eventErrs: make(chan error),
+			}
+			for _, action := range tt.emit {
+				fake.events <- events.Message{
+					Type:   events.ContainerEventType,
+					Action: action,
+					Actor:  events.Actor{ID: "abc123"},
+				}
+			}
+
+			c := NewClientFromAPI(fake)
+			err := c.WaitForHealthy(context.Background(), "abc123", 50*time.Millisecond)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("WaitForHealthy() error = %v", err)
+			}
+			if tt.wantErr != nil {
+				var clientErr *ClientError
+				if !errors.As(err, &clientErr) || !errors.Is(clientErr.Err, tt.wantErr) {
+					t.Fatalf("WaitForHealthy() error = %v, want %v", err, tt.wantErr)
+				}
+			}
+
+			if got := fake.eventOptions.Filters.Get("container"); len(got) != 1 || got[0] != "abc123" {
+				t.Errorf("Expected events filtered by container ID, got %v", got)
+			}
+		})
+	}
+}
+
+func TestPruneImages(t *testing.T) {
+	fake := &fakeAPI{
+		pruneReport: image.PruneReport{
+			ImagesDeleted: []image.DeleteResponse{
+				{Untagged: "blockbuilder/api:old"},
+				{Deleted: "sha256:aaa"},
+				{Deleted: "sha256:bbb"},
+			},
+			SpaceReclaimed: 2048,
+		},
+	}
+	c := NewClientFromAPI(fake)
+
+	report, err := c.PruneImages(context.Background(), true, map[string]string{"com.blockbuilder.image": ""})
+	if err != nil {
+		t.Fatalf("PruneImages failed: %v", err)
+	}
+
+	if want := []string{"sha256:aaa", "sha256:bbb"}; !reflect.DeepEqual(report.ImagesDeleted, want) {
+		t.Errorf("ImagesDeleted = %v, want %v", report.ImagesDeleted, want)
+	}
+	if report.SpaceReclaimed != 2048 {
+		t.Errorf("SpaceReclaimed = %d, want 2048", report.SpaceReclaimed)
+	}
+	if got := fake.pruneFilters.Get("dangling"); !reflect.DeepEqual(got, []string{"true"}) {
+		t.Errorf("dangling filter = %v, want [true]", got)
+	}
+	if got := fake.pruneFilters.Get("label"); !reflect.DeepEqual(got, []string{"com.blockbuilder.image"}) {
+		t.Errorf("label filter = %v, want [com.blockbuilder.image]", got)
+	}
+}
+
+func TestLoadImage(t *testing.T) {
+	fake := &fakeAPI{loadStream: `{"stream":"Loaded image: node:20-alpine\n"}
+{"stream":"Loaded image: blockbuilder/api:latest\n"}
+{"stream":"Loaded image ID: sha256:4f2d\n"}
+`}
+	c := NewClientFromAPI(fake)
+
+	loaded, err := c.LoadImage(context.Background(), strings.NewReader("archive"))
+	if err != nil {
+		t.Fatalf("LoadImage failed: %v", err)
+	}
+	if want := []string{"node:20-alpine", "blockbuilder/api:latest", "sha256:4f2d"}; !reflect.DeepEqual(loaded, want) {
+		t.Errorf("LoadImage = %v, want %v", loaded, want)
+	}
+
+	fake.loadStream = `{"errorDetail":{"message":"archive/tar: invalid tar header"},"error":"archive/tar: invalid tar header"}`
+	if _, err := c.LoadImage(context.Background(), strings.NewReader("not a tar")); err == nil || !strings.Contains(err.Error(), "invalid tar header") {
+		t.Errorf("LoadImage error = %v, want the error from the load stream", err)
+	}
+}
+
+func TestBuildImage(t *testing.T) {
+	contextDir := t.TempDir()
+	files := map[string]string{
+		"Dockerfile":                "FROM node:latest\n",
+		".dockerignore":             "node_modules\nDockerfile\n.dockerignore\n",
+		"package.json":              `{"name": "app"}`,
+		"node_modules/dep/index.js": "",
+		"src/index.js":              "",
+	}
+	for name, content := range files {
+		path := filepath.Join(contextDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fake := &fakeAPI{buildStream: `{"stream":"#5 DONE 0.4s\n"}
+{"id":"moby.image.id","aux":{"ID":"sha256:9c1e"}}
+`}
+	c := NewClientFromAPI(fake)
+
+	labels := ImageBuildLabels(contextDir, time.Now())
+	imageID, err := c.BuildImage(context.Background(), contextDir, BuildOptions{Tag: "blockbuilder/app:latest", Labels: labels})
+	if err != nil {
+		t.Fatalf("BuildImage failed: %v", err)
+	}
+	if imageID != "sha256:9c1e" {
+		t.Errorf("BuildImage = %q, want sha256:9c1e", imageID)
+	}
+
+	opts := fake.buildOptions
+	if opts.Version != types.BuilderBuildKit {
+		t.Errorf("Builder = %q, want BuildKit", opts.Version)
+	}
+	if want := []string{"blockbuilder/app:latest"}; !reflect.DeepEqual(opts.Tags, want) || !reflect.DeepEqual(opts.CacheFrom, want) {
+		t.Errorf("Tags = %v, CacheFrom = %v, want both %v", opts.Tags, opts.CacheFrom, want)
+	}
+	if v := opts.BuildArgs["BUILDKIT_INLINE_CACHE"]; v == nil || *v != "1" {
+		t.Error("Expected the build to embed inline cache metadata")
+	}
+	if !reflect.DeepEqual(opts.Labels, labels) {
+		t.Errorf("Labels = %v, want %v", opts.Labels, labels)
+	}
+
+	// .dockerignore keeps node_modules out, but never the files the daemon needs
+	sent := strings.Join(fake.buildFiles, " ")
+	for _, name := range []string{"Dockerfile", ".dockerignore", "package.json", "src/index.js"} {
+		if !slices.Contains(fake.buildFiles, name) {
+			t.Errorf("Build context is missing %s: %s", name, sent)
+		}
+	}
+	if strings.Contains(sent, "node_modules") {
+		t.Errorf("Build context includes ignored node_modules: %s", sent)
+	}
+
+	fake.buildStream = `{"errorDetail":{"message":"process \"/bin/sh -c npm install\" did not complete successfully: exit code: 1"},"error":"process \"/bin/sh -c npm install\" did not complete successfully: exit code: 1"}`
+	if _, err := c.BuildImage(context.Background(), contextDir, BuildOptions{Tag: "blockbuilder/app:latest"}); err == nil || !strings.Contains(err.Error(), "npm install") {
+		t.Errorf("BuildImage error = %v, want the error from the build stream", err)
+	}
+}
+
+func TestBuildImageSecrets(t *testing.T) {
+	contextDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte("FROM node:latest\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	npmrc := filepath.Join(contextDir, ".npmrc-123")
+	if err := os.WriteFile(npmrc, []byte("//npm.corp.internal/:_authToken=s3cr3t\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sessions, err := session.NewManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := &fakeAPI{
+		buildStream: `{"id":"moby.image.id","aux":{"ID":"sha256:9c1e"}}`,
+		sessions:    sessions,
+		secretIDs:   []string{"npmrc"},
+	}
+	c := NewClientFromAPI(fake)
+
+	_, err = c.BuildImage(context.Background(), contextDir, BuildOptions{
+		Tag:     "blockbuilder/app:latest",
+		Secrets: map[string]string{"npmrc": npmrc},
+	})
+	if err != nil {
+		t.Fatalf("BuildImage failed: %v", err)
+	}
+	if fake.buildOptions.SessionID == "" {
+		t.Fatal("Expected the build to run with a session")
+	}
+	if got := fake.buildSecrets["npmrc"]; got != "//npm.corp.internal/:_authToken=s3cr3t\n" {
+		t.Errorf("Builder read secret %q, want the .npmrc content", got)
+	}
+	if slices.Contains(fake.buildFiles, ".npmrc-123") {
+		t.Error("The secret file was sent with the build context")
+	}
+
+	// Builds without secrets need no session
+	if _, err := c.BuildImage(context.Background(), contextDir, BuildOptions{Tag: "blockbuilder/app:latest"}); err != nil {
+		t.Fatalf("BuildImage failed: %v", err)
+	}
+	if fake.buildOptions.SessionID != "" {
+		t.Errorf("SessionID = %q, want none without secrets", fake.buildOptions.SessionID)
+	}
+}
+
+func TestManagedImageTag(t *testing.T) {
+	tests := map[string]string{
+		"my-app":  "blockbuilder/my-app:latest",
+		"/my_app": "blockbuilder/my_app:latest",
+		"dev.api": "blockbuilder/dev.api:latest",
+	}
+	for name, want := range tests {
+		if got := ManagedImageTag(name); got != want {
+			t.Errorf("ManagedImageTag(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	// Names that differ only in case get tags of their own
+	upper, lower := ManagedImageTag("MyApp"), ManagedImageTag("myapp")
+	if upper == lower || !strings.HasPrefix(upper, "blockbuilder/myapp-") {
+		t.Errorf("ManagedImageTag(MyApp) = %q, want blockbuilder/myapp-<hash> apart from %q", upper, lower)
+	}
+	if ManagedImageTag("/MyApp") != upper {
+		t.Errorf("ManagedImageTag(/MyApp) = %q, want %q", ManagedImageTag("/MyApp"), upper)
+	}
+
+	// Names Docker accepts for containers but not for repositories fall back to a hash
+	tag := ManagedImageTag("a..b")
+	if !strings.HasPrefix(tag, "blockbuilder/app-") || tag == ManagedImageTag("a...b") {
+		t.Errorf("ManagedImageTag(a..b) = %q, want a distinct hashed name", tag)
+	}
+}
+
+func TestListManagedImages(t *testing.T) {
+	fake := &fakeAPI{
+		images: []image.Summary{
+			{ID: "sha256:base", RepoTags: []string{"node:20-alpine"}, Created: 100},
+			{ID: "sha256:tagged", RepoTags: []string{"blockbuilder/api:latest"}, Created: 200, Size: 10},
+			{
+				ID:       "sha256:labelled",
+				RepoTags: []string{"<none>:<none>"},
+				Labels: map[string]string{
+					ManagedByLabel:        ManagedByValue,
+					ImageProjectPathLabel: "/srv/projects/web",
+					ImageBuiltAtLabel:     "2024-05-01T12:00:00Z",
+				},
+				Created: 300,
+			},
+			{ID: "sha256:other", RepoTags: []string{"example/blockbuilder:1"}, Labels: map[string]string{ManagedByLabel: "someone-else"}},
+		},
+	}
+	c := NewClientFromAPI(fake)
+
+	images, err := c.ListManagedImages(context.Background(), map[string]string{ImageProjectPathLabel: "/srv/projects/web"})
+	if err != nil {
+		t.Fatalf("ListManagedImages failed: %v", err)
+	}
+	if got := fake.imageListOptions.Filters.Get("label"); !reflect.DeepEqual(got, []string{ImageProjectPathLabel + "=/srv/projects/web"}) {
+		t.Errorf("label filters = %v, want the project path filter", got)
+	}
+
+	var ids []string
+	for _, img := range images {
+		ids = append(ids, img.ID)
+	}
+	if want := []string{"sha256:labelled", "sha256:tagged"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("managed images = %v, want %v", ids, want)
+	}
+
+	labelled := images[0]
+	if labelled.ProjectPath != "/srv/projects/web" {
+		t.Errorf("ProjectPath = %q, want /srv/projects/web", labelled.ProjectPath)
+	}
+	if labelled.BuiltAt == nil || !labelled.BuiltAt.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("BuiltAt = %v, want 2024-05-01T12:00:00Z", labelled.BuiltAt)
+	}
+	if images[1].BuiltAt != nil {
+		t.Errorf("BuiltAt for an unlabelled image = %v, want nil", images[1].BuiltAt)
+	}
+}
+
+func TestInspectImageBuildMetadata(t *testing.T) {
+	fake := &fakeAPI{
+		imageInspect: types.ImageInspect{
+			ID:       "sha256:abc",
+			RepoTags: []string{"blockbuilder/web:latest"},
+			Config: &container.Config{Labels: map[string]string{
+				nodeproject.SourceCommitLabel:   "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+				nodeproject.BuiltAtLabel:        "2024-05-01T12:00:00Z",
+				nodeproject.FrameworkLabel:      "nextjs",
+				nodeproject.BuilderVersionLabel: "1.4.0",
+			}},
+		},
+	}
+	c := NewClientFromAPI(fake)
+
+	details, err := c.InspectImage(context.Background(), "sha256:abc")
+	if err != nil {
+		t.Fatalf("InspectImage failed: %v", err)
+	}
+	want := BuildMetadata{
+		SourceCommit:   "4b825dc642cb6eb9a060e54bf8d69288fbee4904",
+		BuiltAt:        "2024-05-01T12:00:00Z",
+		Framework:      "nextjs",
+		BuilderVersion: "1.4.0",
+	}
+	if details.BuildMetadata != want {
+		t.Errorf("BuildMetadata = %+v, want %+v", details.BuildMetadata, want)
+	}
+}
+
+func TestCopyAttachOutput(t *testing.T) {
+	t.Run("non-TTY stream is demultiplexed", func(t *testing.T) {
+		var stream bytes.Buffer
+		stdcopy.NewStdWriter(&stream, stdcopy.Stdout).Write([]byte("> 1 + 1\n"))
+		stdcopy.NewStdWriter(&stream, stdcopy.Stderr).Write([]byte("Uncaught ReferenceError: x is not defined\n"))
+		stdcopy.NewStdWriter(&stream, stdcopy.Stdout).Write([]byte("2\n"))
+
+		var out bytes.Buffer
+		if err := copyAttachOutput(&out, &stream, false); err != nil {
+			t.Fatalf("copyAttachOutput() error = %v", err)
+		}
+		want := "> 1 + 1\nUncaught ReferenceError: x is not defined\n2\n"
+		if out.String() != want {
+			t.Errorf("output = %q, want %q", out.String(), want)
+		}
+	})
+
+	t.Run("TTY stream is copied raw", func(t *testing.T) {
+		raw := "\x1b[32m> \x1b[39m2\r\n"
+		var out bytes.Buffer
+		if err := copyAttachOutput(&out, bytes.NewReader([]byte(raw)), true); err != nil {
+			t.Fatalf("copyAttachOutput() error = %v", err)
+		}
+		if out.String() != raw {
+			t.Errorf("output = %q, want %q", out.String(), raw)
+		}
+	})
+}
+
+func TestListContainersCache(t *testing.T) {
+	fake := &fakeAPI{
+		list:     []types.Container{{ID: "abc123", Names: []string{"/api"}, State: "running"}},
+		listGate: make(chan struct{}),
+	}
+	c := NewClientFromAPI(fake)
+	c.EnableListCache(time.Minute)
+
+	const requests = 20
+	var wg sync.WaitGroup
+	results := make([][]ContainerInfo, requests)
+	errs := make([]error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = c.ListContainers(context.Background(), true, map[string]string{"env": "prod", "team": "web"})
+		}(i)
+	}
+	// Hold the daemon call until the first request is in flight, then let everyone finish
+	for fake.listCalls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	close(fake.listGate)
+	wg.Wait()
+
+	if got := fake.listCalls.Load(); got != 1 {
+		t.Errorf("Expected a single daemon call for %d concurrent requests, got %d", requests, got)
+	}
+	for i := range results {
+		if errs[i] != nil || len(results[i]) != 1 || results[i][0].ID != "abc123" {
+			t.Fatalf("Request %d got %v, %v", i, results[i], errs[i])
+		}
+	}
+
+	// Filter order does not matter, but a different query is fetched separately
+	c.ListContainers(context.Background(), true, map[string]string{"team": "web", "env": "prod"})
+	if got := fake.listCalls.Load(); got != 1 {
+		t.Errorf("Expected the reordered filter to hit the cache, got %d calls", got)
+	}
+	c.ListContainers(context.Background(), false, nil)
+	if got := fake.listCalls.Load(); got != 2 {
+		t.Errorf("Expected a different query to call the daemon, got %d calls", got)
+	}
+
+	// Mutations clear the cache
+	if err := c.StopContainer(context.Background(), "abc123", time.Second); err != nil {
+		t.Fatalf("StopContainer failed: %v", err)
+	}
+	c.ListContainers(context.Background(), true, map[string]string{"env": "prod", "team": "web"})
+	if got := fake.listCalls.Load(); got != 3 {
+		t.Errorf("Expected a list after stop to call the daemon, got %d calls", got)
+	}
+}
+
+func TestListContainersCacheExpires(t *testing.T) {
+	fake := &fakeAPI{list: []types.Container{{ID: "abc123", Names: []string{"/api"}}}}
+	c := NewClientFromAPI(fake)
+	c.EnableListCache(10 * time.Millisecond)
+
+	c.ListContainers(context.Background(), true, nil)
+	time.Sleep(20 * time.Millisecond)
+	c.ListContainers(context.Background(), true, nil)
+
+	if got := fake.listCalls.Load(); got != 2 {
+		t.Errorf("Expected an expired entry to be refetched, got %d calls", got)
+	}
+}
+
+func TestGetContainerInspectCacheEvictedByEvent(t *testing.T) {
+	fake := &fakeAPI{
+		inspect: types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:         "abc123def456",
+				Name:       "/api",
+				State:      &types.ContainerState{Status: "running"},
+				HostConfig: &container.HostConfig{},
+			},
+			Config:          &container.Config{Image: "node:latest"},
+			NetworkSettings: &types.NetworkSettings{},
+		},
+		events:    make(chan events.Message),
+		eventErrs: make(chan error),
+	}
+	c := NewClientFromAPI(fake)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c.EnableInspectCache(ctx, time.Minute)
+
+	// Wait for the event subscription, since nothing is cached until it is up
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.inspectCache.mu.Lock()
+		live := c.inspectCache.live
+		c.inspectCache.mu.Unlock()
+		if live {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("inspect cache never subscribed to events")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Read by name and by ID, then once more from the cache
+	for _, id := range []string{"api", "abc123def456", "api"} {
+		if _, err := c.GetContainer(context.Background(), id); err != nil {
+			t.Fatalf("GetContainer(%s) failed: %v", id, err)
+		}
+	}
+	if got := fake.inspectCalls.Load(); got != 2 {
+		t.Fatalf("Expected one daemon inspect per key, got %d", got)
+	}
+
+	// The send returns once the watcher has the event; eviction follows under the cache lock
+	fake.events <- events.Message{
+		Type:   events.ContainerEventType,
+		Action: events.ActionDie,
+		Actor:  events.Actor{ID: "abc123def456"},
+	}
+	deadline = time.Now().Add(5 * time.Second)
+	for {
+		c.inspectCache.mu.Lock()
+		remaining := len(c.inspectCache.entries)
+		c.inspectCache.mu.Unlock()
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("die event left %d cached entries", remaining)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if _, err := c.GetContainer(context.Background(), "api"); err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	if got := fake.inspectCalls.Load(); got != 3 {
+		t.Errorf("Expected the read after the die event to inspect again, got %d calls", got)
+	}
+
+	filterArgs := fake.eventOptions.Filters
+	if !filterArgs.ExactMatch("type", "container") {
+		t.Errorf("Expected a container event subscription, got %v", filterArgs)
+	}
+	for _, action := range []string{"start", "stop", "die", "destroy", "pause", "unpause", "rename", "health_status"} {
+		if !filterArgs.ExactMatch("event", action) {
+			t.Errorf("Expected the subscription to include %s events, got %v", action, filterArgs)
+		}
+	}
+}
+
+func TestReconnectAfterConnectionFailure(t *testing.T) {
+	dead := &fakeAPI{listErr: client.ErrorConnectionFailed("unix:///var/run/docker.sock")}
+	live := &fakeAPI{list: []types.Container{{ID: "abc", Names: []string{"/web"}}}}
+
+	connects := 0
+	c := NewClientFromAPI(dead)
+	c.connect = func() (client.APIClient, error) {
+		connects++
+		return live, nil
+	}
+
+	if _, err := c.ListContainers(context.Background(), true, nil); err == nil {
+		t.Fatal("ListContainers on a dead connection succeeded")
+	}
+	if connects != 1 {
+		t.Fatalf("reconnect attempts = %d, want 1", connects)
+	}
+	if !dead.closed {
+		t.Error("dead client was not closed")
+	}
+
+	containers, err := c.ListContainers(context.Background(), true, nil)
+	if err != nil {
+		t.Fatalf("ListContainers after reconnect failed: %v", err)
+	}
+	if len(containers) != 1 || containers[0].ID != "abc" {
+		t.Errorf("containers after reconnect = %+v, want abc", containers)
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"daemon unreachable", client.ErrorConnectionFailed("unix:///var/run/docker.sock"), true},
+		{"connection refused", fmt.Errorf("dial unix /var/run/docker.sock: %w", syscall.ECONNREFUSED), true},
+		{"connection reset", syscall.ECONNRESET, true},
+		{"not found", errdefs.NotFound(errors.New("No such container: abc")), false},
+		{"canceled", context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConnectionError(tt.err); got != tt.want {
+				t.Errorf("isConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStageBuildFiles(t *testing.T) {
+	buildTempDir := t.TempDir()
+	c := NewClientFromAPI(&fakeAPI{})
+	c.SetBuildTempDir(buildTempDir)
+
+	var staged string
+	err := c.StageBuildFiles(context.Background(), func(dir string) error {
+		staged = dir
+		return os.WriteFile(filepath.Join(dir, ".npmrc"), []byte("registry=https://npm.corp.internal/\n"), 0600)
+	})
+	if err != nil {
+		t.Fatalf("StageBuildFiles failed: %v", err)
+	}
+	if filepath.Dir(staged) != buildTempDir {
+		t.Errorf("files staged in %s, want a directory under %s", staged, buildTempDir)
+	}
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Errorf("staged files %s were not removed: %v", staged, err)
+	}
+
+	// A panic while staging still removes the directory
+	func() {
+		defer func() { recover() }()
+		c.StageBuildFiles(context.Background(), func(dir string) error {
+			staged = dir
+			panic("extract failed")
+		})
+	}()
+	if _, err := os.Stat(staged); !os.IsNotExist(err) {
+		t.Errorf("staged files %s were not removed after a panic: %v", staged, err)
+	}
+}
+
+func TestGetContainerUptime(t *testing.T) {
+	now := time.Now().UTC()
+	fake := &fakeAPI{
+		inspect: types.ContainerJSON{
+			ContainerJSONBase: &types.ContainerJSONBase{
+				ID:      "abc123def456",
+				Name:    "/api",
+				Created: now.Add(-2 * time.Hour).Format(time.RFC3339Nano),
+				State: &types.ContainerState{
+					Status:    "running",
+					Running:   true,
+					StartedAt: now.Add(-90 * time.Minute).Format(time.RFC3339Nano),
+				},
+				HostConfig: &container.HostConfig{},
+			},
+			Config:          &container.Config{Image: "node:latest"},
+			NetworkSettings: &types.NetworkSettings{},
+		},
+	}
+	c := NewClientFromAPI(fake)
+
+	info, err := c.GetContainer(context.Background(), "abc123def456")
+	if err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	if info.AgeSeconds < 7200 || info.AgeSeconds > 7260 {
+		t.Errorf("AgeSeconds = %d, want about 7200", info.AgeSeconds)
+	}
+	if info.UptimeSeconds < 5400 || info.UptimeSeconds > 5460 {
+		t.Errorf("UptimeSeconds = %d, want about 5400", info.UptimeSeconds)
+	}
+
+	// An exited container keeps its start time but has no uptime
+	fake.inspect.State = &types.ContainerState{
+		Status:     "exited",
+		StartedAt:  now.Add(-90 * time.Minute).Format(time.RFC3339Nano),
+		FinishedAt: now.Add(-time.Minute).Format(time.RFC3339Nano),
+	}
+	info, err = c.GetContainer(context.Background(), "abc123def456")
+	if err != nil {
+		t.Fatalf("GetContainer failed: %v", err)
+	}
+	if info.UptimeSeconds != 0 {
+		t.Errorf("UptimeSeconds = %d for an exited container, want 0", info.UptimeSeconds)
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("Failed to marshal container: %v", err)
+	}
+	if strings.Contains(string(data), "uptime_seconds") {
+		t.Errorf("Exited container JSON has uptime_seconds: %s", data)
+	}
+}
+
+func TestCreateContainerDefaultLogConfig(t *testing.T) {
+	fake := &fakeAPI{}
+	c := NewClientFromAPI(fake)
+
+	if _, _, err := c.CreateContainer(context.Background(), "chatty", ContainerConfig{Image: "node:20"}); err != nil {
+		t.Fatalf("CreateContainer failed: %v", err)
+	}
+	want := container.LogConfig{Type: "json-file", Config: map[string]string{"max-size": "10m", "max-file": "3"}}
+	if got := fake.createHostConfig.LogConfig; !reflect.DeepEqual(got, want) {
+		t.Errorf("LogConfig = %+v, want %+v", got, want)
+	}
+
+	_, _, err := c.CreateContainer(context.Background(), "journal", ContainerConfig{
+		Image:     "node:20",
+		LogDriver: "journald",
+		LogOpts:   map[string]string{"tag": "{{.Name}}"},
+	})
+	if err != nil {
+		t.Fatalf("CreateContainer failed: %v", err)
+	}
+	want = container.LogConfig{Type: "journald", Config: map[string]string{"tag": "{{.Name}}"}}
+	if got := fake.createHostConfig.LogConfig; !reflect.DeepEqual(got, want) {
+		t.Errorf("LogConfig = %+v, want %+v", got, want)
+	}
+}
+
+func TestCreateContainerPortProtocols(t *testing.T) {
+	fake := &fakeAPI{}
+	c := NewClientFromAPI(fake)
+
+	_, _, err := c.CreateContainer(context.Background(), "dns", ContainerConfig{
+		Image: "node:20",
+		Ports: map[string]string{"53/udp": "5353", "3000": "3000"},
+	})
+	if err != nil {
+		t.Fatalf("CreateContainer failed: %v", err)
+	}
+
+	want := nat.PortMap{
+		"53/udp":   {{HostIP: "0.0.0.0", HostPort: "5353"}},
+		"3000/tcp": {{HostIP: "0.0.0.0", HostPort: "3000"}},
+	}
+	if !reflect.DeepEqual(fake.createHostConfig.PortBindings, want) {
+		t.Errorf("PortBindings = %v, want %v", fake.createHostConfig.PortBindings, want)
+	}
+	if _, ok := fake.createConfig.ExposedPorts["53/udp"]; !ok {
+		t.Errorf("53/udp not exposed: %v", fake.createConfig.ExposedPorts)
+	}
+
+	_, _, err = c.CreateContainer(context.Background(), "sctp", ContainerConfig{
+		Image: "node:20",
+		Ports: map[string]string{"3868/sctp": "3868"},
+	})
+	if err == nil {
+		t.Error("Expected an error for an sctp port")
+	}
+}
+
+func TestManagedUsage(t *testing.T) {
+	managed := map[string]string{ManagedByLabel: ManagedByValue}
+	fake := &fakeAPI{
+		list: []types.Container{
+			{ID: "aaa", Names: []string{"/api"}, Labels: managed},
+			{ID: "bbb", Names: []string{"/web"}, Labels: managed},
+			{ID: "ccc", Names: []string{"/worker"}, Labels: managed},
+			{ID: "ddd", Names: []string{"/db"}, Labels: managed},
+		},
+		stats: map[string]string{
+			// 50% of one CPU, 100MiB used of which 20MiB is page cache (cgroup v2)
+			"aaa": `{"cpu_stats":{"cpu_usage":{"total_usage":2000},"system_cpu_usage":20000,"online_cpus":4},
+				"precpu_stats":{"cpu_usage":{"total_usage":1000},"system_cpu_usage":12000},
+				"memory_stats":{"usage":104857600,"limit":536870912,"stats":{"inactive_file":20971520}}}`,
+			// 150% across two CPUs, cache reported the cgroup v1 way
+			"bbb": `{"cpu_stats":{"cpu_usage":{"total_usage":4000,"percpu_usage":[2000,2000]},"system_cpu_usage":5000},
+				"precpu_stats":{"cpu_usage":{"total_usage":1000},"system_cpu_usage":1000},
+				"memory_stats":{"usage":52428800,"limit":1073741824,"stats":{"total_inactive_file":10485760}}}`,
+			// Idle container with no previous sample
+			"ddd": `{"cpu_stats":{"cpu_usage":{"total_usage":500},"system_cpu_usage":1000,"online_cpus":1},
+				"memory_stats":{"usage":1048576,"limit":268435456}}`,
+		},
+		statsErrs: map[string]error{"ccc": errors.New("container is restarting")},
+	}
+	c := NewClientFromAPI(fake)
+
+	summary, err := c.ManagedUsage(context.Background(), 2)
+	if err != nil {
+		t.Fatalf("ManagedUsage failed: %v", err)
+	}
+
+	want := []ContainerUsage{
+		{ContainerID: "aaa", Name: "/api", CPUPercent: 50, MemoryUsage: 83886080, MemoryLimit: 536870912},
+		{ContainerID: "bbb", Name: "/web", CPUPercent: 150, MemoryUsage: 41943040, MemoryLimit: 1073741824},
+		{ContainerID: "ccc", Name: "/worker"},
+		{ContainerID: "ddd", Name: "/db", MemoryUsage: 1048576, MemoryLimit: 268435456},
+	}
+	if len(summary.Containers) != len(want) {
+		t.Fatalf("got %d containers, want %d: %+v", len(summary.Containers), len(want), summary.Containers)
+	}
+	for i, got := range summary.Containers {
+		w := want[i]
+		if got.ContainerID != w.ContainerID || got.Name != w.Name || got.CPUPercent != w.CPUPercent ||
+			got.MemoryUsage != w.MemoryUsage || got.MemoryLimit != w.MemoryLimit {
+			t.Errorf("container %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if !strings.Contains(summary.Containers[2].Error, "container is restarting") {
+		t.Errorf("failed container error = %q, want the stats error", summary.Containers[2].Error)
+	}
+
+	if summary.Failed != 1 {
+		t.Errorf("Failed = %d, want 1", summary.Failed)
+	}
+	if summary.CPUPercent != 200 {
+		t.Errorf("CPUPercent = %v, want 200", summary.CPUPercent)
+	}
+	if summary.MemoryUsage != 83886080+41943040+1048576 {
+		t.Errorf("MemoryUsage = %d, want %d", summary.MemoryUsage, 83886080+41943040+1048576)
+	}
+	if summary.MemoryLimit != 536870912+1073741824+268435456 {
+		t.Errorf("MemoryLimit = %d, want %d", summary.MemoryLimit, 536870912+1073741824+268435456)
+	}
+	if peak := fake.statsPeak.Load(); peak > 2 {
+		t.Errorf("%d stats reads in flight, want at most 2", peak)
+	}
+}
+
+func TestParseBuildOutputFailingStep(t *testing.T) {
+	ctx, logs := observedContext()
+	output := strings.Join([]string{
+		`{"stream":"Step 1/4 : FROM node:20-slim"}`,
+		`{"stream":"\n"}`,
+		`{"stream":" ---> 4a1b2c3d\n"}`,
+		`{"stream":"Step 2/4 : COPY package*.json ./\n"}`,
+		`{"stream":"Step 3/4 : RUN npm ci\n"}`,
+		`{"stream":" ---> Running in 9f8e7d6c\n"}`,
+		`{"stream":"npm ERR! missing script: build\n"}`,
+		`{"errorDetail":{"code":1,"message":"The command '/bin/sh -c npm ci' returned a non-zero code: 1"},"error":"The command '/bin/sh -c npm ci' returned a non-zero code: 1"}`,
+	}, "\n")
+
+	parsed, err := ParseBuildOutput(ctx, strings.NewReader(output))
+	var buildErr *BuildStepError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("Expected a BuildStepError, got %v", err)
+	}
+	if buildErr.Step == nil || buildErr.Step.Number != 3 || buildErr.Step.Total != 4 || buildErr.Step.Command != "RUN npm ci" {
+		t.Errorf("Failing step = %+v, want 3/4 RUN npm ci", buildErr.Step)
+	}
+	if !strings.Contains(err.Error(), "step 3/4 (RUN npm ci)") || !strings.Contains(err.Error(), "non-zero code: 1") {
+		t.Errorf("Error = %q, want the failing step and the daemon's message", err.Error())
+	}
+	if len(parsed.Steps) != 3 {
+		t.Errorf("Expected 3 steps, got %+v", parsed.Steps)
+	}
+	if got := logs.FilterMessage("build step").Len(); got != 3 {
+		t.Errorf("Expected 3 step log records, got %d", got)
+	}
+	records := logs.FilterMessage("build step failed").All()
+	if len(records) != 1 || records[0].ContextMap()["command"] != "RUN npm ci" {
+		t.Errorf("Expected the failure to be logged with the failing command, got %+v", records)
+	}
+}
+
+func TestParseBuildOutputBuildKit(t *testing.T) {
+	output := `{"stream":"#1 [internal] load build definition from Dockerfile\n#5 [builder 1/3] FROM docker.io/library/node:20\n#6 [builder 2/3] RUN npm ci\n#6 [builder 2/3] RUN npm ci\n#7 [builder 3/3] RUN npm run build\n"}`
+
+	parsed, err := ParseBuildOutput(context.Background(), strings.NewReader(output))
+	if err != nil {
+		t.Fatalf("ParseBuildOutput failed: %v", err)
+	}
+	want := []BuildStep{
+		{Number: 1, Total: 3, Command: "FROM docker.io/library/node:20"},
+		{Number: 2, Total: 3, Command: "RUN npm ci"},
+		{Number: 3, Total: 3, Command: "RUN npm run build"},
+	}
+	if !reflect.DeepEqual(parsed.Steps, want) {
+		t.Errorf("Steps = %+v, want %+v", parsed.Steps, want)
+	}
+}
+
+// buildkitTrace encodes vertexes as the BuildKit status update the daemon streams for them
+func buildkitTrace(t *testing.T, vertexes ...*controlapi.Vertex) string {
+	t.Helper()
+	data, err := (&controlapi.StatusResponse{Vertexes: vertexes}).MarshalVT()
+	if err != nil {
+		t.Fatalf("Failed to encode status: %v", err)
+	}
+	line, err := json.Marshal(map[string]interface{}{"id": "moby.buildkit.trace", "aux": data})
+	if err != nil {
+		t.Fatalf("Failed to encode trace: %v", err)
+	}
+	return string(line)
+}
+
+func TestParseBuildOutputBuildKitTrace(t *testing.T) {
+	ctx, logs := observedContext()
+	started := timestamppb.Now()
+	internal := &controlapi.Vertex{Digest: "sha256:1", Name: "[internal] load build definition from Dockerfile", Started: started}
+	from := &controlapi.Vertex{Digest: "sha256:2", Name: "[1/4] FROM docker.io/library/node:latest", Started: started}
+	install := &controlapi.Vertex{Digest: "sha256:3", Name: "[3/4] RUN npm ci", Started: started}
+	copySource := &controlapi.Vertex{Digest: "sha256:4", Name: "[4/4] COPY . .", Started: started}
+	failedInstall := &controlapi.Vertex{Digest: "sha256:3", Name: "[3/4] RUN npm ci", Started: started, Completed: timestamppb.Now(), Error: "process \"/bin/sh -c npm ci\" did not complete successfully: exit code: 1"}
+	output := strings.Join([]string{
+		buildkitTrace(t, internal, from),
+		buildkitTrace(t, &controlapi.Vertex{Digest: "sha256:2", Name: from.Name, Started: started, Completed: started}),
+		buildkitTrace(t, install, copySource),
+		buildkitTrace(t, failedInstall),
+		`{"errorDetail":{"message":"process \"/bin/sh -c npm ci\" did not complete successfully: exit code: 1"},"error":"process \"/bin/sh -c npm ci\" did not complete successfully: exit code: 1"}`,
+	}, "\n")
+
+	parsed, err := ParseBuildOutput(ctx, strings.NewReader(output))
+	var buildErr *BuildStepError
+	if !errors.As(err, &buildErr) {
+		t.Fatalf("Expected a BuildStepError, got %v", err)
+	}
+	// The step BuildKit marked as failed is reported, not the last one to start
+	if buildErr.Step == nil || buildErr.Step.Number != 3 || buildErr.Step.Command != "RUN npm ci" {
+		t.Errorf("Failing step = %+v, want 3/4 RUN npm ci", buildErr.Step)
+	}
+	want := []BuildStep{
+		{Number: 1, Total: 4, Command: "FROM docker.io/library/node:latest"},
+		{Number: 3, Total: 4, Command: "RUN npm ci"},
+		{Number: 4, Total: 4, Command: "COPY . ."},
+	}
+	if !reflect.DeepEqual(parsed.Steps, want) {
+		t.Errorf("Steps = %+v, want %+v", parsed.Steps, want)
+	}
+	if got := logs.FilterMessage("build step").Len(); got != 3 {
+		t.Errorf("Expected 3 step log records, got %d", got)
+	}
+
+	parsed, err = ParseBuildOutput(ctx, strings.NewReader(buildkitTrace(t, from)+"\n"+`{"id":"moby.image.id","aux":{"ID":"sha256:9c1e"}}`))
+	if err != nil {
+		t.Fatalf("ParseBuildOutput failed: %v", err)
+	}
+	if parsed.ImageID != "sha256:9c1e" {
+		t.Errorf("ImageID = %q, want sha256:9c1e", parsed.ImageID)
+	}
+}
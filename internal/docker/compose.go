@@ -0,0 +1,202 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+	"gopkg.in/yaml.v3"
+)
+
+// RedactedValue replaces the value of environment variables that look like secrets
+const RedactedValue = "REDACTED"
+
+// secretEnvMarkers are substrings of environment variable names whose values are redacted
+var secretEnvMarkers = []string{"SECRET", "PASSWORD", "PASSWD", "TOKEN", "KEY", "CREDENTIAL", "AUTH"}
+
+// ComposeFile is a docker-compose document with a single service per exported container
+type ComposeFile struct {
+	Services map[string]ComposeService `yaml:"services"`
+}
+
+// ComposeService is the subset of the compose service definition reconstructed from inspect
+type ComposeService struct {
+	Image          string            `yaml:"image"`
+	ContainerName  string            `yaml:"container_name,omitempty"`
+	Command        []string          `yaml:"command,omitempty"`
+	WorkingDir     string            `yaml:"working_dir,omitempty"`
+	User           string            `yaml:"user,omitempty"`
+	Environment    []string          `yaml:"environment,omitempty"`
+	Ports          []string          `yaml:"ports,omitempty"`
+	Volumes        []string          `yaml:"volumes,omitempty"`
+	Tmpfs          []string          `yaml:"tmpfs,omitempty"`
+	Labels         map[string]string `yaml:"labels,omitempty"`
+	Restart        string            `yaml:"restart,omitempty"`
+	NetworkMode    string            `yaml:"network_mode,omitempty"`
+	ExtraHosts     []string          `yaml:"extra_hosts,omitempty"`
+	DNS            []string          `yaml:"dns,omitempty"`
+	DNSSearch      []string          `yaml:"dns_search,omitempty"`
+	CapAdd         []string          `yaml:"cap_add,omitempty"`
+	CapDrop        []string          `yaml:"cap_drop,omitempty"`
+	ReadOnly       bool              `yaml:"read_only,omitempty"`
+	Init           bool              `yaml:"init,omitempty"`
+	StopSignal     string            `yaml:"stop_signal,omitempty"`
+	CPUs           float64           `yaml:"cpus,omitempty"`
+	CPUShares      int64             `yaml:"cpu_shares,omitempty"`
+	MemLimit       int64             `yaml:"mem_limit,omitempty"`
+	MemReservation int64             `yaml:"mem_reservation,omitempty"`
+	MemswapLimit   int64             `yaml:"memswap_limit,omitempty"`
+	PidsLimit      int64             `yaml:"pids_limit,omitempty"`
+}
+
+// ExportCompose renders the container's configuration as a docker-compose.yml document
+func (c *Client) ExportCompose(ctx context.Context, containerID string) ([]byte, error) {
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
+	if err != nil {
+		if client.IsErrNotFound(err) {
+			return nil, &ClientError{
+				Op:      "inspect",
+				Err:     err,
+				Details: "Container not found",
+			}
+		}
+		return nil, &ClientError{
+			Op:  "inspect",
+			Err: err,
+		}
+	}
+
+	name, service := composeService(inspect)
+	out, err := yaml.Marshal(ComposeFile{Services: map[string]ComposeService{name: service}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode compose file: %w", err)
+	}
+	return out, nil
+}
+
+// composeService converts an inspect result into a service name and definition
+func composeService(inspect types.ContainerJSON) (string, ComposeService) {
+	var service ComposeService
+	name := "app"
+
+	if inspect.ContainerJSONBase != nil {
+		if trimmed := strings.TrimPrefix(inspect.Name, "/"); trimmed != "" {
+			name = trimmed
+			service.ContainerName = trimmed
+		}
+	}
+
+	if cfg := inspect.Config; cfg != nil {
+		service.Image = cfg.Image
+		service.Command = cfg.Cmd
+		service.WorkingDir = cfg.WorkingDir
+		service.User = cfg.User
+		service.StopSignal = cfg.StopSignal
+		service.Labels = cfg.Labels
+		for _, env := range cfg.Env {
+			service.Environment = append(service.Environment, redactEnv(env))
+		}
+	}
+
+	for _, m := range inspect.Mounts {
+		source := m.Source
+		if m.Type == mount.TypeVolume {
+			source = m.Name
+		}
+		volume := fmt.Sprintf("%s:%s", source, m.Destination)
+		if !m.RW {
+			volume += ":ro"
+		}
+		service.Volumes = append(service.Volumes, volume)
+	}
+
+	if inspect.ContainerJSONBase == nil || inspect.HostConfig == nil {
+		return name, service
+	}
+	host := inspect.HostConfig
+
+	for port, bindings := range host.PortBindings {
+		target := port.Port()
+		if port.Proto() != "tcp" {
+			target += "/" + port.Proto()
+		}
+		for _, binding := range bindings {
+			published := binding.HostPort
+			if binding.HostIP != "" {
+				published = binding.HostIP + ":" + published
+			}
+			service.Ports = append(service.Ports, fmt.Sprintf("%s:%s", published, target))
+		}
+	}
+	sort.Strings(service.Ports)
+
+	for path, options := range host.Tmpfs {
+		if options != "" {
+			path += ":" + options
+		}
+		service.Tmpfs = append(service.Tmpfs, path)
+	}
+	sort.Strings(service.Tmpfs)
+
+	if mode := string(host.NetworkMode); mode != "" && mode != "default" && mode != "bridge" {
+		service.NetworkMode = mode
+	}
+	service.Restart = string(host.RestartPolicy.Name)
+	if host.RestartPolicy.IsOnFailure() && host.RestartPolicy.MaximumRetryCount > 0 {
+		service.Restart = fmt.Sprintf("%s:%d", host.RestartPolicy.Name, host.RestartPolicy.MaximumRetryCount)
+	}
+	service.ExtraHosts = host.ExtraHosts
+	service.DNS = host.DNS
+	service.DNSSearch = host.DNSSearch
+	service.CapAdd = host.CapAdd
+	service.CapDrop = host.CapDrop
+	service.ReadOnly = host.ReadonlyRootfs
+	service.Init = host.Init != nil && *host.Init
+
+	service.CPUShares = host.CPUShares
+	switch {
+	case host.NanoCPUs > 0:
+		service.CPUs = float64(host.NanoCPUs) / 1e9
+	case host.CPUQuota > 0:
+		period := host.CPUPeriod
+		if period == 0 {
+			period = DefaultCPUPeriod
+		}
+		service.CPUs = float64(host.CPUQuota) / float64(period)
+	}
+	service.MemLimit = host.Memory
+	service.MemReservation = host.MemoryReservation
+	service.MemswapLimit = host.MemorySwap
+	if host.PidsLimit != nil {
+		service.PidsLimit = *host.PidsLimit
+	}
+
+	return name, service
+}
+
+// redactEnv hides the value of a KEY=VALUE pair whose key looks like a secret
+func redactEnv(env string) string {
+	key, _, ok := strings.Cut(env, "=")
+	if !ok {
+		return env
+	}
+	if isSecretEnvKey(key) {
+		return key + "=" + RedactedValue
+	}
+	return env
+}
+
+// isSecretEnvKey reports whether an environment variable name looks like it holds a secret
+func isSecretEnvKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range secretEnvMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package docker
+
+import (
+	"context"
+	"strings"
+)
+
+// ContainerEnv returns a container's environment variables by name. Values of names that look
+// like secrets are replaced with RedactedValue unless reveal is set.
+func (c *Client) ContainerEnv(ctx context.Context, containerID string, reveal bool) (map[string]string, error) {
+	inspect, err := c.InspectContainerRaw(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	if inspect.Config == nil {
+		return map[string]string{}, nil
+	}
+	return ParseEnv(inspect.Config.Env, reveal), nil
+}
+
+// ParseEnv turns KEY=VALUE entries into a map, redacting secret-looking values unless reveal
+// is set. An entry without "=" maps to an empty value; later duplicates win, as in Docker.
+func ParseEnv(env []string, reveal bool) map[string]string {
+	vars := make(map[string]string, len(env))
+	for _, entry := range env {
+		key, value, _ := strings.Cut(entry, "=")
+		if !reveal && isSecretEnvKey(key) {
+			value = RedactedValue
+		}
+		vars[key] = value
+	}
+	return vars
+}
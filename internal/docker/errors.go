@@ -2,6 +2,11 @@ package docker
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
@@ -17,8 +22,141 @@ var (
 
 	// ErrInvalidConfig is returned when container configuration is invalid
 	ErrInvalidConfig = errors.New("invalid container configuration")
+
+	// ErrContainerNotRunning is returned for operations that need a running container
+	ErrContainerNotRunning = errors.New("container is not running")
+
+	// ErrPartialLogs is returned with the logs read before the log stream broke off
+	ErrPartialLogs = errors.New("log stream ended early")
 )
 
+// hostnamePattern matches RFC 1123 hostnames such as host.docker.internal
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]*[A-Za-z0-9])?)*$`)
+
+// containerNamePattern matches the container names Docker accepts
+var containerNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]+$`)
+
+// MaxContainerNameLength keeps names usable as DNS labels, which is how containers on
+// user-defined networks resolve each other
+const MaxContainerNameLength = 63
+
+// ValidateContainerName checks a container name, including any configured prefix, against
+// Docker's naming rules
+func ValidateContainerName(name string) error {
+	if len(name) > MaxContainerNameLength {
+		return fmt.Errorf("%w: name %q is %d characters, longer than the limit of %d; choose a shorter name", ErrInvalidConfig, name, len(name), MaxContainerNameLength)
+	}
+	if !containerNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: name %q must be at least 2 characters, start with a letter or digit and contain only letters, digits, '_', '.' and '-'", ErrInvalidConfig, name)
+	}
+	return nil
+}
+
+// stopSignals are the signals a container may be stopped with. Node.js apps commonly listen
+// for SIGTERM or SIGINT; the rest cover process managers and custom handlers.
+var stopSignals = map[string]bool{
+	"SIGTERM":  true,
+	"SIGINT":   true,
+	"SIGQUIT":  true,
+	"SIGHUP":   true,
+	"SIGKILL":  true,
+	"SIGUSR1":  true,
+	"SIGUSR2":  true,
+	"SIGWINCH": true,
+}
+
+// NormalizeStopSignal converts a signal name such as "int", "INT" or "SIGINT" to the
+// canonical "SIGINT" form used by Docker and Dockerfiles
+func NormalizeStopSignal(signal string) (string, error) {
+	name := strings.ToUpper(strings.TrimSpace(signal))
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if !stopSignals[name] {
+		return "", fmt.Errorf("%w: unsupported stop signal %q", ErrInvalidConfig, signal)
+	}
+	return name, nil
+}
+
+// validateExtraHost checks a "hostname:ip" /etc/hosts entry. The IP may be IPv6 or Docker's
+// special "host-gateway" value, which resolves to the host's address.
+func validateExtraHost(entry string) error {
+	hostname, ip, ok := strings.Cut(entry, ":")
+	if !ok || !hostnamePattern.MatchString(hostname) {
+		return fmt.Errorf("extra host %q must be in hostname:ip format", entry)
+	}
+	if ip != "host-gateway" && net.ParseIP(ip) == nil {
+		return fmt.Errorf("extra host %q has an invalid IP address", entry)
+	}
+	return nil
+}
+
+// validateUser checks a numeric "uid" or "uid:gid". Names are not accepted because they
+// only resolve if the image's /etc/passwd happens to define them.
+func validateUser(user string) error {
+	uid, gid, hasGID := strings.Cut(user, ":")
+	if _, err := strconv.ParseUint(uid, 10, 32); err != nil {
+		return fmt.Errorf("user %q must be a numeric uid or uid:gid", user)
+	}
+	if hasGID {
+		if _, err := strconv.ParseUint(gid, 10, 32); err != nil {
+			return fmt.Errorf("user %q must be a numeric uid or uid:gid", user)
+		}
+	}
+	return nil
+}
+
+// ipcSysctls are the IPC namespace sysctls Docker lets containers set; fs.mqueue.* is also
+// namespaced and checked by prefix
+var ipcSysctls = map[string]bool{
+	"kernel.msgmax":          true,
+	"kernel.msgmnb":          true,
+	"kernel.msgmni":          true,
+	"kernel.sem":             true,
+	"kernel.shmall":          true,
+	"kernel.shmmax":          true,
+	"kernel.shmmni":          true,
+	"kernel.shm_rmid_forced": true,
+}
+
+// validateSysctl checks that a sysctl is one Docker allows: an IPC namespace sysctl, or a
+// net.* sysctl when the container has its own network namespace. Other kernel parameters are
+// shared with the host and cannot be set per container.
+func validateSysctl(key, value, networkMode string) error {
+	if value == "" {
+		return fmt.Errorf("sysctl %s needs a value", key)
+	}
+	switch {
+	case ipcSysctls[key], strings.HasPrefix(key, "fs.mqueue."):
+		return nil
+	case strings.HasPrefix(key, "net."):
+		if networkMode == "host" {
+			return fmt.Errorf("sysctl %s cannot be set with the host network mode, it would change the host's network stack", key)
+		}
+		return nil
+	}
+	return fmt.Errorf("sysctl %s is not namespaced; only net.*, fs.mqueue.* and the kernel IPC sysctls (kernel.msgmax, kernel.msgmnb, kernel.msgmni, kernel.sem, kernel.shmall, kernel.shmmax, kernel.shmmni, kernel.shm_rmid_forced) can be set per container", key)
+}
+
+// validateDevice checks a device mapping's paths and cgroup permissions. Whether the device
+// exists is left to the caller, which runs on the daemon's host.
+func validateDevice(device DeviceMapping) error {
+	if device.HostPath != path.Clean(device.HostPath) || !strings.HasPrefix(device.HostPath, "/dev/") {
+		return fmt.Errorf("device %q must be a clean path under /dev", device.HostPath)
+	}
+	if device.ContainerPath != "" && !path.IsAbs(device.ContainerPath) {
+		return fmt.Errorf("device %s: container path %q must be absolute", device.HostPath, device.ContainerPath)
+	}
+	seen := map[rune]bool{}
+	for _, p := range device.Permissions {
+		if !strings.ContainsRune("rwm", p) || seen[p] {
+			return fmt.Errorf("device %s: permissions %q must combine r, w and m", device.HostPath, device.Permissions)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
 // IsContainerNotFoundError checks if the error is a container not found error
 func IsContainerNotFoundError(err error) bool {
 	if err == nil {
@@ -27,14 +165,50 @@ func IsContainerNotFoundError(err error) bool {
 	return strings.Contains(err.Error(), "No such container")
 }
 
+// IsRemovalInProgressError checks if the error is the daemon already removing the container,
+// as it does for an auto-remove container that has just stopped
+func IsRemovalInProgressError(err error) bool {
+	if err == nil {
+		return false
+	}
+	return strings.Contains(err.Error(), "removal of container") && strings.Contains(err.Error(), "already in progress")
+}
+
+// IsDaemonUnavailableError checks if the error means the Docker daemon could not be reached
+func IsDaemonUnavailableError(err error) bool {
+	return isConnectionError(err)
+}
+
 // IsImageNotFoundError checks if the error is an image not found error
 func IsImageNotFoundError(err error) bool {
 	if err == nil {
 		return false
 	}
+	var clientErr *ClientError
+	if errors.As(err, &clientErr) && errors.Is(clientErr.Err, ErrImageNotFound) {
+		return true
+	}
 	return strings.Contains(err.Error(), "No such image")
 }
 
+// IsNameConflictError checks if the error is caused by a container name that is already in use
+func IsNameConflictError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var clientErr *ClientError
+	if errors.As(err, &clientErr) && errors.Is(clientErr.Err, ErrContainerAlreadyExists) {
+		return true
+	}
+	return strings.Contains(err.Error(), "is already in use")
+}
+
+// IsContainerNotRunningError checks if the error is caused by the container not running
+func IsContainerNotRunningError(err error) bool {
+	var clientErr *ClientError
+	return errors.As(err, &clientErr) && errors.Is(clientErr.Err, ErrContainerNotRunning)
+}
+
 // IsResourceConstraintError checks if the error is related to resource constraints
 func IsResourceConstraintError(err error) bool {
 	if err == nil {
@@ -43,7 +217,8 @@ func IsResourceConstraintError(err error) bool {
 	return strings.Contains(err.Error(), "Resource constraints exceeded")
 }
 
-// ParseContainerError parses Docker API errors and returns appropriate error types
+// ParseContainerError parses Docker API errors and returns appropriate error types. The
+// result wraps both the sentinel and err, so errors.Is and errors.As still reach the original.
 func ParseContainerError(err error) error {
 	if err == nil {
 		return nil
@@ -51,18 +226,85 @@ func ParseContainerError(err error) error {
 
 	switch {
 	case IsContainerNotFoundError(err):
-		return ErrContainerNotFound
+		return wrapSentinel(ErrContainerNotFound, err)
 	case IsImageNotFoundError(err):
-		return ErrImageNotFound
+		return wrapSentinel(ErrImageNotFound, err)
 	case strings.Contains(err.Error(), "Conflict"):
-		return ErrContainerAlreadyExists
+		return wrapSentinel(ErrContainerAlreadyExists, err)
 	default:
 		return err
 	}
 }
 
-// ValidateContainerConfig validates container configuration
+// wrapSentinel returns err wrapped with sentinel, unless err already wraps it
+func wrapSentinel(sentinel, err error) error {
+	if errors.Is(err, sentinel) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", sentinel, err)
+}
+
+// validCapabilities lists the Linux capability names accepted by Docker, without the CAP_ prefix
+var validCapabilities = map[string]bool{
+	"ALL":                true,
+	"AUDIT_CONTROL":      true,
+	"AUDIT_READ":         true,
+	"AUDIT_WRITE":        true,
+	"BLOCK_SUSPEND":      true,
+	"BPF":                true,
+	"CHECKPOINT_RESTORE": true,
+	"CHOWN":              true,
+	"DAC_OVERRIDE":       true,
+	"DAC_READ_SEARCH":    true,
+	"FOWNER":             true,
+	"FSETID":             true,
+	"IPC_LOCK":           true,
+	"IPC_OWNER":          true,
+	"KILL":               true,
+	"LEASE":              true,
+	"LINUX_IMMUTABLE":    true,
+	"MAC_ADMIN":          true,
+	"MAC_OVERRIDE":       true,
+	"MKNOD":              true,
+	"NET_ADMIN":          true,
+	"NET_BIND_SERVICE":   true,
+	"NET_BROADCAST":      true,
+	"NET_RAW":            true,
+	"PERFMON":            true,
+	"SETFCAP":            true,
+	"SETGID":             true,
+	"SETPCAP":            true,
+	"SETUID":             true,
+	"SYS_ADMIN":          true,
+	"SYS_BOOT":           true,
+	"SYS_CHROOT":         true,
+	"SYS_MODULE":         true,
+	"SYS_NICE":           true,
+	"SYS_PACCT":          true,
+	"SYS_PTRACE":         true,
+	"SYS_RAWIO":          true,
+	"SYS_RESOURCE":       true,
+	"SYS_TIME":           true,
+	"SYS_TTY_CONFIG":     true,
+	"SYSLOG":             true,
+	"WAKE_ALARM":         true,
+}
+
+// IsValidCapability checks a capability name, accepting any case and an optional CAP_ prefix
+func IsValidCapability(name string) bool {
+	return validCapabilities[strings.TrimPrefix(strings.ToUpper(name), "CAP_")]
+}
+
+// ValidateContainerConfig validates container configuration. Its errors wrap ErrInvalidConfig.
 func ValidateContainerConfig(config ContainerConfig) error {
+	err := validateContainerConfig(config)
+	if err != nil && !errors.Is(err, ErrInvalidConfig) {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	return err
+}
+
+func validateContainerConfig(config ContainerConfig) error {
 	if config.Image == "" {
 		return errors.New("image name is required")
 	}
@@ -71,10 +313,118 @@ func ValidateContainerConfig(config ContainerConfig) error {
 		return errors.New("memory limit must be non-negative")
 	}
 
+	if config.MemoryReservation < 0 {
+		return errors.New("memory reservation must be non-negative")
+	}
+
+	if config.MemoryLimit > 0 && config.MemoryReservation > config.MemoryLimit {
+		return errors.New("memory reservation must not exceed memory limit")
+	}
+
+	// Docker requires a memory limit when limiting swap, and memory+swap must cover the memory limit
+	if config.MemorySwap < -1 {
+		return errors.New("memory swap must be -1 (unlimited) or non-negative")
+	}
+
+	if config.MemorySwap > 0 {
+		if config.MemoryLimit == 0 {
+			return errors.New("memory limit is required when memory swap is set")
+		}
+		if config.MemorySwap < config.MemoryLimit {
+			return errors.New("memory swap must be greater than or equal to memory limit")
+		}
+	}
+
 	if config.CPUShares < 0 {
 		return errors.New("CPU shares must be non-negative")
 	}
 
+	if config.CPULimit < 0 {
+		return errors.New("CPU limit must be non-negative")
+	}
+
+	if config.CPULimit > 0 && config.CPUQuota != 0 {
+		return errors.New("CPU limit and CPU quota are mutually exclusive")
+	}
+
+	// Docker accepts CFS periods between 1ms and 1s and quotas of at least 1ms
+	if config.CPUPeriod != 0 && (config.CPUPeriod < 1000 || config.CPUPeriod > 1000000) {
+		return errors.New("CPU period must be between 1000 and 1000000 microseconds")
+	}
+
+	if config.CPUQuota != 0 && config.CPUQuota < 1000 {
+		return errors.New("CPU quota must be at least 1000 microseconds")
+	}
+
+	if config.StopSignal != "" && !stopSignals[config.StopSignal] {
+		return fmt.Errorf("unsupported stop signal %q", config.StopSignal)
+	}
+
+	if config.StopTimeout < 0 {
+		return errors.New("stop timeout must be non-negative")
+	}
+
+	if config.PidsLimit < 0 {
+		return errors.New("pids limit must be non-negative")
+	}
+
+	if config.ShmSize < 0 {
+		return errors.New("shm size must be non-negative")
+	}
+
+	for _, ulimit := range config.Ulimits {
+		if ulimit.Name == "" {
+			return errors.New("ulimit name is required")
+		}
+		if ulimit.Soft < 0 || ulimit.Hard < 0 {
+			return fmt.Errorf("ulimit %s values must be non-negative", ulimit.Name)
+		}
+		if ulimit.Soft > ulimit.Hard {
+			return fmt.Errorf("ulimit %s soft limit must not exceed hard limit", ulimit.Name)
+		}
+	}
+
+	if config.BindIP != "" && net.ParseIP(config.BindIP) == nil {
+		return fmt.Errorf("bind IP %q is not a valid IP address", config.BindIP)
+	}
+
+	for spec := range config.Ports {
+		if _, err := parsePortSpec(spec); err != nil {
+			return err
+		}
+	}
+
+	for _, mount := range config.TmpfsMounts {
+		path, _, _ := strings.Cut(mount, ":")
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("tmpfs mount path must be absolute: %s", path)
+		}
+	}
+
+	for _, host := range config.ExtraHosts {
+		if err := validateExtraHost(host); err != nil {
+			return err
+		}
+	}
+
+	for _, server := range config.DNS {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("DNS server %q is not a valid IP address", server)
+		}
+	}
+
+	for _, capability := range config.CapAdd {
+		if !IsValidCapability(capability) {
+			return fmt.Errorf("unknown capability to add: %s", capability)
+		}
+	}
+
+	for _, capability := range config.CapDrop {
+		if !IsValidCapability(capability) {
+			return fmt.Errorf("unknown capability to drop: %s", capability)
+		}
+	}
+
 	if config.NetworkMode != "" {
 		validModes := map[string]bool{
 			"bridge":     true,
@@ -83,12 +433,8 @@ func ValidateContainerConfig(config ContainerConfig) error {
 			"container":  true,
 		}
 
-		// Check if it's a container network mode (container:<name|id>)
-		if strings.HasPrefix(config.NetworkMode, "container:") {
-			return nil
-		}
-
-		if !validModes[config.NetworkMode] {
+		// Container network modes (container:<name|id>) are also valid
+		if !validModes[config.NetworkMode] && !strings.HasPrefix(config.NetworkMode, "container:") {
 			return errors.New("invalid network mode")
 		}
 	}
@@ -106,5 +452,46 @@ func ValidateContainerConfig(config ContainerConfig) error {
 		}
 	}
 
+	if config.RestartMaxRetries < 0 {
+		return errors.New("restart max retries cannot be negative")
+	}
+	if config.RestartMaxRetries > 0 && config.RestartPolicy != "on-failure" {
+		return fmt.Errorf("restart max retries requires the on-failure restart policy, not %q", config.RestartPolicy)
+	}
+
+	if _, err := ParsePlatform(config.Platform); err != nil {
+		return err
+	}
+
+	if config.User != "" {
+		if err := validateUser(config.User); err != nil {
+			return err
+		}
+	}
+
+	for _, device := range config.Devices {
+		if err := validateDevice(device); err != nil {
+			return err
+		}
+	}
+
+	for key, value := range config.Sysctls {
+		if err := validateSysctl(key, value, config.NetworkMode); err != nil {
+			return err
+		}
+	}
+
+	if config.LogDriver != "" && !IsValidLogDriver(config.LogDriver) {
+		return fmt.Errorf("unknown log driver %q", config.LogDriver)
+	}
+	if len(config.LogOpts) > 0 && config.LogDriver == "none" {
+		return errors.New("log options cannot be used with the none log driver")
+	}
+
+	// Docker refuses to restart a container it is about to remove
+	if config.AutoRemove && config.RestartPolicy != "" && config.RestartPolicy != "no" {
+		return fmt.Errorf("autoRemove cannot be combined with restart policy %q", config.RestartPolicy)
+	}
+
 	return nil
 }
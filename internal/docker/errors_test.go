@@ -0,0 +1,281 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+)
+
+func TestValidateContainerConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ContainerConfig
+		wantErr bool
+	}{
+		{
+			name:    "minimal config",
+			config:  ContainerConfig{Image: "node:18-alpine"},
+			wantErr: false,
+		},
+		{
+			name:    "missing image",
+			config:  ContainerConfig{},
+			wantErr: true,
+		},
+		{
+			name: "memory reservation and swap",
+			config: ContainerConfig{
+				Image:             "node:18-alpine",
+				MemoryLimit:       512 * 1024 * 1024,
+				MemoryReservation: 256 * 1024 * 1024,
+				MemorySwap:        1024 * 1024 * 1024,
+			},
+			wantErr: false,
+		},
+		{
+			name: "swap equal to memory disables swap",
+			config: ContainerConfig{
+				Image:       "node:18-alpine",
+				MemoryLimit: 512 * 1024 * 1024,
+				MemorySwap:  512 * 1024 * 1024,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unlimited swap",
+			config: ContainerConfig{
+				Image:       "node:18-alpine",
+				MemoryLimit: 512 * 1024 * 1024,
+				MemorySwap:  -1,
+			},
+			wantErr: false,
+		},
+		{
+			name: "swap less than memory",
+			config: ContainerConfig{
+				Image:       "node:18-alpine",
+				MemoryLimit: 512 * 1024 * 1024,
+				MemorySwap:  256 * 1024 * 1024,
+			},
+			wantErr: true,
+		},
+		{
+			name: "swap without memory limit",
+			config: ContainerConfig{
+				Image:      "node:18-alpine",
+				MemorySwap: 256 * 1024 * 1024,
+			},
+			wantErr: true,
+		},
+		{
+			name: "reservation above limit",
+			config: ContainerConfig{
+				Image:             "node:18-alpine",
+				MemoryLimit:       256 * 1024 * 1024,
+				MemoryReservation: 512 * 1024 * 1024,
+			},
+			wantErr: true,
+		},
+		{
+			name:    "container network mode",
+			config:  ContainerConfig{Image: "node:18-alpine", NetworkMode: "container:db"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid network mode",
+			config:  ContainerConfig{Image: "node:18-alpine", NetworkMode: "overlay"},
+			wantErr: true,
+		},
+		{
+			name:    "auto remove without restart",
+			config:  ContainerConfig{Image: "node:18-alpine", AutoRemove: true, RestartPolicy: "no"},
+			wantErr: false,
+		},
+		{
+			name:    "auto remove with restart policy",
+			config:  ContainerConfig{Image: "node:18-alpine", AutoRemove: true, RestartPolicy: "always"},
+			wantErr: true,
+		},
+		{
+			name:    "arm64 platform",
+			config:  ContainerConfig{Image: "node:18-alpine", Platform: "linux/arm64"},
+			wantErr: false,
+		},
+		{
+			name:    "unknown platform",
+			config:  ContainerConfig{Image: "node:18-alpine", Platform: "linux/sparc"},
+			wantErr: true,
+		},
+		{
+			name:    "on-failure with max retries",
+			config:  ContainerConfig{Image: "node:18-alpine", RestartPolicy: "on-failure", RestartMaxRetries: 3},
+			wantErr: false,
+		},
+		{
+			name:    "max retries without on-failure",
+			config:  ContainerConfig{Image: "node:18-alpine", RestartPolicy: "always", RestartMaxRetries: 3},
+			wantErr: true,
+		},
+		{
+			name:    "negative max retries",
+			config:  ContainerConfig{Image: "node:18-alpine", RestartPolicy: "on-failure", RestartMaxRetries: -1},
+			wantErr: true,
+		},
+		{
+			name:    "valid user uid and gid",
+			config:  ContainerConfig{Image: "node:18-alpine", User: "1000:1000"},
+			wantErr: false,
+		},
+		{
+			name:    "valid user uid only",
+			config:  ContainerConfig{Image: "node:18-alpine", User: "1000"},
+			wantErr: false,
+		},
+		{
+			name:    "user name instead of uid",
+			config:  ContainerConfig{Image: "node:18-alpine", User: "node"},
+			wantErr: true,
+		},
+		{
+			name:    "user with invalid gid",
+			config:  ContainerConfig{Image: "node:18-alpine", User: "1000:staff"},
+			wantErr: true,
+		},
+		{
+			name:    "user with empty gid",
+			config:  ContainerConfig{Image: "node:18-alpine", User: "1000:"},
+			wantErr: true,
+		},
+		{
+			name: "valid extra hosts",
+			config: ContainerConfig{
+				Image:      "node:18-alpine",
+				ExtraHosts: []string{"host.docker.internal:host-gateway", "db:10.0.0.5", "ipv6-host:fd00::1"},
+			},
+			wantErr: false,
+		},
+		{
+			name:    "extra host without ip",
+			config:  ContainerConfig{Image: "node:18-alpine", ExtraHosts: []string{"db"}},
+			wantErr: true,
+		},
+		{
+			name:    "extra host with invalid ip",
+			config:  ContainerConfig{Image: "node:18-alpine", ExtraHosts: []string{"db:999.0.0.1"}},
+			wantErr: true,
+		},
+		{
+			name:    "extra host with invalid hostname",
+			config:  ContainerConfig{Image: "node:18-alpine", ExtraHosts: []string{"bad_host!:10.0.0.5"}},
+			wantErr: true,
+		},
+		{
+			name:    "valid dns servers",
+			config:  ContainerConfig{Image: "node:18-alpine", DNS: []string{"10.0.0.2", "fd00::53"}},
+			wantErr: false,
+		},
+		{
+			name:    "dns server hostname",
+			config:  ContainerConfig{Image: "node:18-alpine", DNS: []string{"dns.corp.internal"}},
+			wantErr: true,
+		},
+		{
+			name:    "udp port",
+			config:  ContainerConfig{Image: "node:18-alpine", Ports: map[string]string{"53/udp": "53"}},
+			wantErr: false,
+		},
+		{
+			name:    "unsupported port protocol",
+			config:  ContainerConfig{Image: "node:18-alpine", Ports: map[string]string{"3868/sctp": "3868"}},
+			wantErr: true,
+		},
+		{
+			name:    "namespaced sysctls",
+			config:  ContainerConfig{Image: "node:18-alpine", Sysctls: map[string]string{"net.core.somaxconn": "1024", "kernel.shmmax": "68719476736", "fs.mqueue.msg_max": "20"}},
+			wantErr: false,
+		},
+		{
+			name:    "host-wide sysctl",
+			config:  ContainerConfig{Image: "node:18-alpine", Sysctls: map[string]string{"vm.max_map_count": "262144"}},
+			wantErr: true,
+		},
+		{
+			name:    "net sysctl with host network",
+			config:  ContainerConfig{Image: "node:18-alpine", NetworkMode: "host", Sysctls: map[string]string{"net.core.somaxconn": "1024"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative stop timeout",
+			config:  ContainerConfig{Image: "node:18-alpine", StopTimeout: -1},
+			wantErr: true,
+		},
+		{
+			name:    "negative shm size",
+			config:  ContainerConfig{Image: "node:18-alpine", ShmSize: -1},
+			wantErr: true,
+		},
+		{
+			name:    "device mapping",
+			config:  ContainerConfig{Image: "node:18-alpine", Devices: []DeviceMapping{{HostPath: "/dev/ttyUSB0", ContainerPath: "/dev/ttyUSB0", Permissions: "rw"}}},
+			wantErr: false,
+		},
+		{
+			name:    "device outside /dev",
+			config:  ContainerConfig{Image: "node:18-alpine", Devices: []DeviceMapping{{HostPath: "/dev/../etc/shadow"}}},
+			wantErr: true,
+		},
+		{
+			name:    "device with invalid permissions",
+			config:  ContainerConfig{Image: "node:18-alpine", Devices: []DeviceMapping{{HostPath: "/dev/null", Permissions: "rwx"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateContainerConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateContainerConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("ValidateContainerConfig() error = %v, want it to wrap ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestErrorWrapping(t *testing.T) {
+	daemonErr := errdefs.NotFound(errors.New("No such container: abc123"))
+	clientErr := &ClientError{Op: "inspect", Err: daemonErr, Details: "Container not found"}
+
+	tests := []struct {
+		name        string
+		err         error
+		sentinel    error
+		clientError bool
+	}{
+		{"container not found", ParseContainerError(clientErr), ErrContainerNotFound, true},
+		{"image not found", ParseContainerError(&ClientError{Op: "create", Err: errors.New("No such image: node:99")}), ErrImageNotFound, true},
+		{"name conflict", ParseContainerError(errors.New(`Conflict. The container name "/api" is already in use`)), ErrContainerAlreadyExists, false},
+		{"sentinel inside ClientError", &ClientError{Op: "inspect_image", Err: fmt.Errorf("%w: node:99", ErrImageNotFound)}, ErrImageNotFound, true},
+		{"already wrapped sentinel", ParseContainerError(&ClientError{Op: "stop", Err: ErrContainerNotFound}), ErrContainerNotFound, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !errors.Is(tt.err, tt.sentinel) {
+				t.Errorf("errors.Is(%v, %v) = false", tt.err, tt.sentinel)
+			}
+			var target *ClientError
+			if errors.As(tt.err, &target) != tt.clientError {
+				t.Errorf("errors.As(%v, *ClientError) = %v, want %v", tt.err, !tt.clientError, tt.clientError)
+			}
+		})
+	}
+
+	// The original daemon error stays reachable through the parsed error
+	if parsed := ParseContainerError(clientErr); !errors.Is(parsed, daemonErr) {
+		t.Errorf("ParseContainerError() = %v, lost the daemon error", parsed)
+	}
+}
@@ -0,0 +1,187 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"docker-management-system/internal/logging"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"go.uber.org/zap"
+)
+
+// eventResubscribeDelay is how long WatchContainerEvents waits before subscribing again after
+// the event stream failed. It is a variable so tests can shorten it.
+var eventResubscribeDelay = time.Second
+
+// ErrContainerExited is returned by WaitForHealthy when the container stops before it is ready
+var ErrContainerExited = errors.New("container exited before becoming ready")
+
+// WaitForHealthy blocks until the container reports healthy or, for containers without a
+// health check, has started. It subscribes to the daemon's event stream for the container
+// instead of polling, so callers can invoke it right before or after starting the container.
+func (c *Client) WaitForHealthy(ctx context.Context, containerID string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	filterArgs := filters.NewArgs(
+		filters.Arg("type", string(events.ContainerEventType)),
+		filters.Arg("container", containerID),
+		filters.Arg("event", string(events.ActionStart)),
+		filters.Arg("event", string(events.ActionHealthStatusHealthy)),
+		filters.Arg("event", string(events.ActionDie)),
+	)
+	messages, errs := c.api().Events(ctx, events.ListOptions{Filters: filterArgs})
+
+	// The container may already be ready if it started before the subscription was set up
+	if inspect, err := c.api().ContainerInspect(ctx, containerID); err == nil && isReady(inspect) {
+		return nil
+	}
+
+	for {
+		select {
+		case msg := <-messages:
+			switch msg.Action {
+			case events.ActionHealthStatusHealthy:
+				return nil
+			case events.ActionStart:
+				// Containers with a health check are only ready once the check passes
+				if !c.hasHealthCheck(ctx, containerID) {
+					return nil
+				}
+			case events.ActionDie:
+				return &ClientError{Op: "wait_healthy", Err: ErrContainerExited, Details: containerID}
+			}
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return waitTimeoutError(containerID, timeout, ctx.Err())
+			}
+			return &ClientError{Op: "wait_healthy", Err: err}
+		case <-ctx.Done():
+			return waitTimeoutError(containerID, timeout, ctx.Err())
+		}
+	}
+}
+
+// isReady reports whether an inspected container is healthy, or running without a health check
+func isReady(inspect types.ContainerJSON) bool {
+	if inspect.ContainerJSONBase == nil || inspect.State == nil || !inspect.State.Running {
+		return false
+	}
+	if inspect.State.Health == nil {
+		return true
+	}
+	return inspect.State.Health.Status == types.Healthy
+}
+
+// hasHealthCheck reports whether the container defines a health check; on inspect failure it
+// assumes one exists so WaitForHealthy keeps waiting for an explicit health event
+func (c *Client) hasHealthCheck(ctx context.Context, containerID string) bool {
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return true
+	}
+	if inspect.ContainerJSONBase != nil && inspect.State != nil && inspect.State.Health != nil {
+		return true
+	}
+	return inspect.Config != nil && inspect.Config.Healthcheck != nil &&
+		len(inspect.Config.Healthcheck.Test) > 0 && inspect.Config.Healthcheck.Test[0] != "NONE"
+}
+
+// ExitError reports a container that stopped while it was expected to keep running
+type ExitError struct {
+	ContainerID string
+	ExitCode    int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("container %s exited with code %d", e.ContainerID, e.ExitCode)
+}
+
+// Unwrap lets callers match the error with errors.Is(err, ErrContainerExited)
+func (e *ExitError) Unwrap() error {
+	return ErrContainerExited
+}
+
+// VerifyRunning waits out a grace period after a start and then checks the container is still
+// up, so an app that crashes on boot is reported instead of looking started. A container that
+// exited, or is being restarted by its restart policy, yields an *ExitError with its exit code.
+func (c *Client) VerifyRunning(ctx context.Context, containerID string, grace time.Duration) error {
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+		return &ClientError{Op: "verify_running", Err: ctx.Err()}
+	}
+
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return &ClientError{Op: "verify_running", Err: err}
+	}
+	if inspect.ContainerJSONBase == nil || inspect.State == nil {
+		return &ClientError{Op: "verify_running", Err: errors.New("container state unavailable"), Details: containerID}
+	}
+	if inspect.State.Running && !inspect.State.Restarting {
+		return nil
+	}
+	return &ExitError{ContainerID: containerID, ExitCode: inspect.State.ExitCode}
+}
+
+func waitTimeoutError(containerID string, timeout time.Duration, err error) error {
+	return &ClientError{
+		Op:      "wait_healthy",
+		Err:     fmt.Errorf("container %s not ready after %s: %w", containerID, timeout, err),
+		Details: "Timed out waiting for container",
+	}
+}
+
+// WatchContainerEvents calls fn for every container event with one of actions until ctx is
+// done, subscribing again whenever the daemon's event stream fails. Events that happen while
+// the subscription is down are missed, so callers that must see every event can pass
+// connected, which is called with true once subscribed and false when the stream drops.
+// It blocks, so run it in its own goroutine.
+func (c *Client) WatchContainerEvents(ctx context.Context, actions []events.Action, fn func(events.Message), connected func(bool)) {
+	filterArgs := filters.NewArgs(filters.Arg("type", string(events.ContainerEventType)))
+	for _, action := range actions {
+		filterArgs.Add("event", string(action))
+	}
+
+	for {
+		subCtx, cancel := context.WithCancel(ctx)
+		messages, errs := c.api().Events(subCtx, events.ListOptions{Filters: filterArgs})
+		if connected != nil {
+			connected(true)
+		}
+
+	stream:
+		for {
+			select {
+			case msg := <-messages:
+				fn(msg)
+			case err := <-errs:
+				if ctx.Err() == nil {
+					logging.GetLogger(ctx).Warn("container event subscription failed", zap.Error(err))
+				}
+				break stream
+			case <-ctx.Done():
+				break stream
+			}
+		}
+		cancel()
+		if connected != nil {
+			connected(false)
+		}
+
+		select {
+		case <-time.After(eventResubscribeDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package docker
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// FileEntry is a file to write into a container. A zero Mode means 0644.
+type FileEntry struct {
+	Path    string
+	Content []byte
+	Mode    int64
+}
+
+// ValidateFilePath checks that a destination inside a container is absolute, already clean
+// and names a file rather than the root, so a tar entry cannot climb out with ".." or land
+// somewhere other than where it reads
+func ValidateFilePath(p string) error {
+	switch {
+	case p == "":
+		return fmt.Errorf("path must not be empty")
+	case strings.ContainsRune(p, 0):
+		return fmt.Errorf("path %q contains a NUL byte", p)
+	case !path.IsAbs(p):
+		return fmt.Errorf("path %s must be absolute", p)
+	case path.Clean(p) != p:
+		return fmt.Errorf("path %s must be clean, without ., .. or repeated or trailing slashes", p)
+	case p == "/":
+		return fmt.Errorf("path must name a file, not /")
+	}
+	return nil
+}
+
+// CopyFilesToContainer writes files into a container with a single copy. The files are
+// packed into one tar archive extracted at /, so missing parent directories are created and
+// existing files are overwritten.
+func (c *Client) CopyFilesToContainer(ctx context.Context, containerID string, files []FileEntry) error {
+	archive, err := tarFiles(files)
+	if err != nil {
+		return &ClientError{Op: "copy_files", Err: err, Details: containerID}
+	}
+
+	if err := c.api().CopyToContainer(ctx, containerID, "/", archive, types.CopyToContainerOptions{}); err != nil {
+		c.checkConnection(ctx, err)
+		return &ClientError{Op: "copy_files", Err: err, Details: containerID}
+	}
+	return nil
+}
+
+// tarFiles packs files into an in-memory tar archive with paths relative to /
+func tarFiles(files []FileEntry) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	seen := make(map[string]bool, len(files))
+	modTime := time.Now()
+
+	for _, file := range files {
+		if err := ValidateFilePath(file.Path); err != nil {
+			return nil, err
+		}
+		if seen[file.Path] {
+			return nil, fmt.Errorf("path %s is listed more than once", file.Path)
+		}
+		seen[file.Path] = true
+
+		mode := file.Mode
+		if mode == 0 {
+			mode = 0o644
+		}
+		if mode&^0o777 != 0 {
+			return nil, fmt.Errorf("mode %o for %s must only have permission bits", mode, file.Path)
+		}
+
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     strings.TrimPrefix(file.Path, "/"),
+			Mode:     mode,
+			Size:     int64(len(file.Content)),
+			ModTime:  modTime,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(file.Content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return &buf, nil
+}
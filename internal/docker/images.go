@@ -0,0 +1,393 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"docker-management-system/internal/docker/nodeproject"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+)
+
+// PullPolicy controls whether an image is pulled before a container is created
+type PullPolicy string
+
+const (
+	// PullAlways pulls before every create to pick up the latest version of the tag
+	PullAlways PullPolicy = "always"
+	// PullMissing pulls only when the image is not present locally
+	PullMissing PullPolicy = "missing"
+	// PullNever never pulls and fails if the image is not present locally
+	PullNever PullPolicy = "never"
+)
+
+// DefaultPullPolicy is used when neither the request nor the configuration sets a policy
+const DefaultPullPolicy = PullMissing
+
+// IsValidPullPolicy reports whether policy is one of the supported pull policies
+func IsValidPullPolicy(policy string) bool {
+	switch PullPolicy(policy) {
+	case PullAlways, PullMissing, PullNever:
+		return true
+	}
+	return false
+}
+
+// EnsureImage makes the image available locally according to policy. With a platform, a local
+// image built for another platform counts as missing. It reports whether a pull was performed.
+func (c *Client) EnsureImage(ctx context.Context, ref string, policy PullPolicy, platform string) (bool, error) {
+	if policy != PullAlways {
+		inspect, _, err := c.api().ImageInspectWithRaw(ctx, ref)
+		if err == nil && !imageMatchesPlatform(inspect, platform) {
+			err = fmt.Errorf("%w: %s for %s", ErrImageNotFound, ref, platform)
+		}
+		switch {
+		case err == nil:
+			return false, nil
+		case !IsImageNotFoundError(err):
+			return false, &ClientError{
+				Op:  "inspect_image",
+				Err: err,
+			}
+		case policy == PullNever:
+			return false, &ClientError{
+				Op:      "inspect_image",
+				Err:     fmt.Errorf("%w: %s", ErrImageNotFound, ref),
+				Details: "pull policy is never",
+			}
+		}
+	}
+
+	if err := c.PullImage(ctx, ref, platform); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// imageMatchesPlatform reports whether a local image runs on platform; any image matches an
+// empty platform
+func imageMatchesPlatform(inspect types.ImageInspect, platform string) bool {
+	p, err := ParsePlatform(platform)
+	if err != nil || p == nil {
+		return true
+	}
+	if inspect.Os != p.OS || inspect.Architecture != p.Architecture {
+		return false
+	}
+	return p.Variant == "" || inspect.Variant == "" || inspect.Variant == p.Variant
+}
+
+// PullImage pulls an image, for platform if one is given, and waits for the pull to finish
+func (c *Client) PullImage(ctx context.Context, ref, platform string) error {
+	progress, err := c.api().ImagePull(ctx, ref, image.PullOptions{Platform: platform})
+	if err != nil {
+		return &ClientError{
+			Op:  "pull_image",
+			Err: err,
+		}
+	}
+	defer progress.Close()
+
+	// The pull only completes once its progress stream has been consumed
+	if _, err := io.Copy(io.Discard, progress); err != nil {
+		return &ClientError{
+			Op:  "pull_image",
+			Err: err,
+		}
+	}
+	return nil
+}
+
+// PullProgress is one progress message from an image pull, as sent by the daemon. ID names
+// the layer the message is about; messages about the pull as a whole have none.
+type PullProgress struct {
+	ID             string         `json:"id,omitempty"`
+	Status         string         `json:"status,omitempty"`
+	Progress       string         `json:"progress,omitempty"` // Rendered progress bar
+	ProgressDetail ProgressDetail `json:"progressDetail"`
+	Error          string         `json:"error,omitempty"`
+}
+
+// ProgressDetail counts the bytes of a layer downloaded or extracted so far
+type ProgressDetail struct {
+	Current int64 `json:"current,omitempty"`
+	Total   int64 `json:"total,omitempty"`
+}
+
+// PullImageWithProgress pulls an image, passing each progress message to fn as it arrives.
+// Cancelling ctx aborts the pull.
+func (c *Client) PullImageWithProgress(ctx context.Context, ref string, fn func(PullProgress) error) error {
+	progress, err := c.api().ImagePull(ctx, ref, image.PullOptions{})
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return &ClientError{
+			Op:  "pull_image",
+			Err: err,
+		}
+	}
+	defer progress.Close()
+
+	if err := ForwardPullProgress(progress, fn); err != nil {
+		return &ClientError{
+			Op:      "pull_image",
+			Err:     err,
+			Details: ref,
+		}
+	}
+	return nil
+}
+
+// ForwardPullProgress decodes the daemon's JSON progress stream and passes each message to fn.
+// A pull that fails part way is reported in the stream rather than by the API call, so a
+// message carrying an error is passed on and then returned as the error.
+func ForwardPullProgress(stream io.Reader, fn func(PullProgress) error) error {
+	decoder := json.NewDecoder(stream)
+	for {
+		var message struct {
+			PullProgress
+			ErrorDetail *struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		progress := message.PullProgress
+		if progress.Error == "" && message.ErrorDetail != nil {
+			progress.Error = message.ErrorDetail.Message
+		}
+		if err := fn(progress); err != nil {
+			return err
+		}
+		if progress.Error != "" {
+			return errors.New(progress.Error)
+		}
+	}
+}
+
+// LoadImage loads the images in a `docker save` tar archive read from r and returns the
+// references they were loaded under: their tags, or their IDs for images saved without one
+func (c *Client) LoadImage(ctx context.Context, r io.Reader) ([]string, error) {
+	resp, err := c.api().ImageLoad(ctx, r, true)
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return nil, &ClientError{
+			Op:  "load_image",
+			Err: err,
+		}
+	}
+	defer resp.Body.Close()
+
+	loaded, err := ParseLoadOutput(resp.Body)
+	if err != nil {
+		return nil, &ClientError{
+			Op:  "load_image",
+			Err: err,
+		}
+	}
+	return loaded, nil
+}
+
+// Prefixes of the messages the daemon sends for each image it loads
+const (
+	loadedImagePrefix   = "Loaded image: "
+	loadedImageIDPrefix = "Loaded image ID: "
+)
+
+// ParseLoadOutput decodes the daemon's JSON image load stream and returns the loaded image
+// references in the order they were reported. Like a pull, a load that fails part way is
+// reported in the stream, so a message carrying an error is returned as the error.
+func ParseLoadOutput(stream io.Reader) ([]string, error) {
+	loaded := []string{}
+	decoder := json.NewDecoder(stream)
+	for {
+		var message struct {
+			Stream      string `json:"stream"`
+			Error       string `json:"error"`
+			ErrorDetail *struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return loaded, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if message.Error == "" && message.ErrorDetail != nil {
+			message.Error = message.ErrorDetail.Message
+		}
+		if message.Error != "" {
+			return nil, errors.New(message.Error)
+		}
+		for _, line := range strings.Split(message.Stream, "\n") {
+			line = strings.TrimSpace(line)
+			if ref, ok := strings.CutPrefix(line, loadedImagePrefix); ok {
+				loaded = append(loaded, ref)
+			} else if id, ok := strings.CutPrefix(line, loadedImageIDPrefix); ok {
+				loaded = append(loaded, id)
+			}
+		}
+	}
+}
+
+// SaveImage streams refs as a single `docker save` tar archive. The caller must close the
+// stream; the archive is produced by the daemon as it is read.
+func (c *Client) SaveImage(ctx context.Context, refs []string) (io.ReadCloser, error) {
+	archive, err := c.api().ImageSave(ctx, refs)
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return nil, &ClientError{
+			Op:      "save_image",
+			Err:     err,
+			Details: strings.Join(refs, ", "),
+		}
+	}
+	return archive, nil
+}
+
+// TagImage adds the tag target to the image source refers to
+func (c *Client) TagImage(ctx context.Context, source, target string) error {
+	if err := c.api().ImageTag(ctx, source, target); err != nil {
+		c.checkConnection(ctx, err)
+		return &ClientError{
+			Op:      "tag_image",
+			Err:     err,
+			Details: target,
+		}
+	}
+	return nil
+}
+
+const (
+	// ManagedImageNamespace is the repository prefix of images built by this service
+	ManagedImageNamespace = "blockbuilder/"
+	// ImageProjectPathLabel records the project directory an image was built from
+	ImageProjectPathLabel = ProjectPathLabel
+	// ImageBuiltAtLabel records when the image's build was prepared, in RFC3339 format
+	ImageBuiltAtLabel = nodeproject.BuiltAtLabel
+)
+
+// ImageBuildLabels returns the labels that mark an image as built by this service
+func ImageBuildLabels(projectPath string, builtAt time.Time) map[string]string {
+	return map[string]string{
+		ManagedByLabel:        ManagedByValue,
+		ImageProjectPathLabel: projectPath,
+		ImageBuiltAtLabel:     builtAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// ManagedImage is an image built by this service
+type ManagedImage struct {
+	ID          string     `json:"id"`
+	Tags        []string   `json:"tags"`
+	ProjectPath string     `json:"projectPath,omitempty"`
+	BuiltAt     *time.Time `json:"builtAt,omitempty"`
+	Created     time.Time  `json:"created"`
+	Size        int64      `json:"size"`
+}
+
+// ListManagedImages returns the images built by this service, newest first. An image counts
+// as managed if it carries the managed-by label or is tagged under ManagedImageNamespace.
+// Label filters narrow the list further, as for ListContainers.
+func (c *Client) ListManagedImages(ctx context.Context, labelFilter map[string]string) ([]ManagedImage, error) {
+	images, err := c.api().ImageList(ctx, image.ListOptions{Filters: labelFilterArgs(labelFilter)})
+	if err != nil {
+		return nil, &ClientError{
+			Op:  "list_images",
+			Err: err,
+		}
+	}
+
+	managed := make([]ManagedImage, 0)
+	for _, img := range images {
+		if !isManagedImage(img) {
+			continue
+		}
+		info := ManagedImage{
+			ID:          img.ID,
+			Tags:        img.RepoTags,
+			ProjectPath: img.Labels[ImageProjectPathLabel],
+			Created:     time.Unix(img.Created, 0),
+			Size:        img.Size,
+		}
+		if builtAt, err := time.Parse(time.RFC3339, img.Labels[ImageBuiltAtLabel]); err == nil {
+			info.BuiltAt = &builtAt
+		}
+		managed = append(managed, info)
+	}
+	sort.SliceStable(managed, func(i, j int) bool {
+		return managed[i].Created.After(managed[j].Created)
+	})
+	return managed, nil
+}
+
+// isManagedImage reports whether an image was built by this service
+func isManagedImage(img image.Summary) bool {
+	if img.Labels[ManagedByLabel] == ManagedByValue {
+		return true
+	}
+	for _, tag := range img.RepoTags {
+		if strings.HasPrefix(tag, ManagedImageNamespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildMetadata is the provenance recorded in an image's labels when it was built
+type BuildMetadata struct {
+	SourceCommit   string `json:"sourceCommit,omitempty"`
+	BuiltAt        string `json:"builtAt,omitempty"`
+	Framework      string `json:"framework,omitempty"`
+	BuilderVersion string `json:"builderVersion,omitempty"`
+}
+
+// ImageDetails describes a single image
+type ImageDetails struct {
+	ID            string            `json:"id"`
+	Tags          []string          `json:"tags"`
+	Created       string            `json:"created"`
+	Size          int64             `json:"size"`
+	Labels        map[string]string `json:"labels"`
+	BuildMetadata BuildMetadata     `json:"buildMetadata"`
+}
+
+// InspectImage returns an image's details, including the build metadata from its labels
+func (c *Client) InspectImage(ctx context.Context, ref string) (*ImageDetails, error) {
+	inspect, _, err := c.api().ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return nil, &ClientError{
+			Op:  "inspect_image",
+			Err: err,
+		}
+	}
+
+	var labels map[string]string
+	if inspect.Config != nil {
+		labels = inspect.Config.Labels
+	}
+	return &ImageDetails{
+		ID:      inspect.ID,
+		Tags:    inspect.RepoTags,
+		Created: inspect.Created,
+		Size:    inspect.Size,
+		Labels:  labels,
+		BuildMetadata: BuildMetadata{
+			SourceCommit:   labels[nodeproject.SourceCommitLabel],
+			BuiltAt:        labels[nodeproject.BuiltAtLabel],
+			Framework:      labels[nodeproject.FrameworkLabel],
+			BuilderVersion: labels[nodeproject.BuilderVersionLabel],
+		},
+	}, nil
+}
@@ -0,0 +1,126 @@
+package docker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+)
+
+// inspectCacheEvents are the container events after which a cached inspect result is stale
+var inspectCacheEvents = []events.Action{
+	events.ActionStart,
+	events.ActionStop,
+	events.ActionDie,
+	events.ActionDestroy,
+	events.ActionPause,
+	events.ActionUnPause,
+	events.ActionRename,
+	// The daemon matches this prefix against every "health_status: <status>" event
+	events.ActionHealthStatus,
+}
+
+// inspectCache keeps container inspect results for a TTL. Entries are evicted as soon as the
+// daemon reports a state change for the container, and nothing is cached while the event
+// subscription is down, because evictions could then be missed.
+type inspectCache struct {
+	ttl time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]inspectCacheEntry
+	generation uint64
+	live       bool
+}
+
+// inspectCacheEntry is one inspect result, keyed by the ID or name the caller asked for
+type inspectCacheEntry struct {
+	inspect types.ContainerJSON
+	expires time.Time
+}
+
+func newInspectCache(ttl time.Duration) *inspectCache {
+	return &inspectCache{ttl: ttl, entries: make(map[string]inspectCacheEntry)}
+}
+
+// get returns the cached result for key, or calls fetch and caches a successful result
+func (ic *inspectCache) get(key string, fetch func() (types.ContainerJSON, error)) (types.ContainerJSON, error) {
+	ic.mu.Lock()
+	if entry, ok := ic.entries[key]; ok && ic.live && time.Now().Before(entry.expires) {
+		ic.mu.Unlock()
+		return entry.inspect, nil
+	}
+	generation := ic.generation
+	ic.mu.Unlock()
+
+	inspect, err := fetch()
+	if err != nil {
+		return inspect, err
+	}
+
+	ic.mu.Lock()
+	// A result that raced with an eviction may already be stale
+	if ic.live && generation == ic.generation {
+		ic.entries[key] = inspectCacheEntry{inspect: inspect, expires: time.Now().Add(ic.ttl)}
+	}
+	ic.mu.Unlock()
+	return inspect, nil
+}
+
+// evict drops the entries for a container. Callers may have looked it up by name or short
+// ID, so entries are matched on the full ID the daemon returned as well as on their key.
+func (ic *inspectCache) evict(containerID string) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	ic.generation++
+	for key, entry := range ic.entries {
+		if key == containerID || entry.inspect.ContainerJSONBase == nil || entry.inspect.ID == containerID {
+			delete(ic.entries, key)
+		}
+	}
+}
+
+// setLive records whether the event subscription is up, dropping every entry either way
+func (ic *inspectCache) setLive(live bool) {
+	ic.mu.Lock()
+	defer ic.mu.Unlock()
+	ic.generation++
+	ic.live = live
+	ic.entries = make(map[string]inspectCacheEntry)
+}
+
+// EnableInspectCache caches GetContainer results for ttl and subscribes to container events
+// until ctx is done, evicting a container's entry when it starts, stops, dies, is destroyed,
+// paused, unpaused or renamed, or its health status changes.
+// If the subscription fails, caching is suspended until it has been re-established. A ttl of
+// zero or less disables caching.
+func (c *Client) EnableInspectCache(ctx context.Context, ttl time.Duration) {
+	if ttl <= 0 {
+		c.inspectCache = nil
+		return
+	}
+	cache := newInspectCache(ttl)
+	c.inspectCache = cache
+	go c.WatchContainerEvents(ctx, inspectCacheEvents, func(msg events.Message) {
+		cache.evict(msg.Actor.ID)
+	}, cache.setLive)
+}
+
+// inspect returns the container's inspect result, from the cache when it is enabled
+func (c *Client) inspect(ctx context.Context, containerID string) (types.ContainerJSON, error) {
+	if c.inspectCache == nil {
+		return c.api().ContainerInspect(ctx, containerID)
+	}
+	return c.inspectCache.get(containerID, func() (types.ContainerJSON, error) {
+		return c.api().ContainerInspect(ctx, containerID)
+	})
+}
+
+// evictInspect drops a container's cached inspect result after an operation that changes it,
+// without waiting for the daemon's event to arrive
+func (c *Client) evictInspect(containerID string) {
+	if c.inspectCache != nil {
+		c.inspectCache.evict(containerID)
+	}
+}
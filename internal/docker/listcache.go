@@ -0,0 +1,117 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// listCache keeps container list results for a short TTL so frequent polling shares daemon
+// calls. Concurrent misses for the same query wait for a single in-flight call.
+type listCache struct {
+	ttl time.Duration
+
+	mu         sync.Mutex
+	entries    map[string]*listCacheEntry
+	generation uint64
+}
+
+// listCacheEntry is one list result, or a call still in flight until done is closed
+type listCacheEntry struct {
+	done       chan struct{}
+	containers []ContainerInfo
+	err        error
+	expires    time.Time
+}
+
+func newListCache(ttl time.Duration) *listCache {
+	return &listCache{ttl: ttl, entries: make(map[string]*listCacheEntry)}
+}
+
+// listCacheKey identifies a list query independent of filter map ordering
+func listCacheKey(all bool, labelFilter map[string]string) string {
+	pairs := make([]string, 0, len(labelFilter))
+	for k, v := range labelFilter {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return fmt.Sprintf("%t|%s", all, strings.Join(pairs, ","))
+}
+
+// get returns the cached result for key, joining an in-flight call or starting one with fetch
+func (lc *listCache) get(ctx context.Context, key string, fetch func() ([]ContainerInfo, error)) ([]ContainerInfo, error) {
+	lc.mu.Lock()
+	entry, ok := lc.entries[key]
+	if ok {
+		select {
+		case <-entry.done:
+			if time.Now().After(entry.expires) {
+				ok = false
+			}
+		default:
+		}
+	}
+	if ok {
+		lc.mu.Unlock()
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return copyContainers(entry.containers), entry.err
+	}
+
+	entry = &listCacheEntry{done: make(chan struct{})}
+	lc.entries[key] = entry
+	generation := lc.generation
+	lc.mu.Unlock()
+
+	entry.containers, entry.err = fetch()
+	entry.expires = time.Now().Add(lc.ttl)
+
+	lc.mu.Lock()
+	// Errors are not cached, and a result that raced with a mutation may already be stale
+	if (entry.err != nil || generation != lc.generation) && lc.entries[key] == entry {
+		delete(lc.entries, key)
+	}
+	lc.mu.Unlock()
+	close(entry.done)
+
+	return copyContainers(entry.containers), entry.err
+}
+
+// invalidate drops every cached result; calls in flight finish but are not reused
+func (lc *listCache) invalidate() {
+	lc.mu.Lock()
+	lc.generation++
+	lc.entries = make(map[string]*listCacheEntry)
+	lc.mu.Unlock()
+}
+
+// copyContainers gives each caller its own slice so sorting or appending cannot race
+func copyContainers(containers []ContainerInfo) []ContainerInfo {
+	if containers == nil {
+		return nil
+	}
+	return append([]ContainerInfo(nil), containers...)
+}
+
+// EnableListCache caches ListContainers results for ttl. Any create, start, stop, remove or
+// recreate through this client clears the cache. A ttl of zero or less disables caching.
+func (c *Client) EnableListCache(ttl time.Duration) {
+	if ttl <= 0 {
+		c.listCache = nil
+		return
+	}
+	c.listCache = newListCache(ttl)
+}
+
+// invalidateListCache clears cached container lists after a mutating operation
+func (c *Client) invalidateListCache() {
+	if c.listCache != nil {
+		c.listCache.invalidate()
+	}
+}
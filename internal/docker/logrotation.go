@@ -0,0 +1,213 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-units"
+)
+
+// Defaults for containers created without a log driver, which rotate their json-file logs
+// so they cannot grow without bound
+const (
+	DefaultLogDriver  = "json-file"
+	DefaultLogMaxSize = "10m"
+	DefaultLogMaxFile = 3
+)
+
+// logDrivers are the log drivers built into Docker, mapped to whether the logs endpoint can
+// read back what they write. Other drivers ship logs elsewhere and can only be read when the
+// daemon's dual logging cache is enabled.
+var logDrivers = map[string]bool{
+	"json-file":  true,
+	"local":      true,
+	"journald":   true,
+	"none":       false,
+	"syslog":     false,
+	"gelf":       false,
+	"fluentd":    false,
+	"awslogs":    false,
+	"splunk":     false,
+	"etwlogs":    false,
+	"gcplogs":    false,
+	"logentries": false,
+}
+
+// IsValidLogDriver reports whether driver is one of Docker's built-in log drivers
+func IsValidLogDriver(driver string) bool {
+	_, ok := logDrivers[driver]
+	return ok
+}
+
+// LogDriverWarning explains that the logs endpoint may not work for a container using driver,
+// or returns "" when the driver's logs can be read back
+func LogDriverWarning(driver string) string {
+	if readable, ok := logDrivers[driver]; !ok || readable {
+		return ""
+	}
+	return fmt.Sprintf("log driver %q does not keep logs the daemon can read back; the logs endpoints are unavailable unless dual logging is enabled", driver)
+}
+
+// DefaultLogConfig is the log configuration of containers created without a log driver: the
+// daemon's own default would keep every line a chatty app writes until the disk fills
+func DefaultLogConfig() container.LogConfig {
+	return container.LogConfig{
+		Type: DefaultLogDriver,
+		Config: map[string]string{
+			"max-size": DefaultLogMaxSize,
+			"max-file": strconv.Itoa(DefaultLogMaxFile),
+		},
+	}
+}
+
+// SupportsLogRotation reports whether driver takes the max-size and max-file options
+func SupportsLogRotation(driver string) bool {
+	return driver == "json-file" || driver == "local"
+}
+
+// logRotationGrace is how long after starting a container may stay silent before a missing
+// start of its logs is attributed to rotation rather than to the application
+const logRotationGrace = time.Minute
+
+// logLineOverhead approximates what a log driver stores for each line besides its timestamped
+// text, e.g. json-file's {"log":...,"stream":...} wrapping
+const logLineOverhead = 40
+
+// Rotation defaults the daemon applies when max-size or max-file is not set
+const (
+	defaultJSONFileMaxFile = 1
+	defaultLocalMaxSize    = "20m"
+	defaultLocalMaxFile    = 5
+)
+
+// logRotates reports whether a log driver keeps a bounded set of files and drops the oldest
+// ones. The local driver always rotates; json-file only does when max-size is set.
+func logRotates(cfg container.LogConfig) bool {
+	switch cfg.Type {
+	case "local":
+		return true
+	case "json-file":
+		return cfg.Config["max-size"] != "" && cfg.Config["max-size"] != "-1"
+	}
+	return false
+}
+
+// rotationThreshold is the least a rotating log driver retains once it has dropped a file:
+// every file but the active one is full, (max-file - 1) x max-size. It is 0 when the options
+// cannot be read, leaving the timestamps to decide.
+func rotationThreshold(cfg container.LogConfig) int64 {
+	maxSize, maxFile := cfg.Config["max-size"], cfg.Config["max-file"]
+	files := defaultJSONFileMaxFile
+	if cfg.Type == "local" {
+		files = defaultLocalMaxFile
+		if maxSize == "" {
+			maxSize = defaultLocalMaxSize
+		}
+	}
+	if maxFile != "" {
+		parsed, err := strconv.Atoi(maxFile)
+		if err != nil || parsed < 1 {
+			return 0
+		}
+		files = parsed
+	}
+	size, err := units.RAMInBytes(maxSize)
+	if err != nil {
+		return 0
+	}
+	return int64(files-1) * size
+}
+
+// LogsTruncated reports whether rotation has discarded the start of a container's logs. The
+// daemon does not say how much it dropped, so this holds when the retained logs are large
+// enough for rotation to have dropped a file and the oldest retained line was written well
+// after the container started. The daemon only records the latest start; output from before
+// a restart that is still retained shows nothing was lost since.
+func (c *Client) LogsTruncated(ctx context.Context, containerID string) (bool, error) {
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return false, &ClientError{
+			Op:  "inspect",
+			Err: err,
+		}
+	}
+	if inspect.ContainerJSONBase == nil || inspect.HostConfig == nil || inspect.State == nil || !logRotates(inspect.HostConfig.LogConfig) {
+		return false, nil
+	}
+	started, err := time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
+	if err != nil || started.IsZero() {
+		return false, nil
+	}
+
+	threshold := rotationThreshold(inspect.HostConfig.LogConfig)
+	oldest, size, err := c.retainedLogs(ctx, containerID, threshold)
+	if err != nil || oldest.IsZero() || size < threshold {
+		return false, err
+	}
+	return oldest.Sub(started) > logRotationGrace, nil
+}
+
+// errLogLimit stops demultiplexing once enough of the logs has been read
+var errLogLimit = errors.New("log limit reached")
+
+// retainedLogs reads a container's retained logs until their estimated stored size reaches
+// limit, and returns the timestamp of the oldest line with the size read
+func (c *Client) retainedLogs(ctx context.Context, containerID string, limit int64) (time.Time, int64, error) {
+	logs, err := c.api().ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+		Tail:       "all",
+	})
+	if err != nil {
+		return time.Time{}, 0, &ClientError{
+			Op:  "get_logs",
+			Err: err,
+		}
+	}
+	defer logs.Close()
+
+	counter := &logSizeWriter{limit: limit}
+	if _, err := stdcopy.StdCopy(counter, counter, logs); err != nil && !errors.Is(err, errLogLimit) && !errors.Is(err, io.EOF) {
+		return time.Time{}, 0, &ClientError{
+			Op:  "read_logs",
+			Err: err,
+		}
+	}
+
+	ts, _, _ := strings.Cut(counter.first, " ")
+	parsed, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, counter.size, nil
+	}
+	return parsed, counter.size, nil
+}
+
+// logSizeWriter keeps the first line written to it and estimates the stored size of the
+// rest, stopping the copy once that reaches limit
+type logSizeWriter struct {
+	first string
+	seen  bool
+	size  int64
+	limit int64
+}
+
+func (w *logSizeWriter) Write(p []byte) (int, error) {
+	if !w.seen {
+		w.first, _, _ = strings.Cut(string(p), "\n")
+		w.seen = true
+	}
+	w.size += int64(len(p)) + int64(bytes.Count(p, []byte("\n")))*logLineOverhead
+	if w.size >= w.limit {
+		return 0, errLogLimit
+	}
+	return len(p), nil
+}
@@ -0,0 +1,115 @@
+package nodeproject
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// lockfiles maps lockfile names to the package manager that writes them, most specific first
+var lockfiles = []struct {
+	name    string
+	manager PackageManager
+}{
+	{"pnpm-lock.yaml", PackageManagerPNPM},
+	{"yarn.lock", PackageManagerYarn},
+	{"package-lock.json", PackageManagerNPM},
+	{"npm-shrinkwrap.json", PackageManagerNPM},
+}
+
+// DetectPackageManager picks the package manager from the project's lockfile. The second
+// return value is false when no lockfile exists and npm is assumed.
+func DetectPackageManager(projectPath string) (PackageManager, bool) {
+	for _, lockfile := range lockfiles {
+		if _, err := os.Stat(filepath.Join(projectPath, lockfile.name)); err == nil {
+			return lockfile.manager, true
+		}
+	}
+	return PackageManagerNPM, false
+}
+
+// frameworks maps a framework name to the package that identifies it, checked in order so
+// meta-frameworks win over the libraries they are built on
+var frameworks = []struct {
+	name string
+	dep  string
+}{
+	{"nextjs", "next"},
+	{"nuxt", "nuxt"},
+	{"remix", "@remix-run/node"},
+	{"nestjs", "@nestjs/core"},
+	{"sveltekit", "@sveltejs/kit"},
+	{"fastify", "fastify"},
+	{"koa", "koa"},
+	{"express", "express"},
+	{"create-react-app", "react-scripts"},
+	{"vite", "vite"},
+}
+
+// DetectFramework returns the framework the package depends on, or "" if none is recognized
+func DetectFramework(pkg *PackageJSON) string {
+	for _, framework := range frameworks {
+		if _, ok := pkg.Dependencies[framework.dep]; ok {
+			return framework.name
+		}
+		if _, ok := pkg.DevDependencies[framework.dep]; ok {
+			return framework.name
+		}
+	}
+	return ""
+}
+
+// ValidationReport summarizes whether a project can be built, without changing anything
+type ValidationReport struct {
+	Valid          bool     `json:"valid"`
+	Framework      string   `json:"framework,omitempty"`
+	PackageManager string   `json:"packageManager,omitempty"`
+	MissingDeps    []string `json:"missingDeps,omitempty"`
+	Warnings       []string `json:"warnings,omitempty"`
+	Errors         []string `json:"errors,omitempty"`
+}
+
+// Report validates the project and detects its framework and package manager
+func (h *ProjectHandler) Report() *ValidationReport {
+	report := &ValidationReport{}
+
+	pkg, err := h.readPackageJSON()
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("failed to read package.json: %v", err))
+		return report
+	}
+	if pkg.Name == "" || pkg.Version == "" {
+		report.Errors = append(report.Errors, "package.json must define name and version")
+	}
+
+	missing, err := h.MissingDependencies()
+	if err != nil {
+		report.Errors = append(report.Errors, err.Error())
+	}
+	report.MissingDeps = missing
+	for _, dep := range missing {
+		report.Errors = append(report.Errors, fmt.Sprintf("required dependency %s not found", dep))
+	}
+
+	report.Framework = DetectFramework(pkg)
+	manager, hasLockfile := DetectPackageManager(h.projectPath)
+	report.PackageManager = string(manager)
+
+	if !hasLockfile {
+		report.Warnings = append(report.Warnings, "no lockfile found; dependency versions are not pinned")
+	}
+	if _, ok := pkg.Scripts["start"]; !ok {
+		report.Warnings = append(report.Warnings, "package.json has no start script; the container runs npm start")
+	}
+	if baseImage, versionWarning, err := h.baseImage(); err == nil {
+		if versionWarning != "" {
+			report.Warnings = append(report.Warnings, versionWarning)
+		}
+		if warning := nativeModuleWarning(baseImage, NativeModules(pkg)); warning != "" {
+			report.Warnings = append(report.Warnings, warning)
+		}
+	}
+
+	report.Valid = len(report.Errors) == 0
+	return report
+}
@@ -0,0 +1,105 @@
+package nodeproject
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DotEnvFile is the name of the environment file apps load with dotenv
+const DotEnvFile = ".env"
+
+// dotEnvKeyPattern matches the variable names dotenv accepts
+var dotEnvKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// LoadDotEnv reads the .env file in projectPath and returns its variables as KEY=VALUE
+// entries in file order. A project without a .env file yields no entries and no error.
+func LoadDotEnv(projectPath string) ([]string, error) {
+	f, err := os.Open(filepath.Join(projectPath, DotEnvFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", DotEnvFile, err)
+	}
+	defer f.Close()
+	return ParseDotEnv(f)
+}
+
+// ParseDotEnv parses dotenv syntax: KEY=VALUE lines with an optional "export " prefix,
+// blank lines and # comments. Double-quoted values may span lines and expand \n, \r, \t,
+// \" and \\; single-quoted values are taken literally. Unquoted values end at a " #"
+// comment and are trimmed. Variable references such as ${PORT} are not expanded.
+func ParseDotEnv(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", DotEnvFile, err)
+	}
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	var env []string
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !dotEnvKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%s line %d: expected KEY=VALUE", DotEnvFile, lineNo)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		switch {
+		case strings.HasPrefix(value, `"`):
+			// A double-quoted value continues until its closing quote, possibly on a later line
+			quoted := value[1:]
+			for closingQuote(quoted) < 0 {
+				if i+1 >= len(lines) {
+					return nil, fmt.Errorf("%s line %d: unterminated double-quoted value", DotEnvFile, lineNo)
+				}
+				i++
+				quoted += "\n" + lines[i]
+			}
+			value = unescapeDotEnv(quoted[:closingQuote(quoted)])
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end < 0 {
+				return nil, fmt.Errorf("%s line %d: unterminated single-quoted value", DotEnvFile, lineNo)
+			}
+			value = value[1 : end+1]
+		default:
+			if idx := strings.Index(value, " #"); idx >= 0 {
+				value = value[:idx]
+			}
+			value = strings.TrimSpace(value)
+		}
+		env = append(env, key+"="+value)
+	}
+	return env, nil
+}
+
+// closingQuote returns the index of the first unescaped double quote in s, or -1
+func closingQuote(s string) int {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+var dotEnvEscapes = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`)
+
+func unescapeDotEnv(s string) string {
+	return dotEnvEscapes.Replace(s)
+}
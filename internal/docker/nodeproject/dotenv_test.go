@@ -0,0 +1,70 @@
+package nodeproject
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnv(t *testing.T) {
+	input := `# database settings
+DB_HOST=localhost
+export DB_PORT = 5432
+API_KEY=abc123 # inline comment
+EMPTY=
+HASH_IN_VALUE=color#fff
+SINGLE='literal $HOME \n # kept'
+DOUBLE="line one\nsays \"hi\" # kept"
+MULTILINE="first
+second"
+
+URL=https://example.com/?a=b
+`
+	env, err := ParseDotEnv(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseDotEnv failed: %v", err)
+	}
+	want := []string{
+		"DB_HOST=localhost",
+		"DB_PORT=5432",
+		"API_KEY=abc123",
+		"EMPTY=",
+		"HASH_IN_VALUE=color#fff",
+		`SINGLE=literal $HOME \n # kept`,
+		"DOUBLE=line one\nsays \"hi\" # kept",
+		"MULTILINE=first\nsecond",
+		"URL=https://example.com/?a=b",
+	}
+	if !reflect.DeepEqual(env, want) {
+		t.Errorf("ParseDotEnv =\n%q\nwant\n%q", env, want)
+	}
+
+	for _, bad := range []string{"NO_EQUALS", "1BAD=x", `OPEN="unterminated`, "OPEN='unterminated"} {
+		if _, err := ParseDotEnv(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseDotEnv(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestSetupEnvironmentKeepsUserDotEnv(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, DotEnvFile), []byte("API_KEY=mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewProjectHandler(dir, nil).SetupEnvironment(); err != nil {
+		t.Fatalf("SetupEnvironment failed: %v", err)
+	}
+	env, err := LoadDotEnv(dir)
+	if err != nil {
+		t.Fatalf("LoadDotEnv failed: %v", err)
+	}
+	if !reflect.DeepEqual(env, []string{"API_KEY=mine"}) {
+		t.Errorf("User .env was replaced: %q", env)
+	}
+
+	if env, err := LoadDotEnv(t.TempDir()); env != nil || err != nil {
+		t.Errorf("LoadDotEnv without a .env = %q, %v; want nothing", env, err)
+	}
+}
@@ -2,6 +2,7 @@ package nodeproject
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -18,14 +19,25 @@ type ProjectConfig struct {
 	RequiredDeps []string
 	BaseImage    string
 	DefaultPort  string
+	// NodeVersion pins the Node.js version, e.g. "20" or "lts/iron"; when empty the version
+	// comes from .nvmrc or engines.node and finally BaseImage
+	NodeVersion string
+	// DockerfileTemplate replaces the built-in Dockerfile with a text/template rendered
+	// against DockerfileData
+	DockerfileTemplate string
+	// Production leaves devDependencies out of the runtime image; a build script still runs
+	// with them in a separate builder stage
+	Production bool
 }
 
 // PackageJSON represents the structure of package.json
 type PackageJSON struct {
-	Name         string            `json:"name"`
-	Version      string            `json:"version"`
-	Dependencies map[string]string `json:"dependencies"`
-	Scripts      map[string]string `json:"scripts"`
+	Name            string            `json:"name"`
+	Version         string            `json:"version"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies,omitempty"`
+	Scripts         map[string]string `json:"scripts"`
+	Engines         map[string]string `json:"engines,omitempty"`
 }
 
 // NewProjectHandler creates a new Node.js project handler
@@ -67,6 +79,22 @@ func (h *ProjectHandler) ValidateProject() error {
 	return nil
 }
 
+// MissingDependencies lists the configured required dependencies absent from package.json
+func (h *ProjectHandler) MissingDependencies() ([]string, error) {
+	pkg, err := h.readPackageJSON()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var missing []string
+	for _, dep := range h.config.RequiredDeps {
+		if _, exists := pkg.Dependencies[dep]; !exists {
+			missing = append(missing, dep)
+		}
+	}
+	return missing, nil
+}
+
 // readPackageJSON reads and parses package.json
 func (h *ProjectHandler) readPackageJSON() (*PackageJSON, error) {
 	data, err := os.ReadFile(filepath.Join(h.projectPath, "package.json"))
@@ -75,15 +103,72 @@ func (h *ProjectHandler) readPackageJSON() (*PackageJSON, error) {
 	}
 
 	var pkg PackageJSON
-	if err := json.Unmarshal(data, &pkg); err != nil {
+	if err := UnmarshalPackageJSON(data, &pkg); err != nil {
 		return nil, err
 	}
 
 	return &pkg, nil
 }
 
+// PackageJSONError reports a malformed package.json with the position of the problem
+type PackageJSONError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *PackageJSONError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("malformed package.json: %v", e.Err)
+	}
+	return fmt.Sprintf("malformed package.json at line %d, column %d: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *PackageJSONError) Unwrap() error {
+	return e.Err
+}
+
+// UnmarshalPackageJSON parses package.json contents into v, turning syntax and type errors
+// into a PackageJSONError that points at the offending line and column
+func UnmarshalPackageJSON(data []byte, v interface{}) error {
+	err := json.Unmarshal(data, v)
+	if err == nil {
+		return nil
+	}
+
+	var offset int64 = -1
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		offset = syntaxErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+	if offset < 0 {
+		return &PackageJSONError{Err: err}
+	}
+
+	// Offset counts the bytes read up to and including the offending one
+	line, column := 1, 1
+	for _, b := range data[:min(max(int(offset)-1, 0), len(data))] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+	return &PackageJSONError{Line: line, Column: column, Err: err}
+}
+
 // CreateProjectStructure creates the basic project structure
 func (h *ProjectHandler) CreateProjectStructure() error {
+	return h.createProjectStructure("node-project")
+}
+
+// createProjectStructure creates the project directories, a package.json for name and a Dockerfile
+func (h *ProjectHandler) createProjectStructure(name string) error {
 	dirs := []string{
 		"src",
 		"public",
@@ -100,7 +185,7 @@ func (h *ProjectHandler) CreateProjectStructure() error {
 
 	// Create package.json
 	pkg := PackageJSON{
-		Name:    "node-project",
+		Name:    name,
 		Version: "1.0.0",
 		Dependencies: map[string]string{
 			"express": "^4.17.1",
@@ -123,12 +208,61 @@ func (h *ProjectHandler) CreateProjectStructure() error {
 	return h.GenerateDockerfile()
 }
 
+// baseImage resolves the Node.js image from the explicit NodeVersion, then the project's
+// own version pin, then the configured BaseImage. Pinned versions use the same image family,
+// Debian or Alpine, as BaseImage. The warning says when the pin was only partly understood.
+func (h *ProjectHandler) baseImage() (string, string, error) {
+	alpine := IsAlpineImage(h.config.BaseImage)
+	if h.config.NodeVersion != "" {
+		version, warning, err := NormalizeNodeVersion(h.config.NodeVersion)
+		if err != nil {
+			return "", "", err
+		}
+		return NodeImage(version, alpine), warning, nil
+	}
+
+	version, warning, err := DetectNodeVersion(h.projectPath)
+	if err != nil {
+		return "", "", err
+	}
+	if version != "" {
+		return NodeImage(version, alpine), warning, nil
+	}
+	return h.config.BaseImage, warning, nil
+}
+
 // GenerateDockerfile creates a Dockerfile for the project
 func (h *ProjectHandler) GenerateDockerfile() error {
+	baseImage, _, err := h.baseImage()
+	if err != nil {
+		return fmt.Errorf("failed to select Node.js version: %w", err)
+	}
+
+	if h.config.DockerfileTemplate != "" {
+		return h.renderDockerfileTemplate(baseImage)
+	}
+
+	// Alpine lacks the toolchain native addons fall back to when no prebuilt binary fits musl
+	buildTools := ""
+	if pkg, err := h.readPackageJSON(); err == nil && nativeModuleWarning(baseImage, NativeModules(pkg)) != "" {
+		buildTools = "\n" + alpineBuildTools + "\n"
+	}
+
+	if h.config.Production {
+		dockerfile, err := productionDockerfile(h.projectPath, baseImage, h.config.DefaultPort, buildTools)
+		if err != nil {
+			return fmt.Errorf("failed to generate production Dockerfile: %w", err)
+		}
+		if err := os.WriteFile(filepath.Join(h.projectPath, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+			return fmt.Errorf("failed to write Dockerfile: %w", err)
+		}
+		return nil
+	}
+
 	dockerfile := fmt.Sprintf(`FROM %s
 
 WORKDIR /app
-
+%s
 COPY package*.json ./
 
 RUN npm install
@@ -137,9 +271,9 @@ COPY . .
 
 EXPOSE %s
 
-CMD ["npm", "start"]`, h.config.BaseImage, h.config.DefaultPort)
+CMD ["npm", "start"]`, baseImage, buildTools, h.config.DefaultPort)
 
-	err := os.WriteFile(filepath.Join(h.projectPath, "Dockerfile"), []byte(dockerfile), 0644)
+	err = os.WriteFile(filepath.Join(h.projectPath, "Dockerfile"), []byte(dockerfile), 0644)
 	if err != nil {
 		return fmt.Errorf("failed to write Dockerfile: %w", err)
 	}
@@ -147,6 +281,34 @@ CMD ["npm", "start"]`, h.config.BaseImage, h.config.DefaultPort)
 	return nil
 }
 
+// renderDockerfileTemplate writes the Dockerfile from the configured template
+func (h *ProjectHandler) renderDockerfileTemplate(baseImage string) error {
+	tmpl, err := ParseDockerfileTemplate(h.config.DockerfileTemplate)
+	if err != nil {
+		return err
+	}
+
+	data := DockerfileData{
+		BaseImage:      baseImage,
+		Port:           h.config.DefaultPort,
+		BuildOutputDir: "dist",
+	}
+	manager, _ := DetectPackageManager(h.projectPath)
+	data.PackageManager = string(manager)
+	if pkg, err := h.readPackageJSON(); err == nil {
+		data.BuildOutputDir = BuildOutputDir(pkg)
+	}
+
+	dockerfile, err := tmpl.Render(data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(h.projectPath, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+		return fmt.Errorf("failed to write Dockerfile: %w", err)
+	}
+	return nil
+}
+
 // PrepareBuildContext prepares the project for building
 func (h *ProjectHandler) PrepareBuildContext() error {
 	// Validate project first
@@ -171,15 +333,22 @@ README.md
 	return nil
 }
 
-// SetupEnvironment sets up the project environment
+// SetupEnvironment writes a default .env file unless the project already has one, so the
+// user's own variables are never replaced
 func (h *ProjectHandler) SetupEnvironment() error {
 	envFile := `NODE_ENV=production
 PORT=${PORT:-3000}
 `
-	err := os.WriteFile(filepath.Join(h.projectPath, ".env"), []byte(envFile), 0644)
+	f, err := os.OpenFile(filepath.Join(h.projectPath, DotEnvFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if os.IsExist(err) {
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("failed to create .env file: %w", err)
 	}
-
-	return nil
+	if _, err := f.WriteString(envFile); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to create .env file: %w", err)
+	}
+	return f.Close()
 }
@@ -1,6 +1,7 @@
 package nodeproject
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"strings"
@@ -114,3 +115,32 @@ func TestGenerateDockerfile(t *testing.T) {
 func contains(s, substr string) bool {
     return strings.Contains(s, substr)
 }
+
+func TestUnmarshalPackageJSONErrorLocation(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantLine   int
+		wantColumn int
+	}{
+		{name: "trailing comma", data: "{\n  \"name\": \"app\",\n}", wantLine: 3, wantColumn: 1},
+		{name: "missing quote", data: "{\n  \"name\": app\n}", wantLine: 2, wantColumn: 11},
+		{name: "wrong type", data: "{\n  \"name\": 42\n}", wantLine: 2, wantColumn: 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pkg PackageJSON
+			err := UnmarshalPackageJSON([]byte(tt.data), &pkg)
+
+			var pkgErr *PackageJSONError
+			if !errors.As(err, &pkgErr) {
+				t.Fatalf("Expected a PackageJSONError, got %v", err)
+			}
+			if pkgErr.Line != tt.wantLine || pkgErr.Column != tt.wantColumn {
+				t.Errorf("Location = line %d column %d, want line %d column %d (%v)",
+					pkgErr.Line, pkgErr.Column, tt.wantLine, tt.wantColumn, err)
+			}
+		})
+	}
+}
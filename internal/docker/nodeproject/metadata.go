@@ -0,0 +1,98 @@
+package nodeproject
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Labels stamped into built images to record where they came from
+const (
+	SourceCommitLabel   = "block-builder.source-commit"
+	BuiltAtLabel        = "block-builder.built-at"
+	FrameworkLabel      = "block-builder.framework"
+	BuilderVersionLabel = "block-builder.version"
+)
+
+// BuilderVersion is the Block-Builder version recorded in image labels. Release builds set it
+// with -ldflags "-X docker-management-system/internal/docker/nodeproject.BuilderVersion=<version>".
+var BuilderVersion = "dev"
+
+// BuildMetadataLabels returns the provenance labels for an image built from the project
+func (h *ProjectHandler) BuildMetadataLabels(builtAt time.Time) map[string]string {
+	framework := ""
+	if pkg, err := h.readPackageJSON(); err == nil {
+		framework = DetectFramework(pkg)
+	}
+	return buildMetadataLabels(sourceCommit(h.projectPath), builtAt, framework)
+}
+
+// buildMetadataLabels assembles the provenance labels. The commit and framework are left out
+// when unknown so an image never claims an empty source.
+func buildMetadataLabels(commit string, builtAt time.Time, framework string) map[string]string {
+	labels := map[string]string{
+		BuiltAtLabel:        builtAt.UTC().Format(time.RFC3339),
+		BuilderVersionLabel: BuilderVersion,
+	}
+	if commit != "" {
+		labels[SourceCommitLabel] = commit
+	}
+	if framework != "" {
+		labels[FrameworkLabel] = framework
+	}
+	return labels
+}
+
+// sourceCommit returns the commit checked out in the git repository containing dir, or ""
+// if dir is not in a repository. It reads .git directly so no git binary is needed.
+func sourceCommit(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if info, err := os.Stat(gitDir); err == nil && info.IsDir() {
+			return resolveHead(gitDir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// resolveHead follows HEAD to a commit hash through loose refs, then packed-refs
+func resolveHead(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(data))
+	ref, symbolic := strings.CutPrefix(head, "ref: ")
+	if !symbolic {
+		// Detached HEAD holds the hash itself
+		return head
+	}
+
+	if data, err := os.ReadFile(filepath.Join(gitDir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, name, ok := strings.Cut(scanner.Text(), " ")
+		if ok && name == ref {
+			return hash
+		}
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package nodeproject
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBuildMetadataLabels(t *testing.T) {
+	builtAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+	labels := buildMetadataLabels("0123456789abcdef", builtAt, "nextjs")
+	want := map[string]string{
+		SourceCommitLabel:   "0123456789abcdef",
+		BuiltAtLabel:        "2024-05-01T10:00:00Z",
+		FrameworkLabel:      "nextjs",
+		BuilderVersionLabel: BuilderVersion,
+	}
+	for key, value := range want {
+		if labels[key] != value {
+			t.Errorf("label %s = %q, want %q", key, labels[key], value)
+		}
+	}
+
+	labels = buildMetadataLabels("", builtAt, "")
+	for _, key := range []string{SourceCommitLabel, FrameworkLabel} {
+		if _, ok := labels[key]; ok {
+			t.Errorf("label %s set for an unknown value", key)
+		}
+	}
+}
+
+func TestProjectBuildMetadataLabels(t *testing.T) {
+	root := t.TempDir()
+	gitDir := filepath.Join(root, ".git")
+	const commit = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	writeTestFile(t, filepath.Join(gitDir, "HEAD"), "ref: refs/heads/main\n")
+	writeTestFile(t, filepath.Join(gitDir, "packed-refs"), "# pack-refs with: peeled fully-peeled sorted\n"+commit+" refs/heads/main\n")
+	writeTestFile(t, filepath.Join(root, "apps", "web", "package.json"), `{"name": "web", "version": "1.0.0", "dependencies": {"next": "14.0.0"}}`)
+
+	labels := NewProjectHandler(filepath.Join(root, "apps", "web"), nil).BuildMetadataLabels(time.Now())
+	if labels[SourceCommitLabel] != commit {
+		t.Errorf("commit from packed-refs = %q, want %q", labels[SourceCommitLabel], commit)
+	}
+	if labels[FrameworkLabel] != "nextjs" {
+		t.Errorf("framework = %q, want nextjs", labels[FrameworkLabel])
+	}
+
+	// A loose ref takes precedence over packed-refs
+	const loose = "e69de29bb2d1d6434b8b29ae775ad8c2e48c5391"
+	writeTestFile(t, filepath.Join(gitDir, "refs", "heads", "main"), loose+"\n")
+	if got := sourceCommit(root); got != loose {
+		t.Errorf("commit from loose ref = %q, want %q", got, loose)
+	}
+}
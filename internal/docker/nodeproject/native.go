@@ -0,0 +1,100 @@
+package nodeproject
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// nativeModules are packages that compile a native addon with node-gyp during install when no
+// prebuilt binary matches the platform, which is common on Alpine's musl libc
+var nativeModules = map[string]bool{
+	"argon2":         true,
+	"bcrypt":         true,
+	"better-sqlite3": true,
+	"bufferutil":     true,
+	"canvas":         true,
+	"cpu-features":   true,
+	"leveldown":      true,
+	"node-sass":      true,
+	"re2":            true,
+	"sharp":          true,
+	"sqlite3":        true,
+	"utf-8-validate": true,
+}
+
+// alpineBuildTools installs the toolchain node-gyp needs, which Alpine images do not ship
+const alpineBuildTools = "RUN apk add --no-cache python3 make g++"
+
+// NativeModules returns the known native-addon packages the project depends on, sorted
+func NativeModules(pkg *PackageJSON) []string {
+	var found []string
+	for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+		for dep := range deps {
+			if nativeModules[dep] {
+				found = append(found, dep)
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// IsAlpineImage reports whether an image reference such as node:20-alpine or
+// node:alpine3.19 names an Alpine-based tag
+func IsAlpineImage(image string) bool {
+	_, tag, ok := strings.Cut(image, ":")
+	return ok && strings.Contains(tag, "alpine")
+}
+
+// nativeModuleWarning explains the build tools added for native modules on an Alpine base,
+// or returns "" when none are needed
+func nativeModuleWarning(baseImage string, modules []string) string {
+	if len(modules) == 0 || !IsAlpineImage(baseImage) {
+		return ""
+	}
+	return fmt.Sprintf("native modules (%s) may need to compile on Alpine's musl libc; python3, make and g++ are installed before dependencies", strings.Join(modules, ", "))
+}
+
+// AddNativeBuildTools installs the build tools right after every FROM line of dockerfile that
+// starts a stage on an Alpine image, and returns the warning to surface with it. The Dockerfile
+// comes back unchanged, with no warning, when pkg has no known native modules.
+func AddNativeBuildTools(dockerfile string, pkg *PackageJSON) (string, string) {
+	modules := NativeModules(pkg)
+	if len(modules) == 0 {
+		return dockerfile, ""
+	}
+
+	var b strings.Builder
+	warning := ""
+	for _, line := range strings.SplitAfter(dockerfile, "\n") {
+		b.WriteString(line)
+		image := fromImage(line)
+		if !IsAlpineImage(image) {
+			continue
+		}
+		if !strings.HasSuffix(line, "\n") {
+			b.WriteString("\n")
+		}
+		b.WriteString(alpineBuildTools + "\n")
+		if warning == "" {
+			warning = nativeModuleWarning(image, modules)
+		}
+	}
+	return b.String(), warning
+}
+
+// fromImage returns the image a FROM instruction builds on, skipping flags such as
+// --platform, or "" for any other line
+func fromImage(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+		return ""
+	}
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "--") {
+			return field
+		}
+	}
+	return ""
+}
@@ -0,0 +1,100 @@
+package nodeproject
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNativeModuleHandling(t *testing.T) {
+	pkgJSON := `{
+		"name": "auth-service",
+		"version": "1.0.0",
+		"scripts": {"start": "node index.js"},
+		"dependencies": {"express": "^4.18.2", "bcrypt": "^5.1.1"}
+	}`
+
+	tests := []struct {
+		name        string
+		baseImage   string
+		wantTools   bool
+		wantWarning bool
+	}{
+		{name: "alpine base", baseImage: "node:18-alpine", wantTools: true, wantWarning: true},
+		{name: "debian base", baseImage: "node:20-slim", wantTools: false, wantWarning: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTestFile(t, filepath.Join(dir, "package.json"), pkgJSON)
+			handler := NewProjectHandler(dir, &ProjectConfig{
+				RequiredDeps: []string{"express"},
+				BaseImage:    tt.baseImage,
+				DefaultPort:  "3000",
+			})
+
+			if err := handler.GenerateDockerfile(); err != nil {
+				t.Fatalf("GenerateDockerfile failed: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+			if err != nil {
+				t.Fatalf("Failed to read Dockerfile: %v", err)
+			}
+			dockerfile := string(data)
+
+			tools := strings.Index(dockerfile, alpineBuildTools)
+			if (tools >= 0) != tt.wantTools {
+				t.Fatalf("build tools installed = %v, want %v:\n%s", tools >= 0, tt.wantTools, dockerfile)
+			}
+			if tt.wantTools && tools > strings.Index(dockerfile, "RUN npm install") {
+				t.Errorf("build tools are installed after dependencies:\n%s", dockerfile)
+			}
+
+			warned := false
+			for _, warning := range handler.Report().Warnings {
+				if strings.Contains(warning, "bcrypt") {
+					warned = true
+				}
+			}
+			if warned != tt.wantWarning {
+				t.Errorf("native module warning = %v, want %v", warned, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestNativeModules(t *testing.T) {
+	pkg := &PackageJSON{
+		Dependencies:    map[string]string{"sharp": "^0.33.0", "express": "^4.18.2"},
+		DevDependencies: map[string]string{"better-sqlite3": "^9.0.0"},
+	}
+	if got, want := NativeModules(pkg), []string{"better-sqlite3", "sharp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NativeModules = %v, want %v", got, want)
+	}
+}
+
+func TestAddNativeBuildTools(t *testing.T) {
+	dockerfile := "FROM --platform=linux/amd64 node:20-alpine AS deps\nRUN npm ci\n\nFROM deps AS build\nRUN npm run build\n\nFROM node:20-slim\nCMD [\"npm\", \"start\"]\n"
+
+	t.Run("native modules", func(t *testing.T) {
+		pkg := &PackageJSON{Dependencies: map[string]string{"bcrypt": "^5.1.1"}}
+		got, warning := AddNativeBuildTools(dockerfile, pkg)
+		want := "FROM --platform=linux/amd64 node:20-alpine AS deps\n" + alpineBuildTools + "\nRUN npm ci\n\nFROM deps AS build\nRUN npm run build\n\nFROM node:20-slim\nCMD [\"npm\", \"start\"]\n"
+		if got != want {
+			t.Errorf("AddNativeBuildTools() =\n%s\nwant\n%s", got, want)
+		}
+		if !strings.Contains(warning, "bcrypt") {
+			t.Errorf("warning = %q, want it to name bcrypt", warning)
+		}
+	})
+
+	t.Run("no native modules", func(t *testing.T) {
+		pkg := &PackageJSON{Dependencies: map[string]string{"express": "^4.18.2"}}
+		if got, warning := AddNativeBuildTools(dockerfile, pkg); got != dockerfile || warning != "" {
+			t.Errorf("AddNativeBuildTools() changed the Dockerfile or warned: %q\n%s", warning, got)
+		}
+	})
+}
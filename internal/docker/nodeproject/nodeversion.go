@@ -0,0 +1,221 @@
+package nodeproject
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ltsCodenames maps Node.js LTS codenames, as used in "lts/<name>", to their major version
+var ltsCodenames = map[string]string{
+	"argon":    "4",
+	"boron":    "6",
+	"carbon":   "8",
+	"dubnium":  "10",
+	"erbium":   "12",
+	"fermium":  "14",
+	"gallium":  "16",
+	"hydrogen": "18",
+	"iron":     "20",
+	"jod":      "22",
+	"krypton":  "24",
+}
+
+// errUnsupportedComparator is returned for an engines range this package cannot interpret
+var errUnsupportedComparator = errors.New("unsupported comparator")
+
+var (
+	exactVersionPattern = regexp.MustCompile(`^\d+(\.\d+){0,2}$`)
+	// comparatorPattern matches one comparator of an engines range, e.g. ">=18.1", "^20" or "18.x"
+	comparatorPattern = regexp.MustCompile(`^(>=|<=|>|<|=|\^|~)?v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-[0-9A-Za-z.-]+)?$`)
+	// operatorSpacePattern matches the optional space between an operator and its version
+	operatorSpacePattern = regexp.MustCompile(`(>=|<=|>|<|=|\^|~)\s+`)
+)
+
+// NodeImage returns the official Node.js image for a version tag such as "20", "lts" or
+// "18.17.0". Images are Debian-based like node:latest unless alpine asks for the Alpine
+// variant. An empty version is the latest release.
+func NodeImage(version string, alpine bool) string {
+	if version == "" {
+		version = "latest"
+	}
+	if !alpine {
+		return "node:" + version
+	}
+	if version == "latest" {
+		return "node:alpine"
+	}
+	return "node:" + version + "-alpine"
+}
+
+// NormalizeNodeVersion converts an .nvmrc style version such as "v18.17.0", "18",
+// "lts/hydrogen" or "lts/*" into the Node.js image tag for it, e.g. "18.17.0", "18" or "lts".
+// An LTS codename newer than this list falls back to the latest LTS, with a warning.
+func NormalizeNodeVersion(version string) (string, string, error) {
+	v := strings.ToLower(strings.TrimSpace(version))
+
+	switch v {
+	case "lts", "lts/*":
+		return "lts", "", nil
+	case "node", "stable", "latest", "current":
+		return "current", "", nil
+	}
+
+	if codename, ok := strings.CutPrefix(v, "lts/"); ok {
+		major, known := ltsCodenames[codename]
+		if !known {
+			return "lts", fmt.Sprintf("unknown Node.js LTS codename %q; using the latest LTS", codename), nil
+		}
+		return major, "", nil
+	}
+
+	v = strings.TrimPrefix(v, "v")
+	if !exactVersionPattern.MatchString(v) {
+		return "", "", fmt.Errorf("unsupported Node.js version %q", version)
+	}
+	return v, "", nil
+}
+
+// normalizeEnginesRange maps a package.json engines.node range such as ">=18", "^20.1",
+// "18.x" or ">=16 <20" to the lowest major version it allows. A range of alternatives joined
+// by "||" uses the lowest major any of them allows. It returns "" for a range that allows
+// every version, such as "*", and an error for one that allows none or cannot be parsed;
+// the latter wraps errUnsupportedComparator.
+func normalizeEnginesRange(versionRange string) (string, error) {
+	lowest := -1
+	for _, alternative := range strings.Split(versionRange, "||") {
+		major, err := lowestMajor(alternative)
+		if err != nil {
+			return "", fmt.Errorf("engines range %q: %w", versionRange, err)
+		}
+		if major >= 0 && (lowest < 0 || major < lowest) {
+			lowest = major
+		}
+	}
+	if lowest < 0 {
+		return "", nil
+	}
+	return strconv.Itoa(lowest), nil
+}
+
+// lowestMajor returns the lowest major version a set of space-separated comparators allows,
+// or -1 when they allow any version. With only an upper bound, the highest major below it is
+// used, since older releases are the ones the bound rules out least.
+func lowestMajor(comparators string) (int, error) {
+	comparators = operatorSpacePattern.ReplaceAllString(strings.TrimSpace(comparators), "$1")
+	// A hyphen range "18 - 20" is the same as ">=18 <=20"
+	if from, to, ok := strings.Cut(comparators, " - "); ok {
+		comparators = ">=" + strings.TrimSpace(from) + " <=" + strings.TrimSpace(to)
+	}
+
+	lower, upper := -1, -1
+	raise := func(major int) {
+		if major > lower {
+			lower = major
+		}
+	}
+	limit := func(major int) {
+		if upper < 0 || major < upper {
+			upper = major
+		}
+	}
+	for _, comparator := range strings.Fields(comparators) {
+		match := comparatorPattern.FindStringSubmatch(comparator)
+		if match == nil {
+			return 0, fmt.Errorf("%w: %q", errUnsupportedComparator, comparator)
+		}
+		operator, version := match[1], match[2:]
+		if isWildcard(version[0]) {
+			if operator == "<" {
+				return 0, fmt.Errorf("comparator %q allows no version", comparator)
+			}
+			continue
+		}
+		major, _ := strconv.Atoi(version[0])
+		// Only the major is given, or the rest is zero or a wildcard
+		wholeMajor := isZeroOrWildcard(version[1]) && isZeroOrWildcard(version[2])
+
+		switch operator {
+		case ">=":
+			raise(major)
+		case ">":
+			if version[1] == "" || isWildcard(version[1]) {
+				raise(major + 1)
+			} else {
+				raise(major)
+			}
+		case "<":
+			if wholeMajor {
+				if major == 0 {
+					return 0, fmt.Errorf("comparator %q allows no version", comparator)
+				}
+				limit(major - 1)
+			} else {
+				limit(major)
+			}
+		case "<=":
+			limit(major)
+		default:
+			// An exact version, x-range, caret or tilde range stays within its major
+			raise(major)
+			limit(major)
+		}
+	}
+
+	if lower >= 0 && upper >= 0 && lower > upper {
+		return 0, errors.New("no version satisfies every comparator")
+	}
+	if lower < 0 {
+		return upper, nil
+	}
+	return lower, nil
+}
+
+func isWildcard(part string) bool {
+	return part == "x" || part == "X" || part == "*"
+}
+
+func isZeroOrWildcard(part string) bool {
+	return part == "" || part == "0" || isWildcard(part)
+}
+
+// DetectNodeVersion picks the Node.js image tag from .nvmrc, falling back to engines.node in
+// package.json. It returns "" without an error when the project pins no version, and a warning
+// when the pin was only partly understood.
+func DetectNodeVersion(projectPath string) (string, string, error) {
+	data, err := os.ReadFile(filepath.Join(projectPath, ".nvmrc"))
+	if err == nil {
+		// .nvmrc may contain comments or trailing lines; the first non-empty line is the version
+		for _, line := range strings.Split(string(data), "\n") {
+			line, _, _ = strings.Cut(line, "#")
+			if line = strings.TrimSpace(line); line != "" {
+				version, warning, err := NormalizeNodeVersion(line)
+				if err != nil {
+					return "", "", fmt.Errorf("invalid .nvmrc: %w", err)
+				}
+				return version, warning, nil
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return "", "", fmt.Errorf("failed to read .nvmrc: %w", err)
+	}
+
+	h := &ProjectHandler{projectPath: projectPath}
+	pkg, err := h.readPackageJSON()
+	if err != nil || pkg.Engines["node"] == "" {
+		return "", "", nil
+	}
+	version, err := normalizeEnginesRange(pkg.Engines["node"])
+	if errors.Is(err, errUnsupportedComparator) {
+		// A range this package cannot read only loses the hint, it does not break the build
+		return "", fmt.Sprintf("ignoring engines.node: %v", err), nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("invalid engines.node: %w", err)
+	}
+	return version, "", nil
+}
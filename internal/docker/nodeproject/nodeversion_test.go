@@ -0,0 +1,139 @@
+package nodeproject
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateDockerfileNvmrc(t *testing.T) {
+	tests := []struct {
+		name      string
+		nvmrc     string
+		engines   string
+		version   string
+		wantImage string
+		wantErr   bool
+	}{
+		{name: "full version with v prefix", nvmrc: "v18.17.0\n", wantImage: "node:18.17.0-alpine"},
+		{name: "major only", nvmrc: "18", wantImage: "node:18-alpine"},
+		{name: "major and minor", nvmrc: "20.11", wantImage: "node:20.11-alpine"},
+		{name: "lts codename", nvmrc: "lts/hydrogen", wantImage: "node:18-alpine"},
+		{name: "lts codename mixed case", nvmrc: "LTS/Iron", wantImage: "node:20-alpine"},
+		{name: "latest lts", nvmrc: "lts/*", wantImage: "node:lts-alpine"},
+		{name: "current", nvmrc: "node", wantImage: "node:current-alpine"},
+		{name: "comment and blank lines", nvmrc: "\n# pinned for CI\n v16 \n", wantImage: "node:16-alpine"},
+		{name: "newest lts codename", nvmrc: "lts/krypton", wantImage: "node:24-alpine"},
+		{name: "unknown codename falls back to latest lts", nvmrc: "lts/unobtainium", wantImage: "node:lts-alpine"},
+		{name: "garbage", nvmrc: "eighteen", wantErr: true},
+		{name: "engines fallback", engines: ">=18.0.0", wantImage: "node:18-alpine"},
+		{name: "nvmrc wins over engines", nvmrc: "20", engines: "^18", wantImage: "node:20-alpine"},
+		{name: "unusable engines range", engines: "*", wantImage: "node:18-alpine"},
+		{name: "upper bound only", engines: "<18", wantImage: "node:17-alpine"},
+		{name: "unsupported comparator is ignored", engines: "!=18", wantImage: "node:18-alpine"},
+		{name: "unsatisfiable engines range", engines: ">=20 <18", wantErr: true},
+		{name: "explicit version wins", nvmrc: "16", version: "22", wantImage: "node:22-alpine"},
+		{name: "no pin uses base image", wantImage: "node:18-alpine"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			pkg := `{"name": "app", "version": "1.0.0"}`
+			if tt.engines != "" {
+				pkg = `{"name": "app", "version": "1.0.0", "engines": {"node": "` + tt.engines + `"}}`
+			}
+			if err := os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte(pkg), 0644); err != nil {
+				t.Fatalf("Failed to write package.json: %v", err)
+			}
+			if tt.nvmrc != "" {
+				if err := os.WriteFile(filepath.Join(tmpDir, ".nvmrc"), []byte(tt.nvmrc), 0644); err != nil {
+					t.Fatalf("Failed to write .nvmrc: %v", err)
+				}
+			}
+
+			handler := NewProjectHandler(tmpDir, &ProjectConfig{
+				BaseImage:   "node:18-alpine",
+				DefaultPort: "3000",
+				NodeVersion: tt.version,
+			})
+			err := handler.GenerateDockerfile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateDockerfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			content, err := os.ReadFile(filepath.Join(tmpDir, "Dockerfile"))
+			if err != nil {
+				t.Fatalf("Failed to read Dockerfile: %v", err)
+			}
+			if !contains(string(content), "FROM "+tt.wantImage+"\n") {
+				t.Errorf("Dockerfile does not use %s:\n%s", tt.wantImage, content)
+			}
+		})
+	}
+}
+
+func TestGenerateDockerfileNodeImageFamily(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte(`{"name": "app", "version": "1.0.0"}`), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, ".nvmrc"), []byte("20\n"), 0644); err != nil {
+		t.Fatalf("Failed to write .nvmrc: %v", err)
+	}
+
+	// A Debian base keeps pinned versions on Debian, so native modules still find glibc
+	handler := NewProjectHandler(tmpDir, &ProjectConfig{BaseImage: "node:latest", DefaultPort: "3000"})
+	if err := handler.GenerateDockerfile(); err != nil {
+		t.Fatalf("GenerateDockerfile() error = %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(tmpDir, "Dockerfile"))
+	if err != nil {
+		t.Fatalf("Failed to read Dockerfile: %v", err)
+	}
+	if !contains(string(content), "FROM node:20\n") {
+		t.Errorf("Dockerfile does not use node:20:\n%s", content)
+	}
+}
+
+func TestNormalizeEnginesRange(t *testing.T) {
+	tests := []struct {
+		versionRange string
+		want         string
+		wantErr      bool
+	}{
+		{versionRange: ">=18", want: "18"},
+		{versionRange: ">= 18.17.0", want: "18"},
+		{versionRange: "^20.1", want: "20"},
+		{versionRange: "~16.14", want: "16"},
+		{versionRange: "18.x", want: "18"},
+		{versionRange: "v20", want: "20"},
+		{versionRange: ">18", want: "19"},
+		{versionRange: ">18.2", want: "18"},
+		{versionRange: "<18", want: "17"},
+		{versionRange: "<18.5", want: "18"},
+		{versionRange: "<=20", want: "20"},
+		{versionRange: ">=16 <20", want: "16"},
+		{versionRange: "18 - 20", want: "18"},
+		{versionRange: "^20 || ^18", want: "18"},
+		{versionRange: "*", want: ""},
+		{versionRange: ">=20 <18", wantErr: true},
+		{versionRange: "<0", wantErr: true},
+		{versionRange: "!=18", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.versionRange, func(t *testing.T) {
+			got, err := normalizeEnginesRange(tt.versionRange)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalizeEnginesRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeEnginesRange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package nodeproject
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsProductionEnv reports whether env, in KEY=value form, sets NODE_ENV=production
+func IsProductionEnv(env []string) bool {
+	for _, kv := range env {
+		if key, value, ok := strings.Cut(kv, "="); ok && key == "NODE_ENV" && value == "production" {
+			return true
+		}
+	}
+	return false
+}
+
+// installCommand returns the dependency install for manager. Production installs leave out
+// devDependencies. npm ci needs a lockfile, so npm without one falls back to npm install.
+func installCommand(manager PackageManager, hasLockfile, production bool) string {
+	switch manager {
+	case PackageManagerPNPM:
+		if production {
+			return "corepack enable && pnpm install --prod"
+		}
+		return "corepack enable && pnpm install"
+	case PackageManagerYarn:
+		if production {
+			return "corepack enable && yarn install --production"
+		}
+		return "corepack enable && yarn install"
+	default:
+		install := "npm install"
+		if hasLockfile {
+			install = "npm ci"
+		}
+		if production {
+			install += " --omit=dev"
+		}
+		return install
+	}
+}
+
+// manifestCopy is the COPY instruction for package.json and the project's lockfile, so the
+// install layer is only rebuilt when dependencies change
+func manifestCopy(projectPath string) string {
+	files := []string{"package*.json"}
+	for _, lockfile := range lockfiles {
+		if _, err := os.Stat(filepath.Join(projectPath, lockfile.name)); err != nil {
+			continue
+		}
+		if lockfile.name != "package-lock.json" {
+			files = append(files, lockfile.name)
+		}
+		break
+	}
+	return fmt.Sprintf("COPY %s ./", strings.Join(files, " "))
+}
+
+// ProductionDockerfile renders a Dockerfile whose runtime image installs only production
+// dependencies. A project with a build script, such as a TypeScript compile, gets a builder
+// stage that installs every dependency and builds; the runtime stage then copies the build
+// output from it.
+func ProductionDockerfile(projectPath, baseImage, port string) (string, error) {
+	return productionDockerfile(projectPath, baseImage, port, "")
+}
+
+// productionDockerfile is ProductionDockerfile with extra instructions run in every stage
+// before dependencies are installed
+func productionDockerfile(projectPath, baseImage, port, setup string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(projectPath, "package.json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read package.json: %w", err)
+	}
+	var pkg PackageJSON
+	if err := UnmarshalPackageJSON(data, &pkg); err != nil {
+		return "", err
+	}
+
+	manager, hasLockfile := DetectPackageManager(projectPath)
+	copyManifests := manifestCopy(projectPath)
+	runtime := fmt.Sprintf(`FROM %s
+
+WORKDIR /app
+ENV NODE_ENV=production
+%s
+# Copy package files
+%s
+
+# Install production dependencies only
+RUN %s
+
+# Copy project files
+COPY . .
+`, baseImage, setup, copyManifests, installCommand(manager, hasLockfile, true))
+
+	builder := ""
+	if _, ok := pkg.Scripts["build"]; ok {
+		outputDir := BuildOutputDir(&pkg)
+		builder = fmt.Sprintf(`FROM %s AS builder
+
+WORKDIR /app
+%s
+# Copy package files
+%s
+
+# The build needs devDependencies such as typescript
+RUN %s
+
+# Copy project files and build
+COPY . .
+RUN %s run build
+
+`, baseImage, setup, copyManifests, installCommand(manager, hasLockfile, false), manager)
+		runtime += fmt.Sprintf(`
+# Take the build output from the builder stage
+COPY --from=builder /app/%[1]s ./%[1]s
+`, outputDir)
+	}
+
+	return builder + runtime + fmt.Sprintf(`
+# Expose the app's ports
+EXPOSE %s
+
+# Start the application
+CMD ["npm", "start"]
+`, port), nil
+}
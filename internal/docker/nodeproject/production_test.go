@@ -0,0 +1,129 @@
+package nodeproject
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateDockerfileProduction(t *testing.T) {
+	tmpDir := t.TempDir()
+	pkg := `{
+		"name": "api",
+		"scripts": {"build": "tsc", "start": "node dist/index.js"},
+		"dependencies": {"express": "^4.18.2"},
+		"devDependencies": {"typescript": "^5.3.0"}
+	}`
+	if err := os.WriteFile(filepath.Join(tmpDir, "package.json"), []byte(pkg), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "package-lock.json"), []byte("{}"), 0644); err != nil {
+		t.Fatalf("Failed to write package-lock.json: %v", err)
+	}
+
+	handler := NewProjectHandler(tmpDir, &ProjectConfig{
+		BaseImage:   "node:20-slim",
+		DefaultPort: "3000",
+		Production:  true,
+	})
+	if err := handler.GenerateDockerfile(); err != nil {
+		t.Fatalf("GenerateDockerfile failed: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "Dockerfile"))
+	if err != nil {
+		t.Fatalf("Failed to read Dockerfile: %v", err)
+	}
+
+	builder, runtime, ok := strings.Cut(string(data), "\nFROM node:20-slim\n")
+	if !ok || !strings.HasPrefix(builder, "FROM node:20-slim AS builder\n") {
+		t.Fatalf("Expected a builder stage followed by a runtime stage:\n%s", data)
+	}
+	if !strings.Contains(builder, "RUN npm ci\n") || strings.Contains(builder, "--omit=dev") {
+		t.Errorf("Builder stage should install dev dependencies:\n%s", builder)
+	}
+	if !strings.Contains(builder, "RUN npm run build\n") {
+		t.Errorf("Builder stage does not run the build:\n%s", builder)
+	}
+	for _, want := range []string{
+		"ENV NODE_ENV=production\n",
+		"RUN npm ci --omit=dev\n",
+		"COPY --from=builder /app/dist ./dist\n",
+		"EXPOSE 3000\n",
+	} {
+		if !strings.Contains(runtime, want) {
+			t.Errorf("Runtime stage missing %q:\n%s", want, runtime)
+		}
+	}
+}
+
+func TestProductionDockerfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		lockfile string
+		scripts  string
+		want     []string
+		notWant  []string
+	}{
+		{
+			name:     "yarn without a build",
+			lockfile: "yarn.lock",
+			scripts:  `{"start": "node index.js"}`,
+			want:     []string{"COPY package*.json yarn.lock ./\n", "RUN corepack enable && yarn install --production\n"},
+			notWant:  []string{"AS builder"},
+		},
+		{
+			name:     "pnpm with a build",
+			lockfile: "pnpm-lock.yaml",
+			scripts:  `{"build": "tsc", "start": "node dist/index.js"}`,
+			want:     []string{"RUN corepack enable && pnpm install\n", "RUN pnpm run build\n", "RUN corepack enable && pnpm install --prod\n"},
+		},
+		{
+			name:    "npm without a lockfile",
+			scripts: `{"start": "node index.js"}`,
+			want:    []string{"RUN npm install --omit=dev\n"},
+			notWant: []string{"npm ci"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			pkg := `{"name": "app", "scripts": ` + tt.scripts + `}`
+			if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(pkg), 0644); err != nil {
+				t.Fatalf("Failed to write package.json: %v", err)
+			}
+			if tt.lockfile != "" {
+				if err := os.WriteFile(filepath.Join(dir, tt.lockfile), nil, 0644); err != nil {
+					t.Fatalf("Failed to write %s: %v", tt.lockfile, err)
+				}
+			}
+
+			got, err := ProductionDockerfile(dir, "node:latest", "3000")
+			if err != nil {
+				t.Fatalf("ProductionDockerfile failed: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("Dockerfile missing %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("Dockerfile should not contain %q:\n%s", notWant, got)
+				}
+			}
+		})
+	}
+}
+
+func TestIsProductionEnv(t *testing.T) {
+	if !IsProductionEnv([]string{"PORT=3000", "NODE_ENV=production"}) {
+		t.Error("NODE_ENV=production not detected")
+	}
+	for _, env := range [][]string{nil, {"NODE_ENV=development"}, {"MY_NODE_ENV=production"}, {"NODE_ENV"}} {
+		if IsProductionEnv(env) {
+			t.Errorf("IsProductionEnv(%q) = true, want false", env)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package nodeproject
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrProjectExists is returned when scaffolding into a directory that already holds a project
+var ErrProjectExists = errors.New("project already exists")
+
+// packageNamePattern matches the names npm accepts for new packages, optionally scoped
+var packageNamePattern = regexp.MustCompile(`^(@[a-z0-9-~][a-z0-9-._~]*/)?[a-z0-9-~][a-z0-9-._~]*$`)
+
+// maxPackageNameLength is npm's limit on package names, including the scope
+const maxPackageNameLength = 214
+
+// expressEntryPoint is src/index.js of a scaffolded app: an Express server on $PORT
+const expressEntryPoint = `const express = require('express');
+
+const app = express();
+const port = process.env.PORT || 3000;
+
+app.get('/', (req, res) => {
+  res.json({ status: 'ok' });
+});
+
+app.listen(port, () => {
+  console.log('listening on port ' + port);
+});
+`
+
+// ValidatePackageName checks a package name against npm's naming rules
+func ValidatePackageName(name string) error {
+	if len(name) > maxPackageNameLength {
+		return fmt.Errorf("package name must be at most %d characters", maxPackageNameLength)
+	}
+	if !packageNamePattern.MatchString(name) {
+		return fmt.Errorf("package name %q must be lowercase and contain only letters, digits, '-', '.', '_' and '~'", name)
+	}
+	return nil
+}
+
+// ScaffoldExpressApp creates a minimal Express app named name: the project structure with its
+// package.json and Dockerfile, plus a src/index.js that serves on $PORT. It refuses to write
+// into a directory that already has a package.json.
+func (h *ProjectHandler) ScaffoldExpressApp(name string) error {
+	if err := ValidatePackageName(name); err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(h.projectPath, "package.json")); err == nil {
+		return fmt.Errorf("%w: %s already has a package.json", ErrProjectExists, h.projectPath)
+	}
+
+	if err := h.createProjectStructure(name); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(h.projectPath, "src", "index.js"), []byte(expressEntryPoint), 0644); err != nil {
+		return fmt.Errorf("failed to write src/index.js: %w", err)
+	}
+	return nil
+}
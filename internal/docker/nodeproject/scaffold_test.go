@@ -0,0 +1,47 @@
+package nodeproject
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScaffoldExpressAppPassesValidation(t *testing.T) {
+	dir := t.TempDir()
+	handler := NewProjectHandler(dir, nil)
+
+	if err := handler.ScaffoldExpressApp("hello"); err != nil {
+		t.Fatalf("ScaffoldExpressApp failed: %v", err)
+	}
+
+	for _, file := range []string{"package.json", "src/index.js", "Dockerfile"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(file))); err != nil {
+			t.Errorf("%s not created: %v", file, err)
+		}
+	}
+	if err := handler.ValidateProject(); err != nil {
+		t.Fatalf("scaffolded project fails validation: %v", err)
+	}
+	report := handler.Report()
+	if !report.Valid || report.Framework != "express" {
+		t.Errorf("report = %+v, want a valid express project", report)
+	}
+
+	if err := handler.ScaffoldExpressApp("hello"); !errors.Is(err, ErrProjectExists) {
+		t.Errorf("scaffolding over an existing project: err = %v, want ErrProjectExists", err)
+	}
+}
+
+func TestValidatePackageName(t *testing.T) {
+	for _, name := range []string{"hello", "@acme/api", "my.app_v2"} {
+		if err := ValidatePackageName(name); err != nil {
+			t.Errorf("ValidatePackageName(%q) = %v, want nil", name, err)
+		}
+	}
+	for _, name := range []string{"", "Hello", "../escape", "with space", ".hidden"} {
+		if err := ValidatePackageName(name); err == nil {
+			t.Errorf("ValidatePackageName(%q) = nil, want an error", name)
+		}
+	}
+}
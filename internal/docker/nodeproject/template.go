@@ -0,0 +1,141 @@
+package nodeproject
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+)
+
+// maxDockerfileTemplateSize bounds a user-supplied template so a request cannot make the
+// service parse or render an arbitrarily large document
+const maxDockerfileTemplateSize = 64 * 1024
+
+// DockerfileData holds the values a Dockerfile template can reference
+type DockerfileData struct {
+	BaseImage      string // Node.js image, e.g. "node:20-alpine"
+	Port           string // Port the app listens on, e.g. "3000"
+	PackageManager string // "npm", "yarn" or "pnpm", from the lockfile
+	BuildOutputDir string // Where the framework's build writes its output, e.g. "dist" or ".next"
+}
+
+// buildOutputDirs maps frameworks to the directory their build writes to; others use dist
+var buildOutputDirs = map[string]string{
+	"nextjs":           ".next",
+	"nuxt":             ".output",
+	"remix":            "build",
+	"sveltekit":        "build",
+	"create-react-app": "build",
+}
+
+// BuildOutputDir returns where the package's framework writes its build output
+func BuildOutputDir(pkg *PackageJSON) string {
+	if dir, ok := buildOutputDirs[DetectFramework(pkg)]; ok {
+		return dir
+	}
+	return "dist"
+}
+
+// DockerfileTemplate is a parsed user-supplied Dockerfile template
+type DockerfileTemplate struct {
+	tmpl *template.Template
+}
+
+// ParseDockerfileTemplate parses a text/template Dockerfile. References to fields that
+// DockerfileData does not have are rejected here, including ones in branches that would not
+// run, so a typo fails the request instead of rendering as an empty string.
+func ParseDockerfileTemplate(text string) (*DockerfileTemplate, error) {
+	if len(text) > maxDockerfileTemplateSize {
+		return nil, fmt.Errorf("Dockerfile template is larger than %d bytes", maxDockerfileTemplateSize)
+	}
+	tmpl, err := template.New("Dockerfile").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Dockerfile template: %w", err)
+	}
+
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if err := checkTemplateFields(t.Tree.Root); err != nil {
+			return nil, fmt.Errorf("invalid Dockerfile template: %w", err)
+		}
+	}
+	return &DockerfileTemplate{tmpl: tmpl}, nil
+}
+
+// Render executes the template with data
+func (t *DockerfileTemplate) Render(data DockerfileData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render Dockerfile template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// checkTemplateFields walks a template tree and reports the first field DockerfileData lacks
+func checkTemplateFields(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkTemplateFields(child); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkTemplateFields(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			if err := checkTemplateFields(cmd); err != nil {
+				return err
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if err := checkTemplateFields(arg); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkBranchFields(&n.BranchNode)
+	case *parse.RangeNode:
+		return checkBranchFields(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranchFields(&n.BranchNode)
+	case *parse.TemplateNode:
+		return checkTemplateFields(n.Pipe)
+	case *parse.ChainNode:
+		return checkTemplateFields(n.Node)
+	case *parse.FieldNode:
+		return checkFieldName(n.Ident[0])
+	case *parse.VariableNode:
+		// $ is the template's data, so $.Name is a field reference as well
+		if n.Ident[0] == "$" && len(n.Ident) > 1 {
+			return checkFieldName(n.Ident[1])
+		}
+	}
+	return nil
+}
+
+func checkBranchFields(n *parse.BranchNode) error {
+	for _, child := range []parse.Node{n.Pipe, n.List, n.ElseList} {
+		if err := checkTemplateFields(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkFieldName(name string) error {
+	if _, ok := reflect.TypeOf(DockerfileData{}).FieldByName(name); !ok {
+		return fmt.Errorf("unknown field .%s; available fields are .BaseImage, .Port, .PackageManager and .BuildOutputDir", name)
+	}
+	return nil
+}
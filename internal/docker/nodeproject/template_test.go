@@ -0,0 +1,94 @@
+package nodeproject
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerfileTemplate(t *testing.T) {
+	tmpl, err := ParseDockerfileTemplate(`FROM {{.BaseImage}} AS build
+WORKDIR /app
+COPY . .
+{{if eq .PackageManager "pnpm"}}RUN corepack enable && pnpm install --frozen-lockfile{{else}}RUN {{.PackageManager}} install{{end}}
+RUN {{.PackageManager}} run build
+
+FROM {{$.BaseImage}}
+COPY --from=build /app/{{.BuildOutputDir}} ./{{.BuildOutputDir}}
+EXPOSE {{.Port}}
+`)
+	if err != nil {
+		t.Fatalf("ParseDockerfileTemplate failed: %v", err)
+	}
+
+	got, err := tmpl.Render(DockerfileData{
+		BaseImage:      "node:20-alpine",
+		Port:           "8080",
+		PackageManager: "pnpm",
+		BuildOutputDir: ".next",
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := `FROM node:20-alpine AS build
+WORKDIR /app
+COPY . .
+RUN corepack enable && pnpm install --frozen-lockfile
+RUN pnpm run build
+
+FROM node:20-alpine
+COPY --from=build /app/.next ./.next
+EXPOSE 8080
+`
+	if got != want {
+		t.Errorf("Render() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDockerfileTemplateRejectsUnknownFields(t *testing.T) {
+	for name, text := range map[string]string{
+		"unknown field":          "FROM {{.Image}}",
+		"unknown field via $":    "FROM {{$.NodeVersion}}",
+		"in a branch never run":  "FROM node\n{{if false}}EXPOSE {{.HostPort}}{{end}}",
+		"in a pipeline argument": `FROM {{printf "%s" .Base}}`,
+		"in a defined template":  `{{define "stage"}}FROM {{.Img}}{{end}}{{template "stage" .}}`,
+		"syntax error":           "FROM {{.BaseImage",
+		"too large":              strings.Repeat("#", maxDockerfileTemplateSize+1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseDockerfileTemplate(text); err == nil {
+				t.Errorf("ParseDockerfileTemplate(%.40q) succeeded, want an error", text)
+			}
+		})
+	}
+}
+
+func TestGenerateDockerfileFromTemplate(t *testing.T) {
+	dir := t.TempDir()
+	pkg := `{"name": "web", "version": "1.0.0", "dependencies": {"next": "^14.0.0"}}`
+	if err := os.WriteFile(filepath.Join(dir, "package.json"), []byte(pkg), 0644); err != nil {
+		t.Fatalf("Failed to write package.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "yarn.lock"), nil, 0644); err != nil {
+		t.Fatalf("Failed to write yarn.lock: %v", err)
+	}
+
+	h := NewProjectHandler(dir, &ProjectConfig{
+		BaseImage:          "node:20-alpine",
+		DefaultPort:        "3000",
+		DockerfileTemplate: "FROM {{.BaseImage}}\nRUN {{.PackageManager}} build\nCMD [\"node\", \"{{.BuildOutputDir}}\"]\nEXPOSE {{.Port}}\n",
+	})
+	if err := h.GenerateDockerfile(); err != nil {
+		t.Fatalf("GenerateDockerfile failed: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "Dockerfile"))
+	if err != nil {
+		t.Fatalf("Failed to read Dockerfile: %v", err)
+	}
+	want := "FROM node:20-alpine\nRUN yarn build\nCMD [\"node\", \".next\"]\nEXPOSE 3000\n"
+	if string(got) != want {
+		t.Errorf("Dockerfile =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,246 @@
+package nodeproject
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PackageManager identifies the tool that manages a workspace
+type PackageManager string
+
+const (
+	PackageManagerNPM  PackageManager = "npm"
+	PackageManagerYarn PackageManager = "yarn"
+	PackageManagerPNPM PackageManager = "pnpm"
+)
+
+// Workspace describes a monorepo root that hoists dependencies for its packages
+type Workspace struct {
+	Root     string
+	Manager  PackageManager
+	Patterns []string
+}
+
+// rootPackageJSON is the subset of a root package.json needed for workspace detection.
+// Workspaces may be a list of globs or, for yarn, an object with a packages list.
+type rootPackageJSON struct {
+	Workspaces json.RawMessage `json:"workspaces"`
+}
+
+// DetectWorkspace checks root for a pnpm-workspace.yaml or a package.json workspaces field.
+// It returns nil without an error when root is not a workspace root.
+func DetectWorkspace(root string) (*Workspace, error) {
+	data, err := os.ReadFile(filepath.Join(root, "pnpm-workspace.yaml"))
+	if err == nil {
+		var cfg struct {
+			Packages []string `yaml:"packages"`
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse pnpm-workspace.yaml: %w", err)
+		}
+		return &Workspace{Root: root, Manager: PackageManagerPNPM, Patterns: cfg.Packages}, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read pnpm-workspace.yaml: %w", err)
+	}
+
+	data, err = os.ReadFile(filepath.Join(root, "package.json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read package.json: %w", err)
+	}
+
+	var pkg rootPackageJSON
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("failed to parse package.json: %w", err)
+	}
+	if len(pkg.Workspaces) == 0 {
+		return nil, nil
+	}
+
+	patterns, err := parseWorkspacePatterns(pkg.Workspaces)
+	if err != nil {
+		return nil, err
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	manager := PackageManagerNPM
+	if _, err := os.Stat(filepath.Join(root, "yarn.lock")); err == nil {
+		manager = PackageManagerYarn
+	}
+	return &Workspace{Root: root, Manager: manager, Patterns: patterns}, nil
+}
+
+// parseWorkspacePatterns accepts both the array and the {"packages": [...]} forms
+func parseWorkspacePatterns(raw json.RawMessage) ([]string, error) {
+	var patterns []string
+	if err := json.Unmarshal(raw, &patterns); err == nil {
+		return patterns, nil
+	}
+
+	var object struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return nil, fmt.Errorf("invalid workspaces field in package.json: %w", err)
+	}
+	return object.Packages, nil
+}
+
+// Includes reports whether the package at relDir (relative to the root, slash separated)
+// is matched by the workspace patterns. Negated patterns exclude previously matched packages.
+func (w *Workspace) Includes(relDir string) bool {
+	relDir = path.Clean(filepath.ToSlash(relDir))
+
+	included := false
+	for _, pattern := range w.Patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = path.Clean(strings.TrimPrefix(strings.TrimPrefix(pattern, "!"), "./"))
+		if matchWorkspacePattern(pattern, relDir) {
+			included = !negated
+		}
+	}
+	return included
+}
+
+// matchWorkspacePattern matches a single glob, treating a trailing "/**" as any depth
+func matchWorkspacePattern(pattern, relDir string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+		return relDir != prefix && strings.HasPrefix(relDir, prefix+"/")
+	}
+	matched, err := path.Match(pattern, relDir)
+	return err == nil && matched
+}
+
+// rootManifestFiles are the files at a workspace root that affect dependency installation
+var rootManifestFiles = []string{
+	"package.json",
+	"package-lock.json",
+	"npm-shrinkwrap.json",
+	"yarn.lock",
+	".yarnrc.yml",
+	"pnpm-lock.yaml",
+	"pnpm-workspace.yaml",
+}
+
+// ManifestFiles lists, relative to the root and slash separated, the root manifest and
+// lockfiles plus the package.json of every workspace package. Copying only these before
+// installing lets Docker reuse the install layer until dependencies change.
+func (w *Workspace) ManifestFiles() ([]string, error) {
+	var files []string
+	for _, name := range rootManifestFiles {
+		if _, err := os.Stat(filepath.Join(w.Root, name)); err == nil {
+			files = append(files, name)
+		}
+	}
+
+	err := filepath.WalkDir(w.Root, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if name := d.Name(); p != w.Root && (name == "node_modules" || strings.HasPrefix(name, ".")) {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(w.Root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if !w.Includes(rel) {
+			return nil
+		}
+		if _, err := os.Stat(filepath.Join(p, "package.json")); err == nil {
+			files = append(files, rel+"/package.json")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan workspace packages: %w", err)
+	}
+	return files, nil
+}
+
+// GenerateDockerfile renders a Dockerfile that installs from the workspace root so hoisted
+// dependencies resolve, builds the package at relDir and runs it from there. Only manifests
+// and lockfiles are copied before the install, so source changes keep the install cached.
+func (w *Workspace) GenerateDockerfile(relDir, baseImage, port string) (string, error) {
+	relDir = path.Clean(filepath.ToSlash(relDir))
+
+	data, err := os.ReadFile(filepath.Join(w.Root, filepath.FromSlash(relDir), "package.json"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read workspace package.json: %w", err)
+	}
+	var pkg PackageJSON
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return "", fmt.Errorf("failed to parse workspace package.json: %w", err)
+	}
+
+	var install, build string
+	switch w.Manager {
+	case PackageManagerPNPM:
+		install = "corepack enable && pnpm install"
+		build = fmt.Sprintf("pnpm --filter ./%s run build", relDir)
+	case PackageManagerYarn:
+		if pkg.Name == "" {
+			return "", fmt.Errorf("workspace package at %s has no name", relDir)
+		}
+		install = "corepack enable && yarn install"
+		build = fmt.Sprintf("yarn workspace %s run build", pkg.Name)
+	default:
+		install = "npm install"
+		build = fmt.Sprintf("npm run build -w %s", relDir)
+	}
+
+	buildStep := ""
+	if _, ok := pkg.Scripts["build"]; ok {
+		buildStep = fmt.Sprintf("\n# Build the target package\nRUN %s\n", build)
+	}
+
+	manifests, err := w.ManifestFiles()
+	if err != nil {
+		return "", err
+	}
+	var copyManifests strings.Builder
+	for _, file := range manifests {
+		dir := path.Dir(file)
+		if dir == "." {
+			fmt.Fprintf(&copyManifests, "COPY %s ./\n", file)
+		} else {
+			fmt.Fprintf(&copyManifests, "COPY %s %s/\n", file, dir)
+		}
+	}
+
+	return fmt.Sprintf(`FROM %s
+
+WORKDIR /app
+
+# Copy manifests and lockfiles first so the install layer is reused until dependencies change
+%s
+# Install all workspace dependencies from the root
+RUN %s
+
+# Copy the rest of the workspace
+COPY . .
+%s
+WORKDIR /app/%s
+
+# Expose default port
+EXPOSE %s
+
+# Start the application
+CMD ["npm", "start"]
+`, baseImage, copyManifests.String(), install, buildStep, relDir, port), nil
+}
@@ -0,0 +1,244 @@
+package nodeproject
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestDetectWorkspace(t *testing.T) {
+	tests := []struct {
+		name         string
+		files        map[string]string
+		wantManager  PackageManager
+		wantPatterns []string
+		wantNil      bool
+		wantErr      bool
+	}{
+		{
+			name: "npm workspaces array",
+			files: map[string]string{
+				"package.json": `{"name": "root", "workspaces": ["apps/*", "packages/*"]}`,
+			},
+			wantManager:  PackageManagerNPM,
+			wantPatterns: []string{"apps/*", "packages/*"},
+		},
+		{
+			name: "yarn workspaces object",
+			files: map[string]string{
+				"package.json": `{"name": "root", "workspaces": {"packages": ["apps/*"]}}`,
+				"yarn.lock":    "",
+			},
+			wantManager:  PackageManagerYarn,
+			wantPatterns: []string{"apps/*"},
+		},
+		{
+			name: "pnpm workspace",
+			files: map[string]string{
+				"package.json":        `{"name": "root"}`,
+				"pnpm-workspace.yaml": "packages:\n  - 'apps/*'\n  - 'libs/**'\n",
+			},
+			wantManager:  PackageManagerPNPM,
+			wantPatterns: []string{"apps/*", "libs/**"},
+		},
+		{
+			name: "plain package.json",
+			files: map[string]string{
+				"package.json": `{"name": "root", "dependencies": {"express": "^4.17.1"}}`,
+			},
+			wantNil: true,
+		},
+		{
+			name:    "no root manifest",
+			files:   map[string]string{},
+			wantNil: true,
+		},
+		{
+			name: "malformed workspaces",
+			files: map[string]string{
+				"package.json": `{"workspaces": "apps/*"}`,
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, content := range tt.files {
+				writeTestFile(t, filepath.Join(root, name), content)
+			}
+
+			ws, err := DetectWorkspace(root)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectWorkspace() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantNil {
+				if ws != nil {
+					t.Fatalf("DetectWorkspace() = %+v, want nil", ws)
+				}
+				return
+			}
+			if ws == nil {
+				t.Fatal("DetectWorkspace() = nil, want workspace")
+			}
+			if ws.Manager != tt.wantManager {
+				t.Errorf("Manager = %q, want %q", ws.Manager, tt.wantManager)
+			}
+			if len(ws.Patterns) != len(tt.wantPatterns) {
+				t.Fatalf("Patterns = %v, want %v", ws.Patterns, tt.wantPatterns)
+			}
+			for i, pattern := range tt.wantPatterns {
+				if ws.Patterns[i] != pattern {
+					t.Errorf("Patterns[%d] = %q, want %q", i, ws.Patterns[i], pattern)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkspaceIncludes(t *testing.T) {
+	ws := &Workspace{Patterns: []string{"apps/*", "./libs/**", "!apps/legacy"}}
+
+	tests := []struct {
+		relDir string
+		want   bool
+	}{
+		{"apps/api", true},
+		{"apps/legacy", false},
+		{"apps/api/nested", false},
+		{"libs/ui", true},
+		{"libs/ui/forms", true},
+		{"libs", false},
+		{"tools/cli", false},
+	}
+
+	for _, tt := range tests {
+		if got := ws.Includes(tt.relDir); got != tt.want {
+			t.Errorf("Includes(%q) = %v, want %v", tt.relDir, got, tt.want)
+		}
+	}
+}
+
+func TestWorkspaceGenerateDockerfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		manager  PackageManager
+		appPkg   string
+		expected []string
+		absent   []string
+	}{
+		{
+			name:    "npm workspace",
+			manager: PackageManagerNPM,
+			appPkg:  `{"name": "@acme/api", "scripts": {"build": "tsc", "start": "node dist/index.js"}}`,
+			expected: []string{
+				"COPY . .",
+				"RUN npm install",
+				"RUN npm run build -w apps/api",
+				"WORKDIR /app/apps/api",
+			},
+		},
+		{
+			name:    "pnpm workspace",
+			manager: PackageManagerPNPM,
+			appPkg:  `{"name": "@acme/api", "scripts": {"build": "tsc"}}`,
+			expected: []string{
+				"RUN corepack enable && pnpm install",
+				"RUN pnpm --filter ./apps/api run build",
+				"WORKDIR /app/apps/api",
+			},
+		},
+		{
+			name:    "yarn workspace",
+			manager: PackageManagerYarn,
+			appPkg:  `{"name": "@acme/api", "scripts": {"build": "tsc"}}`,
+			expected: []string{
+				"RUN corepack enable && yarn install",
+				"RUN yarn workspace @acme/api run build",
+			},
+		},
+		{
+			name:     "no build script",
+			manager:  PackageManagerNPM,
+			appPkg:   `{"name": "@acme/api", "scripts": {"start": "node index.js"}}`,
+			expected: []string{"RUN npm install"},
+			absent:   []string{"run build"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestFile(t, filepath.Join(root, "apps", "api", "package.json"), tt.appPkg)
+
+			ws := &Workspace{Root: root, Manager: tt.manager, Patterns: []string{"apps/*"}}
+			dockerfile, err := ws.GenerateDockerfile("apps/api", "node:18-alpine", "3000")
+			if err != nil {
+				t.Fatalf("GenerateDockerfile failed: %v", err)
+			}
+
+			for _, expected := range append(tt.expected, "FROM node:18-alpine", "EXPOSE 3000") {
+				if !contains(dockerfile, expected) {
+					t.Errorf("Dockerfile missing expected content: %s\n%s", expected, dockerfile)
+				}
+			}
+			for _, absent := range tt.absent {
+				if contains(dockerfile, absent) {
+					t.Errorf("Dockerfile contains unexpected content: %s\n%s", absent, dockerfile)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkspaceDockerfileCopiesManifestsBeforeSource(t *testing.T) {
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "package.json"), `{"name": "monorepo", "private": true, "workspaces": ["apps/*", "packages/*"]}`)
+	writeTestFile(t, filepath.Join(root, "package-lock.json"), `{}`)
+	writeTestFile(t, filepath.Join(root, "apps", "api", "package.json"), `{"name": "@acme/api"}`)
+	writeTestFile(t, filepath.Join(root, "apps", "api", "index.js"), `console.log("api")`)
+	writeTestFile(t, filepath.Join(root, "packages", "shared", "package.json"), `{"name": "@acme/shared"}`)
+	writeTestFile(t, filepath.Join(root, "apps", "api", "node_modules", "dep", "package.json"), `{"name": "dep"}`)
+	writeTestFile(t, filepath.Join(root, "tools", "package.json"), `{"name": "tools"}`)
+
+	ws := &Workspace{Root: root, Manager: PackageManagerNPM, Patterns: []string{"apps/*", "packages/*"}}
+	manifests, err := ws.ManifestFiles()
+	if err != nil {
+		t.Fatalf("ManifestFiles failed: %v", err)
+	}
+	want := []string{"package.json", "package-lock.json", "apps/api/package.json", "packages/shared/package.json"}
+	if !reflect.DeepEqual(manifests, want) {
+		t.Errorf("ManifestFiles() = %v, want %v", manifests, want)
+	}
+
+	dockerfile, err := ws.GenerateDockerfile("apps/api", "node:18-alpine", "3000")
+	if err != nil {
+		t.Fatalf("GenerateDockerfile failed: %v", err)
+	}
+	install := strings.Index(dockerfile, "RUN npm install")
+	source := strings.Index(dockerfile, "COPY . .")
+	if install < 0 || source < 0 || install > source {
+		t.Fatalf("Expected dependencies to install before the source is copied:\n%s", dockerfile)
+	}
+	for _, line := range []string{"COPY package.json ./", "COPY package-lock.json ./", "COPY apps/api/package.json apps/api/", "COPY packages/shared/package.json packages/shared/"} {
+		if i := strings.Index(dockerfile, line); i < 0 || i > install {
+			t.Errorf("Expected %q before the install step:\n%s", line, dockerfile)
+		}
+	}
+}
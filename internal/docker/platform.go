@@ -0,0 +1,41 @@
+package docker
+
+import (
+	"fmt"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// knownPlatforms are the platforms a container can be pinned to, in os/arch[/variant] form
+var knownPlatforms = []string{
+	"linux/amd64",
+	"linux/arm64",
+	"linux/arm64/v8",
+	"linux/arm/v7",
+	"linux/arm/v6",
+	"linux/386",
+	"linux/ppc64le",
+	"linux/s390x",
+	"linux/riscv64",
+}
+
+// ParsePlatform parses a platform such as "linux/arm64". An empty string means the daemon's
+// own platform and yields nil.
+func ParsePlatform(platform string) (*ocispec.Platform, error) {
+	if platform == "" {
+		return nil, nil
+	}
+	normalized := strings.ToLower(platform)
+	for _, known := range knownPlatforms {
+		if normalized == known {
+			parts := strings.Split(normalized, "/")
+			p := &ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+			if len(parts) == 3 {
+				p.Variant = parts[2]
+			}
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported platform %q, must be one of %s", platform, strings.Join(knownPlatforms, ", "))
+}
@@ -0,0 +1,123 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"docker-management-system/internal/logging"
+
+	"github.com/docker/docker/client"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxReconnectAttempts bounds how often a lost daemon connection is re-established in a row
+	maxReconnectAttempts = 3
+	// reconnectPingTimeout bounds the ping that confirms a new connection works
+	reconnectPingTimeout = 2 * time.Second
+)
+
+// reconnectBackoff is the delay after the first failed reconnect attempt, doubled after each
+// further failure. It is a variable so tests can shorten it.
+var reconnectBackoff = 200 * time.Millisecond
+
+// api returns the current Docker API client
+func (c *Client) api() client.APIClient {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cli
+}
+
+// Reconnect replaces the Docker API client with a new one created from the options the
+// Client was created with, then closes the old one. Use it after the daemon has restarted.
+func (c *Client) Reconnect() error {
+	if c.connect == nil {
+		return &ClientError{
+			Op:  "reconnect",
+			Err: errors.New("client was not created from connection options"),
+		}
+	}
+
+	cli, err := c.connect()
+	if err != nil {
+		return &ClientError{
+			Op:  "reconnect",
+			Err: err,
+		}
+	}
+
+	c.mu.Lock()
+	old := c.cli
+	c.cli = cli
+	c.mu.Unlock()
+	c.invalidateListCache()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// checkConnection reconnects when err shows the daemon connection is gone, so the next call
+// gets a working client. It returns err unchanged; the failed call itself is not retried
+// because not every Docker operation is safe to repeat.
+func (c *Client) checkConnection(ctx context.Context, err error) error {
+	if isConnectionError(err) {
+		c.reconnectWithBackoff(ctx)
+	}
+	return err
+}
+
+// reconnectWithBackoff reconnects until the new client answers a ping. Concurrent callers
+// that hit the same broken connection skip the attempt while one is already in progress.
+func (c *Client) reconnectWithBackoff(ctx context.Context) {
+	if c.connect == nil || !c.reconnectMu.TryLock() {
+		return
+	}
+	defer c.reconnectMu.Unlock()
+
+	backoff := reconnectBackoff
+	for attempt := 1; attempt <= maxReconnectAttempts; attempt++ {
+		err := c.Reconnect()
+		if err == nil {
+			// The failed request's context may be nearly spent, so the ping gets its own deadline
+			pingCtx, cancel := context.WithTimeout(context.Background(), reconnectPingTimeout)
+			_, err = c.api().Ping(pingCtx)
+			cancel()
+		}
+		if err == nil {
+			logging.GetLogger(ctx).Info("reconnected to Docker daemon", zap.Int("attempt", attempt))
+			return
+		}
+
+		logging.LogError(ctx, "failed to reconnect to Docker daemon", err,
+			zap.String("operation", "reconnect"),
+			zap.Int("attempt", attempt),
+		)
+		if attempt < maxReconnectAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// isConnectionError reports whether err means the daemon could not be reached or dropped
+// the connection, as opposed to the daemon rejecting the request
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if client.IsErrConnectionFailed(err) {
+		return true
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
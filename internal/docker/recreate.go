@@ -0,0 +1,392 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-management-system/internal/logging"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+	"go.uber.org/zap"
+)
+
+// RecreateWithLabels replaces a container with an identical one carrying labels. Docker
+// cannot change labels in place, so the container is stopped, removed and created again
+// under the same name, then started if it was running. The new container runs the image the
+// original ran, by ID, even if its tag has since moved to another build. It returns the new
+// container ID.
+func (c *Client) RecreateWithLabels(ctx context.Context, containerID string, labels map[string]string, stopTimeout time.Duration) (string, error) {
+	defer c.invalidateListCache()
+	defer c.evictInspect(containerID)
+
+	inspect, err := c.api().ContainerInspect(ctx, containerID)
+	if err != nil {
+		return "", &ClientError{
+			Op:  "recreate",
+			Err: err,
+		}
+	}
+	if inspect.ContainerJSONBase == nil || inspect.Config == nil || inspect.HostConfig == nil {
+		return "", &ClientError{
+			Op:  "recreate",
+			Err: fmt.Errorf("incomplete inspect result for container %s", containerID),
+		}
+	}
+
+	wasRunning := inspect.State != nil && inspect.State.Running
+	// The daemon deletes a running auto-remove container itself once it stops. The wait is
+	// registered before the stop so the removal cannot be missed.
+	autoRemoved := wasRunning && inspect.HostConfig.AutoRemove
+	var removed <-chan container.WaitResponse
+	var waitErrs <-chan error
+	if autoRemoved {
+		removed, waitErrs = c.api().ContainerWait(ctx, inspect.ID, container.WaitConditionRemoved)
+	}
+	if wasRunning {
+		if err := c.StopContainer(ctx, inspect.ID, stopTimeout); err != nil {
+			return "", err
+		}
+	}
+
+	if autoRemoved {
+		err = waitForRemoval(removed, waitErrs)
+	} else {
+		err = c.api().ContainerRemove(ctx, inspect.ID, container.RemoveOptions{})
+	}
+	if err != nil {
+		return "", &ClientError{
+			Op:  "recreate",
+			Err: fmt.Errorf("remove container %s: %w", inspect.ID, err),
+		}
+	}
+
+	name := strings.TrimPrefix(inspect.Name, "/")
+	original := *inspect.Config
+	if inspect.Image != "" {
+		original.Image = inspect.Image
+	}
+	config := original
+	config.Labels = labels
+	// Docker defaults the hostname to the short container ID; let the new container get its own
+	if len(inspect.ID) >= 12 && config.Hostname == inspect.ID[:12] {
+		config.Hostname = ""
+	}
+	networking := recreateNetworkingConfig(inspect)
+
+	created, err := c.api().ContainerCreate(ctx, &config, inspect.HostConfig, networking, nil, name)
+	if err != nil {
+		createErr := &ClientError{
+			Op:  "recreate",
+			Err: fmt.Errorf("create container %s: %w", name, err),
+		}
+		// Put the original container back so a failed label update does not lose it
+		restored, restoreErr := c.api().ContainerCreate(ctx, &original, inspect.HostConfig, networking, nil, name)
+		if restoreErr != nil {
+			logging.LogError(ctx, "failed to restore container after recreate failure", restoreErr,
+				zap.String("operation", "recreate"),
+				zap.String("container_name", name),
+			)
+			return "", errors.Join(createErr, restoreErr)
+		}
+		if wasRunning {
+			c.StartContainer(ctx, restored.ID)
+		}
+		return "", createErr
+	}
+
+	if wasRunning {
+		if err := c.StartContainer(ctx, created.ID); err != nil {
+			return created.ID, &ClientError{
+				Op:  "recreate",
+				Err: fmt.Errorf("start container %s: %w", created.ID, err),
+			}
+		}
+	}
+	return created.ID, nil
+}
+
+// waitForRemoval blocks until a ContainerWait for WaitConditionRemoved reports the container
+// gone. A container that no longer exists by the time the wait is answered counts as removed.
+func waitForRemoval(removed <-chan container.WaitResponse, waitErrs <-chan error) error {
+	select {
+	case <-removed:
+		return nil
+	case err := <-waitErrs:
+		if errdefs.IsNotFound(err) || IsContainerNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+}
+
+// RebuildResult reports the outcome of rebuilding a single container
+type RebuildResult struct {
+	ContainerID    string `json:"containerId"`
+	Name           string `json:"name"`
+	ProjectPath    string `json:"projectPath,omitempty"`
+	NewContainerID string `json:"newContainerId,omitempty"`
+	Rebuilt        bool   `json:"rebuilt"`
+	Skipped        bool   `json:"skipped,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// ErrRebuildSkipped is wrapped by a RebuildManagedContainers build function's error to skip a
+// container that cannot be rebuilt as recorded, rather than fail it. The error is the reason.
+var ErrRebuildSkipped = errors.New("rebuild skipped")
+
+// RebuildManagedContainers rebuilds every managed container that records the project it was
+// created from, at most concurrency at a time. build builds the container's image again from its
+// project and returns the reference to run; a container is only touched once its image is built.
+// Each is then replaced by a container with the same configuration and name running the new
+// image, see rebuild. Containers without a recorded project, and those build skips with
+// ErrRebuildSkipped, are skipped.
+func (c *Client) RebuildManagedContainers(ctx context.Context, stopTimeout time.Duration, concurrency int, build func(ctx context.Context, info ContainerInfo) (string, error)) ([]RebuildResult, error) {
+	containers, err := c.ListContainers(ctx, true, map[string]string{ManagedByLabel: ManagedByValue})
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	results := make([]RebuildResult, 0, len(containers))
+	errs := make([]error, 0, len(containers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, info := range containers {
+		if info.Labels[ManagedByLabel] != ManagedByValue {
+			continue
+		}
+		projectPath := info.Labels[ProjectPathLabel]
+		if projectPath == "" {
+			mu.Lock()
+			results = append(results, RebuildResult{ContainerID: info.ID, Name: info.Name, Skipped: true, Error: "no recorded project path"})
+			mu.Unlock()
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(info ContainerInfo) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result := RebuildResult{ContainerID: info.ID, Name: info.Name, ProjectPath: projectPath}
+			image, err := build(ctx, info)
+			if errors.Is(err, ErrRebuildSkipped) {
+				result.Skipped = true
+				result.Error = strings.TrimPrefix(err.Error(), ErrRebuildSkipped.Error()+": ")
+				mu.Lock()
+				defer mu.Unlock()
+				results = append(results, result)
+				return
+			}
+			if err == nil {
+				result.NewContainerID, err = c.rebuild(ctx, info, image, stopTimeout)
+			}
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Rebuilt = true
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}(info)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ContainerID < results[j].ContainerID })
+	return results, errors.Join(errs...)
+}
+
+// rebuildSuffix is appended to a container's name for its replacement until the original is gone
+const rebuildSuffix = "-rebuild"
+
+// rebuild replaces a container with one created from image, with the same configuration. The
+// replacement is created under a temporary name and started if the original was running, and
+// only then is the original stopped and removed and the replacement renamed, so a failure before
+// that leaves the original in place. A running container that publishes fixed host ports or
+// pins its network address is stopped first, since its replacement cannot claim them while it
+// runs; it is started again if the replacement fails to start.
+func (c *Client) rebuild(ctx context.Context, info ContainerInfo, image string, stopTimeout time.Duration) (string, error) {
+	defer c.invalidateListCache()
+	defer c.evictInspect(info.ID)
+
+	inspect, err := c.api().ContainerInspect(ctx, info.ID)
+	if err != nil {
+		return "", &ClientError{
+			Op:  "rebuild",
+			Err: err,
+		}
+	}
+	if inspect.ContainerJSONBase == nil || inspect.Config == nil || inspect.HostConfig == nil {
+		return "", &ClientError{
+			Op:  "rebuild",
+			Err: fmt.Errorf("incomplete inspect result for container %s", info.ID),
+		}
+	}
+
+	name := strings.TrimPrefix(inspect.Name, "/")
+	tempName := name + rebuildSuffix
+	config := *inspect.Config
+	config.Image = image
+	// Docker defaults the hostname to the short container ID; let the new container get its own
+	if len(inspect.ID) >= 12 && config.Hostname == inspect.ID[:12] {
+		config.Hostname = ""
+	}
+	created, err := c.api().ContainerCreate(ctx, &config, inspect.HostConfig, recreateNetworkingConfig(inspect), nil, tempName)
+	if err != nil {
+		return "", &ClientError{
+			Op:  "rebuild",
+			Err: fmt.Errorf("create container %s: %w", tempName, err),
+		}
+	}
+	// discard removes a replacement that cannot take over from the original
+	discard := func(cause error) (string, error) {
+		if err := c.api().ContainerRemove(ctx, created.ID, container.RemoveOptions{Force: true}); err != nil {
+			logging.LogError(ctx, "failed to remove replacement container after rebuild failure", err,
+				zap.String("operation", "rebuild"),
+				zap.String("container_id", created.ID),
+			)
+		}
+		return "", cause
+	}
+
+	wasRunning := inspect.State != nil && inspect.State.Running
+	stopFirst := wasRunning && (publishesFixedHostPorts(inspect.HostConfig) || pinsNetworkAddress(inspect))
+	// The daemon deletes a running auto-remove container itself once it stops. The wait is
+	// registered before the stop so the removal cannot be missed.
+	autoRemoved := wasRunning && inspect.HostConfig.AutoRemove
+	var removed <-chan container.WaitResponse
+	var waitErrs <-chan error
+	if autoRemoved {
+		removed, waitErrs = c.api().ContainerWait(ctx, inspect.ID, container.WaitConditionRemoved)
+	}
+	stopped := false
+	if stopFirst {
+		if err := c.StopContainer(ctx, inspect.ID, stopTimeout); err != nil {
+			return discard(err)
+		}
+		stopped = true
+	}
+
+	if wasRunning {
+		if err := c.StartContainer(ctx, created.ID); err != nil {
+			startErr := &ClientError{
+				Op:  "rebuild",
+				Err: fmt.Errorf("start container %s: %w", created.ID, err),
+			}
+			// An auto-remove original is already gone once stopped
+			if stopped && !autoRemoved {
+				c.StartContainer(ctx, inspect.ID)
+			}
+			return discard(startErr)
+		}
+	}
+
+	if wasRunning && !stopped {
+		if err := c.StopContainer(ctx, inspect.ID, stopTimeout); err != nil {
+			return discard(err)
+		}
+	}
+	if autoRemoved {
+		err = waitForRemoval(removed, waitErrs)
+	} else {
+		err = c.api().ContainerRemove(ctx, inspect.ID, container.RemoveOptions{})
+	}
+	if err != nil {
+		return created.ID, &ClientError{
+			Op:  "rebuild",
+			Err: fmt.Errorf("remove container %s: %w", inspect.ID, err),
+		}
+	}
+
+	if err := c.api().ContainerRename(ctx, created.ID, name); err != nil {
+		return created.ID, &ClientError{
+			Op:  "rebuild",
+			Err: fmt.Errorf("rename container %s to %s: %w", tempName, name, err),
+		}
+	}
+	return created.ID, nil
+}
+
+// publishesFixedHostPorts reports whether a container binds any host port it chose, rather
+// than one the daemon assigns
+func publishesFixedHostPorts(hostConfig *container.HostConfig) bool {
+	for _, bindings := range hostConfig.PortBindings {
+		for _, binding := range bindings {
+			if binding.HostPort != "" && binding.HostPort != "0" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// pinsNetworkAddress reports whether a container carries a static IP or MAC address over to its
+// replacement, which would clash with the original on the same network
+func pinsNetworkAddress(inspect types.ContainerJSON) bool {
+	if configuredMacAddress(inspect) != "" {
+		return true
+	}
+	if inspect.NetworkSettings == nil {
+		return false
+	}
+	for _, settings := range inspect.NetworkSettings.Networks {
+		if settings == nil {
+			continue
+		}
+		if ipam := settings.IPAMConfig; ipam != nil && (ipam.IPv4Address != "" || ipam.IPv6Address != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// configuredMacAddress returns the MAC address a container was created with. The endpoints'
+// MacAddress cannot tell: Docker reports the address it assigned there for every container.
+func configuredMacAddress(inspect types.ContainerJSON) string {
+	if inspect.Config == nil {
+		return ""
+	}
+	return inspect.Config.MacAddress //nolint:staticcheck // the API version this service speaks still records it here
+}
+
+// recreateNetworkingConfig carries over the user-set endpoint settings of each network the
+// container is attached to, leaving runtime state such as IPs, MAC addresses and endpoint IDs
+// behind. A MAC address the container was created with travels in its config instead, see
+// configuredMacAddress.
+func recreateNetworkingConfig(inspect types.ContainerJSON) *network.NetworkingConfig {
+	if inspect.NetworkSettings == nil || len(inspect.NetworkSettings.Networks) == 0 {
+		return nil
+	}
+
+	endpoints := make(map[string]*network.EndpointSettings, len(inspect.NetworkSettings.Networks))
+	for name, settings := range inspect.NetworkSettings.Networks {
+		if settings == nil {
+			continue
+		}
+		endpoints[name] = &network.EndpointSettings{
+			IPAMConfig: settings.IPAMConfig,
+			Links:      settings.Links,
+			Aliases:    settings.Aliases,
+			DriverOpts: settings.DriverOpts,
+		}
+	}
+	return &network.NetworkingConfig{EndpointsConfig: endpoints}
+}
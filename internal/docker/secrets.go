@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SecretsLabel records the host directory holding a container's secret files, so the
+// directory can be removed together with the container
+const SecretsLabel = "block-builder.secrets-dir"
+
+// secretsDirPattern is the MkdirTemp pattern for per-container secret directories
+const secretsDirPattern = "secrets-*"
+
+// Secret is a value exposed inside a container as a read-only file at Target
+type Secret struct {
+	Name   string
+	Value  []byte
+	Target string
+}
+
+// String omits the value so a secret cannot end up in a log line by accident
+func (s Secret) String() string {
+	return fmt.Sprintf("secret %s at %s", s.Name, s.Target)
+}
+
+// GoString omits the value for %#v as well
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// SecretFile is a host file bind-mounted read-only into a container
+type SecretFile struct {
+	Source string
+	Target string
+}
+
+// WriteSecrets writes each secret to its own file in a new private directory under root,
+// which should be a tmpfs such as /dev/shm so the values never reach disk. It returns the
+// directory and the files to mount; on error nothing is left behind.
+func WriteSecrets(root string, secrets []Secret) (string, []SecretFile, error) {
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return "", nil, &ClientError{Op: "write_secrets", Err: err}
+	}
+	dir, err := os.MkdirTemp(root, secretsDirPattern)
+	if err != nil {
+		return "", nil, &ClientError{Op: "write_secrets", Err: err}
+	}
+
+	files := make([]SecretFile, 0, len(secrets))
+	for i, secret := range secrets {
+		// Files are named by position so secret names never need to be valid file names
+		source := filepath.Join(dir, strconv.Itoa(i))
+		if err := os.WriteFile(source, secret.Value, 0o444); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, &ClientError{Op: "write_secrets", Err: err, Details: fmt.Sprintf("secret %s", secret.Name)}
+		}
+		files = append(files, SecretFile{Source: source, Target: secret.Target})
+	}
+	return dir, files, nil
+}
+
+// RemoveSecrets removes a directory created by WriteSecrets. Anything that is not a secrets
+// directory directly under root is refused, since the path comes from a container label.
+func RemoveSecrets(root, dir string) error {
+	dir = filepath.Clean(dir)
+	if filepath.Dir(dir) != filepath.Clean(root) || !strings.HasPrefix(filepath.Base(dir), strings.TrimSuffix(secretsDirPattern, "*")) {
+		return &ClientError{Op: "remove_secrets", Err: fmt.Errorf("%s is not a secrets directory under %s", dir, root)}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return &ClientError{Op: "remove_secrets", Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package docker
+
+import (
+	"context"
+	"os"
+
+	"docker-management-system/internal/logging"
+
+	"go.uber.org/zap"
+)
+
+// SetBuildTempDir sets where files a build needs outside the project, such as build secrets,
+// are staged. An empty dir uses the OS temp directory.
+func (c *Client) SetBuildTempDir(dir string) {
+	c.buildTempDir = dir
+}
+
+// StageBuildFiles creates a private directory under the build temp directory, passes it to fn
+// to write the files a build needs outside its context in and run the build, and removes it
+// once fn returns. The removal is deferred, so it also runs when fn panics.
+func (c *Client) StageBuildFiles(ctx context.Context, fn func(dir string) error) error {
+	dir, err := os.MkdirTemp(c.buildTempDir, "build-files-*")
+	if err != nil {
+		return &ClientError{
+			Op:  "stage_build_files",
+			Err: err,
+		}
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			logging.LogError(ctx, "failed to remove staged build files", err,
+				zap.String("operation", "stage_build_files"),
+				zap.String("dir", dir),
+			)
+		}
+	}()
+
+	return fn(dir)
+}
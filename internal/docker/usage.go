@@ -0,0 +1,159 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ContainerUsage is a point-in-time resource reading for one container. CPUPercent is relative
+// to one CPU, so a container using two full cores reports 200. MemoryUsage excludes reclaimable
+// page cache, and MemoryLimit is the host's memory when the container is unlimited. Error is set
+// when the stats could not be read, and the container is then left out of the totals.
+type ContainerUsage struct {
+	ContainerID string  `json:"containerId"`
+	Name        string  `json:"name"`
+	CPUPercent  float64 `json:"cpuPercent"`
+	MemoryUsage uint64  `json:"memoryUsage"`
+	MemoryLimit uint64  `json:"memoryLimit"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// UsageSummary totals the resource usage of the managed containers. Failed counts the
+// containers whose stats could not be read.
+type UsageSummary struct {
+	Containers  []ContainerUsage `json:"containers"`
+	CPUPercent  float64          `json:"cpuPercent"`
+	MemoryUsage uint64           `json:"memoryUsage"`
+	MemoryLimit uint64           `json:"memoryLimit"`
+	Failed      int              `json:"failed"`
+}
+
+// ManagedUsage reads the stats of every running managed container, at most concurrency at a
+// time, and totals them. A container whose stats fail is reported with its error rather than
+// failing the whole summary.
+func (c *Client) ManagedUsage(ctx context.Context, concurrency int) (*UsageSummary, error) {
+	containers, err := c.ListContainers(ctx, false, map[string]string{ManagedByLabel: ManagedByValue})
+	if err != nil {
+		return nil, err
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	summary := &UsageSummary{Containers: make([]ContainerUsage, 0, len(containers))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for _, info := range containers {
+		if info.Labels[ManagedByLabel] != ManagedByValue {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(info ContainerInfo) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			usage := ContainerUsage{ContainerID: info.ID, Name: info.Name}
+			stats, err := c.containerStats(ctx, info.ID)
+			if err != nil {
+				usage.Error = err.Error()
+			} else {
+				usage.CPUPercent = cpuPercent(stats)
+				usage.MemoryUsage = memoryUsage(stats.MemoryStats)
+				usage.MemoryLimit = stats.MemoryStats.Limit
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			summary.Containers = append(summary.Containers, usage)
+			if err != nil {
+				summary.Failed++
+				return
+			}
+			summary.CPUPercent += usage.CPUPercent
+			summary.MemoryUsage += usage.MemoryUsage
+			summary.MemoryLimit += usage.MemoryLimit
+		}(info)
+	}
+	wg.Wait()
+
+	sort.Slice(summary.Containers, func(i, j int) bool {
+		return summary.Containers[i].ContainerID < summary.Containers[j].ContainerID
+	})
+	return summary, nil
+}
+
+// GetContainerUsage reads a single stats sample for one container
+func (c *Client) GetContainerUsage(ctx context.Context, containerID string) (*ContainerUsage, error) {
+	stats, err := c.containerStats(ctx, containerID)
+	if err != nil {
+		return nil, err
+	}
+	return &ContainerUsage{
+		ContainerID: containerID,
+		Name:        strings.TrimPrefix(stats.Name, "/"),
+		CPUPercent:  cpuPercent(stats),
+		MemoryUsage: memoryUsage(stats.MemoryStats),
+		MemoryLimit: stats.MemoryStats.Limit,
+	}, nil
+}
+
+// containerStats reads a single stats sample. It is not one-shot, so the daemon includes the
+// previous CPU reading needed to compute a CPU percentage.
+func (c *Client) containerStats(ctx context.Context, containerID string) (container.StatsResponse, error) {
+	var stats container.StatsResponse
+	resp, err := c.api().ContainerStats(ctx, containerID, false)
+	if err != nil {
+		c.checkConnection(ctx, err)
+		return stats, &ClientError{Op: "container_stats", Err: err, Details: containerID}
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return stats, &ClientError{Op: "container_stats", Err: err, Details: containerID}
+	}
+	return stats, nil
+}
+
+// cpuPercent computes CPU usage between the two samples in stats the way docker stats does. A
+// container that has just started has no previous sample and reports 0.
+func cpuPercent(stats container.StatsResponse) float64 {
+	if stats.PreCPUStats.SystemUsage == 0 {
+		return 0
+	}
+	cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(stats.CPUStats.SystemUsage) - float64(stats.PreCPUStats.SystemUsage)
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	cpus := float64(stats.CPUStats.OnlineCPUs)
+	if cpus == 0 {
+		cpus = float64(len(stats.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpus == 0 {
+		cpus = 1
+	}
+	return cpuDelta / systemDelta * cpus * 100
+}
+
+// memoryUsage subtracts reclaimable page cache from the raw usage, matching docker stats. The
+// cache is reported as inactive_file on cgroup v2 and total_inactive_file on cgroup v1.
+func memoryUsage(stats container.MemoryStats) uint64 {
+	cache, ok := stats.Stats["inactive_file"]
+	if !ok {
+		cache = stats.Stats["total_inactive_file"]
+	}
+	if cache > stats.Usage {
+		return stats.Usage
+	}
+	return stats.Usage - cache
+}
@@ -5,6 +5,41 @@ import (
 	"net/http"
 )
 
+// ErrorType is the broad category of an error
+type ErrorType string
+
+const (
+	TypeValidation       ErrorType = "validation_error"
+	TypeNotFound         ErrorType = "not_found"
+	TypeConflict         ErrorType = "conflict"
+	TypeMethodNotAllowed ErrorType = "method_not_allowed"
+	TypeUnauthorized     ErrorType = "unauthorized"
+	TypeUnavailable      ErrorType = "unavailable"
+	TypeApplication      ErrorType = "application_error"
+	TypeServer           ErrorType = "server_error"
+)
+
+// ErrorCode identifies a specific error. Unlike the message it never changes, so clients can
+// branch on it; unlike the HTTP status it tells apart errors that share a status.
+type ErrorCode string
+
+const (
+	CodeValidationFailed       ErrorCode = "VALIDATION_FAILED"
+	CodeInvalidConfig          ErrorCode = "INVALID_CONFIG"
+	CodeInvalidProject         ErrorCode = "INVALID_PROJECT"
+	CodeContainerNotFound      ErrorCode = "CONTAINER_NOT_FOUND"
+	CodeImageNotFound          ErrorCode = "IMAGE_NOT_FOUND"
+	CodeRouteNotFound          ErrorCode = "ROUTE_NOT_FOUND"
+	CodeMethodNotAllowed       ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeUnauthorized           ErrorCode = "UNAUTHORIZED"
+	CodeContainerAlreadyExists ErrorCode = "CONTAINER_ALREADY_EXISTS"
+	CodeContainerNotRunning    ErrorCode = "CONTAINER_NOT_RUNNING"
+	CodeContainerExited        ErrorCode = "CONTAINER_EXITED"
+	CodeDockerUnavailable      ErrorCode = "DOCKER_UNAVAILABLE"
+	CodeApplicationError       ErrorCode = "APPLICATION_ERROR"
+	CodeInternal               ErrorCode = "INTERNAL_ERROR"
+)
+
 // AppError represents a custom application error
 type AppError struct {
 	Code       int         `json:"code"`
@@ -12,7 +47,8 @@ type AppError struct {
 	Details    interface{} `json:"details,omitempty"`
 	Internal   error       `json:"-"`
 	RequestID  string      `json:"request_id,omitempty"`
-	ErrorType  string      `json:"error_type"`
+	ErrorType  ErrorType   `json:"error_type"`
+	ErrorCode  ErrorCode   `json:"error_code"`
 }
 
 func (e *AppError) Error() string {
@@ -22,13 +58,19 @@ func (e *AppError) Error() string {
 	return e.Message
 }
 
+// Unwrap returns the internal error, so errors.Is and errors.As see through AppError
+func (e *AppError) Unwrap() error {
+	return e.Internal
+}
+
 // NewAppError creates a new AppError
 func NewAppError(code int, message string, err error) *AppError {
 	return &AppError{
 		Code:      code,
 		Message:   message,
 		Internal:  err,
-		ErrorType: "application_error",
+		ErrorType: TypeApplication,
+		ErrorCode: CodeApplicationError,
 	}
 }
 
@@ -44,7 +86,21 @@ func NewValidationError(field, message string) *AppError {
 		Code:    http.StatusBadRequest,
 		Message: "Validation failed",
 		Details: []ValidationError{{Field: field, Message: message}},
-		ErrorType: "validation_error",
+		ErrorType: TypeValidation,
+		ErrorCode: CodeValidationFailed,
+	}
+}
+
+// NewInvalidProjectError reports a project directory that cannot be built, such as one
+// without a valid package.json
+func NewInvalidProjectError(err error) *AppError {
+	return &AppError{
+		Code:      http.StatusBadRequest,
+		Message:   "Invalid project",
+		Details:   err.Error(),
+		Internal:  err,
+		ErrorType: TypeValidation,
+		ErrorCode: CodeInvalidProject,
 	}
 }
 
@@ -59,7 +115,7 @@ func IsNotFound(err error) bool {
 // IsValidationError checks if error is a validation error
 func IsValidationError(err error) bool {
 	if appErr, ok := err.(*AppError); ok {
-		return appErr.ErrorType == "validation_error"
+		return appErr.ErrorType == TypeValidation
 	}
 	return false
 }
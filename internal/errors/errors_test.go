@@ -0,0 +1,34 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestConstructorCodes(t *testing.T) {
+	if err := NewValidationError("name", "is required"); err.ErrorCode != CodeValidationFailed || !IsValidationError(err) {
+		t.Errorf("NewValidationError() = %+v", err)
+	}
+	if err := NewInvalidProjectError(stderrors.New("package.json not found")); err.ErrorCode != CodeInvalidProject || err.Code != http.StatusBadRequest {
+		t.Errorf("NewInvalidProjectError() = %+v", err)
+	}
+	if err := NewAppError(http.StatusTeapot, "short and stout", nil); err.ErrorCode != CodeApplicationError {
+		t.Errorf("NewAppError() = %+v", err)
+	}
+}
+
+func TestAppErrorUnwrap(t *testing.T) {
+	cause := stderrors.New("no such container")
+	appErr := &AppError{Code: http.StatusNotFound, Message: "Container not found", Internal: cause, ErrorCode: CodeContainerNotFound}
+
+	var err error = fmt.Errorf("handling request: %w", appErr)
+	if !stderrors.Is(err, cause) {
+		t.Errorf("errors.Is(%v, cause) = false", err)
+	}
+	var gotApp *AppError
+	if !stderrors.As(err, &gotApp) || gotApp.ErrorCode != CodeContainerNotFound {
+		t.Errorf("errors.As(*AppError) = %+v", gotApp)
+	}
+}
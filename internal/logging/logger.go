@@ -2,10 +2,14 @@ package logging
 
 import (
 	"context"
+	"os"
 	"time"
 
+	"docker-management-system/internal/config"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type contextKey string
@@ -13,21 +17,48 @@ type contextKey string
 const (
 	requestIDKey contextKey = "request_id"
 	loggerKey    contextKey = "logger"
+	actorKey     contextKey = "actor"
 )
 
-var globalLogger *zap.Logger
+// AnonymousActor is recorded in audit logs when no authenticated actor is known
+const AnonymousActor = "anonymous"
 
-// InitLogger initializes the global logger
-func InitLogger() {
-	config := zap.NewProductionConfig()
-	config.EncoderConfig.TimeKey = "timestamp"
-	config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+// globalLogger defaults to a no-op logger so packages can log before InitLogger runs
+var globalLogger = zap.NewNop()
 
-	logger, err := config.Build(zap.AddCallerSkip(1))
-	if err != nil {
-		panic(err)
+// auditLogger receives audit records; when nil they go to the request's logger
+var auditLogger *zap.Logger
+
+// InitLogger initializes the global logger. Logs go to stderr unless a file is
+// configured, in which case they are written to a size-rotated file and, in
+// development, mirrored to stdout.
+func InitLogger(cfg config.LoggingConfig) error {
+	zapConfig := zap.NewProductionConfig()
+	zapConfig.EncoderConfig.TimeKey = "timestamp"
+	zapConfig.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+
+	if cfg.File == "" {
+		logger, err := zapConfig.Build(zap.AddCallerSkip(1))
+		if err != nil {
+			return err
+		}
+		globalLogger = logger
+		return nil
 	}
-	globalLogger = logger
+
+	rotator := &lumberjack.Logger{
+		Filename:   cfg.File,
+		MaxSize:    cfg.MaxSizeMB,
+		MaxBackups: cfg.MaxBackups,
+	}
+	encoder := zapcore.NewJSONEncoder(zapConfig.EncoderConfig)
+	core := zapcore.NewCore(encoder, zapcore.AddSync(rotator), zapConfig.Level)
+	if cfg.Development {
+		core = zapcore.NewTee(core, zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), zapConfig.Level))
+	}
+
+	globalLogger = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
+	return nil
 }
 
 // GetLogger returns a logger from context or global logger
@@ -41,12 +72,61 @@ func GetLogger(ctx context.Context) *zap.Logger {
 	return globalLogger
 }
 
+// WithLogger stores a logger in the context for GetLogger to return
+func WithLogger(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
 // WithRequestID adds request ID to logger
 func WithRequestID(ctx context.Context, requestID string) context.Context {
 	logger := GetLogger(ctx).With(zap.String("request_id", requestID))
+	ctx = context.WithValue(ctx, requestIDKey, requestID)
 	return context.WithValue(ctx, loggerKey, logger)
 }
 
+// RequestIDFromContext returns the request ID stored by WithRequestID, if any
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// WithActor records the authenticated caller for audit logging
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the caller stored by WithActor, or AnonymousActor
+func ActorFromContext(ctx context.Context) string {
+	if ctx != nil {
+		if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+			return actor
+		}
+	}
+	return AnonymousActor
+}
+
+// DetachedContext returns a context for work that outlives the request, such as a background
+// build. It is not cancelled when the request ends, but keeps the request ID and actor so the
+// work's logs and audit records can still be correlated with the request that started it.
+func DetachedContext(ctx context.Context) context.Context {
+	detached := context.Background()
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		detached = WithRequestID(detached, requestID)
+	}
+	if actor := ActorFromContext(ctx); actor != AnonymousActor {
+		detached = WithActor(detached, actor)
+	}
+	return detached
+}
+
+// SetAuditLogger routes audit records to a dedicated logger, e.g. one writing to a separate file
+func SetAuditLogger(logger *zap.Logger) {
+	auditLogger = logger
+}
+
 // LogRequest logs HTTP request details
 func LogRequest(ctx context.Context, method, path string, duration time.Duration, statusCode int) {
 	GetLogger(ctx).Info("http_request",
@@ -66,6 +146,29 @@ func LogError(ctx context.Context, msg string, err error, fields ...zapcore.Fiel
 	logger.Error(msg, fields...)
 }
 
+// LogAudit records a state-changing operation for the who-did-what audit trail
+func LogAudit(ctx context.Context, action, resourceID, actor string, success bool) {
+	logger := auditLogger
+	if logger == nil {
+		logger = GetLogger(ctx)
+	}
+
+	fields := []zapcore.Field{
+		zap.String("log_type", "audit"),
+		zap.String("action", action),
+		zap.String("resource_id", resourceID),
+		zap.String("actor", actor),
+		zap.Bool("success", success),
+	}
+	// The request's logger already carries the request ID; a dedicated sink needs it explicitly
+	if auditLogger != nil {
+		if requestID := RequestIDFromContext(ctx); requestID != "" {
+			fields = append(fields, zap.String("request_id", requestID))
+		}
+	}
+	logger.Info("audit", fields...)
+}
+
 // LogOperation logs operation metrics
 func LogOperation(ctx context.Context, operation string, duration time.Duration, success bool) {
 	GetLogger(ctx).Info("operation_metrics",
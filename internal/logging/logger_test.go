@@ -0,0 +1,99 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"docker-management-system/internal/config"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestInitLoggerFileRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	logFile := filepath.Join(tmpDir, "server.log")
+
+	previous := globalLogger
+	defer func() { globalLogger = previous }()
+
+	if err := InitLogger(config.LoggingConfig{File: logFile, MaxSizeMB: 1, MaxBackups: 2}); err != nil {
+		t.Fatalf("InitLogger failed: %v", err)
+	}
+
+	// Write a little over 1MB so the file rolls over at least once
+	payload := strings.Repeat("x", 1024)
+	for i := 0; i < 1200; i++ {
+		GetLogger(nil).Info("filler", zap.Int("seq", i), zap.String("payload", payload))
+	}
+	GetLogger(nil).Sync()
+
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatalf("Failed to read log directory: %v", err)
+	}
+	if len(entries) < 2 {
+		t.Fatalf("Expected a rotated backup alongside the log file, found %d file(s)", len(entries))
+	}
+
+	f, err := os.Open(logFile)
+	if err != nil {
+		t.Fatalf("Failed to open log file: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lines := 0
+	for scanner.Scan() {
+		var record map[string]interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %v", lines+1, err)
+		}
+		if record["msg"] != "filler" {
+			t.Errorf("Unexpected message %v", record["msg"])
+		}
+		lines++
+	}
+	if lines == 0 {
+		t.Error("Expected log records in the current log file")
+	}
+}
+
+func TestDetachedContext(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	previous := globalLogger
+	globalLogger = zap.New(core)
+	defer func() { globalLogger = previous }()
+
+	reqCtx, cancel := context.WithCancel(WithActor(WithRequestID(context.Background(), "req-42"), "ops-team"))
+	ctx := DetachedContext(reqCtx)
+
+	// The request ending must not stop the background work
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := ctx.Err(); err != nil {
+			t.Errorf("detached context cancelled with the request: %v", err)
+		}
+		GetLogger(ctx).Info("build finished")
+	}()
+	<-done
+
+	records := logs.FilterMessage("build finished").All()
+	if len(records) != 1 {
+		t.Fatalf("Expected 1 log record, got %d", len(records))
+	}
+	if got := records[0].ContextMap()["request_id"]; got != "req-42" {
+		t.Errorf("request_id = %v, want req-42", got)
+	}
+	if got := ActorFromContext(ctx); got != "ops-team" {
+		t.Errorf("actor = %q, want ops-team", got)
+	}
+}
@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// DefaultCompressMinSize is the smallest response body worth compressing
+const DefaultCompressMinSize = 1024
+
+// incompressibleTypes are content type prefixes that are already compressed or streamed
+// to the client event by event
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"application/gzip",
+	"application/zip",
+	"application/x-gzip",
+	"application/x-tar",
+	"text/event-stream",
+}
+
+// Compress gzips responses for clients that accept it. Bodies smaller than minSize and
+// content that is already encoded or compressed are passed through unchanged.
+func Compress(minSize int) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Upgraded connections such as websockets need the raw, hijackable writer
+			if r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" || !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize, statusCode: http.StatusOK}
+			defer gw.finish()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		encoding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(encoding), "gzip") {
+			return strings.ReplaceAll(params, " ", "") != "q=0"
+		}
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether compression
+// pays off, then either switches to gzip or writes the buffered bytes unchanged
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize     int
+	statusCode  int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	gz          *gzip.Writer
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = code
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		if !w.compressible() {
+			if err := w.passThrough(); err != nil {
+				return 0, err
+			}
+		} else {
+			w.buf = append(w.buf, p...)
+			if len(w.buf) < w.minSize {
+				return len(p), nil
+			}
+			if err := w.startGzip(); err != nil {
+				return 0, err
+			}
+			return len(p), nil
+		}
+	}
+
+	if w.gz != nil {
+		return w.gz.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush commits to compression so streamed responses reach the client as they are written
+func (w *gzipResponseWriter) Flush() {
+	if !w.decided {
+		var err error
+		if w.compressible() {
+			err = w.startGzip()
+		} else {
+			err = w.passThrough()
+		}
+		if err != nil {
+			return
+		}
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection, so streaming handlers can
+// lift the server's deadlines through this writer
+func (w *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible checks the headers the handler has set so far
+func (w *gzipResponseWriter) compressible() bool {
+	switch w.statusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	contentType := header.Get("Content-Type")
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *gzipResponseWriter) startGzip() error {
+	w.decided = true
+	header := w.Header()
+	// Sniff from the plain bytes; net/http would otherwise sniff the gzip stream
+	if header.Get("Content-Type") == "" {
+		header.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	header.Del("Content-Length")
+	header.Set("Content-Encoding", "gzip")
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	w.gz = gzip.NewWriter(w.ResponseWriter)
+	buf := w.buf
+	w.buf = nil
+	_, err := w.gz.Write(buf)
+	return err
+}
+
+func (w *gzipResponseWriter) passThrough() error {
+	w.decided = true
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// finish writes a response that stayed below the threshold, or closes the gzip stream
+func (w *gzipResponseWriter) finish() {
+	if !w.decided {
+		w.passThrough()
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
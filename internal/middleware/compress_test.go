@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// largeJSONHandler returns a container list big enough to be compressed
+func largeJSONHandler(w http.ResponseWriter, r *http.Request) {
+	containers := make([]map[string]string, 200)
+	for i := range containers {
+		containers[i] = map[string]string{"id": fmt.Sprintf("container-%d", i), "status": "running"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(containers)
+}
+
+func TestCompressLargeJSON(t *testing.T) {
+	handler := Compress(DefaultCompressMinSize)(http.HandlerFunc(largeJSONHandler))
+
+	t.Run("gzip requested", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/containers", nil)
+		req.Header.Set("Accept-Encoding", "gzip, deflate")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+			t.Fatalf("Content-Encoding = %q, want gzip", got)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", got)
+		}
+
+		gz, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("Response is not gzip encoded: %v", err)
+		}
+		var containers []map[string]string
+		if err := json.NewDecoder(gz).Decode(&containers); err != nil {
+			t.Fatalf("Failed to decode decompressed body: %v", err)
+		}
+		if len(containers) != 200 {
+			t.Errorf("Expected 200 containers, got %d", len(containers))
+		}
+	})
+
+	t.Run("gzip not requested", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/containers", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != "" {
+			t.Fatalf("Content-Encoding = %q, want none", got)
+		}
+		var containers []map[string]string
+		if err := json.NewDecoder(rec.Body).Decode(&containers); err != nil {
+			t.Fatalf("Failed to decode plain body: %v", err)
+		}
+	})
+}
+
+func TestCompressSkipsSmallAndEncodedResponses(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{
+			name: "small payload",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				io.WriteString(w, `{"containerId":"abc123"}`)
+			},
+		},
+		{
+			name: "already compressed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/gzip")
+				w.WriteHeader(http.StatusCreated)
+				w.Write(make([]byte, 4*DefaultCompressMinSize))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			Compress(DefaultCompressMinSize)(tt.handler).ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, want none", got)
+			}
+			if rec.Code != http.StatusCreated {
+				t.Errorf("Expected status %d, got %d", http.StatusCreated, rec.Code)
+			}
+		})
+	}
+}
+
+func TestCompressPassesUpgradeRequestsThrough(t *testing.T) {
+	var gotWriter http.ResponseWriter
+	handler := Compress(DefaultCompressMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotWriter = w
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/containers/abc123/attach", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	// Websocket handlers hijack the connection, so they must get the original writer
+	if gotWriter != http.ResponseWriter(rec) {
+		t.Errorf("Expected the original response writer, got %T", gotWriter)
+	}
+}
+
+func TestCompressAllowsClearingDeadlines(t *testing.T) {
+	errs := make(chan error, 1)
+	handler := Compress(DefaultCompressMinSize)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		errs <- http.NewResponseController(w).SetWriteDeadline(time.Time{})
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	// Streaming handlers rely on this to outlive the server's WriteTimeout
+	if err := <-errs; err != nil {
+		t.Errorf("SetWriteDeadline through the gzip writer: %v", err)
+	}
+}
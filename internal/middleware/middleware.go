@@ -1,8 +1,11 @@
 package middleware
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"docker-management-system/internal/errors"
@@ -25,6 +28,52 @@ func RequestID(next http.Handler) http.Handler {
 	})
 }
 
+// Authenticate attributes requests to the actor whose bearer token they present, as configured
+// in tokens (actor name to token). Requests without an Authorization header stay anonymous;
+// a token that matches no actor is rejected so a typo never silently drops the attribution.
+func Authenticate(tokens map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			scheme, token, _ := strings.Cut(header, " ")
+			token = strings.TrimSpace(token)
+			actor := ""
+			if strings.EqualFold(scheme, "Bearer") && token != "" {
+				actor = actorForToken(tokens, token)
+			}
+			if actor == "" {
+				respondWithError(w, &errors.AppError{
+					Code:      http.StatusUnauthorized,
+					Message:   "Invalid credentials",
+					Details:   "the Authorization header must carry a configured bearer token",
+					RequestID: logging.RequestIDFromContext(r.Context()),
+					ErrorType: errors.TypeUnauthorized,
+					ErrorCode: errors.CodeUnauthorized,
+				})
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(logging.WithActor(r.Context(), actor)))
+		})
+	}
+}
+
+// actorForToken returns the actor owning token, or "" if none does. Every token is compared in
+// constant time so the response time does not reveal how much of a guess was right.
+func actorForToken(tokens map[string]string, token string) string {
+	match := ""
+	for actor, candidate := range tokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			match = actor
+		}
+	}
+	return match
+}
+
 // Logger logs request/response details
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -46,7 +95,8 @@ func ErrorHandler(next http.Handler) http.Handler {
 				appErr := &errors.AppError{
 					Code:      http.StatusInternalServerError,
 					Message:   "Internal server error",
-					ErrorType: "server_error",
+					ErrorType: errors.TypeServer,
+					ErrorCode: errors.CodeInternal,
 				}
 				logging.LogError(r.Context(), "panic recovered", nil, zap.Any("panic", err))
 				respondWithError(w, appErr)
@@ -71,3 +121,33 @@ func respondWithError(w http.ResponseWriter, err *errors.AppError) {
 	w.WriteHeader(err.Code)
 	json.NewEncoder(w).Encode(err)
 }
+
+// NotFoundHandler answers requests for unknown paths with a JSON error. mux does not run router
+// middleware for unmatched requests, so the request ID is assigned here.
+func NotFoundHandler() http.Handler {
+	return RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, &errors.AppError{
+			Code:      http.StatusNotFound,
+			Message:   "Resource not found",
+			Details:   fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path),
+			RequestID: logging.RequestIDFromContext(r.Context()),
+			ErrorType: errors.TypeNotFound,
+			ErrorCode: errors.CodeRouteNotFound,
+		})
+	}))
+}
+
+// MethodNotAllowedHandler answers requests for a known path with an unsupported method with a
+// JSON error, assigning the request ID like NotFoundHandler
+func MethodNotAllowedHandler() http.Handler {
+	return RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, &errors.AppError{
+			Code:      http.StatusMethodNotAllowed,
+			Message:   "Method not allowed",
+			Details:   fmt.Sprintf("%s is not supported on %s", r.Method, r.URL.Path),
+			RequestID: logging.RequestIDFromContext(r.Context()),
+			ErrorType: errors.TypeMethodNotAllowed,
+			ErrorCode: errors.CodeMethodNotAllowed,
+		})
+	}))
+}
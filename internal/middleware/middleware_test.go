@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"docker-management-system/internal/errors"
+	"docker-management-system/internal/logging"
+
+	"github.com/gorilla/mux"
+)
+
+func TestUnmatchedRoutesReturnJSON(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET", "OPTIONS")
+	router.NotFoundHandler = NotFoundHandler()
+	router.MethodNotAllowedHandler = MethodNotAllowedHandler()
+
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		code      int
+		errorType errors.ErrorType
+		errorCode errors.ErrorCode
+	}{
+		{"unsupported method", http.MethodDelete, "/health", http.StatusMethodNotAllowed, errors.TypeMethodNotAllowed, errors.CodeMethodNotAllowed},
+		{"unknown path", http.MethodGet, "/api/v1/nope", http.StatusNotFound, errors.TypeNotFound, errors.CodeRouteNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Request-ID", "req-7")
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("Expected status %d, got %d", tt.code, rec.Code)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", ct)
+			}
+			var appErr errors.AppError
+			if err := json.Unmarshal(rec.Body.Bytes(), &appErr); err != nil {
+				t.Fatalf("Response is not an AppError: %v\n%s", err, rec.Body.String())
+			}
+			if appErr.Code != tt.code || appErr.ErrorType != tt.errorType || appErr.ErrorCode != tt.errorCode || appErr.RequestID != "req-7" {
+				t.Errorf("AppError = %+v, want code %d, type %s, error code %s and request ID req-7", appErr, tt.code, tt.errorType, tt.errorCode)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	var actor string
+	handler := Authenticate(map[string]string{"ci": "s3cret", "alice": "other-token"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor = logging.ActorFromContext(r.Context())
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		code          int
+		actor         string
+	}{
+		{"no credentials", "", http.StatusOK, logging.AnonymousActor},
+		{"known token", "Bearer s3cret", http.StatusOK, "ci"},
+		{"scheme is case-insensitive", "bearer other-token", http.StatusOK, "alice"},
+		{"unknown token", "Bearer guess", http.StatusUnauthorized, ""},
+		{"token prefix", "Bearer s3c", http.StatusUnauthorized, ""},
+		{"other scheme", "Basic czNjcmV0", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor = ""
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/containers", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Fatalf("Expected status %d, got %d", tt.code, rec.Code)
+			}
+			if actor != tt.actor {
+				t.Errorf("actor = %q, want %q", actor, tt.actor)
+			}
+			if tt.code == http.StatusUnauthorized {
+				var appErr errors.AppError
+				if err := json.Unmarshal(rec.Body.Bytes(), &appErr); err != nil || appErr.ErrorCode != errors.CodeUnauthorized {
+					t.Errorf("Expected an UNAUTHORIZED AppError, got %s (%v)", rec.Body.String(), err)
+				}
+			}
+		})
+	}
+}
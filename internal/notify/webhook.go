@@ -0,0 +1,189 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"docker-management-system/internal/docker"
+	"docker-management-system/internal/logging"
+
+	"github.com/docker/docker/api/types/events"
+	"go.uber.org/zap"
+)
+
+// Actions are the container events the webhook reports
+var Actions = []events.Action{events.ActionDie, events.ActionDestroy}
+
+// defaultTimeout bounds a delivery attempt when the webhook is created without a timeout
+const defaultTimeout = 5 * time.Second
+
+// maxPendingEvents bounds how many notifications can wait for delivery before new ones are dropped
+const maxPendingEvents = 100
+
+// retryBackoff is the delay after the first failed delivery, doubled after each further
+// failure. It is a variable so tests can shorten it.
+var retryBackoff = 500 * time.Millisecond
+
+// Event is the JSON payload posted to the webhook. ExitCode is only set for die events.
+type Event struct {
+	Event       string    `json:"event"`
+	ContainerID string    `json:"containerId"`
+	Name        string    `json:"name"`
+	ExitCode    *int      `json:"exitCode,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// Webhook posts lifecycle events of managed containers to a URL. Deliveries run on their own
+// goroutine, so a slow or failing endpoint never holds up the event stream that feeds it.
+type Webhook struct {
+	url        string
+	timeout    time.Duration
+	maxRetries int
+	client     *http.Client
+
+	pending chan Event
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewWebhook starts a webhook that posts to url. Each attempt is bounded by timeout, and a
+// failed delivery is retried up to maxRetries times with exponential backoff.
+func NewWebhook(url string, timeout time.Duration, maxRetries int) *Webhook {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Webhook{
+		url:        url,
+		timeout:    timeout,
+		maxRetries: maxRetries,
+		client:     &http.Client{},
+		pending:    make(chan Event, maxPendingEvents),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+	w.wg.Add(1)
+	go w.worker()
+	return w
+}
+
+// HandleEvent queues a notification for a die or destroy event of a managed container and
+// ignores every other event. It never blocks; when the queue is full the event is dropped.
+func (w *Webhook) HandleEvent(msg events.Message) {
+	event, ok := EventFromMessage(msg)
+	if !ok {
+		return
+	}
+	select {
+	case w.pending <- event:
+	default:
+		logging.GetLogger(w.ctx).Warn("webhook queue is full, dropping notification",
+			zap.String("container_id", event.ContainerID),
+			zap.String("event", event.Event),
+		)
+	}
+}
+
+// Close stops the webhook, abandoning notifications that have not been delivered yet
+func (w *Webhook) Close() {
+	w.cancel()
+	w.wg.Wait()
+}
+
+// EventFromMessage converts a Docker event into a notification. It reports false for events
+// of containers this service does not manage and for actions the webhook does not report.
+func EventFromMessage(msg events.Message) (Event, bool) {
+	if msg.Type != events.ContainerEventType || (msg.Action != events.ActionDie && msg.Action != events.ActionDestroy) {
+		return Event{}, false
+	}
+	// Docker includes the container's labels among the actor attributes
+	if msg.Actor.Attributes[docker.ManagedByLabel] != docker.ManagedByValue {
+		return Event{}, false
+	}
+
+	event := Event{
+		Event:       string(msg.Action),
+		ContainerID: msg.Actor.ID,
+		Name:        strings.TrimPrefix(msg.Actor.Attributes["name"], "/"),
+		Timestamp:   time.Unix(0, msg.TimeNano).UTC(),
+	}
+	if msg.Action == events.ActionDie {
+		if code, err := strconv.Atoi(msg.Actor.Attributes["exitCode"]); err == nil {
+			event.ExitCode = &code
+		}
+	}
+	return event, true
+}
+
+func (w *Webhook) worker() {
+	defer w.wg.Done()
+	for {
+		select {
+		case event := <-w.pending:
+			w.deliver(event)
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// deliver posts event, retrying failed attempts with exponential backoff
+func (w *Webhook) deliver(event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		logging.LogError(w.ctx, "failed to encode webhook notification", err)
+		return
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		err = w.post(body)
+		if err == nil {
+			return
+		}
+		if attempt >= w.maxRetries {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-w.ctx.Done():
+			return
+		}
+		backoff *= 2
+	}
+	logging.LogError(w.ctx, "webhook delivery failed", err,
+		zap.String("container_id", event.ContainerID),
+		zap.String("event", event.Event),
+		zap.Int("attempts", w.maxRetries+1),
+	)
+}
+
+// post makes a single delivery attempt bounded by the webhook's timeout
+func (w *Webhook) post(body []byte) error {
+	ctx, cancel := context.WithTimeout(w.ctx, w.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
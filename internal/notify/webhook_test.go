@@ -0,0 +1,129 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"docker-management-system/internal/docker"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+// dieEvent is the event Docker sends when a container's process exits
+func dieEvent(id string, labels map[string]string) events.Message {
+	attributes := map[string]string{"name": "my-app", "exitCode": "137"}
+	for k, v := range labels {
+		attributes[k] = v
+	}
+	return events.Message{
+		Type:     events.ContainerEventType,
+		Action:   events.ActionDie,
+		Actor:    events.Actor{ID: id, Attributes: attributes},
+		TimeNano: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC).UnixNano(),
+	}
+}
+
+func TestWebhookDeliversDieEvent(t *testing.T) {
+	received := make(chan Event, 2)
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q, want application/json", r.Header.Get("Content-Type"))
+		}
+		// Fail the first attempt so the delivery has to be retried
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		received <- event
+	}))
+	defer server.Close()
+
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	webhook := NewWebhook(server.URL, time.Second, 2)
+	defer webhook.Close()
+
+	// Containers this service does not manage are not reported
+	webhook.HandleEvent(dieEvent("other", nil))
+	webhook.HandleEvent(dieEvent("abc123", map[string]string{docker.ManagedByLabel: docker.ManagedByValue}))
+
+	select {
+	case event := <-received:
+		if event.Event != "die" || event.ContainerID != "abc123" || event.Name != "my-app" {
+			t.Errorf("Payload = %+v, want die of abc123 named my-app", event)
+		}
+		if event.ExitCode == nil || *event.ExitCode != 137 {
+			t.Errorf("Exit code = %v, want 137", event.ExitCode)
+		}
+		if want := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC); !event.Timestamp.Equal(want) {
+			t.Errorf("Timestamp = %v, want %v", event.Timestamp, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook never received the die event")
+	}
+	if got := attempts.Load(); got != 2 {
+		t.Errorf("Expected one failed attempt and one retry, got %d attempts", got)
+	}
+
+	select {
+	case event := <-received:
+		t.Errorf("Unexpected notification %+v", event)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestWebhookTimeoutDoesNotBlockEvents(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	webhook := NewWebhook(server.URL, 10*time.Millisecond, 0)
+	defer webhook.Close()
+
+	// Queuing returns at once even though every delivery hangs until it times out
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < maxPendingEvents+10; i++ {
+			webhook.HandleEvent(dieEvent("abc123", map[string]string{docker.ManagedByLabel: docker.ManagedByValue}))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("HandleEvent blocked on a slow webhook")
+	}
+}
+
+func TestEventFromMessage(t *testing.T) {
+	managed := map[string]string{docker.ManagedByLabel: docker.ManagedByValue}
+
+	destroy := dieEvent("abc123", managed)
+	destroy.Action = events.ActionDestroy
+	event, ok := EventFromMessage(destroy)
+	if !ok || event.Event != "destroy" || event.ExitCode != nil {
+		t.Errorf("EventFromMessage(destroy) = %+v, %v; want a destroy event without exit code", event, ok)
+	}
+
+	start := dieEvent("abc123", managed)
+	start.Action = events.ActionStart
+	if _, ok := EventFromMessage(start); ok {
+		t.Error("EventFromMessage reported a start event")
+	}
+}